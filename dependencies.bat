@@ -22,7 +22,9 @@ go get -u github.com/dkumor/acmewrapper
 go get -u github.com/gernest/hot
 go get -u github.com/russross/blackfriday
 go get -u github.com/microcosm-cc/bluemonday
+go get -u github.com/graphql-go/graphql
 go get -u github.com/stretchr/testify
+go get -u github.com/apache/arrow/go/arrow/ipc github.com/xitongsys/parquet-go/reader github.com/xitongsys/parquet-go-source/buffer
 go get -u github.com/connectordb/pipescript
 
 :: We must run go install on go-sqlite3
@@ -0,0 +1,166 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+
+// Package client is a Go client for the ConnectorDB REST API
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// APIPrefix is the path of the REST API on the server
+const APIPrefix = "/api/v1"
+
+// Error is an error returned by the server
+type Error struct {
+	Code      int    `json:"code"`
+	Message   string `json:"msg"`
+	Reference string `json:"ref,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d: %s (%s)", e.Code, e.Message, e.Reference)
+}
+
+// Client accesses a ConnectorDB server through its REST API. If Username is empty,
+// the Password is the apikey of the device to log in as.
+type Client struct {
+	Server   string
+	Username string
+	Password string
+
+	HTTP *http.Client
+}
+
+// New returns a client which logs in to the given server (such as "http://localhost:3124") as the device with the given apikey
+func New(server, apikey string) *Client {
+	return &Client{
+		Server:   strings.TrimSuffix(server, "/"),
+		Password: apikey,
+		HTTP:     http.DefaultClient,
+	}
+}
+
+// NewUser returns a client which logs in to the given server with a username and password
+func NewUser(server, username, password string) *Client {
+	c := New(server, password)
+	c.Username = username
+	return c
+}
+
+// URL returns the full URL of the given API path
+func (c *Client) URL(path string, query url.Values) string {
+	u := c.Server + APIPrefix + "/" + strings.TrimPrefix(path, "/")
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+// request runs a request against the API, and returns the response body if the request was successful
+func (c *Client) request(method, path string, query url.Values, body interface{}) (io.ReadCloser, error) {
+	return c.requestWithHeader(method, path, query, nil, body)
+}
+
+// requestWithHeader is request with additional headers set on the request
+func (c *Client) requestWithHeader(method, path string, query url.Values, header http.Header, body interface{}) (io.ReadCloser, error) {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, c.URL(path, query), reader)
+	if err != nil {
+		return nil, err
+	}
+	for k := range header {
+		req.Header.Set(k, header.Get(k))
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Username != "" || c.Password != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		apierr := &Error{Code: resp.StatusCode}
+		b, err := ioutil.ReadAll(resp.Body)
+		if err != nil || json.Unmarshal(b, apierr) != nil {
+			apierr.Message = string(b)
+		}
+		return nil, apierr
+	}
+	return resp.Body, nil
+}
+
+// do runs a request, and unmarshals the JSON response into result, unless it is nil
+func (c *Client) do(method, path string, query url.Values, body interface{}, result interface{}) error {
+	r, err := c.request(method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if result == nil {
+		_, err = io.Copy(ioutil.Discard, r)
+		return err
+	}
+	return json.NewDecoder(r).Decode(result)
+}
+
+// getInt runs a GET request which returns an integer
+func (c *Client) getInt(path string, query url.Values) (int64, error) {
+	r, err := c.request("GET", path, query, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(b), 10, 64)
+}
+
+// This returns the "user/device" path of the device the client is logged in as
+func (c *Client) This() (string, error) {
+	r, err := c.request("GET", "/", url.Values{"q": {"this"}}, nil)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	b, err := ioutil.ReadAll(r)
+	return string(b), err
+}
+
+// CountUsers returns the number of users in the database
+func (c *Client) CountUsers() (int64, error) {
+	return c.getInt("/", url.Values{"q": {"countusers"}})
+}
+
+// CountDevices returns the number of devices in the database
+func (c *Client) CountDevices() (int64, error) {
+	return c.getInt("/", url.Values{"q": {"countdevices"}})
+}
+
+// CountStreams returns the number of streams in the database
+func (c *Client) CountStreams() (int64, error) {
+	return c.getInt("/", url.Values{"q": {"countstreams"}})
+}
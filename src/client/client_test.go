@@ -0,0 +1,411 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package client
+
+import (
+	"config"
+	"connectordb"
+	"connectordb/datastream"
+	"connectordb/messenger"
+	"connectordb/query"
+	"connectordb/users"
+	"log"
+	"net/http/httptest"
+	"server/restapi"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	db     *connectordb.Database
+	server *httptest.Server
+)
+
+func init() {
+	tdb, err := connectordb.Open(config.TestConfiguration.Options())
+	if err != nil {
+		log.Fatal(err)
+	}
+	db = tdb
+	go db.RunWriter()
+
+	r := mux.NewRouter()
+	if _, err = restapi.Router(db, r.PathPrefix(APIPrefix).Subrouter()); err != nil {
+		log.Fatal(err)
+	}
+	server = httptest.NewServer(r)
+}
+
+func setup(t *testing.T) *Client {
+	db.Clear()
+	require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: "tst", Email: "root@localhost", Password: "mypass", Role: "admin", Public: true}}))
+	return NewUser(server.URL, "tst", "mypass")
+}
+
+func TestClientCrud(t *testing.T) {
+	c := setup(t)
+
+	this, err := c.This()
+	require.NoError(t, err)
+	require.Equal(t, "tst/user", this)
+
+	_, err = NewUser(server.URL, "tst", "wrongpass").ReadUser("tst")
+	require.Error(t, err)
+	require.Equal(t, 401, err.(*Error).Code)
+
+	u, err := c.CreateUser(&users.UserMaker{User: users.User{Name: "tst2", Email: "tst2@localhost", Password: "pass", Role: "user"}})
+	require.NoError(t, err)
+	require.Equal(t, "tst2", u.Name)
+
+	u, err = c.UpdateUser("tst2", map[string]interface{}{"nickname": "hi"})
+	require.NoError(t, err)
+	require.Equal(t, "hi", u.Nickname)
+
+	ul, err := c.ListUsers()
+	require.NoError(t, err)
+	require.Len(t, ul, 2)
+
+	d, err := c.CreateDevice("tst2/mydevice", &users.DeviceMaker{})
+	require.NoError(t, err)
+	require.Equal(t, "mydevice", d.Name)
+
+	dl, err := c.ListDevices("tst2")
+	require.NoError(t, err)
+	require.True(t, len(dl) > 1)
+
+	s, err := c.CreateStream("tst2/mydevice/mystream", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"number"}`}})
+	require.NoError(t, err)
+	require.Equal(t, "mystream", s.Name)
+
+	s, err = c.UpdateStream("tst2/mydevice/mystream", map[string]interface{}{"nickname": "str"})
+	require.NoError(t, err)
+	require.Equal(t, "str", s.Nickname)
+
+	sl, err := c.ListStreams("tst2/mydevice")
+	require.NoError(t, err)
+	require.Len(t, sl, 1)
+
+	require.NoError(t, c.DeleteStream("tst2/mydevice/mystream"))
+	_, err = c.ReadStream("tst2/mydevice/mystream")
+	require.Error(t, err)
+	require.NoError(t, c.DeleteDevice("tst2/mydevice"))
+	require.NoError(t, c.DeleteUser("tst2"))
+	_, err = c.ReadUser("tst2")
+	require.Error(t, err)
+}
+
+func TestClientData(t *testing.T) {
+	c := setup(t)
+
+	_, err := c.CreateStream("tst/user/s1", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"number"}`}})
+	require.NoError(t, err)
+	_, err = c.CreateStream("tst/user/s2", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"number"}`}})
+	require.NoError(t, err)
+
+	require.NoError(t, c.Insert("tst/user/s1", datastream.DatapointArray{
+		datastream.Datapoint{Timestamp: 1, Data: 1},
+		datastream.Datapoint{Timestamp: 2, Data: 2},
+		datastream.Datapoint{Timestamp: 3, Data: 3},
+	}, false))
+	require.NoError(t, c.Insert("tst/user/s2", datastream.DatapointArray{
+		datastream.Datapoint{Timestamp: 1.5, Data: 10},
+	}, false))
+	require.Error(t, c.Insert("tst/user/s1", datastream.DatapointArray{datastream.Datapoint{Timestamp: 4, Data: "notanumber"}}, false))
+
+	l, err := c.Length("tst/user/s1")
+	require.NoError(t, err)
+	require.EqualValues(t, 3, l)
+
+	dpa, err := c.IndexRange("tst/user/s1", 1, 0, "")
+	require.NoError(t, err)
+	require.Len(t, dpa, 2)
+	require.EqualValues(t, 2, dpa[0].Data)
+
+	dpa, err = c.IndexRange("tst/user/s1", 0, 0, "$ > 1")
+	require.NoError(t, err)
+	require.Len(t, dpa, 3)
+	require.Equal(t, false, dpa[0].Data)
+
+	dpa, err = c.TimeRange("tst/user/s1", 1, 3, 0, "")
+	require.NoError(t, err)
+	require.Len(t, dpa, 2)
+
+	dpa, err = c.Merge([]*query.StreamQuery{&query.StreamQuery{Stream: "tst/user/s1"}, &query.StreamQuery{Stream: "tst/user/s2"}})
+	require.NoError(t, err)
+	require.Len(t, dpa, 4)
+	require.EqualValues(t, 10, dpa[1].Data)
+
+	dpa, err = c.Dataset(&query.DatasetQuery{
+		StreamQuery: query.StreamQuery{Stream: "tst/user/s1"},
+		Dataset: map[string]*query.DatasetQueryElement{
+			"s2": &query.DatasetQueryElement{StreamQuery: query.StreamQuery{Stream: "tst/user/s2"}, Interpolator: "closest"},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, dpa, 3)
+}
+
+func TestClientInsertOnce(t *testing.T) {
+	c := setup(t)
+
+	_, err := c.CreateStream("tst/user/s1", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"number"}`}})
+	require.NoError(t, err)
+
+	dpa := datastream.DatapointArray{
+		datastream.Datapoint{Timestamp: 1, Data: 1},
+		datastream.Datapoint{Timestamp: 2, Data: 2},
+	}
+	require.NoError(t, c.InsertOnce("tst/user/s1", "batch1", dpa, false))
+
+	// Retrying the insert neither fails on the timestamps nor writes the data twice
+	require.NoError(t, c.InsertOnce("tst/user/s1", "batch1", dpa, false))
+	require.NoError(t, c.InsertOnce("tst/user/s1", "batch1", dpa, true))
+
+	l, err := c.Length("tst/user/s1")
+	require.NoError(t, err)
+	require.EqualValues(t, 2, l)
+
+	require.NoError(t, c.InsertOnce("tst/user/s1", "batch2", dpa, true))
+	l, err = c.Length("tst/user/s1")
+	require.NoError(t, err)
+	require.EqualValues(t, 4, l)
+}
+
+func TestClientInsertBulk(t *testing.T) {
+	c := setup(t)
+
+	_, err := c.CreateStream("tst/user/s1", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"number"}`}})
+	require.NoError(t, err)
+	_, err = c.CreateStream("tst/user/s2", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"string"}`}})
+	require.NoError(t, err)
+
+	dpa := datastream.DatapointArray{
+		datastream.Datapoint{Timestamp: 1, Data: 1},
+		datastream.Datapoint{Timestamp: 2, Data: 2},
+	}
+	require.NoError(t, c.InsertBulk(map[string]datastream.DatapointArray{
+		"tst/user/s1": dpa,
+		"tst/user/s2": datastream.DatapointArray{datastream.Datapoint{Timestamp: 1, Data: "hi"}},
+	}, false))
+
+	// The failure of one stream doesn't stop the others
+	err = c.InsertBulk(map[string]datastream.DatapointArray{
+		"tst/user/s1":           datastream.DatapointArray{datastream.Datapoint{Timestamp: 3, Data: 3}},
+		"tst/user/s2":           dpa,
+		"tst/user/doesnotexist": dpa,
+	}, false)
+	require.Error(t, err)
+	b, ok := err.(BulkInsertError)
+	require.True(t, ok)
+	require.Len(t, b, 2)
+	require.Contains(t, b, "tst/user/s2")
+	require.Contains(t, b, "tst/user/doesnotexist")
+
+	l, err := c.Length("tst/user/s1")
+	require.NoError(t, err)
+	require.EqualValues(t, 3, l)
+	l, err = c.Length("tst/user/s2")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, l)
+
+	ws, err := c.Websocket()
+	require.NoError(t, err)
+	defer ws.Close()
+
+	require.NoError(t, ws.InsertBulk(map[string]datastream.DatapointArray{"tst/user/s1": dpa}))
+	err = ws.InsertBulk(map[string]datastream.DatapointArray{"tst/user/s1": dpa, "tst/user/s2": dpa})
+	b, ok = err.(BulkInsertError)
+	require.True(t, ok)
+	require.Len(t, b, 1)
+	require.Contains(t, b, "tst/user/s2")
+
+	l, err = c.Length("tst/user/s1")
+	require.NoError(t, err)
+	require.EqualValues(t, 7, l)
+}
+
+func TestClientWebsocket(t *testing.T) {
+	c := setup(t)
+
+	_, err := c.CreateStream("tst/user/s1", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"number"}`}})
+	require.NoError(t, err)
+
+	ws, err := c.Websocket()
+	require.NoError(t, err)
+	defer ws.Close()
+
+	require.NoError(t, ws.Subscribe("tst/user/s1", ""))
+	require.Error(t, ws.Subscribe("tst/user/s1", "notatransform("))
+	require.Error(t, ws.Subscribe("tst/user/doesnotexist", ""))
+	require.Error(t, ws.Unsubscribe("tst/user/doesnotexist", ""))
+
+	require.NoError(t, c.Insert("tst/user/s1", datastream.DatapointArray{datastream.Datapoint{Timestamp: 1, Data: 1}}, false))
+
+	select {
+	case m := <-ws.C:
+		require.Equal(t, "tst/user/s1", m.Stream)
+		require.Len(t, m.Data, 1)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Subscription timed out")
+	}
+
+	require.NoError(t, ws.Insert("tst/user/s1", datastream.DatapointArray{datastream.Datapoint{Timestamp: 2, Data: 2}}))
+	select {
+	case m := <-ws.C:
+		require.EqualValues(t, 2, m.Data[0].Data)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Subscription timed out")
+	}
+
+	require.Error(t, ws.Insert("tst/user/s1", datastream.DatapointArray{datastream.Datapoint{Timestamp: 3, Data: "notanumber"}}))
+}
+
+func TestClientWebsocketReplay(t *testing.T) {
+	c := setup(t)
+
+	_, err := c.CreateStream("tst/user/s1", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"number"}`}})
+	require.NoError(t, err)
+	require.NoError(t, c.Insert("tst/user/s1", datastream.DatapointArray{
+		datastream.Datapoint{Timestamp: 1, Data: 1},
+		datastream.Datapoint{Timestamp: 2, Data: 2},
+		datastream.Datapoint{Timestamp: 3, Data: 3},
+	}, false))
+
+	ws, err := c.Websocket()
+	require.NoError(t, err)
+	defer ws.Close()
+
+	next := func() messenger.Message {
+		select {
+		case m := <-ws.C:
+			return m
+		case <-time.After(2 * time.Second):
+			t.Fatal("Subscription timed out")
+		}
+		return messenger.Message{}
+	}
+
+	// The history is sent first
+	require.NoError(t, ws.SubscribeFrom("tst/user/s1", "", 1))
+	m := next()
+	require.EqualValues(t, 1, m.Index)
+	require.Len(t, m.Data, 2)
+
+	require.NoError(t, c.Insert("tst/user/s1", datastream.DatapointArray{datastream.Datapoint{Timestamp: 4, Data: 4}}, false))
+	m = next()
+	require.EqualValues(t, 3, m.Index)
+	require.EqualValues(t, 4, m.Data[0].Data)
+
+	// Subscribing from a time, with a transform
+	require.NoError(t, ws.SubscribeFromTime("tst/user/s1", "$ > 2", 2.5))
+	m = next()
+	require.Equal(t, "$ > 2", m.Transform)
+	require.Len(t, m.Data, 2)
+	require.Equal(t, true, m.Data[0].Data)
+
+	require.Error(t, ws.SubscribeFrom("tst/user/doesnotexist", "", 0))
+}
+
+func TestClientWebsocketMeta(t *testing.T) {
+	c := setup(t)
+
+	ws, err := c.Websocket()
+	require.NoError(t, err)
+	defer ws.Close()
+
+	next := func() messenger.MetaEvent {
+		select {
+		case e := <-ws.Meta:
+			return e
+		case <-time.After(2 * time.Second):
+			t.Fatal("Subscription timed out")
+		}
+		return messenger.MetaEvent{}
+	}
+
+	require.Error(t, ws.UnsubscribeMeta())
+	require.NoError(t, ws.SubscribeMeta("tst/user"))
+
+	_, err = c.CreateStream("tst/user/s1", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"number"}`}})
+	require.NoError(t, err)
+	e := next()
+	require.Equal(t, "stream", e.Type)
+	require.Equal(t, "create", e.Action)
+	require.Equal(t, "tst/user/s1", e.Path)
+	require.Equal(t, "tst/user", e.Actor)
+
+	// Changes outside of the subscribed path are not sent
+	_, err = c.CreateDevice("tst/mydevice", &users.DeviceMaker{})
+	require.NoError(t, err)
+
+	_, err = c.UpdateStream("tst/user/s1", map[string]interface{}{"nickname": "str"})
+	require.NoError(t, err)
+	e = next()
+	require.Equal(t, "update", e.Action)
+	require.Equal(t, []string{"nickname"}, e.Fields)
+
+	require.NoError(t, c.DeleteStream("tst/user/s1"))
+	e = next()
+	require.Equal(t, "delete", e.Action)
+	require.Equal(t, "tst/user/s1", e.Path)
+
+	require.NoError(t, ws.UnsubscribeMeta())
+}
+
+func TestClientCommands(t *testing.T) {
+	c := setup(t)
+
+	_, err := c.CreateStream("tst/user/relay", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"boolean"}`, Downlink: true}})
+	require.NoError(t, err)
+
+	// The command is sent while the device is offline
+	cmd, err := c.SendCommand("tst/user/relay", true, 60)
+	require.NoError(t, err)
+	require.Equal(t, users.CommandPending, cmd.Status)
+	require.Equal(t, "tst/user", cmd.Sender)
+	_, err = c.SendCommand("tst/user/relay", "notabool", 0)
+	require.Error(t, err)
+
+	ws, err := c.Websocket()
+	require.NoError(t, err)
+	defer ws.Close()
+
+	next := func() users.Command {
+		select {
+		case m := <-ws.Commands:
+			return m
+		case <-time.After(2 * time.Second):
+			t.Fatal("Command subscription timed out")
+		}
+		return users.Command{}
+	}
+
+	// The queued command is delivered when subscribing, and live commands after it
+	require.NoError(t, ws.SubscribeCommands("tst/user/relay"))
+	m := next()
+	require.Equal(t, cmd.CommandID, m.CommandID)
+	require.Equal(t, users.CommandDelivered, m.Status)
+
+	cmd2, err := c.SendCommand("tst/user/relay", false, 0)
+	require.NoError(t, err)
+	m = next()
+	require.Equal(t, cmd2.CommandID, m.CommandID)
+	require.Equal(t, "false", string(m.Data))
+
+	cmd, err = c.UpdateCommand("tst/user/relay", cmd.CommandID, users.CommandDone, map[string]interface{}{"temperature": 20})
+	require.NoError(t, err)
+	require.Equal(t, users.CommandDone, cmd.Status)
+	require.JSONEq(t, `{"temperature":20}`, string(cmd.Result))
+
+	cmds, err := c.ListCommands("tst/user/relay", users.CommandDelivered)
+	require.NoError(t, err)
+	require.Len(t, cmds, 1)
+	require.Equal(t, cmd2.CommandID, cmds[0].CommandID)
+
+	require.NoError(t, ws.UnsubscribeCommands("tst/user/relay"))
+}
@@ -0,0 +1,48 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package client
+
+import (
+	"connectordb/users"
+	"net/url"
+	"strconv"
+)
+
+func commandPath(streampath string, commandID int64) string {
+	return crud(streampath) + "/commands/" + strconv.FormatInt(commandID, 10)
+}
+
+// SendCommand queues a command on the downlink stream. The command expires after ttl seconds, unless ttl is 0.
+func (c *Client) SendCommand(streampath string, data interface{}, ttl float64) (cmd *users.Command, err error) {
+	body := map[string]interface{}{"data": data, "ttl": ttl}
+	err = c.do("POST", crud(streampath)+"/commands", nil, body, &cmd)
+	return
+}
+
+// ListCommands returns the commands queued on the stream which have the given status, or all commands if status is empty
+func (c *Client) ListCommands(streampath, status string) (cmds []*users.Command, err error) {
+	var q url.Values
+	if status != "" {
+		q = url.Values{"status": {status}}
+	}
+	err = c.do("GET", crud(streampath)+"/commands", q, nil, &cmds)
+	return
+}
+
+// ReadCommand reads the command of the given stream
+func (c *Client) ReadCommand(streampath string, commandID int64) (cmd *users.Command, err error) {
+	err = c.do("GET", commandPath(streampath, commandID), nil, nil, &cmd)
+	return
+}
+
+// UpdateCommand acknowledges the command, setting its status to delivered, done or failed. The result is optional.
+func (c *Client) UpdateCommand(streampath string, commandID int64, status string, result interface{}) (cmd *users.Command, err error) {
+	body := map[string]interface{}{"status": status}
+	if result != nil {
+		body["result"] = result
+	}
+	err = c.do("POST", commandPath(streampath, commandID), nil, body, &cmd)
+	return
+}
@@ -0,0 +1,120 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package client
+
+import (
+	"connectordb/users"
+	"net/url"
+)
+
+func crud(path string) string {
+	return "/crud/" + path
+}
+
+var ls = url.Values{"q": {"ls"}}
+
+// ListUsers returns all users that the client can read
+func (c *Client) ListUsers() (u []*users.User, err error) {
+	err = c.do("GET", "/crud/", ls, nil, &u)
+	return
+}
+
+// ReadUser reads the given user
+func (c *Client) ReadUser(name string) (u *users.User, err error) {
+	err = c.do("GET", crud(name), nil, nil, &u)
+	return
+}
+
+// CreateUser creates the user with the given name, along with the devices and streams in the UserMaker
+func (c *Client) CreateUser(um *users.UserMaker) (u *users.User, err error) {
+	err = c.do("POST", crud(um.Name), nil, um, &u)
+	return
+}
+
+// UpdateUser sets the given fields of the user
+func (c *Client) UpdateUser(name string, updates map[string]interface{}) (u *users.User, err error) {
+	err = c.do("PUT", crud(name), nil, updates, &u)
+	return
+}
+
+// DeleteUser deletes the given user
+func (c *Client) DeleteUser(name string) error {
+	return c.do("DELETE", crud(name), nil, nil, nil)
+}
+
+// ListDevices returns the devices of the given user
+func (c *Client) ListDevices(username string) (d []*users.Device, err error) {
+	err = c.do("GET", crud(username), ls, nil, &d)
+	return
+}
+
+// ReadDevice reads the device at the given "user/device" path
+func (c *Client) ReadDevice(devpath string) (d *users.Device, err error) {
+	err = c.do("GET", crud(devpath), nil, nil, &d)
+	return
+}
+
+// CreateDevice creates the device at the given path, along with the streams in the DeviceMaker
+func (c *Client) CreateDevice(devpath string, dm *users.DeviceMaker) (d *users.Device, err error) {
+	err = c.do("POST", crud(devpath), nil, dm, &d)
+	return
+}
+
+// UpdateDevice sets the given fields of the device
+func (c *Client) UpdateDevice(devpath string, updates map[string]interface{}) (d *users.Device, err error) {
+	err = c.do("PUT", crud(devpath), nil, updates, &d)
+	return
+}
+
+// DeleteDevice deletes the given device
+func (c *Client) DeleteDevice(devpath string) error {
+	return c.do("DELETE", crud(devpath), nil, nil, nil)
+}
+
+// ListStreams returns the streams of the device at the given path
+func (c *Client) ListStreams(devpath string) (s []*users.Stream, err error) {
+	err = c.do("GET", crud(devpath), ls, nil, &s)
+	return
+}
+
+// ListUserStreams returns the streams of all of the user's devices, optionally only returning
+// public, downlink, or visible streams
+func (c *Client) ListUserStreams(username string, public, downlink, visible bool) (s []*users.Stream, err error) {
+	q := url.Values{"q": {"streams"}}
+	if public {
+		q.Set("public", "true")
+	}
+	if downlink {
+		q.Set("downlink", "true")
+	}
+	if visible {
+		q.Set("visible", "true")
+	}
+	err = c.do("GET", crud(username), q, nil, &s)
+	return
+}
+
+// ReadStream reads the stream at the given "user/device/stream" path
+func (c *Client) ReadStream(streampath string) (s *users.Stream, err error) {
+	err = c.do("GET", crud(streampath), nil, nil, &s)
+	return
+}
+
+// CreateStream creates the stream at the given path
+func (c *Client) CreateStream(streampath string, sm *users.StreamMaker) (s *users.Stream, err error) {
+	err = c.do("POST", crud(streampath), nil, sm, &s)
+	return
+}
+
+// UpdateStream sets the given fields of the stream
+func (c *Client) UpdateStream(streampath string, updates map[string]interface{}) (s *users.Stream, err error) {
+	err = c.do("PUT", crud(streampath), nil, updates, &s)
+	return
+}
+
+// DeleteStream deletes the given stream
+func (c *Client) DeleteStream(streampath string) error {
+	return c.do("DELETE", crud(streampath), nil, nil, nil)
+}
@@ -0,0 +1,144 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package client
+
+import (
+	"connectordb/datastream"
+	"connectordb/query"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// dataPath returns the API path of a stream's data, along with the query which selects its substream.
+// The stream path can include the substream, such as "user/device/stream/downlink"
+func dataPath(streampath string) (string, url.Values) {
+	q := url.Values{}
+	if strings.Count(streampath, "/") == 3 && strings.HasSuffix(streampath, "/downlink") {
+		streampath = strings.TrimSuffix(streampath, "/downlink")
+		q.Set("downlink", "true")
+	}
+	return crud(streampath) + "/data", q
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// Insert inserts the datapoints into the stream. If restamp is true, datapoints with timestamps
+// older than the most recent datapoint in the stream are given the most recent timestamp instead of failing the insert
+func (c *Client) Insert(streampath string, dpa datastream.DatapointArray, restamp bool) error {
+	path, q := dataPath(streampath)
+	method := "POST"
+	if restamp {
+		method = "PUT"
+	}
+	return c.do(method, path, q, dpa, nil)
+}
+
+// InsertOnce is Insert with an idempotency key. If an insert which timed out is retried with the same key,
+// the server only writes the data once, and acknowledges the retry.
+func (c *Client) InsertOnce(streampath, key string, dpa datastream.DatapointArray, restamp bool) error {
+	path, q := dataPath(streampath)
+	method := "POST"
+	if restamp {
+		method = "PUT"
+	}
+	r, err := c.requestWithHeader(method, path, q, http.Header{"Idempotency-Key": {key}}, dpa)
+	if err != nil {
+		return err
+	}
+	return r.Close()
+}
+
+// BulkInsertError is returned by bulk inserts where the insert into some of the streams failed.
+// It maps the failed streams to their error messages. The data of all other streams was written.
+type BulkInsertError map[string]string
+
+func (b BulkInsertError) Error() string {
+	return fmt.Sprintf("%d of the bulk inserts failed", len(b))
+}
+
+// InsertBulk inserts into multiple streams with one request. The data maps stream paths to their datapoints.
+// Each stream's insert succeeds or fails on its own: if any fail, a BulkInsertError is returned.
+func (c *Client) InsertBulk(data map[string]datastream.DatapointArray, restamp bool) error {
+	method := "POST"
+	if restamp {
+		method = "PUT"
+	}
+	var results map[string]struct {
+		Error string `json:"error"`
+	}
+	if err := c.do(method, crud(""), nil, data, &results); err != nil {
+		return err
+	}
+	failed := BulkInsertError{}
+	for streampath, r := range results {
+		if r.Error != "" {
+			failed[streampath] = r.Error
+		}
+	}
+	if len(failed) > 0 {
+		return failed
+	}
+	return nil
+}
+
+// Length returns the number of datapoints in the stream
+func (c *Client) Length(streampath string) (int64, error) {
+	path, q := dataPath(streampath)
+	q.Set("q", "length")
+	return c.getInt(path, q)
+}
+
+// TimeToIndex returns the index of the stream closest to the given timestamp
+func (c *Client) TimeToIndex(streampath string, t float64) (int64, error) {
+	path, q := dataPath(streampath)
+	q.Set("q", "time2index")
+	q.Set("t", formatFloat(t))
+	return c.getInt(path, q)
+}
+
+func (c *Client) getRange(path string, q url.Values, transform string) (dpa datastream.DatapointArray, err error) {
+	if transform != "" {
+		q.Set("transform", transform)
+	}
+	err = c.do("GET", path, q, nil, &dpa)
+	return
+}
+
+// IndexRange returns the datapoints of the stream with indices in [i1,i2). Negative indices are from the end of the stream,
+// and an i2 of 0 is the end of the stream.
+func (c *Client) IndexRange(streampath string, i1, i2 int64, transform string) (datastream.DatapointArray, error) {
+	path, q := dataPath(streampath)
+	q.Set("i1", strconv.FormatInt(i1, 10))
+	q.Set("i2", strconv.FormatInt(i2, 10))
+	return c.getRange(path, q, transform)
+}
+
+// TimeRange returns the datapoints of the stream between the two timestamps, up to limit datapoints if limit > 0
+func (c *Client) TimeRange(streampath string, t1, t2 float64, limit int64, transform string) (datastream.DatapointArray, error) {
+	path, q := dataPath(streampath)
+	q.Set("t1", formatFloat(t1))
+	q.Set("t2", formatFloat(t2))
+	if limit > 0 {
+		q.Set("limit", strconv.FormatInt(limit, 10))
+	}
+	return c.getRange(path, q, transform)
+}
+
+// Dataset generates a dataset from multiple streams
+func (c *Client) Dataset(dq *query.DatasetQuery) (dpa datastream.DatapointArray, err error) {
+	err = c.do("POST", "/query/dataset", nil, dq, &dpa)
+	return
+}
+
+// Merge merges the given streams into a single array of datapoints ordered by time
+func (c *Client) Merge(sq []*query.StreamQuery) (dpa datastream.DatapointArray, err error) {
+	err = c.do("POST", "/query/merge", nil, sq, &dpa)
+	return
+}
@@ -0,0 +1,244 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package client
+
+import (
+	"connectordb/datastream"
+	"connectordb/messenger"
+	"connectordb/users"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebsocketProtocol is the websocket protocol version used by the client
+const WebsocketProtocol = 2
+
+// ErrWebsocketClosed is returned when a command is waiting for its response when the websocket closes
+var ErrWebsocketClosed = errors.New("The websocket was closed")
+
+// websocketCommand is a command sent to the server over the websocket
+type websocketCommand struct {
+	ID        string                    `json:"id"`
+	Cmd       string                    `json:"cmd"`
+	Arg       string                    `json:"arg"`
+	Transform string                    `json:"transform,omitempty"`
+	I1        *int64                    `json:"i1,omitempty"`
+	T1        *float64                  `json:"t1,omitempty"`
+	D         datastream.DatapointArray `json:"d,omitempty"`
+	Key       string                    `json:"key,omitempty"`
+
+	Data map[string]datastream.DatapointArray `json:"data,omitempty"`
+}
+
+// websocketMessage is a message from the server: either the response to a command,
+// a notice, or data from a subscription
+type websocketMessage struct {
+	Type  string `json:"type"`
+	ID    string `json:"id"`
+	Cmd   string `json:"cmd"`
+	Arg   string `json:"arg"`
+	Error string `json:"error"`
+
+	Results map[string]string `json:"results"`
+
+	messenger.Message
+	Meta    messenger.MetaEvent `json:"meta"`
+	Command users.Command       `json:"command"`
+}
+
+// Notice is sent by the server when it drops a subscription, such as when the stream is deleted
+type Notice struct {
+	Cmd    string
+	Stream string
+	Error  string
+}
+
+// Websocket is a websocket connection to the server, which allows subscribing to streams
+// and inserting data with little overhead. Messages of subscribed streams are sent to C,
+// and notices to Notices. Both are closed when the connection closes. Since command responses
+// are read in order with the data, C must be read while commands are being sent.
+// Metadata change events are sent to Meta, and downlink commands to Commands, which are also closed with the connection.
+type Websocket struct {
+	C        chan messenger.Message
+	Notices  chan Notice
+	Meta     chan messenger.MetaEvent
+	Commands chan users.Command
+
+	sync.Mutex // Protects writes to the websocket and the pending commands
+	ws         *websocket.Conn
+	nextID     int64
+	pending    map[string]chan error
+	closed     bool
+}
+
+// Websocket opens a websocket connection to the server
+func (c *Client) Websocket() (*Websocket, error) {
+	wsurl := "ws" + strings.TrimPrefix(c.URL("/websocket", url.Values{"protocol": {strconv.Itoa(WebsocketProtocol)}}), "http")
+	header := http.Header{}
+	if c.Username != "" || c.Password != "" {
+		// Use a request to generate the basic auth header
+		req := &http.Request{Header: header}
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	ws, _, err := websocket.DefaultDialer.Dial(wsurl, header)
+	if err != nil {
+		return nil, err
+	}
+	w := &Websocket{
+		C:        make(chan messenger.Message, 100),
+		Notices:  make(chan Notice, 10),
+		Meta:     make(chan messenger.MetaEvent, 10),
+		Commands: make(chan users.Command, 10),
+		ws:       ws,
+		pending:  make(map[string]chan error),
+	}
+	go w.run()
+	return w, nil
+}
+
+func (w *Websocket) run() {
+	defer func() {
+		close(w.C)
+		close(w.Notices)
+		close(w.Meta)
+		close(w.Commands)
+		w.Lock()
+		w.closed = true
+		for id, r := range w.pending {
+			r <- ErrWebsocketClosed
+			delete(w.pending, id)
+		}
+		w.Unlock()
+	}()
+	for {
+		var m websocketMessage
+		if err := w.ws.ReadJSON(&m); err != nil {
+			return
+		}
+		switch m.Type {
+		case "data":
+			w.C <- m.Message
+		case "notice":
+			w.Notices <- Notice{m.Cmd, m.Arg, m.Error}
+		case "meta":
+			w.Meta <- m.Meta
+		case "command":
+			w.Commands <- m.Command
+		case "ack", "error":
+			w.Lock()
+			r, ok := w.pending[m.ID]
+			delete(w.pending, m.ID)
+			w.Unlock()
+			if ok {
+				if m.Type == "error" && len(m.Results) > 0 {
+					r <- BulkInsertError(m.Results)
+				} else if m.Type == "error" {
+					r <- errors.New(m.Error)
+				} else {
+					r <- nil
+				}
+			}
+		}
+	}
+}
+
+// send sends the command, and waits for the server's response
+func (w *Websocket) send(cmd *websocketCommand) error {
+	r := make(chan error, 1)
+	w.Lock()
+	if w.closed {
+		w.Unlock()
+		return ErrWebsocketClosed
+	}
+	w.nextID++
+	cmd.ID = strconv.FormatInt(w.nextID, 10)
+	w.pending[cmd.ID] = r
+	err := w.ws.WriteJSON(cmd)
+	if err != nil {
+		delete(w.pending, cmd.ID)
+	}
+	w.Unlock()
+	if err != nil {
+		return err
+	}
+	return <-r
+}
+
+// Subscribe subscribes to the given stream, with an optional transform which is run on the data before it is sent
+func (w *Websocket) Subscribe(streampath, transform string) error {
+	return w.send(&websocketCommand{Cmd: "subscribe", Arg: streampath, Transform: transform})
+}
+
+// SubscribePattern subscribes to all readable streams matching the pattern, such as "user/*/heartrate".
+// Messages of the subscription have their Pattern set to the pattern.
+func (w *Websocket) SubscribePattern(pattern, transform string) error {
+	return w.send(&websocketCommand{Cmd: "subscribe", Arg: pattern, Transform: transform})
+}
+
+// SubscribeFrom subscribes to the given stream, first receiving the stream's data starting at index i1.
+// Each message contains the stream index of its first datapoint, so a subscription without a transform
+// can be resumed from the index after the last datapoint received.
+func (w *Websocket) SubscribeFrom(streampath, transform string, i1 int64) error {
+	return w.send(&websocketCommand{Cmd: "subscribe", Arg: streampath, Transform: transform, I1: &i1})
+}
+
+// SubscribeFromTime subscribes to the given stream, first receiving the stream's data starting at time t1
+func (w *Websocket) SubscribeFromTime(streampath, transform string, t1 float64) error {
+	return w.send(&websocketCommand{Cmd: "subscribe", Arg: streampath, Transform: transform, T1: &t1})
+}
+
+// Unsubscribe stops the subscription with the given stream and transform
+func (w *Websocket) Unsubscribe(streampath, transform string) error {
+	return w.send(&websocketCommand{Cmd: "unsubscribe", Arg: streampath, Transform: transform})
+}
+
+// SubscribeMeta subscribes to the metadata change events of the given user, device or stream, and its children.
+// An empty path subscribes to changes of everything the device can read.
+func (w *Websocket) SubscribeMeta(path string) error {
+	return w.send(&websocketCommand{Cmd: "subscribe_meta", Arg: path})
+}
+
+// UnsubscribeMeta stops the metadata change events
+func (w *Websocket) UnsubscribeMeta() error {
+	return w.send(&websocketCommand{Cmd: "unsubscribe_meta"})
+}
+
+// SubscribeCommands subscribes to the commands of the given downlink stream. Commands which are not finished are
+// sent first. When the client is logged in as the device which owns the stream, the commands are marked as delivered.
+func (w *Websocket) SubscribeCommands(streampath string) error {
+	return w.send(&websocketCommand{Cmd: "subscribe_commands", Arg: streampath})
+}
+
+// UnsubscribeCommands stops the commands of the given stream
+func (w *Websocket) UnsubscribeCommands(streampath string) error {
+	return w.send(&websocketCommand{Cmd: "unsubscribe_commands", Arg: streampath})
+}
+
+// Insert inserts the datapoints into the stream, restamping them if they are older than the stream's most recent datapoint
+func (w *Websocket) Insert(streampath string, dpa datastream.DatapointArray) error {
+	return w.send(&websocketCommand{Cmd: "insert", Arg: streampath, D: dpa})
+}
+
+// InsertOnce is Insert with an idempotency key: if the insert is retried with the same key, the data is only written once
+func (w *Websocket) InsertOnce(streampath, key string, dpa datastream.DatapointArray) error {
+	return w.send(&websocketCommand{Cmd: "insert", Arg: streampath, D: dpa, Key: key})
+}
+
+// InsertBulk inserts into multiple streams, restamping datapoints which are older than their stream's most recent datapoint.
+// If the insert into some of the streams fails, a BulkInsertError is returned.
+func (w *Websocket) InsertBulk(data map[string]datastream.DatapointArray) error {
+	return w.send(&websocketCommand{Cmd: "insert_bulk", Data: data})
+}
+
+// Close closes the websocket
+func (w *Websocket) Close() error {
+	return w.ws.Close()
+}
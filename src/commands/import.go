@@ -20,7 +20,7 @@ import (
 )
 
 type importContext struct {
-	ExportInfo
+	connectordb.ExportInfo
 	db *connectordb.Database
 }
 
@@ -137,7 +137,7 @@ func importStream(c *importContext, dbpath string, deviceID int64, dir string) e
 	sm.Schema = "{}"
 
 	// Create the stream
-	if err = c.db.CreateStreamByDeviceID(&sm); err != nil {
+	if err = c.db.AdminOperator().CreateStreamByDeviceID(&sm); err != nil {
 		return err
 	}
 
@@ -161,7 +161,7 @@ func importStream(c *importContext, dbpath string, deviceID int64, dir string) e
 
 	// Now finally set the stream schema again if it isn't {}
 	if schema != "{}" {
-		if err = c.db.UpdateStreamByID(s.StreamID, map[string]interface{}{"schema": schema}); err != nil {
+		if err = c.db.AdminOperator().UpdateStreamByID(s.StreamID, map[string]interface{}{"schema": schema}); err != nil {
 			return err
 		}
 	}
@@ -201,7 +201,7 @@ func importDevice(c *importContext, dbpath string, userID int64, dir string) err
 			return err
 		}
 	} else {
-		if err = c.db.CreateDeviceByUserID(&dm); err != nil {
+		if err = c.db.AdminOperator().CreateDeviceByUserID(&dm); err != nil {
 			return err
 		}
 	}
@@ -245,7 +245,7 @@ func importUser(c *importContext, dir string) error {
 	// In the UserMaker, hash scheme and other stuff is ignored
 	um.Password = um.Name
 
-	if err = c.db.CreateUser(&um); err != nil {
+	if err = c.db.AdminOperator().CreateUser(&um); err != nil {
 		return err
 	}
 
@@ -335,6 +335,9 @@ It is given the directory where a ConnectorDB export was performed.`,
 		if info.Version <= 0 || info.Version > 2 {
 			return errors.New("Can't open the export version")
 		}
+		if info.Format != "" && info.Format != "json" {
+			return errors.New("Exports in the " + info.Format + " format can't be imported")
+		}
 
 		// Open the ConnectorDB database
 		db, err := connectordb.Open(cfg.Options())
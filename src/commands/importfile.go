@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"config"
+	"connectordb"
+	"connectordb/importer"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// importFileStreams holds the name of the stream into which each kind of data is imported
+var importFileStreams = make(map[string]*string)
+
+// importFileBackfill is whether the created streams backfill data older than their own
+var importFileBackfill bool
+
+// importFile imports a single file into the device's streams
+func importFile(db *connectordb.Database, devicepath string, filename string, streams map[string]string) error {
+	o, err := db.AsDevice(devicepath)
+	if err != nil {
+		return err
+	}
+
+	log.Info("... Importing ", filepath.Base(filename))
+	results, err := importer.ImportFile(o, devicepath, filename, streams, importFileBackfill, func(records, inserted int64) {
+		log.Infof("................ %d records read, %d datapoints inserted", records, inserted)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		if r.Error != "" {
+			return fmt.Errorf("Failed to import into %s: %s", r.Stream, r.Error)
+		}
+		log.Infof("................ %s: %d inserted, %d duplicates skipped, %d older datapoints skipped", r.Stream, r.Inserted, r.Duplicates, r.Late)
+	}
+	return nil
+}
+
+// ImportFileCmd imports activity files into a device's streams
+var ImportFileCmd = &cobra.Command{
+	Use:   "import-file [config file path or database directory] [user/device] [files...]",
+	Short: "Imports activity files and health exports into a device's streams",
+	Long: `Parses the locations, elevations, heart rates and cadences of GPX, TCX and
+FIT activity files, and inserts them into streams of the given device. Streams
+which don't exist are created. Datapoints already in the streams are skipped,
+so a file can be imported again without duplicating its data.
+
+Datapoints older than the data of a stream are skipped, unless the stream
+backfills. With --backfill, created streams backfill, so that older files can
+be imported after newer ones. Backfilling shifts the indices of the stream's
+later datapoints.
+
+Google Takeout location history (Records.json) and Apple Health exports
+(export.xml) are also supported. Since these can be several gigabytes, they are
+inserted in chunks as they are read, and are not checked for duplicates.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return ErrConfig
+		}
+		if len(args) < 3 {
+			return errors.New("Must specify the device and the files to import")
+		}
+		devicepath := args[1]
+		if strings.Count(devicepath, "/") != 1 {
+			return errors.New("The device must be given as user/device")
+		}
+
+		cfg, err := config.LoadConfig(args[0])
+		if err != nil {
+			return err
+		}
+
+		setLogging(cfg)
+
+		streams := make(map[string]string)
+		for kind, name := range importFileStreams {
+			streams[kind] = *name
+		}
+
+		// Open the ConnectorDB database
+		db, err := connectordb.Open(cfg.Options())
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		for _, filename := range args[2:] {
+			if err = importFile(db, devicepath, filename, streams); err != nil {
+				return fmt.Errorf("%s: %s", filename, err.Error())
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	for kind := range importer.Kinds {
+		importFileStreams[kind] = ImportFileCmd.Flags().String(kind, kind, fmt.Sprintf("the stream into which %s data is imported (empty to skip)", kind))
+	}
+	ImportFileCmd.Flags().BoolVar(&importFileBackfill, "backfill", false, "create streams which accept data older than their own, shifting the indices of later datapoints")
+	RootCmd.AddCommand(ImportFileCmd)
+}
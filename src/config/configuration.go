@@ -53,10 +53,18 @@ type Configuration struct {
 	Nats  Service     `json:"nats"`
 	Sql   *SQLService `json:"sql"`
 
+	// Optional listeners for StatsD and Graphite plaintext metrics
+	StatsD   Listener `json:"statsd"`
+	Graphite Listener `json:"graphite"`
+
 	// The size of batches and chunks to use with the database
 	BatchSize int `json:"batchsize"` // BatchSize is the number of datapoints per database entry
 	ChunkSize int `json:"chunksize"` // ChunkSize is number of batches per database insert transaction
 
+	// The number of seconds for which the idempotency keys of inserts are remembered. Retrying an insert
+	// with the same key within this window does not write the data twice. A window of 0 disables the keys.
+	InsertKeyWindow int64 `json:"insert_key_window"`
+
 	// The cache sizes for users/devices/streams
 	UseCache        bool  `json:"cache"`         // Whether or not to enable caching
 	CacheTimeout    int64 `json:"cache_timeout"` // Whether the cache times out in seconds
@@ -64,6 +72,20 @@ type Configuration struct {
 	DeviceCacheSize int64 `json:"device_cache_size"`
 	StreamCacheSize int64 `json:"stream_cache_size"`
 
+	// Devices are considered online if they made an authenticated request within the presence timeout (in seconds).
+	// A timeout of 0 disables presence tracking. If PresenceLog is set, the presence changes of a user's devices
+	// are also written to the user's meta/presence stream.
+	PresenceTimeout int64 `json:"presence_timeout"`
+	PresenceLog     bool  `json:"presence_log"`
+
+	// Deleted users, devices and streams are moved to the trash, from which they can be restored for the trash period
+	// (in seconds). Once it passes, they are deleted for good. A trash period of 0 deletes immediately.
+	TrashPeriod int64 `json:"trash_period"`
+
+	// Finished and expired downlink commands are deleted once they are older than the command retention period
+	// (in seconds). A retention period of 0 keeps them forever.
+	CommandRetention int64 `json:"command_retention"`
+
 	// The default algorithm to use for hashing passwords. Options are SHA512 and bcrypt
 	// This can be changed during runtime, and the user passwords will upgrade when they log in
 	PasswordHash string `json:"password_hash"`
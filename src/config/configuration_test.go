@@ -17,6 +17,23 @@ func TestValidate(t *testing.T) {
 	cfg.Permissions = "boo"
 	require.Error(t, cfg.Validate())
 	cfg.Permissions = "default"
+
+	cfg.TakeoutExpire = -1
+	require.Error(t, cfg.Validate())
+	cfg.TakeoutExpire = 0
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, int64(7*24*60*60), cfg.TakeoutExpire)
+
+	cfg.InsertKeyWindow = -1
+	require.Error(t, cfg.Validate())
+	cfg.InsertKeyWindow = 0
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, int64(0), cfg.InsertKeyWindow)
+
+	cfg.TrashPeriod = -1
+	require.Error(t, cfg.Validate())
+	cfg.TrashPeriod = 0
+	require.NoError(t, cfg.Validate())
 }
 
 func TestSave(t *testing.T) {
@@ -28,3 +45,19 @@ func TestSave(t *testing.T) {
 	require.NoError(t, err)
 	require.NoError(t, cfg2.Validate())
 }
+
+func TestValidateListener(t *testing.T) {
+	cfg := NewConfiguration()
+	cfg.StatsD.Enabled = true
+	require.Error(t, cfg.Validate())
+
+	cfg.StatsD.Device = "tst/metrics"
+	require.NoError(t, cfg.Validate())
+
+	cfg.StatsD.Protocol = "http"
+	require.Error(t, cfg.Validate())
+	cfg.StatsD.Protocol = "tcp"
+
+	cfg.StatsD.FlushInterval = 0
+	require.Error(t, cfg.Validate())
+}
@@ -36,6 +36,24 @@ func NewConfiguration() *Configuration {
 			Password: natspassword.String(),
 			Enabled:  true,
 		},
+		StatsD: Listener{
+			Service: Service{
+				Hostname: "localhost",
+				Port:     8125,
+				Enabled:  false,
+			},
+			Protocol:      "udp",
+			FlushInterval: 10,
+		},
+		Graphite: Listener{
+			Service: Service{
+				Hostname: "localhost",
+				Port:     2003,
+				Enabled:  false,
+			},
+			Protocol:      "tcp",
+			FlushInterval: 10,
+		},
 		Sql: &SQLService{
 			Type: "postgres",
 			URI:  "", // connectordb generates a postgres uri if not given
@@ -97,6 +115,10 @@ func NewConfiguration() *Configuration {
 			// A limit of 10MB of data per insert is reasonable to me
 			InsertLimitBytes: 1024 * 1024 * 10,
 
+			// Takeout archives can be downloaded for a week
+			TakeoutDirectory: "takeout",
+			TakeoutExpire:    7 * 24 * 60 * 60,
+
 			// The options that pertain to the websocket interface
 			Websocket: Websocket{
 				// 1MB per websocket is also reasonable
@@ -134,12 +156,25 @@ func NewConfiguration() *Configuration {
 		BatchSize: 250,
 		ChunkSize: 10,
 
+		// Retries of inserts are recognized for a day
+		InsertKeyWindow: 24 * 60 * 60,
+
 		UseCache:        true,
 		CacheTimeout:    30 * 1000, // Seems like a reasonable timeout to me
 		UserCacheSize:   1000,
 		DeviceCacheSize: 10000,
 		StreamCacheSize: 10000,
 
+		// A device which hasn't talked to the server in 5 minutes is offline
+		PresenceTimeout: 5 * 60,
+		PresenceLog:     false,
+
+		// Deleted things can be restored for a month
+		TrashPeriod: 30 * 24 * 60 * 60,
+
+		// Finished commands are kept for a week
+		CommandRetention: 7 * 24 * 60 * 60,
+
 		// No reason not to use bcrypt
 		PasswordHash: "bcrypt",
 
@@ -63,6 +63,11 @@ type Frontend struct {
 	// The limit in bytes per REST insert
 	InsertLimitBytes int64 `json:"insert_limit_bytes"`
 
+	// Users can request a takeout archive of all of their data. The archives are written to the
+	// takeout directory, and can be downloaded for takeout_expire seconds after they are ready.
+	TakeoutDirectory string `json:"takeout_directory"`
+	TakeoutExpire    int64  `json:"takeout_expire"`
+
 	// The read timeout used for the http connection in seconds. A value of 0 means infinite
 	HTTPReadTimeout int64 `json:"http_read_timeout"`
 
@@ -0,0 +1,45 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Listener is a metrics listener (StatsD or Graphite plaintext). The metrics it receives are aggregated over
+// each flush interval, and written into streams of the given device, which are created as needed.
+type Listener struct {
+	Service
+
+	Protocol string `json:"protocol"` // The protocol to listen on: udp or tcp
+	Device   string `json:"device"`   // The user/device which owns the metric streams
+
+	// The number of seconds over which metrics are aggregated before they are written
+	FlushInterval int64 `json:"flush_interval"`
+}
+
+// GetListenAddress returns the address on which the listener runs
+func (l *Listener) GetListenAddress() string {
+	return fmt.Sprintf("%s:%d", l.Hostname, l.Port)
+}
+
+// Validate ensures that the listener options are valid
+func (l *Listener) Validate() error {
+	if !l.Enabled {
+		return nil
+	}
+	if l.Protocol != "udp" && l.Protocol != "tcp" {
+		return fmt.Errorf("The protocol of a metrics listener must be one of udp,tcp (got '%s')", l.Protocol)
+	}
+	if strings.Count(l.Device, "/") != 1 {
+		return errors.New("A metrics listener must have the user/device into which to write its metrics")
+	}
+	if l.FlushInterval < 1 {
+		return errors.New("The flush interval of a metrics listener must be at least 1 second")
+	}
+	return nil
+}
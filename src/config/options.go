@@ -26,8 +26,16 @@ type Options struct {
 	CacheEnabled    bool
 	CacheTimeout    int64
 
+	PresenceTimeout int64 // PresenceTimeout is the number of seconds after its last request that a device goes offline
+	PresenceLog     bool  // PresenceLog writes presence changes to each user's meta/presence stream
+
+	TrashPeriod      int64 // TrashPeriod is the number of seconds for which deleted users, devices and streams can be restored
+	CommandRetention int64 // CommandRetention is the number of seconds for which finished commands are kept. 0 keeps them forever.
+
 	BatchSize int // BatchSize is the number of datapoints per batch of data in a stream
 	ChunkSize int // ChunkSize is the number of batches to queue up before writing to storage
+
+	InsertKeyWindow int64 // InsertKeyWindow is the number of seconds for which idempotency keys of inserts are remembered. 0 disables them.
 }
 
 func (o *Options) String() string {
@@ -59,6 +67,7 @@ func (c *Configuration) Options() *Options {
 
 	opt.BatchSize = c.BatchSize
 	opt.ChunkSize = c.ChunkSize
+	opt.InsertKeyWindow = c.InsertKeyWindow
 
 	opt.CacheEnabled = c.UseCache
 	opt.DeviceCacheSize = c.DeviceCacheSize
@@ -66,5 +75,11 @@ func (c *Configuration) Options() *Options {
 	opt.StreamCacheSize = c.StreamCacheSize
 	opt.CacheTimeout = c.CacheTimeout
 
+	opt.PresenceTimeout = c.PresenceTimeout
+	opt.PresenceLog = c.PresenceLog
+
+	opt.TrashPeriod = c.TrashPeriod
+	opt.CommandRetention = c.CommandRetention
+
 	return &opt
 }
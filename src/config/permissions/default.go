@@ -57,10 +57,11 @@ var Default = Permissions{
 
 			// ... Not bad
 			DeviceRole: DeviceRole{
-				PrivateAccessLevel: "none",
-				PublicAccessLevel:  "userpublic",
-				UserAccessLevel:    "userself",
-				SelfAccessLevel:    "userself",
+				CanAutoCreateStreams: true,
+				PrivateAccessLevel:   "none",
+				PublicAccessLevel:    "userpublic",
+				UserAccessLevel:      "userself",
+				SelfAccessLevel:      "userself",
 			},
 		},
 		"admin": &UserRole{
@@ -84,23 +85,25 @@ var Default = Permissions{
 
 			// ACCESS ALL THE THINGS
 			DeviceRole: DeviceRole{
-				CanCountUsers:      true,
-				CanCountDevices:    true,
-				CanCountStreams:    true,
-				PrivateAccessLevel: "fullnp",
-				PublicAccessLevel:  "fullnp",
-				UserAccessLevel:    "fullnp",
-				SelfAccessLevel:    "fullnp",
+				CanCountUsers:        true,
+				CanCountDevices:      true,
+				CanCountStreams:      true,
+				CanAutoCreateStreams: true,
+				PrivateAccessLevel:   "fullnp",
+				PublicAccessLevel:    "fullnp",
+				UserAccessLevel:      "fullnp",
+				SelfAccessLevel:      "fullnp",
 			},
 		},
 	},
 
 	DeviceRoles: map[string]*DeviceRole{
 		"none": &DeviceRole{
-			PrivateAccessLevel: "none",
-			PublicAccessLevel:  "none",
-			UserAccessLevel:    "none",
-			SelfAccessLevel:    "fulldevice",
+			CanAutoCreateStreams: true,
+			PrivateAccessLevel:   "none",
+			PublicAccessLevel:    "none",
+			UserAccessLevel:      "none",
+			SelfAccessLevel:      "fulldevice",
 		},
 		"reader": &DeviceRole{
 			PrivateAccessLevel: "devicereader",
@@ -109,19 +112,21 @@ var Default = Permissions{
 			SelfAccessLevel:    "fulldevice",
 		},
 		"writer": &DeviceRole{
-			PrivateAccessLevel: "devicewriter",
-			PublicAccessLevel:  "devicewriter",
-			UserAccessLevel:    "devicewriter",
-			SelfAccessLevel:    "fulldevice",
+			CanAutoCreateStreams: true,
+			PrivateAccessLevel:   "devicewriter",
+			PublicAccessLevel:    "devicewriter",
+			UserAccessLevel:      "devicewriter",
+			SelfAccessLevel:      "fulldevice",
 		},
 		"user": &DeviceRole{
-			CanCountUsers:      true,
-			CanCountDevices:    true,
-			CanCountStreams:    true,
-			PrivateAccessLevel: "fulldownlink",
-			PublicAccessLevel:  "fulldownlink",
-			UserAccessLevel:    "fulldownlink",
-			SelfAccessLevel:    "full",
+			CanCountUsers:        true,
+			CanCountDevices:      true,
+			CanCountStreams:      true,
+			CanAutoCreateStreams: true,
+			PrivateAccessLevel:   "fulldownlink",
+			PublicAccessLevel:    "fulldownlink",
+			UserAccessLevel:      "fulldownlink",
+			SelfAccessLevel:      "full",
 		},
 	},
 
@@ -259,6 +264,10 @@ var Default = Permissions{
 			DeviceUserEditable:              false,
 			DevicePublic:                    true,
 			DeviceRole:                      false,
+			DeviceAutoCreateStreams:         false,
+			DeviceInfluxMapping:             true,
+			DeviceWebhookToken:              false,
+			DeviceWebhookRules:              true,
 			StreamName:                      true,
 			StreamNickname:                  true,
 			StreamDescription:               true,
@@ -267,6 +276,7 @@ var Default = Permissions{
 			StreamDatatype:                  true,
 			StreamEphemeral:                 true,
 			StreamDownlink:                  true,
+			StreamBackfill:                  true,
 		},
 		"selfwrite": &RWAccess{
 			CanAccessUser:                   true,
@@ -293,6 +303,10 @@ var Default = Permissions{
 			DeviceUserEditable:              false,
 			DevicePublic:                    true,
 			DeviceRole:                      true,
+			DeviceAutoCreateStreams:         true,
+			DeviceInfluxMapping:             true,
+			DeviceWebhookToken:              true,
+			DeviceWebhookRules:              true,
 			StreamName:                      false,
 			StreamNickname:                  true,
 			StreamDescription:               true,
@@ -301,6 +315,7 @@ var Default = Permissions{
 			StreamDatatype:                  true,
 			StreamEphemeral:                 true,
 			StreamDownlink:                  true,
+			StreamBackfill:                  true,
 		},
 		"selfread": &RWAccess{
 			CanAccessUser:                   true,
@@ -327,6 +342,10 @@ var Default = Permissions{
 			DeviceUserEditable:              true,
 			DevicePublic:                    true,
 			DeviceRole:                      true,
+			DeviceAutoCreateStreams:         true,
+			DeviceInfluxMapping:             true,
+			DeviceWebhookToken:              true,
+			DeviceWebhookRules:              true,
 			StreamName:                      true,
 			StreamNickname:                  true,
 			StreamDescription:               true,
@@ -335,6 +354,7 @@ var Default = Permissions{
 			StreamDatatype:                  true,
 			StreamEphemeral:                 true,
 			StreamDownlink:                  true,
+			StreamBackfill:                  true,
 		},
 		"deviceread": &RWAccess{
 			CanAccessUser:                   true,
@@ -361,6 +381,10 @@ var Default = Permissions{
 			DeviceUserEditable:              true,
 			DevicePublic:                    true,
 			DeviceRole:                      true,
+			DeviceAutoCreateStreams:         true,
+			DeviceInfluxMapping:             true,
+			DeviceWebhookToken:              false,
+			DeviceWebhookRules:              true,
 			StreamName:                      true,
 			StreamNickname:                  true,
 			StreamDescription:               true,
@@ -369,6 +393,7 @@ var Default = Permissions{
 			StreamDatatype:                  true,
 			StreamEphemeral:                 true,
 			StreamDownlink:                  true,
+			StreamBackfill:                  true,
 		},
 		"devicewrite": &RWAccess{
 			CanAccessUser:                   true,
@@ -395,6 +420,10 @@ var Default = Permissions{
 			DeviceUserEditable:              true,
 			DevicePublic:                    true,
 			DeviceRole:                      false,
+			DeviceAutoCreateStreams:         false,
+			DeviceInfluxMapping:             true,
+			DeviceWebhookToken:              false,
+			DeviceWebhookRules:              true,
 			StreamName:                      true,
 			StreamNickname:                  true,
 			StreamDescription:               true,
@@ -403,6 +432,7 @@ var Default = Permissions{
 			StreamDatatype:                  true,
 			StreamEphemeral:                 true,
 			StreamDownlink:                  true,
+			StreamBackfill:                  true,
 		},
 		"fulldevicewrite": &RWAccess{
 			CanAccessUser:                   true,
@@ -429,6 +459,10 @@ var Default = Permissions{
 			DeviceUserEditable:              true,
 			DevicePublic:                    true,
 			DeviceRole:                      false,
+			DeviceAutoCreateStreams:         false,
+			DeviceInfluxMapping:             true,
+			DeviceWebhookToken:              true,
+			DeviceWebhookRules:              true,
 			StreamName:                      false,
 			StreamNickname:                  true,
 			StreamDescription:               true,
@@ -437,6 +471,7 @@ var Default = Permissions{
 			StreamDatatype:                  true,
 			StreamEphemeral:                 true,
 			StreamDownlink:                  true,
+			StreamBackfill:                  true,
 		},
 		"fulldownlinkwrite": &RWAccess{
 			CanAccessUser:                   true,
@@ -463,6 +498,10 @@ var Default = Permissions{
 			DeviceUserEditable:              true,
 			DevicePublic:                    true,
 			DeviceRole:                      true,
+			DeviceAutoCreateStreams:         true,
+			DeviceInfluxMapping:             true,
+			DeviceWebhookToken:              true,
+			DeviceWebhookRules:              true,
 			StreamName:                      true,
 			StreamNickname:                  true,
 			StreamDescription:               true,
@@ -471,6 +510,7 @@ var Default = Permissions{
 			StreamDatatype:                  true,
 			StreamEphemeral:                 true,
 			StreamDownlink:                  true,
+			StreamBackfill:                  true,
 		},
 	},
 }
@@ -16,6 +16,10 @@ type DeviceRole struct {
 	CanCountDevices bool
 	CanCountStreams bool
 
+	// Whether devices with the role can have streams created automatically when inserting into streams
+	// that don't exist. This is only done for devices which have auto_create_streams set.
+	CanAutoCreateStreams bool `json:"can_auto_create_streams"`
+
 	PrivateAccessLevel string `json:"private_access_level"` // The access level to private users/devices/streams
 	PublicAccessLevel  string `json:"public_access_level"`  // The access level to public users/devices/streams
 	UserAccessLevel    string `json:"user_access_level"`    // The access level to devices/streams that belong to you and your own user
@@ -13,7 +13,8 @@ var (
 	FullRWAccess = RWAccess{true, true, true, true, true,
 		true, true, true, true, true, true, true, true,
 		true, true, true, true, true, true, true, true, true,
-		true, true, true, true, true, true, true, true, true, true, nil}
+		true, true, true, true, true, true, true, true, true, true, true, true, true,
+		true, true, nil}
 )
 
 // RWAccess is a struct of boolean permissions given for a certain role.
@@ -46,16 +47,20 @@ type RWAccess struct {
 	UserPassword    bool `json:"user_password"`
 
 	// Access of device properties
-	DeviceName         bool `json:"device_name"`
-	DeviceNickname     bool `json:"device_nickname"`
-	DeviceDescription  bool `json:"device_description"`
-	DeviceIcon         bool `json:"device_icon"`
-	DeviceAPIKey       bool `json:"device_apikey"`
-	DeviceEnabled      bool `json:"device_enabled"`
-	DeviceIsVisible    bool `json:"device_visible"`
-	DeviceUserEditable bool `json:"device_user_editable"`
-	DevicePublic       bool `json:"device_public"`
-	DeviceRole         bool `json:"device_role"`
+	DeviceName              bool `json:"device_name"`
+	DeviceNickname          bool `json:"device_nickname"`
+	DeviceDescription       bool `json:"device_description"`
+	DeviceIcon              bool `json:"device_icon"`
+	DeviceAPIKey            bool `json:"device_apikey"`
+	DeviceEnabled           bool `json:"device_enabled"`
+	DeviceIsVisible         bool `json:"device_visible"`
+	DeviceUserEditable      bool `json:"device_user_editable"`
+	DevicePublic            bool `json:"device_public"`
+	DeviceRole              bool `json:"device_role"`
+	DeviceAutoCreateStreams bool `json:"device_auto_create_streams"`
+	DeviceInfluxMapping     bool `json:"device_influx_mapping"`
+	DeviceWebhookToken      bool `json:"device_webhook_token"`
+	DeviceWebhookRules      bool `json:"device_webhook_rules"`
 
 	// Access of stream properties
 	StreamName        bool `json:"stream_name"`
@@ -66,6 +71,7 @@ type RWAccess struct {
 	StreamDatatype    bool `json:"stream_datatype"`
 	StreamEphemeral   bool `json:"stream_ephemeral"`
 	StreamDownlink    bool `json:"stream_downlink"`
+	StreamBackfill    bool `json:"stream_backfill"`
 
 	// Internal: cached map of access levels (used in reflection)
 	cmap map[string]bool
@@ -97,6 +103,17 @@ func (a *RWAccess) GetMap() map[string]bool {
 	return a.cmap
 }
 
+// Covers returns true if the access allows everything that the other access allows
+func (a *RWAccess) Covers(other *RWAccess) bool {
+	m := a.GetMap()
+	for k, v := range other.GetMap() {
+		if v && !m[k] {
+			return false
+		}
+	}
+	return true
+}
+
 func (a *RWAccess) Validate() error {
 	return a.LoadMap()
 }
@@ -12,3 +12,12 @@ func TestRWAccessMap(t *testing.T) {
 	require.False(t, m["user_password"])
 	require.True(t, m["user_name"])
 }
+
+func TestRWAccessCovers(t *testing.T) {
+	a := RWAccess{UserName: true, UserEmail: true}
+	require.True(t, a.Covers(&RWAccess{UserName: true}))
+	require.True(t, a.Covers(&RWAccess{}))
+	require.False(t, a.Covers(&RWAccess{UserName: true, UserPassword: true}))
+	require.True(t, Default.RWAccess["selfread"].Covers(Default.RWAccess["publicread"]))
+	require.False(t, Default.RWAccess["publicread"].Covers(Default.RWAccess["selfread"]))
+}
@@ -26,6 +26,8 @@ type DeviceDefaults struct {
 	Public       bool   `json:"public"`
 	IsVisible    bool   `json:"visible"`
 	UserEditable bool   `json:"user_editable"`
+
+	AutoCreateStreams bool `json:"auto_create_streams"`
 }
 
 type StreamDefaults struct {
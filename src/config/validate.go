@@ -60,6 +60,19 @@ func (f *Frontend) Validate(c *Configuration) (err error) {
 		return errors.New("The limit of single insert has to be at least 100 bytes.")
 	}
 
+	if f.TakeoutExpire < 0 {
+		return errors.New("The takeout expiration time can't be negative")
+	}
+	if f.TakeoutExpire == 0 {
+		f.TakeoutExpire = 7 * 24 * 60 * 60
+	}
+	if f.TakeoutDirectory == "" {
+		f.TakeoutDirectory = "takeout"
+	}
+	if f.TakeoutDirectory, err = filepath.Abs(f.TakeoutDirectory); err != nil {
+		return err
+	}
+
 	if err = f.Websocket.Validate(); err != nil {
 		return err
 	}
@@ -83,6 +96,19 @@ func (c *Configuration) Validate() error {
 		return errors.New("Chunk size must be >=0")
 	}
 
+	if c.InsertKeyWindow < 0 {
+		return errors.New("Insert key window must be >=0")
+	}
+	if c.PresenceTimeout < 0 {
+		return errors.New("Presence timeout must be >=0")
+	}
+	if c.TrashPeriod < 0 {
+		return errors.New("Trash period must be >=0")
+	}
+	if c.CommandRetention < 0 {
+		return errors.New("Command retention must be >=0")
+	}
+
 	if c.UseCache {
 		if c.UserCacheSize < 1 {
 			return errors.New("User cache size must be >=1")
@@ -105,6 +131,12 @@ func (c *Configuration) Validate() error {
 	if err := c.Sql.Validate(); err != nil {
 		return err
 	}
+	if err := c.StatsD.Validate(); err != nil {
+		return err
+	}
+	if err := c.Graphite.Validate(); err != nil {
+		return err
+	}
 
 	// Try loading the permissions
 	_, err := permissions.Load(c.Permissions)
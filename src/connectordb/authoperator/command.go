@@ -0,0 +1,67 @@
+package authoperator
+
+import (
+	"connectordb/authoperator/permissions"
+	"connectordb/users"
+	"errors"
+
+	"github.com/nats-io/nats"
+)
+
+// CreateCommandByID queues a command on the stream, if the device can write the stream's downlink
+func (a *AuthOperator) CreateCommandByID(streamID int64, c *users.Command) error {
+	perm, ua, da, err := a.getIOPermissions(streamID)
+	if err != nil {
+		return err
+	}
+	if !permissions.GetWriteAccess(perm, ua).CanAccessStreamDownlink || !permissions.GetWriteAccess(perm, da).CanAccessStreamDownlink {
+		return errors.New("Write access to stream downlink denied.")
+	}
+	c.Sender = a.Name()
+	return a.Operator.CreateCommandByID(streamID, c)
+}
+
+// ReadCommandByID reads the command, if the device can read the downlink of the command's stream
+func (a *AuthOperator) ReadCommandByID(commandID int64) (*users.Command, error) {
+	c, err := a.Operator.ReadCommandByID(commandID)
+	if err != nil {
+		return nil, permissions.ErrNoAccess
+	}
+	if err = a.ErrorIfNoIOReadAccess(c.StreamID, "downlink"); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ReadCommandsByID reads the commands queued on the stream, if the device can read the stream's downlink
+func (a *AuthOperator) ReadCommandsByID(streamID int64, status string) ([]*users.Command, error) {
+	if err := a.ErrorIfNoIOReadAccess(streamID, "downlink"); err != nil {
+		return nil, err
+	}
+	return a.Operator.ReadCommandsByID(streamID, status)
+}
+
+// UpdateCommandByID sets the status and result of a command. Only devices which can write the stream's data,
+// such as the device which owns the stream, can acknowledge its commands.
+func (a *AuthOperator) UpdateCommandByID(commandID int64, status string, result string) error {
+	c, err := a.Operator.ReadCommandByID(commandID)
+	if err != nil {
+		return permissions.ErrNoAccess
+	}
+	perm, ua, da, err := a.getIOPermissions(c.StreamID)
+	if err != nil {
+		return err
+	}
+	if !permissions.GetWriteAccess(perm, ua).CanAccessStreamData || !permissions.GetWriteAccess(perm, da).CanAccessStreamData {
+		return errors.New("Write access to stream data denied.")
+	}
+	return a.Operator.UpdateCommandByID(commandID, status, result)
+}
+
+// SubscribeCommandsByID subscribes to the commands sent to the stream, if the device can read the stream's downlink
+func (a *AuthOperator) SubscribeCommandsByID(streamID int64, chn chan users.Command) (*nats.Subscription, error) {
+	if err := a.ErrorIfNoIOReadAccess(streamID, "downlink"); err != nil {
+		return nil, err
+	}
+	return a.Operator.SubscribeCommandsByID(streamID, chn)
+}
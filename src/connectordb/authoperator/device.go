@@ -107,6 +107,8 @@ func (a *AuthOperator) DeviceMaker() (*users.DeviceMaker, error) {
 			IsVisible:    d.IsVisible,
 			UserEditable: d.UserEditable,
 			Enabled:      d.Enabled,
+
+			AutoCreateStreams: d.AutoCreateStreams,
 		},
 	}, nil
 }
@@ -154,7 +156,13 @@ func (a *AuthOperator) ReadDeviceToMap(devpath string) (map[string]interface{},
 	if err != nil {
 		return nil, err
 	}
-	return permissions.ReadObjectToMap(perm, ua, da, "device", dev)
+	m, err := permissions.ReadObjectToMap(perm, ua, da, "device", dev)
+	if err == nil {
+		// The presence of a device is visible to anyone who can read the device
+		m["lastseen"] = dev.LastSeen
+		m["online"] = dev.Online
+	}
+	return m, err
 }
 
 // ReadDeviceByUserID reads the given device by its name and user ID
@@ -181,6 +189,45 @@ func (a *AuthOperator) UpdateDeviceByID(deviceID int64, updates map[string]inter
 	return a.Operator.UpdateDeviceByID(deviceID, updates)
 }
 
+// RenameDeviceByID renames the device, if the operator is permitted to write the device's name
+func (a *AuthOperator) RenameDeviceByID(deviceID int64, name string, alias bool) error {
+	perm, _, _, _, ua, da, err := a.getDeviceAccessLevels(deviceID)
+	if err != nil {
+		return err
+	}
+
+	err = permissions.CheckIfUpdateFieldsPermitted(perm, ua, da, "device", map[string]interface{}{"name": name})
+	if err != nil {
+		return err
+	}
+	return a.Operator.RenameDeviceByID(deviceID, name, alias)
+}
+
+// TransferDeviceByID gives the device to another user. This requires permission to delete the device from
+// its user, and to create a device for the other user.
+func (a *AuthOperator) TransferDeviceByID(deviceID int64, userID int64) error {
+	_, _, _, _, ua, da, err := a.getDeviceAccessLevels(deviceID)
+	if err != nil {
+		return err
+	}
+	if !ua.CanDeleteDevice || !da.CanDeleteDevice {
+		return permissions.ErrNoAccess
+	}
+
+	u, err := a.Operator.ReadUserByID(userID)
+	if err != nil {
+		return permissions.ErrNoAccess
+	}
+	_, _, _, ua, da, err = a.getAccessLevels(userID, u.Public, false)
+	if err != nil {
+		return err
+	}
+	if !ua.CanCreateDevice || !da.CanCreateDevice {
+		return permissions.ErrNoAccess
+	}
+	return a.Operator.TransferDeviceByID(deviceID, userID)
+}
+
 // DeleteDeviceByID removes a device based upon its ID
 func (a *AuthOperator) DeleteDeviceByID(deviceID int64) error {
 	_, _, _, _, ua, da, err := a.getDeviceAccessLevels(deviceID)
@@ -192,3 +239,20 @@ func (a *AuthOperator) DeleteDeviceByID(deviceID int64) error {
 	}
 	return a.Operator.DeleteDeviceByID(deviceID)
 }
+
+// SeenDeviceByID records that the device just talked to the server. A device can only mark itself as seen.
+func (a *AuthOperator) SeenDeviceByID(deviceID int64) error {
+	if deviceID != a.deviceID {
+		return permissions.ErrNoAccess
+	}
+	if a.deviceID == -2 {
+		// The nobody operator has no presence
+		return nil
+	}
+	return a.Operator.SeenDeviceByID(deviceID)
+}
+
+// Seen marks the operator's device as seen. It is called on each authenticated request.
+func (a *AuthOperator) Seen() error {
+	return a.SeenDeviceByID(a.deviceID)
+}
@@ -171,3 +171,29 @@ func TestAuthDeviceDeviceCrud(t *testing.T) {
 	require.NoError(t, o.DeleteDevice("tstusr/testdevice"))
 
 }
+
+func TestAuthDevicePresence(t *testing.T) {
+	db.Clear()
+	require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: "streamdb_test", Email: "root@localhost", Password: "mypass", Role: "user", Public: true}}))
+	require.NoError(t, db.CreateDevice("streamdb_test/testdevice", &users.DeviceMaker{}))
+
+	o, err := db.AsUser("streamdb_test")
+	require.NoError(t, err)
+
+	dev, err := o.ReadDevice("streamdb_test/testdevice")
+	require.NoError(t, err)
+	require.False(t, dev.Online)
+
+	// A device can only mark itself as seen
+	require.Error(t, o.SeenDeviceByID(dev.DeviceID))
+	require.NoError(t, o.Seen())
+
+	m, err := o.ReadDeviceToMap("streamdb_test/user")
+	require.NoError(t, err)
+	require.Equal(t, true, m["online"])
+	require.True(t, m["lastseen"].(float64) > 0)
+
+	m, err = o.ReadDeviceToMap("streamdb_test/testdevice")
+	require.NoError(t, err)
+	require.Equal(t, false, m["online"])
+}
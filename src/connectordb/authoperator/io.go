@@ -61,6 +61,11 @@ func (a *AuthOperator) TimeToIndexStreamByID(streamID int64, substream string, t
 
 // InsertStreamByID inserts the given data into the stream
 func (a *AuthOperator) InsertStreamByID(streamID int64, substream string, data datastream.DatapointArray, restamp bool) error {
+	return a.InsertStreamOnceByID(streamID, substream, "", data, restamp)
+}
+
+// InsertStreamOnceByID inserts the given data into the stream, unless an insert with the same key was already made
+func (a *AuthOperator) InsertStreamOnceByID(streamID int64, substream string, key string, data datastream.DatapointArray, restamp bool) error {
 	strm, err := a.Operator.ReadStreamByID(streamID)
 	if err != nil {
 		return permissions.ErrNoAccess
@@ -104,7 +109,13 @@ func (a *AuthOperator) InsertStreamByID(streamID int64, substream string, data d
 		return errors.New("Unrecognized substream type")
 	}
 
-	return a.Operator.InsertStreamByID(streamID, substream, data, restamp)
+	err = a.Operator.InsertStreamOnceByID(streamID, substream, key, data, restamp)
+	if err == nil {
+		// Inserts are made over the websocket too, so they also count as the device being seen.
+		// A failure to update presence does not fail the insert.
+		a.Seen()
+	}
+	return err
 }
 
 // GetStreamTimeRangeByID is defined in Operator
@@ -132,3 +132,48 @@ func TestAuthSubstream(t *testing.T) {
 	dr.Close()
 
 }
+
+func TestAuthStreamAutoCreate(t *testing.T) {
+	db.Clear()
+	require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: "tst", Email: "root@localhost", Password: "mypass", Role: "user", Public: true}}))
+	require.NoError(t, db.CreateDevice("tst/tst", &users.DeviceMaker{}))
+	require.NoError(t, db.CreateDevice("tst/auto", &users.DeviceMaker{Device: users.Device{AutoCreateStreams: true}}))
+	require.NoError(t, db.CreateDevice("tst/reader", &users.DeviceMaker{Device: users.Device{AutoCreateStreams: true, Role: "reader"}}))
+
+	data := datastream.DatapointArray{datastream.Datapoint{Timestamp: 1.0, Data: 12.5}}
+
+	// Streams are only created for devices which have the option set
+	o, err := db.AsDevice("tst/tst")
+	require.NoError(t, err)
+	require.Equal(t, users.ErrStreamNotFound, o.InsertStream("tst/tst/temperature", data, false))
+
+	// ...and whose role allows it
+	o, err = db.AsDevice("tst/reader")
+	require.NoError(t, err)
+	require.Equal(t, users.ErrStreamNotFound, o.InsertStream("tst/reader/temperature", data, false))
+
+	o, err = db.AsDevice("tst/auto")
+	require.NoError(t, err)
+	require.NoError(t, o.InsertStream("tst/auto/temperature", data, false))
+
+	s, err := o.ReadStream("tst/auto/temperature")
+	require.NoError(t, err)
+	require.Equal(t, `{"type":"number"}`, s.Schema)
+
+	l, err := o.LengthStream("tst/auto/temperature")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, l)
+
+	// The inferred schema is enforced on later inserts
+	require.Error(t, o.InsertStream("tst/auto/temperature", datastream.DatapointArray{datastream.Datapoint{Timestamp: 2.0, Data: "hot"}}, false))
+
+	// The creation is in the metalog
+	dr, err := db.GetStreamIndexRange("tst/meta/log", -1, 0, "")
+	require.NoError(t, err)
+	dp, err := dr.Next()
+	require.NoError(t, err)
+	require.NotNil(t, dp)
+	dr.Close()
+	require.Equal(t, "CreateStream", dp.Data.(map[string]interface{})["cmd"])
+	require.Equal(t, "tst/auto/temperature", dp.Data.(map[string]interface{})["arg"])
+}
@@ -217,6 +217,52 @@ func (a *AuthOperator) UpdateStreamByID(streamID int64, updates map[string]inter
 	return a.Operator.UpdateStreamByID(streamID, updates)
 }
 
+// MoveStreamByID moves the stream to the given device and name. Besides writing the stream's name, moving a stream
+// to another device requires permission to delete it from its device, and to create it on the other device.
+func (a *AuthOperator) MoveStreamByID(streamID int64, deviceID int64, name string, alias bool) error {
+	s, err := a.Operator.ReadStreamByID(streamID)
+	if err != nil {
+		return permissions.ErrNoAccess
+	}
+	perm, _, _, _, ua, da, err := a.getDeviceAccessLevels(s.DeviceID)
+	if err != nil {
+		return err
+	}
+	err = permissions.CheckIfUpdateFieldsPermitted(perm, ua, da, "stream", map[string]interface{}{"name": name})
+	if err != nil {
+		return err
+	}
+	if deviceID != s.DeviceID {
+		if !ua.CanDeleteStream || !da.CanDeleteStream {
+			return permissions.ErrNoAccess
+		}
+		_, _, _, _, ua, da, err = a.getDeviceAccessLevels(deviceID)
+		if err != nil {
+			return err
+		}
+		if !ua.CanCreateStream || !da.CanCreateStream {
+			return permissions.ErrNoAccess
+		}
+	}
+	return a.Operator.MoveStreamByID(streamID, deviceID, name, alias)
+}
+
+// CloneStreamByID copies the stream to the given device and name. This requires read access to the stream's
+// data, and permission to create the stream on the device.
+func (a *AuthOperator) CloneStreamByID(streamID int64, deviceID int64, name string, t1 float64, t2 float64) error {
+	if err := a.ErrorIfNoIOReadAccess(streamID, ""); err != nil {
+		return err
+	}
+	_, _, _, _, ua, da, err := a.getDeviceAccessLevels(deviceID)
+	if err != nil {
+		return err
+	}
+	if !ua.CanCreateStream || !da.CanCreateStream {
+		return permissions.ErrNoAccess
+	}
+	return a.Operator.CloneStreamByID(streamID, deviceID, name, t1, t2)
+}
+
 // DeleteStreamByID deletes the given stream
 func (a *AuthOperator) DeleteStreamByID(streamID int64, substream string) error {
 	s, err := a.Operator.ReadStreamByID(streamID)
@@ -232,3 +278,44 @@ func (a *AuthOperator) DeleteStreamByID(streamID int64, substream string) error
 	}
 	return a.Operator.DeleteStreamByID(streamID, substream)
 }
+
+// ReadStreamSchemasByID reads the schema versions of the stream, if the operator can read the stream's schema
+func (a *AuthOperator) ReadStreamSchemasByID(streamID int64) ([]*users.StreamSchema, error) {
+	s, err := a.Operator.ReadStreamByID(streamID)
+	if err != nil {
+		return nil, permissions.ErrNoAccess
+	}
+	perm, _, _, _, ua, da, err := a.getDeviceAccessLevels(s.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+	if !permissions.GetReadAccess(perm, ua).StreamSchema || !permissions.GetReadAccess(perm, da).StreamSchema {
+		return nil, permissions.ErrNoAccess
+	}
+	return a.Operator.ReadStreamSchemasByID(streamID)
+}
+
+// UpdateStreamSchemaByID sets a new schema version, if the operator is permitted to write the stream's schema
+func (a *AuthOperator) UpdateStreamSchemaByID(streamID int64, schema string, upgrade string) error {
+	s, err := a.Operator.ReadStreamByID(streamID)
+	if err != nil {
+		return permissions.ErrNoAccess
+	}
+	perm, _, _, _, ua, da, err := a.getDeviceAccessLevels(s.DeviceID)
+	if err != nil {
+		return err
+	}
+	err = permissions.CheckIfUpdateFieldsPermitted(perm, ua, da, "stream", map[string]interface{}{"schema": schema})
+	if err != nil {
+		return err
+	}
+	return a.Operator.UpdateStreamSchemaByID(streamID, schema, upgrade)
+}
+
+// CheckStreamSchemaByID checks the stream's data against a proposed schema. It requires read access to the data.
+func (a *AuthOperator) CheckStreamSchemaByID(streamID int64, substream string, schema string, upgrade string) (*users.SchemaCheck, error) {
+	if err := a.ErrorIfNoIOReadAccess(streamID, substream); err != nil {
+		return nil, err
+	}
+	return a.Operator.CheckStreamSchemaByID(streamID, substream, schema, upgrade)
+}
@@ -3,6 +3,10 @@ package authoperator
 import (
 	"connectordb/messenger"
 	"errors"
+	"strings"
+	"sync"
+	"time"
+	"util"
 
 	"github.com/nats-io/nats"
 )
@@ -25,3 +29,98 @@ func (a *AuthOperator) SubscribeStreamByID(streamID int64, substream string, chn
 	}
 	return a.Operator.SubscribeStreamByID(streamID, substream, chn)
 }
+
+// accessCacheTTL is the time for which the result of a permission check is reused by a pattern subscription
+const accessCacheTTL = 30 * time.Second
+
+// accessCacheSize is the maximum number of streams whose permissions a pattern subscription remembers
+const accessCacheSize = 10000
+
+// accessCache remembers whether a device could read each stream, so that permissions are not read from
+// the database for every message of a pattern subscription
+type accessCache struct {
+	sync.Mutex
+	entries map[string]accessCacheEntry
+}
+
+type accessCacheEntry struct {
+	ok      bool
+	expires time.Time
+}
+
+// get returns the cached access to the stream, calling check if it is not cached or has expired
+func (c *accessCache) get(stream string, check func() bool) bool {
+	c.Lock()
+	defer c.Unlock()
+	now := time.Now()
+	if e, ok := c.entries[stream]; ok && now.Before(e.expires) {
+		return e.ok
+	}
+	if len(c.entries) >= accessCacheSize {
+		c.entries = make(map[string]accessCacheEntry)
+	}
+	ok := check()
+	c.entries[stream] = accessCacheEntry{ok, now.Add(accessCacheTTL)}
+	return ok
+}
+
+// SubscribePattern subscribes to all streams matching the pattern. Since the matching streams are not known
+// in advance, permissions are checked for each message, and only messages of streams the device can read are sent.
+// The permissions of each stream are cached for accessCacheTTL, and the cheaper filter is run first.
+func (a *AuthOperator) SubscribePattern(pattern string, filter func(*messenger.Message) bool, chn chan messenger.Message) (*nats.Subscription, error) {
+	cache := &accessCache{entries: make(map[string]accessCacheEntry)}
+	return a.Operator.SubscribePattern(pattern, func(m *messenger.Message) bool {
+		if filter != nil && !filter(m) {
+			return false
+		}
+		return cache.get(m.Stream, func() bool { return a.canReadMessage(m) })
+	}, chn)
+}
+
+// canReadMessage returns true if the device has read access to the stream of the message
+func (a *AuthOperator) canReadMessage(m *messenger.Message) bool {
+	_, _, streampath, _, substream, err := util.SplitStreamPath(m.Stream)
+	if err != nil {
+		return false
+	}
+	strm, err := a.Operator.ReadStream(streampath)
+	if err != nil {
+		return false
+	}
+	return a.ErrorIfNoIOReadAccess(strm.StreamID, substream) == nil
+}
+
+// SubscribeMeta subscribes to metadata change events. Only events about objects which the device can read are sent.
+// Since deleted and purged objects can't be read, their events are sent if the device can read the object's parent.
+// The filter is run first, so that the database is only read for the events which the subscriber wants.
+func (a *AuthOperator) SubscribeMeta(filter func(*messenger.MetaEvent) bool, chn chan messenger.MetaEvent) (*nats.Subscription, error) {
+	return a.Operator.SubscribeMeta(func(e *messenger.MetaEvent) bool {
+		return (filter == nil || filter(e)) && a.canReadMeta(e)
+	}, chn)
+}
+
+// canReadMeta returns true if the device has read access to the object of the event
+func (a *AuthOperator) canReadMeta(e *messenger.MetaEvent) bool {
+	var err error
+	if e.Action != "delete" && e.Action != "purge" {
+		switch e.Type {
+		case "user":
+			_, err = a.ReadUser(e.Path)
+		case "device":
+			_, err = a.ReadDevice(e.Path)
+		default:
+			_, err = a.ReadStream(e.Path)
+		}
+		return err == nil
+	}
+	switch e.Type {
+	case "user":
+		_, _, _, ua, da, err := a.getAccessLevels(-1, false, false)
+		return err == nil && ua.CanListUsers && da.CanListUsers
+	case "device":
+		_, err = a.ReadUser(e.User())
+	default:
+		_, err = a.ReadDevice(e.Path[:strings.LastIndex(e.Path, "/")])
+	}
+	return err == nil
+}
@@ -87,10 +87,65 @@ func TestAuthSubscribe(t *testing.T) {
 			// easy assert tests rather than require.
 			data := []datastream.Datapoint{datastream.Datapoint{}}
 
-			recvstream <- messenger.Message{"TIMEOUT", "", data}
+			recvstream <- messenger.Message{Stream: "TIMEOUT", Data: data}
 		}()
 		m := <-recvstream
 		assert.Equal(t, "tst/tst/tst", m.Stream)
 		assert.Equal(t, "Hello World!", m.Data[0].Data)
 	}
 }
+
+func TestAuthSubscribePattern(t *testing.T) {
+	db.Clear()
+	require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: "tst", Email: "root@localhost", Password: "mypass", Role: "user", Public: true}}))
+	require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: "tst2", Email: "root2@localhost", Password: "mypass", Role: "user"}}))
+	require.NoError(t, db.CreateDevice("tst/tst", &users.DeviceMaker{}))
+	require.NoError(t, db.CreateDevice("tst2/tst", &users.DeviceMaker{}))
+	require.NoError(t, db.CreateStream("tst/tst/s", &users.StreamMaker{Stream: users.Stream{Schema: `{"type": "string"}`}}))
+	require.NoError(t, db.CreateStream("tst2/tst/s", &users.StreamMaker{Stream: users.Stream{Schema: `{"type": "string"}`}}))
+
+	o, err := db.AsDevice("tst/user")
+	require.NoError(t, err)
+
+	recvchan := make(chan messenger.Message, 2)
+	_, err = o.Subscribe("*/*", recvchan)
+	require.Error(t, err)
+	_, err = o.Subscribe("*/*/s", recvchan)
+	require.NoError(t, err)
+	db.Messenger.Flush()
+
+	// Only the stream which the device can read is forwarded
+	data := []datastream.Datapoint{datastream.Datapoint{Timestamp: 1.0, Data: "Hello World!"}}
+	require.NoError(t, db.InsertStream("tst2/tst/s", data, false))
+	require.NoError(t, db.InsertStream("tst/tst/s", data, false))
+
+	select {
+	case m := <-recvchan:
+		require.Equal(t, "tst/tst/s", m.Stream)
+		require.Equal(t, "*/*/s", m.Pattern)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Subscription timed out")
+	}
+}
+
+func TestAccessCache(t *testing.T) {
+	c := &accessCache{entries: make(map[string]accessCacheEntry)}
+	checks := 0
+	check := func() bool {
+		checks++
+		return true
+	}
+
+	// The permissions of each stream are only checked once while cached
+	require.True(t, c.get("tst/tst/s", check))
+	require.True(t, c.get("tst/tst/s", check))
+	require.Equal(t, 1, checks)
+	require.False(t, c.get("tst2/tst/s", func() bool { return false }))
+	require.False(t, c.get("tst2/tst/s", check))
+	require.Equal(t, 1, checks)
+
+	// Expired entries are checked again
+	c.entries["tst/tst/s"] = accessCacheEntry{true, time.Now()}
+	require.True(t, c.get("tst/tst/s", check))
+	require.Equal(t, 2, checks)
+}
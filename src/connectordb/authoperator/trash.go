@@ -0,0 +1,54 @@
+package authoperator
+
+import (
+	"connectordb/authoperator/permissions"
+	"connectordb/users"
+	"strings"
+)
+
+// canRestore returns whether the operator would be allowed to delete the item if it were not in the trash.
+// Whoever can delete something can also take it back out of the trash.
+func (a *AuthOperator) canRestore(item *users.TrashItem) bool {
+	// Users are never self, and neither are devices in the trash. A stream is self if it belongs to the operator's device.
+	_, _, _, ua, da, err := a.getAccessLevels(item.UserID, item.Public, item.Type == "stream" && item.DeviceID == a.deviceID)
+	if err != nil {
+		return false
+	}
+	switch item.Type {
+	case "user":
+		return ua.CanDeleteUser && da.CanDeleteUser
+	case "device":
+		return ua.CanDeleteDevice && da.CanDeleteDevice
+	default:
+		return ua.CanDeleteStream && da.CanDeleteStream
+	}
+}
+
+// ReadTrash lists the things in the user's trash that the operator is allowed to restore
+func (a *AuthOperator) ReadTrash(username string) ([]*users.TrashItem, error) {
+	items, err := a.Operator.ReadTrash(username)
+	if err != nil {
+		return nil, permissions.ErrNoAccess
+	}
+	result := make([]*users.TrashItem, 0, len(items))
+	for _, item := range items {
+		if a.canRestore(item) {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+// RestoreTrash restores the given path from the trash, if the operator is allowed to
+func (a *AuthOperator) RestoreTrash(path string) error {
+	items, err := a.ReadTrash(strings.Split(path, "/")[0])
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if item.Path == path {
+			return a.Operator.RestoreTrash(path)
+		}
+	}
+	return permissions.ErrNoAccess
+}
@@ -0,0 +1,109 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package connectordb
+
+import (
+	pconfig "config/permissions"
+	"connectordb/authoperator/permissions"
+	"connectordb/users"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// CloneStreamByID creates a copy of the stream with the given name on the given device, and copies the stream's
+// data into it, along with the schema history describing the data. If t1 or t2 are nonzero, only the data in the
+// time range is copied. Only the stream itself is copied - its substreams, such as the downlink, are not.
+func (db *Database) CloneStreamByID(streamID int64, deviceID int64, name string, t1 float64, t2 float64) error {
+	s, err := db.ReadStreamByID(streamID)
+	if err != nil {
+		return err
+	}
+
+	clone := *s
+	clone.Name = name
+	clone.DeviceID = deviceID
+	if err = db.CreateStreamByDeviceID(&users.StreamMaker{Stream: clone}); err != nil {
+		return err
+	}
+	if s.Ephemeral {
+		// Ephemeral streams don't hold any data
+		return nil
+	}
+	c, err := db.ReadStreamByDeviceID(deviceID, name)
+	if err != nil {
+		return err
+	}
+
+	if err = db.cloneData(s, c, t1, t2); err != nil {
+		// Don't leave behind a partial copy
+		if err2 := db.Userdb.DeleteStream(c.StreamID); err2 != nil {
+			log.Errorf("Failed to delete partial clone %d of stream %d: %s", c.StreamID, streamID, err2.Error())
+		} else {
+			db.DataStream.DeleteStream(c.DeviceID, c.StreamID)
+		}
+	}
+	return err
+}
+
+// cloneData copies the data of stream s in the given time range into stream c
+func (db *Database) cloneData(s *users.Stream, c *users.Stream, t1 float64, t2 float64) error {
+	dev, err := db.ReadDeviceByID(c.DeviceID)
+	if err != nil {
+		return err
+	}
+	u, err := db.ReadUserByID(dev.UserID)
+	if err != nil {
+		return err
+	}
+	r := permissions.GetUserRole(pconfig.Get(), u)
+
+	dr, err := db.DataStream.TRange(s.DeviceID, s.StreamID, "", t1, t2)
+	if err != nil {
+		return err
+	}
+	defer dr.Close()
+
+	// The range has not been read yet, so its index is the index of the first copied datapoint
+	if err = db.cloneSchemas(s, c, dr.Index()); err != nil {
+		return err
+	}
+
+	// The data is copied one chunk at a time, so that large streams are not held in memory
+	for {
+		dpa, err := dr.NextArray()
+		if err != nil || dpa == nil {
+			return err
+		}
+		if len(*dpa) == 0 {
+			continue
+		}
+		if _, err = db.DataStream.Insert(c.DeviceID, c.StreamID, "", *dpa, false, r.MaxDeviceSize, r.MaxStreamSize); err != nil {
+			return err
+		}
+	}
+}
+
+// cloneSchemas copies the schema versions of stream s to its copy c, whose data starts at the given index of s.
+// Without them, the copied data written under older versions would not be upgraded when read.
+func (db *Database) cloneSchemas(s *users.Stream, c *users.Stream, start int64) error {
+	if s.SchemaVersion == 0 {
+		return nil
+	}
+	versions, err := db.ReadStreamSchemasByID(s.StreamID)
+	if err != nil {
+		return err
+	}
+	for _, v := range versions {
+		v.StreamID = c.StreamID
+		v.StartIndex -= start
+		if v.StartIndex < 0 {
+			v.StartIndex = 0
+		}
+		// The downlink is not copied
+		v.DownlinkIndex = 0
+	}
+	c.SchemaVersion = s.SchemaVersion
+	return db.Userdb.UpdateStreamSchema(c, versions...)
+}
@@ -0,0 +1,180 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package connectordb
+
+import (
+	"connectordb/datastream"
+	"connectordb/users"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/nats-io/nats"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+var (
+	// ErrNotDownlink is returned when sending a command to a stream which is not a downlink
+	ErrNotDownlink = errors.New("Commands can only be sent to downlink streams")
+
+	// ErrCommandFinished is returned when updating a command which is done, failed or expired
+	ErrCommandFinished = users.ErrCommandFinished
+
+	// ErrCommandStatus is returned when a command is updated with an invalid status
+	ErrCommandStatus = errors.New("The command status must be one of delivered, done or failed")
+)
+
+func now() float64 {
+	return float64(time.Now().UnixNano()) * 1e-9
+}
+
+// expireCommand marks the command as expired if its expiry time has passed
+func (db *Database) expireCommand(c *users.Command) error {
+	if c.IsFinished() || c.Expires <= 0 || c.Expires > now() {
+		return nil
+	}
+	c.Status = users.CommandExpired
+	c.Updated = c.Expires
+	err := db.Userdb.UpdateCommand(c)
+	if err == users.ErrCommandFinished {
+		// The command was finished before it could be expired, so return its status
+		var cmd *users.Command
+		if cmd, err = db.Userdb.ReadCommandByID(c.CommandID); err == nil {
+			*c = *cmd
+		}
+	}
+	return err
+}
+
+// CreateCommandByID queues the command on the given downlink stream, and publishes it to devices subscribed
+// to the stream's commands. The command's data must conform to the stream's schema.
+func (db *Database) CreateCommandByID(streamID int64, c *users.Command) error {
+	strm, err := db.ReadStreamByID(streamID)
+	if err != nil {
+		return err
+	}
+	if !strm.Downlink {
+		return ErrNotDownlink
+	}
+
+	var data interface{}
+	if err = json.Unmarshal(c.Data, &data); err != nil {
+		return err
+	}
+	if !strm.Validate(datastream.DatapointArray{datastream.Datapoint{Data: data}}) {
+		return datastream.ErrInvalidDatapoint
+	}
+
+	_, _, streampath, err := db.getStreamPath(strm)
+	if err != nil {
+		return err
+	}
+
+	c.StreamID = streamID
+	c.Status = users.CommandPending
+	c.Result = nil
+	c.Timestamp = now()
+	c.Updated = c.Timestamp
+	if err = db.Userdb.CreateCommand(c); err != nil {
+		return err
+	}
+	return db.Messenger.PublishCommand(streampath, c)
+}
+
+// ReadCommandByID returns the given command
+func (db *Database) ReadCommandByID(commandID int64) (*users.Command, error) {
+	c, err := db.Userdb.ReadCommandByID(commandID)
+	if err != nil {
+		return nil, err
+	}
+	return c, db.expireCommand(c)
+}
+
+// ReadCommandsByID returns the commands queued on the stream. If status is not empty,
+// only commands with the given status are returned.
+func (db *Database) ReadCommandsByID(streamID int64, status string) ([]*users.Command, error) {
+	// Commands are expired when they are read, so expired commands might still be stored as pending or delivered
+	qstatus := status
+	if status == users.CommandExpired {
+		qstatus = ""
+	}
+	cmds, err := db.Userdb.ReadCommands(streamID, qstatus)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*users.Command, 0, len(cmds))
+	for _, c := range cmds {
+		if err = db.expireCommand(c); err != nil {
+			return nil, err
+		}
+		if status == "" || c.Status == status {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
+
+// UpdateCommandByID sets the status of the command, along with an optional JSON encoded result.
+// Devices set the status to delivered when they receive the command, and to done or failed once it ran.
+func (db *Database) UpdateCommandByID(commandID int64, status string, result string) error {
+	if status != users.CommandDelivered && status != users.CommandDone && status != users.CommandFailed {
+		return ErrCommandStatus
+	}
+	if result != "" {
+		var v interface{}
+		if err := json.Unmarshal([]byte(result), &v); err != nil {
+			return err
+		}
+	}
+	c, err := db.ReadCommandByID(commandID)
+	if err != nil {
+		return err
+	}
+	if c.IsFinished() {
+		return ErrCommandFinished
+	}
+	c.Status = status
+	if result != "" {
+		c.Result = json.RawMessage(result)
+	}
+	c.Updated = now()
+
+	// The update only succeeds if the command is not finished in the meantime
+	return db.Userdb.UpdateCommand(c)
+}
+
+// PurgeCommands deletes the commands which finished or expired longer than the command retention period ago
+func (db *Database) PurgeCommands() error {
+	if db.commandRetention <= 0 {
+		return nil
+	}
+	n, err := db.Userdb.DeleteCommandsBefore(now() - db.commandRetention)
+	if n > 0 {
+		log.Infof("Purged %d finished commands", n)
+	}
+	return err
+}
+
+// SubscribeCommandsByID subscribes to the commands sent to the given stream
+func (db *Database) SubscribeCommandsByID(streamID int64, chn chan users.Command) (*nats.Subscription, error) {
+	strm, err := db.ReadStreamByID(streamID)
+	if err != nil {
+		return nil, err
+	}
+	_, _, streampath, err := db.getStreamPath(strm)
+	if err != nil {
+		return nil, err
+	}
+	return db.Messenger.SubscribeCommands(streampath, func(c *users.Command) {
+		// Commands are dropped if the channel is full, so that a slow subscriber can't block the delivery of commands.
+		// Dropped commands stay queued on the stream.
+		select {
+		case chn <- *c:
+		default:
+			log.Warnf("Dropped command %d of %s: the subscriber is not reading commands", c.CommandID, streampath)
+		}
+	})
+}
@@ -0,0 +1,87 @@
+package connectordb
+
+import (
+	"connectordb/users"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand(t *testing.T) {
+	Tdb.Clear()
+	db := Tdb
+
+	require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: "myuser", Email: "email@email", Password: "test", Role: "user", Public: true}}))
+	require.NoError(t, db.CreateDevice("myuser/mydevice", &users.DeviceMaker{}))
+	require.NoError(t, db.CreateStream("myuser/mydevice/uplink", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"number"}`}}))
+	require.NoError(t, db.CreateStream("myuser/mydevice/relay", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"boolean"}`, Downlink: true}}))
+
+	require.Equal(t, ErrNotDownlink, db.CreateCommand("myuser/mydevice/uplink", &users.Command{Data: json.RawMessage("true")}))
+	require.Error(t, db.CreateCommand("myuser/mydevice/relay", &users.Command{Data: json.RawMessage("12")}))
+
+	recvchan := make(chan users.Command, 2)
+	_, err := db.SubscribeCommands("myuser/mydevice/relay", recvchan)
+	require.NoError(t, err)
+	db.Messenger.Flush()
+
+	c := &users.Command{Data: json.RawMessage("true")}
+	require.NoError(t, db.CreateCommand("myuser/mydevice/relay", c))
+	require.Equal(t, users.CommandPending, c.Status)
+
+	select {
+	case m := <-recvchan:
+		require.Equal(t, c.CommandID, m.CommandID)
+		require.Equal(t, "true", string(m.Data))
+	case <-time.After(2 * time.Second):
+		t.Fatal("Command subscription timed out")
+	}
+
+	cmds, err := db.ReadCommands("myuser/mydevice/relay", users.CommandPending)
+	require.NoError(t, err)
+	require.Len(t, cmds, 1)
+
+	require.Equal(t, ErrCommandStatus, db.UpdateCommand("myuser/mydevice/relay", c.CommandID, users.CommandExpired, ""))
+	require.NoError(t, db.UpdateCommand("myuser/mydevice/relay", c.CommandID, users.CommandDelivered, ""))
+	require.NoError(t, db.UpdateCommand("myuser/mydevice/relay", c.CommandID, users.CommandDone, `{"ok": true}`))
+	require.Equal(t, ErrCommandFinished, db.UpdateCommand("myuser/mydevice/relay", c.CommandID, users.CommandFailed, ""))
+
+	c, err = db.ReadCommand("myuser/mydevice/relay", c.CommandID)
+	require.NoError(t, err)
+	require.Equal(t, users.CommandDone, c.Status)
+	require.Equal(t, `{"ok": true}`, string(c.Result))
+
+	// A command finished while it was being updated keeps its status
+	c.Status = users.CommandFailed
+	require.Equal(t, ErrCommandFinished, db.Userdb.UpdateCommand(c))
+	c, err = db.ReadCommand("myuser/mydevice/relay", c.CommandID)
+	require.NoError(t, err)
+	require.Equal(t, users.CommandDone, c.Status)
+
+	_, err = db.ReadCommand("myuser/mydevice/uplink", c.CommandID)
+	require.Equal(t, users.ErrCommandNotFound, err)
+
+	// Commands which are not done by their expiry time are expired
+	c = &users.Command{Data: json.RawMessage("false"), Expires: 1}
+	require.NoError(t, db.CreateCommand("myuser/mydevice/relay", c))
+	cmds, err = db.ReadCommands("myuser/mydevice/relay", users.CommandExpired)
+	require.NoError(t, err)
+	require.Len(t, cmds, 1)
+	require.Equal(t, c.CommandID, cmds[0].CommandID)
+	require.Equal(t, ErrCommandFinished, db.UpdateCommand("myuser/mydevice/relay", c.CommandID, users.CommandDone, ""))
+
+	cmds, err = db.ReadCommands("myuser/mydevice/relay", "")
+	require.NoError(t, err)
+	require.Len(t, cmds, 2)
+
+	// Only the commands which finished longer than the retention period ago are purged
+	retention := db.commandRetention
+	defer func() { db.commandRetention = retention }()
+	db.commandRetention = 60
+	require.NoError(t, db.PurgeCommands())
+	cmds, err = db.ReadCommands("myuser/mydevice/relay", "")
+	require.NoError(t, err)
+	require.Len(t, cmds, 1)
+	require.Equal(t, users.CommandDone, cmds[0].Status)
+}
@@ -42,6 +42,13 @@ type Database struct {
 	Messenger  *messenger.Messenger   //messenger is a connection to the messaging client
 
 	Sqldb *sqlx.DB //We only need the sql object here to close it properly, since it is used everywhere.
+
+	presence presence // presence tracks which devices are online
+	trash    trash    // trash holds deleted users, devices and streams until they are purged
+
+	commandRetention float64 // The number of seconds for which finished commands are kept. 0 keeps them forever.
+
+	admin MetaLog // The admin operator, which publishes the changes made through it
 }
 
 // Open ConnectorDB is given an Options object, which holds the information necessary to connect to the database
@@ -69,6 +76,7 @@ func Open(opt *config.Options) (dbp *Database, err error) {
 	if err != nil {
 		return nil, err
 	}
+	db.admin = adminMetaLog(&db)
 
 	log.Debugf("Opening Redis cache")
 	rc, err := rediscache.NewRedisConnection(&opt.RedisOptions)
@@ -86,6 +94,23 @@ func Open(opt *config.Options) (dbp *Database, err error) {
 		return nil, err
 	}
 
+	db.presence.timeout = float64(opt.PresenceTimeout)
+	db.presence.log = opt.PresenceLog
+	db.presence.seen = make(map[int64]float64)
+	db.presence.saved = make(map[int64]float64)
+	if opt.PresenceTimeout > 0 {
+		db.presence.done = make(chan struct{})
+		go db.runPresence(db.presence.done)
+	}
+
+	db.trash.period = float64(opt.TrashPeriod)
+	db.commandRetention = float64(opt.CommandRetention)
+	db.trash.done = make(chan struct{})
+	go db.runTrash(db.trash.done)
+
+	db.DataStream.InsertKeyWindow = time.Duration(opt.InsertKeyWindow) * time.Second
+	db.DataStream.OnShift = users.ShiftStreamSchemaIndices
+
 	// Close the database when the system exits just in case it isn't.
 	util.CloseOnExit(&db)
 
@@ -96,6 +121,14 @@ func Open(opt *config.Options) (dbp *Database, err error) {
 //Close closes all database connections and releases all resources.
 //A word of warning though: If RunWriter() is functional, then RunWriter will crash
 func (db *Database) Close() {
+	if db.presence.done != nil {
+		close(db.presence.done)
+		db.presence.done = nil
+	}
+	if db.trash.done != nil {
+		close(db.trash.done)
+		db.trash.done = nil
+	}
 	if db.DataStream != nil {
 		db.DataStream.Close()
 	}
@@ -141,6 +174,11 @@ func (db *Database) RunWriter() {
 func (db *Database) Clear() {
 	db.DataStream.Clear()
 	db.Userdb.Clear()
+
+	db.presence.Lock()
+	db.presence.seen = make(map[int64]float64)
+	db.presence.saved = make(map[int64]float64)
+	db.presence.Unlock()
 }
 
 // Name is the "Name" of the database. It is needed to conform to the Operator interface
@@ -158,7 +196,7 @@ func (db *Database) Device() (*users.Device, error) {
 	return nil, ErrAdmin
 }
 
-// AdminOperator is just the database
+// AdminOperator is the database, with the changes made through it published as metadata events
 func (db *Database) AdminOperator() operator.PathOperator {
-	return db
+	return db.admin
 }
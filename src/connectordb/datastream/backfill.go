@@ -0,0 +1,251 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package datastream
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	//ErrBackfillConflict is returned when the stream is already being backfilled, or its data is being written
+	ErrBackfillConflict = errors.New("The stream changed during the backfill. Insert Failed.")
+)
+
+//BackfillRetries is the number of times a backfill is attempted before giving up when the stream
+//keeps being backfilled or written to the database by others
+const BackfillRetries = 5
+
+//Backfill inserts the given datapoints into the stream at their correct time positions, even if the stream already
+//has datapoints with greater timestamps. Datapoints which already exist come before inserted datapoints
+//with the same timestamp. Since inserting into the middle of a stream shifts the indices of all later datapoints,
+//the returned boolean is true if any existing datapoints were moved. If all of the datapoints are newer than the
+//stream's data, this is just an Insert.
+func (ds *DataStream) Backfill(deviceID, streamID int64, substream string, dpa DatapointArray, maxDeviceSize, maxStreamSize int64) (l int64, shifted bool, err error) {
+	return ds.BackfillOnce("", deviceID, streamID, substream, dpa, maxDeviceSize, maxStreamSize)
+}
+
+//BackfillOnce is Backfill with an idempotency key, which works just like the key in InsertOnce
+func (ds *DataStream) BackfillOnce(key string, deviceID, streamID int64, substream string, dpa DatapointArray, maxDeviceSize, maxStreamSize int64) (l int64, shifted bool, err error) {
+	if !dpa.IsTimestampOrdered() {
+		return 0, false, ErrTimestampOrder
+	}
+	if len(dpa) == 0 {
+		l, err = ds.StreamLength(deviceID, streamID, substream)
+		return l, false, err
+	}
+	for i := 0; i < BackfillRetries; i++ {
+		l, shifted, err = ds.backfill(key, deviceID, streamID, substream, dpa, maxDeviceSize, maxStreamSize)
+		if err != ErrBackfillConflict {
+			return l, shifted, err
+		}
+		log.Debugf("Backfill of %d/%s conflicted with a concurrent write. Retrying.", streamID, substream)
+		time.Sleep(time.Duration(10*(i+1)) * time.Millisecond)
+	}
+	return l, shifted, err
+}
+
+//backfill makes one attempt at a backfill. The stream's cache is locked, so that none of its batches are written while
+//the backfill is in progress. The datapoints which belong before the cached data are then merged into the batches
+//in the sql store, rewriting them in place, and the rest are merged into the cache once that transaction was committed.
+func (ds *DataStream) backfill(key string, deviceID, streamID int64, substream string, dpa DatapointArray, maxDeviceSize, maxStreamSize int64) (int64, bool, error) {
+	length, err := ds.cache.StreamLength(deviceID, streamID, substream)
+	if err != nil {
+		return 0, false, err
+	}
+
+	// If the data comes after the stream's last datapoint, there is nothing to backfill
+	if length == 0 {
+		l, err := ds.insert(key, deviceID, streamID, substream, dpa, false, maxDeviceSize, maxStreamSize)
+		return l, false, err
+	}
+	dr, err := ds.IRange(deviceID, streamID, substream, length-1, length)
+	if err != nil {
+		return 0, false, err
+	}
+	last, err := dr.Next()
+	dr.Close()
+	if err != nil {
+		return 0, false, err
+	}
+	if last == nil {
+		return 0, false, ErrBackfillConflict
+	}
+	if dpa[0].Timestamp >= last.Timestamp {
+		l, err := ds.insert(key, deviceID, streamID, substream, dpa, false, maxDeviceSize, maxStreamSize)
+		return l, false, err
+	}
+
+	if ds.InsertKeyWindow <= 0 {
+		key = ""
+	}
+	cachestart, err := ds.cache.StartBackfill(deviceID, streamID, substream, key, dpa, maxDeviceSize, maxStreamSize)
+	if err != nil {
+		return 0, false, err
+	}
+
+	tx, err := ds.sqls.db.Beginx()
+	if err != nil {
+		ds.abortBackfill(deviceID, streamID, substream)
+		return 0, false, err
+	}
+	written, err := ds.backfillSql(tx, deviceID, streamID, substream, cachestart, dpa)
+	if err == nil {
+		err = tx.Commit()
+	} else {
+		tx.Rollback()
+	}
+	if err != nil {
+		ds.abortBackfill(deviceID, streamID, substream)
+		return 0, false, err
+	}
+
+	// The database was changed, so the cache must follow
+	l, err := ds.cache.FinishBackfill(deviceID, streamID, substream, key, ds.InsertKeyWindow, written, dpa)
+	if err != nil {
+		log.Errorf("Backfill of %d/%s was written to the database, but not to the cache: %s", streamID, substream, err.Error())
+		return 0, false, err
+	}
+	return l, true, nil
+}
+
+//abortBackfill unlocks the cache after a backfill failed before changing any data
+func (ds *DataStream) abortBackfill(deviceID, streamID int64, substream string) {
+	if err := ds.cache.AbortBackfill(deviceID, streamID, substream); err != nil {
+		log.Errorf("Failed to abort backfill of %d/%s: %s", streamID, substream, err.Error())
+	}
+}
+
+//backfillSql merges the datapoints which come before the stream's cached data into the batches in the sql store,
+//and shifts the stream's indices held elsewhere in the database. It returns the number of datapoints written.
+//The batches are rewritten one at a time from the last one which gets datapoints backwards, so that only one batch is held in memory and
+//the new end indices never collide with a batch that was not yet moved.
+func (ds *DataStream) backfillSql(tx *sqlx.Tx, deviceID, streamID int64, substream string, cachestart int64, dpa DatapointArray) (int, error) {
+	endindex, batch, err := ds.sqls.batchBefore(tx, streamID, substream, math.MaxInt64)
+	if err != nil {
+		return 0, err
+	}
+	// The stream's batches can't be written while it is locked, so the database must end where the cache starts
+	if endindex != cachestart {
+		return 0, ErrBackfillConflict
+	}
+
+	written := 0
+	if batch != nil {
+		cached, _, _, err := ds.cache.ReadRange(deviceID, streamID, substream, cachestart, cachestart+1)
+		if err != nil {
+			return 0, err
+		}
+		if len(cached) > 0 {
+			written = countBefore(dpa, cached[0].Timestamp)
+		} else {
+			written = countBefore(dpa, batch[len(batch)-1].Timestamp)
+		}
+	}
+
+	// The indices are shifted before rewriting, since the shift is computed from the existing data
+	if ds.OnShift != nil {
+		err = ds.OnShift(tx, streamID, substream, func(index int64) (int64, error) {
+			t, ok, err := ds.timestampAt(tx, deviceID, streamID, substream, cachestart, index)
+			if err != nil || !ok {
+				return int64(len(dpa)), err
+			}
+			return int64(countBefore(dpa, t)), nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// The batches after the last one which gets datapoints are only moved, which is done for all of them at once,
+	// so that backfilling old data does not rewrite the whole stream after it
+	if written > 0 {
+		e, b, err := ds.sqls.batchAfterTime(tx, streamID, substream, dpa[written-1].Timestamp)
+		if err != nil {
+			return 0, err
+		}
+		if b != nil && e < endindex {
+			if err = ds.sqls.shiftBatches(tx, streamID, substream, e, int64(written)); err != nil {
+				return 0, err
+			}
+			endindex, batch = e, b
+		}
+	}
+
+	through := written
+	for through > 0 {
+		startindex := endindex - int64(len(batch))
+		from := 0
+		if startindex > 0 {
+			from = countBefore(dpa[:through], batch[0].Timestamp)
+		}
+		err = ds.sqls.rewriteBatch(tx, streamID, substream, endindex, endindex+int64(through), len(batch), mergeDatapoints(batch, dpa[from:through]))
+		if err != nil || from == 0 {
+			return written, err
+		}
+		through = from
+
+		if endindex, batch, err = ds.sqls.batchBefore(tx, streamID, substream, startindex); err != nil {
+			return 0, err
+		}
+		if endindex != startindex {
+			return 0, ErrorDatabaseCorrupted
+		}
+	}
+	return written, nil
+}
+
+//timestampAt returns the timestamp of the datapoint at the given index, reading the database within the transaction.
+//The boolean is false if the index is past the end of the stream.
+func (ds *DataStream) timestampAt(tx *sqlx.Tx, deviceID, streamID int64, substream string, cachestart, index int64) (float64, bool, error) {
+	if index >= cachestart {
+		length, err := ds.cache.StreamLength(deviceID, streamID, substream)
+		if err != nil || index >= length {
+			return 0, false, err
+		}
+		dpa, _, _, err := ds.cache.ReadRange(deviceID, streamID, substream, index, index+1)
+		if err != nil || len(dpa) == 0 {
+			return 0, false, err
+		}
+		return dpa[0].Timestamp, true, nil
+	}
+	endindex, batch, err := ds.sqls.batchAt(tx, streamID, substream, index)
+	if err != nil {
+		return 0, false, err
+	}
+	if batch == nil || endindex-index > int64(len(batch)) {
+		return 0, false, ErrorDatabaseCorrupted
+	}
+	return batch[int64(len(batch))-(endindex-index)].Timestamp, true, nil
+}
+
+//countBefore returns the number of datapoints in the timestamp-ordered array which are older than the given timestamp
+func countBefore(dpa DatapointArray, timestamp float64) int {
+	return sort.Search(len(dpa), func(i int) bool {
+		return dpa[i].Timestamp >= timestamp
+	})
+}
+
+//mergeDatapoints merges two timestamp-ordered arrays. On equal timestamps, the datapoints of the first array come first.
+func mergeDatapoints(a, b DatapointArray) DatapointArray {
+	result := make(DatapointArray, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if b[j].Timestamp < a[i].Timestamp {
+			result = append(result, b[j])
+			j++
+		} else {
+			result = append(result, a[i])
+			i++
+		}
+	}
+	result = append(result, a[i:]...)
+	return append(result, b[j:]...)
+}
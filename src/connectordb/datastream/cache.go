@@ -4,6 +4,8 @@ Licensed under the MIT license.
 **/
 package datastream
 
+import "time"
+
 //Cache is an interface that caches datapoints for all the streams until there are enoguh in memory to form a batch
 //of data
 type Cache interface {
@@ -11,9 +13,16 @@ type Cache interface {
 	DeviceSize(deviceID int64) (int64, error)
 	StreamSize(deviceID, streamID int64, substream string) (int64, error)
 	Insert(deviceID, streamID int64, substream string, dpa DatapointArray, restamp bool, maxDeviceSize int64, maxStreamSize int64) (int64, error)
+	InsertOnce(deviceID, streamID int64, substream, key string, window time.Duration, dpa DatapointArray, restamp bool, maxDeviceSize int64, maxStreamSize int64) (int64, error)
+	StartBackfill(deviceID, streamID int64, substream, key string, dpa DatapointArray, maxDeviceSize, maxStreamSize int64) (int64, error)
+	FinishBackfill(deviceID, streamID int64, substream, key string, window time.Duration, written int, dpa DatapointArray) (int64, error)
+	AbortBackfill(deviceID, streamID int64, substream string) error
+	LockStream(deviceID, streamID int64, substream string) (int64, error)
+	UnlockStream(deviceID, streamID int64, substream string) error
 	DeleteDevice(deviceID int64) error
 	DeleteStream(deviceID, streamID int64) error
 	DeleteSubstream(deviceID, streamID int64, substream string) error
+	MoveStream(deviceID, streamID, newDeviceID int64) error
 	ReadProcessingQueue() ([]Batch, error)
 	ReadBatches(batchnumber int) ([]Batch, error)
 	ReadRange(deviceID, streamID int64, substream string, i1, i2 int64) (DatapointArray, int64, int64, error)
@@ -9,6 +9,7 @@ import (
 	"compress/gzip"
 	"util"
 	"errors"
+	"sort"
 	"time"
 
 	"github.com/xeipuuv/gojsonschema"
@@ -200,6 +201,17 @@ func (dpa DatapointArray) IsTimestampOrdered() bool {
 	return true
 }
 
+//SortByTime sorts the DatapointArray by increasing timestamp. Datapoints with equal timestamps keep their order.
+func (dpa DatapointArray) SortByTime() {
+	sort.Stable(timeSorter(dpa))
+}
+
+type timeSorter DatapointArray
+
+func (s timeSorter) Len() int           { return len(s) }
+func (s timeSorter) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s timeSorter) Less(i, j int) bool { return s[i].Timestamp < s[j].Timestamp }
+
 //SetZeroTime replaces 0 times with current timestamp
 func (dpa DatapointArray) SetZeroTime() {
 	ts := float64(time.Now().UnixNano()) * 1e-9
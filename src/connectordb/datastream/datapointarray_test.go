@@ -166,6 +166,16 @@ func TestTimestampOrdered(t *testing.T) {
 	require.False(t, dpa8.IsTimestampOrdered())
 }
 
+func TestSortByTime(t *testing.T) {
+	dpa := DatapointArray{Datapoint{2.0, "a", ""}, Datapoint{1.0, "b", ""}, Datapoint{2.0, "c", ""}, Datapoint{0.5, "d", ""}}
+	dpa.SortByTime()
+	require.True(t, dpa.IsTimestampOrdered())
+	require.Equal(t, "d", dpa[0].Data)
+	require.Equal(t, "b", dpa[1].Data)
+	require.Equal(t, "a", dpa[2].Data)
+	require.Equal(t, "c", dpa[3].Data)
+}
+
 func TestTimeIndex(t *testing.T) {
 	require.Equal(t, DatapointArray{}.FindTimeIndex(1.0), -1)
 	require.Equal(t, -1, dpa7.FindTimeIndex(20.0))
@@ -7,6 +7,7 @@ package datastream
 import (
 	"errors"
 	"strings"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/jmoiron/sqlx"
@@ -15,8 +16,18 @@ import (
 var (
 	//ErrTimestampOrder is thrown when out of order tiemstamps are detected
 	ErrTimestampOrder = errors.New("The datapoints must be ordered by increasing timestamp")
+
+	//ErrDuplicateInsert is returned when an insert repeats the idempotency key of an earlier insert
+	ErrDuplicateInsert = errors.New("An insert with the given key was already performed")
+
+	//ErrStreamLocked is returned by inserts into a stream whose schema is being changed. The data must be validated
+	//against the new schema before it is inserted again.
+	ErrStreamLocked = errors.New("The stream's schema is being changed. Insert Failed.")
 )
 
+//DefaultInsertKeyWindow is the default duration for which the idempotency keys of inserts are remembered
+const DefaultInsertKeyWindow = 24 * time.Hour
+
 //DataStream is how the database extracts data from a stream. It is the main object in datastream
 type DataStream struct {
 	cache Cache
@@ -24,15 +35,26 @@ type DataStream struct {
 
 	//ChunkSize is the number of batches to write to postgres in one transaction.
 	ChunkSize int
+
+	//InsertKeyWindow is the duration for which the idempotency keys of inserts are remembered. 0 disables the keys.
+	InsertKeyWindow time.Duration
+
+	//OnShift is run by backfills which move existing datapoints, if set
+	OnShift ShiftHook
 }
 
+//ShiftHook is run within the transaction in which a backfill rewrites the stream's data, so that indices of the stream
+//which are stored elsewhere in the database can be moved along with its datapoints. The shift function returns
+//the number of backfilled datapoints inserted before the datapoint which was at the given index.
+type ShiftHook func(tx *sqlx.Tx, streamID int64, substream string, shift func(index int64) (int64, error)) error
+
 //OpenDataStream does just that - it opens the DataStream
 func OpenDataStream(c Cache, sd *sqlx.DB, chunksize int) (ds *DataStream, err error) {
 	sqls, err := OpenSqlStore(sd)
 	if err != nil {
 		return nil, err
 	}
-	return &DataStream{c, sqls, chunksize}, nil
+	return &DataStream{c, sqls, chunksize, DefaultInsertKeyWindow, nil}, nil
 }
 
 //Close releases all resources held by the DataStream. It does NOT close open ExtendedDataRanges
@@ -61,6 +83,44 @@ func (ds *DataStream) DeleteStream(deviceID, streamID int64) error {
 	return ds.sqls.DeleteStream(streamID)
 }
 
+//MoveStream moves a stream to another device. Only the cache is keyed by device, so the sql store is unchanged.
+func (ds *DataStream) MoveStream(deviceID, streamID, newDeviceID int64) error {
+	if deviceID == newDeviceID {
+		return nil
+	}
+	return ds.cache.MoveStream(deviceID, streamID, newDeviceID)
+}
+
+//LockStream runs fn while the given substreams of the stream are locked, giving it their lengths. While a substream
+//is locked, no datapoints can be inserted or backfilled into it, so that data about the stream's indices can be
+//written elsewhere in the database without the indices changing in the meantime.
+func (ds *DataStream) LockStream(deviceID, streamID int64, substreams []string, fn func(lengths []int64) error) error {
+	lengths := make([]int64, 0, len(substreams))
+	defer func() {
+		for i := range lengths {
+			if err := ds.cache.UnlockStream(deviceID, streamID, substreams[i]); err != nil {
+				log.Errorf("Failed to unlock %d/%s: %s", streamID, substreams[i], err.Error())
+			}
+		}
+	}()
+	for _, substream := range substreams {
+		var l int64
+		var err error
+		// The substream might be in the middle of a backfill, which is waited for
+		for i := 0; i < BackfillRetries; i++ {
+			if l, err = ds.cache.LockStream(deviceID, streamID, substream); err != ErrBackfillConflict {
+				break
+			}
+			time.Sleep(time.Duration(10*(i+1)) * time.Millisecond)
+		}
+		if err != nil {
+			return err
+		}
+		lengths = append(lengths, l)
+	}
+	return fn(lengths)
+}
+
 //DeleteSubstream deletes the substream from the database
 func (ds *DataStream) DeleteSubstream(deviceID, streamID int64, substream string) error {
 	err := ds.cache.DeleteSubstream(deviceID, streamID, substream)
@@ -84,6 +144,25 @@ func (ds *DataStream) Insert(deviceID, streamID int64, substream string, dpa Dat
 	return ds.cache.Insert(deviceID, streamID, substream, dpa, restamp, maxDeviceSize, maxStreamSize)
 }
 
+//InsertOnce is Insert with an idempotency key. If an insert with the same key was made into the substream within
+//InsertKeyWindow, nothing is written and ErrDuplicateInsert is returned, so that clients can safely retry inserts.
+//The key is remembered along with the written data, so a failed insert does not use it up.
+//An empty key always inserts.
+func (ds *DataStream) InsertOnce(key string, deviceID, streamID int64, substream string, dpa DatapointArray, restamp bool, maxDeviceSize, maxStreamSize int64) (int64, error) {
+	if !dpa.IsTimestampOrdered() {
+		return 0, ErrTimestampOrder
+	}
+	return ds.insert(key, deviceID, streamID, substream, dpa, restamp, maxDeviceSize, maxStreamSize)
+}
+
+//insert writes the data to the cache, along with its idempotency key if keys are enabled
+func (ds *DataStream) insert(key string, deviceID, streamID int64, substream string, dpa DatapointArray, restamp bool, maxDeviceSize, maxStreamSize int64) (int64, error) {
+	if key == "" || ds.InsertKeyWindow <= 0 {
+		return ds.cache.Insert(deviceID, streamID, substream, dpa, restamp, maxDeviceSize, maxStreamSize)
+	}
+	return ds.cache.InsertOnce(deviceID, streamID, substream, key, ds.InsertKeyWindow, dpa, restamp, maxDeviceSize, maxStreamSize)
+}
+
 //WriteChunk takes a chunk of batches and writes it to the sql store
 func (ds *DataStream) WriteChunk() error {
 	b, err := ds.cache.ReadBatches(ds.ChunkSize)
@@ -8,6 +8,7 @@ import (
 	"dbsetup/dbutil"
 	"os"
 	"testing"
+	"time"
 
 	"config"
 
@@ -59,6 +60,10 @@ func (m *MockCache) DeleteSubstream(deviceID, streamID int64, substream string)
 	args := m.Called(deviceID, streamID, substream)
 	return args.Error(0)
 }
+func (m *MockCache) MoveStream(deviceID, streamID, newDeviceID int64) error {
+	args := m.Called(deviceID, streamID, newDeviceID)
+	return args.Error(0)
+}
 func (m *MockCache) ReadProcessingQueue() ([]Batch, error) {
 	args := m.Called()
 	return args.Get(0).([]Batch), args.Error(1)
@@ -75,6 +80,30 @@ func (m *MockCache) ClearBatches(b []Batch) error {
 	args := m.Called(b)
 	return args.Error(0)
 }
+func (m *MockCache) InsertOnce(deviceID, streamID int64, substream, key string, window time.Duration, dpa DatapointArray, restamp bool, maxDeviceSize int64, maxStreamSize int64) (int64, error) {
+	args := m.Called(deviceID, streamID, substream, key, window, dpa, restamp, maxDeviceSize, maxStreamSize)
+	return args.Get(0).(int64), args.Error(1)
+}
+func (m *MockCache) StartBackfill(deviceID, streamID int64, substream, key string, dpa DatapointArray, maxDeviceSize, maxStreamSize int64) (int64, error) {
+	args := m.Called(deviceID, streamID, substream, key, dpa, maxDeviceSize, maxStreamSize)
+	return args.Get(0).(int64), args.Error(1)
+}
+func (m *MockCache) FinishBackfill(deviceID, streamID int64, substream, key string, window time.Duration, written int, dpa DatapointArray) (int64, error) {
+	args := m.Called(deviceID, streamID, substream, key, window, written, dpa)
+	return args.Get(0).(int64), args.Error(1)
+}
+func (m *MockCache) AbortBackfill(deviceID, streamID int64, substream string) error {
+	args := m.Called(deviceID, streamID, substream)
+	return args.Error(0)
+}
+func (m *MockCache) LockStream(deviceID, streamID int64, substream string) (int64, error) {
+	args := m.Called(deviceID, streamID, substream)
+	return args.Get(0).(int64), args.Error(1)
+}
+func (m *MockCache) UnlockStream(deviceID, streamID int64, substream string) error {
+	args := m.Called(deviceID, streamID, substream)
+	return args.Error(0)
+}
 func (m *MockCache) Close() error {
 	return nil
 }
@@ -173,4 +202,9 @@ func TestBasics(t *testing.T) {
 
 	mc.On("DeleteDevice", int64(1)).Return(nil)
 	require.NoError(t, ds.DeleteDevice(1))
+
+	mc.On("MoveStream", int64(1), int64(2), int64(3)).Return(nil)
+	require.NoError(t, ds.MoveStream(1, 2, 3))
+	require.NoError(t, ds.MoveStream(3, 2, 3))
+	mc.AssertExpectations(t)
 }
@@ -0,0 +1,112 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package datastream
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+//InferSchema returns a JSON schema which accepts the data of all of the given datapoints. Numbers, strings and booleans
+//get their type, objects get the schemas of their properties, and arrays the schema of their items. If the datapoints
+//have data of different types, the schema accepts anything.
+func InferSchema(dpa DatapointArray) string {
+	var schema map[string]interface{}
+	for i := range dpa {
+		s := inferValue(reflect.ValueOf(dpa[i].Data))
+		if i == 0 {
+			schema = s
+		} else {
+			schema = mergeSchemas(schema, s)
+		}
+	}
+	if schema == nil {
+		schema = map[string]interface{}{}
+	}
+	b, err := json.Marshal(schema)
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
+
+func inferValue(v reflect.Value) map[string]interface{} {
+	for v.IsValid() && (v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr) {
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return map[string]interface{}{"type": "null"}
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return map[string]interface{}{}
+		}
+		properties := map[string]interface{}{}
+		for _, k := range v.MapKeys() {
+			properties[k.String()] = inferValue(v.MapIndex(k))
+		}
+		return map[string]interface{}{"type": "object", "properties": properties}
+	case reflect.Slice, reflect.Array:
+		var items map[string]interface{}
+		for i := 0; i < v.Len(); i++ {
+			if i == 0 {
+				items = inferValue(v.Index(i))
+			} else {
+				items = mergeSchemas(items, inferValue(v.Index(i)))
+			}
+		}
+		if items == nil {
+			return map[string]interface{}{"type": "array"}
+		}
+		return map[string]interface{}{"type": "array", "items": items}
+	}
+	return map[string]interface{}{}
+}
+
+// mergeSchemas returns a schema which accepts the values of both of the given schemas
+func mergeSchemas(a, b map[string]interface{}) map[string]interface{} {
+	if a["type"] == nil || a["type"] != b["type"] {
+		return map[string]interface{}{}
+	}
+	switch a["type"] {
+	case "object":
+		ap := a["properties"].(map[string]interface{})
+		bp := b["properties"].(map[string]interface{})
+		properties := map[string]interface{}{}
+		for k, v := range ap {
+			if bv, ok := bp[k]; ok {
+				properties[k] = mergeSchemas(v.(map[string]interface{}), bv.(map[string]interface{}))
+			} else {
+				properties[k] = v
+			}
+		}
+		for k, v := range bp {
+			if _, ok := ap[k]; !ok {
+				properties[k] = v
+			}
+		}
+		return map[string]interface{}{"type": "object", "properties": properties}
+	case "array":
+		ai, aok := a["items"].(map[string]interface{})
+		bi, bok := b["items"].(map[string]interface{})
+		switch {
+		case aok && bok:
+			return map[string]interface{}{"type": "array", "items": mergeSchemas(ai, bi)}
+		case aok:
+			return a
+		}
+		return b
+	}
+	return a
+}
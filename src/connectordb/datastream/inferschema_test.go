@@ -0,0 +1,34 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package datastream
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+func TestInferSchema(t *testing.T) {
+	require.Equal(t, `{"type":"number"}`, InferSchema(dpa6))
+	require.Equal(t, `{"type":"string"}`, InferSchema(dpa1))
+	require.Equal(t, `{"type":"boolean"}`, InferSchema(DatapointArray{Datapoint{1., true, ""}}))
+	require.Equal(t, `{}`, InferSchema(DatapointArray{Datapoint{1., true, ""}, Datapoint{2., 2., ""}}))
+	require.Equal(t, `{}`, InferSchema(DatapointArray{}))
+	require.Equal(t, `{"properties":{"hello":{"type":"number"},"y":{"type":"string"}},"type":"object"}`, InferSchema(dpa5))
+
+	// Properties which only some of the objects have are allowed, and conflicting types accept anything
+	dpa := DatapointArray{
+		Datapoint{1., map[string]interface{}{"x": 1., "list": []interface{}{1., 2.}}, ""},
+		Datapoint{2., map[string]interface{}{"x": "hi", "z": false}, ""},
+	}
+	s := InferSchema(dpa)
+	require.Equal(t, `{"properties":{"list":{"items":{"type":"number"},"type":"array"},"x":{},"z":{"type":"boolean"}},"type":"object"}`, s)
+
+	// The inferred schema accepts the data it was inferred from
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(s))
+	require.NoError(t, err)
+	require.NoError(t, dpa.VerifySchema(schema))
+}
@@ -8,6 +8,7 @@ import (
 	"config"
 	"connectordb/datastream"
 	"dbsetup/dbutil"
+	"fmt"
 	"testing"
 
 	_ "github.com/lib/pq"
@@ -141,3 +142,166 @@ func TestTimePlusIndexRange(t *testing.T) {
 	dr, err = ds.TimePlusIndexRange(0, 1, "", 5., 0, 50)
 	require.Error(t, err)
 }
+
+func TestDataStreamInsertOnce(t *testing.T) {
+	rc.BatchSize = 2
+	sqldb, err := dbutil.OpenDatabase(config.TestConfiguration.Sql.Type, config.TestConfiguration.Sql.GetSqlConnectionString())
+	require.NoError(t, err)
+
+	ds, err := datastream.OpenDataStream(RedisCache{rc}, sqldb, 2)
+	require.NoError(t, err)
+
+	ds.Clear()
+
+	i, err := ds.InsertOnce("key1", 0, 1, "", dpa6, false, 0, 0)
+	require.NoError(t, err)
+	require.EqualValues(t, 5, i)
+
+	// A retry of the same insert is not written
+	_, err = ds.InsertOnce("key1", 0, 1, "", dpa6, false, 0, 0)
+	require.Equal(t, datastream.ErrDuplicateInsert, err)
+
+	i, err = ds.StreamLength(0, 1, "")
+	require.NoError(t, err)
+	require.EqualValues(t, 5, i)
+
+	// The key is per substream
+	i, err = ds.InsertOnce("key1", 0, 1, "downlink", dpa6, false, 0, 0)
+	require.NoError(t, err)
+	require.EqualValues(t, 5, i)
+
+	// A failed insert does not use up its key
+	_, err = ds.InsertOnce("key2", 0, 1, "", dpa6, false, 0, 0)
+	require.Error(t, err)
+	require.NotEqual(t, datastream.ErrDuplicateInsert, err)
+
+	i, err = ds.InsertOnce("key2", 0, 1, "", dpa6, true, 0, 0)
+	require.NoError(t, err)
+	require.EqualValues(t, 10, i)
+
+	// Inserts without a key are never deduplicated
+	i, err = ds.InsertOnce("", 0, 1, "", dpa6, true, 0, 0)
+	require.NoError(t, err)
+	require.EqualValues(t, 15, i)
+	i, err = ds.InsertOnce("", 0, 1, "", dpa6, true, 0, 0)
+	require.NoError(t, err)
+	require.EqualValues(t, 20, i)
+
+	// Without a window, keys are not remembered
+	ds.InsertKeyWindow = 0
+	i, err = ds.InsertOnce("key3", 0, 1, "", dpa6, true, 0, 0)
+	require.NoError(t, err)
+	require.EqualValues(t, 25, i)
+	i, err = ds.InsertOnce("key3", 0, 1, "", dpa6, true, 0, 0)
+	require.NoError(t, err)
+	require.EqualValues(t, 30, i)
+
+	ds.Clear()
+}
+
+func readStream(t *testing.T, ds *datastream.DataStream) datastream.DatapointArray {
+	dr, err := ds.IRange(0, 1, "", 0, 0)
+	require.NoError(t, err)
+	defer dr.Close()
+	result := datastream.DatapointArray{}
+	for {
+		ar, err := dr.NextArray()
+		require.NoError(t, err)
+		if ar == nil {
+			return result
+		}
+		result = append(result, *ar...)
+	}
+}
+
+func TestDataStreamBackfill(t *testing.T) {
+	rc.BatchSize = 2
+	sqldb, err := dbutil.OpenDatabase(config.TestConfiguration.Sql.Type, config.TestConfiguration.Sql.GetSqlConnectionString())
+	require.NoError(t, err)
+
+	ds, err := datastream.OpenDataStream(RedisCache{rc}, sqldb, 2)
+	require.NoError(t, err)
+
+	ds.Clear()
+
+	// Backfilling an empty stream is just an insert
+	i, shifted, err := ds.Backfill(0, 1, "", dpa6, 0, 0)
+	require.NoError(t, err)
+	require.False(t, shifted)
+	require.EqualValues(t, 5, i)
+
+	// The rewrite is entirely within redis
+	i, shifted, err = ds.Backfill(0, 1, "", datastream.DatapointArray{datastream.Datapoint{4.5, 4.5, ""}}, 0, 0)
+	require.NoError(t, err)
+	require.True(t, shifted)
+	require.EqualValues(t, 6, i)
+	require.Equal(t, "[1 2 3 4 4.5 5]", readValues(readStream(t, ds)))
+
+	// Now write to the database, and backfill into the written batches
+	require.NoError(t, ds.WriteChunk())
+
+	i, shifted, err = ds.Backfill(0, 1, "", datastream.DatapointArray{datastream.Datapoint{2.5, 2.5, ""}}, 0, 0)
+	require.NoError(t, err)
+	require.True(t, shifted)
+	require.EqualValues(t, 7, i)
+	require.Equal(t, "[1 2 2.5 3 4 4.5 5]", readValues(readStream(t, ds)))
+
+	// The batches in the database were rewritten in place, so nothing needs to be written again
+	writestrings, err := rc.GetList("BATCHLIST")
+	require.NoError(t, err)
+	require.Equal(t, 0, len(writestrings))
+	l, err := rc.StreamLength("0", "1", "")
+	require.NoError(t, err)
+	require.EqualValues(t, 7, l)
+
+	// A stream can't be backfilled while it is locked by another backfill
+	_, err = RedisCache{rc}.StartBackfill(0, 1, "", "", datastream.DatapointArray{datastream.Datapoint{1.5, 1.5, ""}}, 0, 0)
+	require.NoError(t, err)
+	_, _, err = ds.Backfill(0, 1, "", datastream.DatapointArray{datastream.Datapoint{1.5, 1.5, ""}}, 0, 0)
+	require.Equal(t, datastream.ErrBackfillConflict, err)
+	require.NoError(t, RedisCache{rc}.AbortBackfill(0, 1, ""))
+	require.Equal(t, "[1 2 2.5 3 4 4.5 5]", readValues(readStream(t, ds)))
+
+	// Existing datapoints come before backfilled ones with the same timestamp
+	i, shifted, err = ds.Backfill(0, 1, "", datastream.DatapointArray{datastream.Datapoint{3.0, 30.0, ""}, datastream.Datapoint{6.0, 6.0, ""}}, 0, 0)
+	require.NoError(t, err)
+	require.True(t, shifted)
+	require.EqualValues(t, 9, i)
+	require.Equal(t, "[1 2 2.5 3 30 4 4.5 5 6]", readValues(readStream(t, ds)))
+
+	// Newer data is not a backfill
+	i, shifted, err = ds.Backfill(0, 1, "", datastream.DatapointArray{datastream.Datapoint{7.0, 7.0, ""}}, 0, 0)
+	require.NoError(t, err)
+	require.False(t, shifted)
+	require.EqualValues(t, 10, i)
+
+	// Time queries see the backfilled data
+	dr, err := ds.TRange(0, 1, "", 2.9, 4.0)
+	require.NoError(t, err)
+	dp, err := dr.Next()
+	require.NoError(t, err)
+	require.EqualValues(t, 3.0, dp.Data)
+	dp, err = dr.Next()
+	require.NoError(t, err)
+	require.EqualValues(t, 30.0, dp.Data)
+	dr.Close()
+
+	// The idempotency key works for backfills
+	_, _, err = ds.BackfillOnce("key1", 0, 1, "", datastream.DatapointArray{datastream.Datapoint{1.5, 1.5, ""}}, 0, 0)
+	require.NoError(t, err)
+	_, _, err = ds.BackfillOnce("key1", 0, 1, "", datastream.DatapointArray{datastream.Datapoint{1.5, 1.5, ""}}, 0, 0)
+	require.Equal(t, datastream.ErrDuplicateInsert, err)
+	require.Equal(t, "[1 1.5 2 2.5 3 30 4 4.5 5 6 7]", readValues(readStream(t, ds)))
+
+	ds.Clear()
+	sqldb.Close()
+	rc.BatchSize = 250
+}
+
+func readValues(dpa datastream.DatapointArray) string {
+	v := make([]interface{}, len(dpa))
+	for i := range dpa {
+		v[i] = dpa[i].Data
+	}
+	return fmt.Sprint(v)
+}
@@ -68,10 +68,12 @@ const (
 	RedisNilString = "redis: nil"
 
 	//The insert script does the following:
-	//It is given 3 keys:
+	//It is given 5 keys:
 	//1	stream key - the key where a list of chunks has been inserted
 	//2	metadata key - the key where the stream's metadata is stored
 	//3	batch writer key - the key to which to write batches. If == stream key, doesn't write batches
+	//4	insert key - the idempotency key of the insert. If empty, the insert has no key
+	//5	backfill key - the key which exists while the stream is being backfilled, or its schema is being changed
 	//Of the arguments, it is given:
 	//1	subpath - the name of the stream in "stream:substream" format
 	//2	starttime - the start time of the datapoints
@@ -81,8 +83,19 @@ const (
 	//6	datasize - the size of the currently inserted array in bytes
 	//7	maxdevicesize - the maximum number of bytes to permit in a device. =0 means unlimited
 	//8	maxstreamsize - the maximum number of bytes to permit in a stream. =0 means unlimited
+	//9	window - the number of milliseconds for which the insert key is remembered
 	//	... array of the datapoints to be inserted ...
 	insertScript = `
+		-- The data was validated against the stream's old schema, so it can't be written while the schema changes
+		if (redis.call('get',KEYS[5]) == 'schema') then
+			return {["err"]="The stream's schema is being changed. Insert Failed."}
+		end
+
+		-- An insert with the same key was already written
+		if (KEYS[4] ~= '' and redis.call('exists',KEYS[4]) == 1) then
+			return {["err"]="An insert with the given key was already performed"}
+		end
+
 		-- Check to make sure we don't go over the size limits for device and stream
 		if (ARGV[7] ~= '0') then
 			local device_size = tonumber(redis.call('hget',KEYS[2], 'size')) or 0
@@ -113,7 +126,7 @@ const (
 				stream_endtime = stream_endtime + 0.00001
 			end
 
-			for i=9,#ARGV,1 do
+			for i=10,#ARGV,1 do
 				local val = cmsgpack.unpack(ARGV[i])
 				if (val['t'] > stream_endtime) then
 					break
@@ -131,7 +144,7 @@ const (
 		-- Set the end time
 		redis.call('hset',KEYS[2], 'endtime:' .. ARGV[1], ARGV[3])
 		-- Set the total stream length
-		redis.call('hincrby',KEYS[2], 'length:' .. ARGV[1], #ARGV - 8)
+		redis.call('hincrby',KEYS[2], 'length:' .. ARGV[1], #ARGV - 9)
 		-- Set the stream size
 		redis.call('hincrby',KEYS[2], 'size:' .. ARGV[1], ARGV[6])
 		-- Set the device total size
@@ -139,12 +152,21 @@ const (
 
 		-- Insert the datapoints into the stream - redis lua has some weird stuff about the maximum
 		-- number of arguments to a function - we avoid this by manually splitting insert into chunks
-		for i=9,#ARGV,5000 do
+		for i=10,#ARGV,5000 do
 			redis.call('rpush',KEYS[1], unpack(ARGV,i,math.min(i+4999,#ARGV)))
 		end
 
-		-- Check to see if we should write a batch
+		-- The key is only remembered once the data is written
+		if (KEYS[4] ~= '') then
+			redis.call('set',KEYS[4],'1','PX',ARGV[9])
+		end
+
+		-- Check to see if we should write a batch. While the stream is being backfilled, the backfill
+		-- schedules the batches once it is done.
 		local streamlength = tonumber(redis.call('hget',KEYS[2], 'length:' .. ARGV[1]))
+		if (redis.call('exists',KEYS[5]) == 1) then
+			return streamlength
+		end
 		local batchindex = tonumber(redis.call('hget',KEYS[2], 'batchindex:' .. ARGV[1])) or 0
 		local batchsize = tonumber(ARGV[5])
 		if (streamlength > batchindex + batchsize) then
@@ -161,6 +183,203 @@ const (
 		return streamlength
 	`
 
+	//A backfill inserts datapoints into the middle of a stream. Since that moves the datapoints after them, the stream
+	//must not be written to the database while the backfill is in progress. The backfill start script therefore locks
+	//the stream: it fails if the stream is already being backfilled or one of its batches is being written, and removes
+	//the stream's batches which wait to be written, so that the batch writer leaves the stream alone. Inserts are still
+	//possible, but don't schedule batches while the backfill key exists. It returns the index of the first cached datapoint.
+	//It is given 6 keys:
+	//1	stream key
+	//2	metadata key
+	//3	batch writer key
+	//4	batch processing key
+	//5	insert key - the idempotency key of the backfill. If empty, the backfill has no key
+	//6	backfill key - the lock on the stream's backfills
+	//Of the arguments, it is given:
+	//1	subpath - the name of the stream in "stream:substream" format
+	//2	datasize - the size of the backfilled datapoints in bytes
+	//3	maxdevicesize - the maximum number of bytes to permit in a device. =0 means unlimited
+	//4	maxstreamsize - the maximum number of bytes to permit in a stream. =0 means unlimited
+	//5	timeout - the number of milliseconds after which the lock expires if the backfill never finishes
+	backfillStartScript = `
+		local lock = redis.call('get',KEYS[6])
+		if (lock == 'schema') then
+			return {["err"]="The stream's schema is being changed. Insert Failed."}
+		end
+		if (lock) then
+			return {["err"]="The stream changed during the backfill. Insert Failed."}
+		end
+		if (KEYS[5] ~= '' and redis.call('exists',KEYS[5]) == 1) then
+			return {["err"]="An insert with the given key was already performed"}
+		end
+
+		if (ARGV[3] ~= '0') then
+			local device_size = tonumber(redis.call('hget',KEYS[2], 'size')) or 0
+			if (device_size + tonumber(ARGV[2]) > tonumber(ARGV[3])) then
+				return {["err"]="Insert Failed: Exceeded device size limit"}
+			end
+		end
+		if (ARGV[4] ~= '0') then
+			local stream_size = tonumber(redis.call('hget',KEYS[2], 'size:' .. ARGV[1])) or 0
+			if (stream_size + tonumber(ARGV[2]) > tonumber(ARGV[4])) then
+				return {["err"]="Insert Failed: Exceeded stream size limit"}
+			end
+		end
+
+		-- A batch which is being written can't be changed anymore
+		local batchprefix = KEYS[1] .. ":"
+		for _,b in ipairs(redis.call('lrange',KEYS[4],0,-1)) do
+			if (string.sub(b,1,#batchprefix) == batchprefix and string.match(string.sub(b,#batchprefix+1), "^%d+:%d+$")) then
+				return {["err"]="The stream changed during the backfill. Insert Failed."}
+			end
+		end
+
+		-- Batches waiting to be written are scheduled again once the backfill is done
+		for _,b in ipairs(redis.call('lrange',KEYS[3],0,-1)) do
+			if (string.sub(b,1,#batchprefix) == batchprefix and string.match(string.sub(b,#batchprefix+1), "^%d+:%d+$")) then
+				redis.call('lrem',KEYS[3],0,b)
+			end
+		end
+		local streamlength = tonumber(redis.call('hget',KEYS[2], 'length:' .. ARGV[1])) or 0
+		local cachestart = streamlength - tonumber(redis.call('llen',KEYS[1]))
+		redis.call('hset',KEYS[2], 'batchindex:' .. ARGV[1], cachestart)
+
+		redis.call('set',KEYS[6],'1','PX',ARGV[5])
+		return cachestart
+	`
+
+	//The backfill finish script is run once the datapoints which belong before the cached data were written to the
+	//database. It merges the remaining datapoints into the cached data, updates the stream's metadata, schedules the
+	//stream's batches and releases the lock taken by the start script. It can't fail, since the database was already changed.
+	//It is given 5 keys:
+	//1	stream key
+	//2	metadata key
+	//3	batch writer key
+	//4	insert key - the idempotency key of the backfill. If empty, the backfill has no key
+	//5	backfill key
+	//Of the arguments, it is given:
+	//1	subpath - the name of the stream in "stream:substream" format
+	//2	batchsize - the number of datapoints which constitute a batch
+	//3	datasize - the size of all the backfilled datapoints in bytes
+	//4	written - the number of backfilled datapoints which were written to the database
+	//5	window - the number of milliseconds for which the insert key is remembered
+	//	... array of the datapoints to merge into the cache ...
+	backfillFinishScript = `
+		if (#ARGV > 5) then
+			-- Existing datapoints come before backfilled ones with the same timestamp
+			local cached = redis.call('lrange',KEYS[1],0,-1)
+			local merged = {}
+			local i = 1
+			local cached_t = nil
+			for j=6,#ARGV,1 do
+				local t = cmsgpack.unpack(ARGV[j])['t']
+				while (i <= #cached) do
+					if (cached_t == nil) then
+						cached_t = cmsgpack.unpack(cached[i])['t']
+					end
+					if (cached_t > t) then
+						break
+					end
+					table.insert(merged,cached[i])
+					i = i + 1
+					cached_t = nil
+				end
+				table.insert(merged,ARGV[j])
+			end
+			for k=i,#cached,1 do
+				table.insert(merged,cached[k])
+			end
+
+			redis.call('del',KEYS[1])
+			for k=1,#merged,5000 do
+				redis.call('rpush',KEYS[1], unpack(merged,k,math.min(k+4999,#merged)))
+			end
+
+			local endtime = cmsgpack.unpack(ARGV[#ARGV])['t']
+			if (endtime > (tonumber(redis.call('hget',KEYS[2], 'endtime:' .. ARGV[1])) or 0)) then
+				redis.call('hset',KEYS[2], 'endtime:' .. ARGV[1], endtime)
+			end
+		end
+
+		local streamlength = redis.call('hincrby',KEYS[2], 'length:' .. ARGV[1], #ARGV - 5 + tonumber(ARGV[4]))
+		redis.call('hincrby',KEYS[2], 'size:' .. ARGV[1], ARGV[3])
+		redis.call('hincrby',KEYS[2], 'size', ARGV[3])
+		if (KEYS[4] ~= '') then
+			redis.call('set',KEYS[4],'1','PX',ARGV[5])
+		end
+		redis.call('del',KEYS[5])
+
+		-- The datapoints written to the database moved the cached data
+		local batchindex = (tonumber(redis.call('hget',KEYS[2], 'batchindex:' .. ARGV[1])) or 0) + tonumber(ARGV[4])
+		local batchsize = tonumber(ARGV[2])
+		if (streamlength > batchindex + batchsize) then
+			local batchnum = math.floor((streamlength-batchindex)/batchsize)
+			local batches = {}
+			for i=batchindex,streamlength-batchsize,batchsize do
+				table.insert(batches,KEYS[1] .. ":" .. i .. ":" .. (i+batchsize))
+			end
+			redis.call('lpush',KEYS[3],unpack(batches))
+			batchindex = batchindex+batchsize*batchnum
+		end
+		redis.call('hset',KEYS[2], 'batchindex:' .. ARGV[1], batchindex)
+
+		return streamlength
+	`
+
+	//The schema lock script locks the substream while the stream's schema is changed, so that the index at which the new
+	//schema version starts can't change until the version is written. It takes the backfill key, so it fails if the
+	//substream is being backfilled, and inserts and backfills fail while it is held. It returns the substream's length.
+	//It is given 2 keys:
+	//1	metadata key
+	//2	backfill key
+	//Of the arguments, it is given:
+	//1	subpath - the name of the stream in "stream:substream" format
+	//2	timeout - the number of milliseconds after which the lock expires if it is never released
+	schemaLockScript = `
+		if (redis.call('exists',KEYS[2]) == 1) then
+			return {["err"]="The stream changed during the backfill. Insert Failed."}
+		end
+		redis.call('set',KEYS[2],'schema','PX',ARGV[2])
+		return tonumber(redis.call('hget',KEYS[1], 'length:' .. ARGV[1])) or 0
+	`
+
+	//The schema unlock script releases the lock taken by the schema lock script, if it is still held.
+	//It is given the backfill key.
+	schemaUnlockScript = `
+		if (redis.call('get',KEYS[1]) == 'schema') then
+			redis.call('del',KEYS[1])
+		end
+		return 0
+	`
+
+	//The backfill abort script releases the lock of a backfill which failed before changing the database, and
+	//schedules the batches of the stream again.
+	//It is given 4 keys:
+	//1	stream key
+	//2	metadata key
+	//3	batch writer key
+	//4	backfill key
+	//Of the arguments, it is given:
+	//1	subpath - the name of the stream in "stream:substream" format
+	//2	batchsize - the number of datapoints which constitute a batch
+	backfillAbortScript = `
+		redis.call('del',KEYS[4])
+
+		local streamlength = tonumber(redis.call('hget',KEYS[2], 'length:' .. ARGV[1])) or 0
+		local batchindex = tonumber(redis.call('hget',KEYS[2], 'batchindex:' .. ARGV[1])) or 0
+		local batchsize = tonumber(ARGV[2])
+		if (streamlength > batchindex + batchsize) then
+			local batchnum = math.floor((streamlength-batchindex)/batchsize)
+			local batches = {}
+			for i=batchindex,streamlength-batchsize,batchsize do
+				table.insert(batches,KEYS[1] .. ":" .. i .. ":" .. (i+batchsize))
+			end
+			redis.call('lpush',KEYS[3],unpack(batches))
+			redis.call('hset',KEYS[2], 'batchindex:' .. ARGV[1], batchindex+batchsize*batchnum)
+		end
+		return 'ok'
+	`
+
 	//The subdelete script deletes a given substream.
 	//Given 2 keys:
 	//	the stream key
@@ -255,7 +474,12 @@ const (
 	//	The stream path
 	//	index to trim to (ie, keep datapoints AFTER index)
 	trimScript = `
-		local startindex = tonumber(redis.call('hget',KEYS[2], 'length:' .. ARGV[1])) - tonumber(redis.call('llen',KEYS[1]))
+		-- The stream might have been moved to another hash or deleted while its batch was being written
+		local streamlength = redis.call('hget',KEYS[2], 'length:' .. ARGV[1])
+		if (not streamlength) then
+			return 'ok'
+		end
+		local startindex = tonumber(streamlength) - tonumber(redis.call('llen',KEYS[1]))
 		local i = tonumber(ARGV[2])
 
 		if (i > startindex) then
@@ -264,8 +488,57 @@ const (
 			return 'ok'
 		end
 	`
+
+	//The move script moves a substream from one hash to another, along with its metadata and the batches of it
+	//which are waiting to be written. Note that the keys are in different hashes, so this does not work on a cluster.
+	//Given 6 keys:
+	//	the old stream key
+	//	the old metadata key
+	//	the new stream key
+	//	the new metadata key
+	//	the batch list key
+	//	the batch processing key
+	//In arguments it is given:
+	//	The stream metadata subpath
+	moveScript = `
+		if (redis.call('hexists',KEYS[4], 'length:' .. ARGV[1]) == 1) then
+			return {["err"]="The stream already exists in the destination"}
+		end
+		if (redis.call('exists',KEYS[1]) == 1) then
+			redis.call('rename',KEYS[1],KEYS[3])
+		end
+
+		-- Move the metadata, and the substream's share of the device size
+		local fields = {'endtime:','length:','starttime:','batchindex:','size:'}
+		for i=1,#fields,1 do
+			local v = redis.call('hget',KEYS[2], fields[i] .. ARGV[1])
+			if (v) then
+				redis.call('hset',KEYS[4], fields[i] .. ARGV[1], v)
+				redis.call('hdel',KEYS[2], fields[i] .. ARGV[1])
+			end
+		end
+		local stream_size = tonumber(redis.call('hget',KEYS[4], 'size:' .. ARGV[1])) or 0
+		redis.call('hincrby', KEYS[2], 'size', -stream_size)
+		redis.call('hincrby', KEYS[4], 'size', stream_size)
+
+		-- Batches are of the form streamkey:i1:i2, so they are pointed to the new stream key
+		local prefix = KEYS[1] .. ':'
+		for k=5,6,1 do
+			local batches = redis.call('lrange',KEYS[k],0,-1)
+			for i=1,#batches,1 do
+				if (string.sub(batches[i],1,#prefix) == prefix) then
+					redis.call('lset',KEYS[k],i-1,KEYS[3] .. string.sub(batches[i],#prefix))
+				end
+			end
+		end
+		return 'ok'
+	`
 )
 
+//BackfillTimeout is the time after which the lock on a stream being backfilled expires, in case the backfill
+//never finishes
+var BackfillTimeout = 10 * time.Minute
+
 var (
 	//ErrTimestamp is returned when trying to insert old timestamps
 	ErrTimestamp = errors.New("Greater timestamp already exists for the stream. Insert Failed.")
@@ -279,6 +552,7 @@ type redisConnection interface {
 	LRange(key string, start, stop int64) *redis.StringSliceCmd
 	HGet(key, field string) *redis.StringCmd
 	HKeys(key string) *redis.StringSliceCmd
+	LLen(key string) *redis.IntCmd
 	Del(keys ...string) *redis.IntCmd
 	FlushDb() *redis.StatusCmd
 
@@ -299,10 +573,16 @@ type RedisConnection struct {
 	BatchSize int64
 
 	//The server side scripts which speed up certain operations
-	insertScript    *redis.Script
-	subdeleteScript *redis.Script
-	rangeScript     *redis.Script
-	trimScript      *redis.Script
+	insertScript         *redis.Script
+	subdeleteScript      *redis.Script
+	backfillStartScript  *redis.Script
+	backfillFinishScript *redis.Script
+	backfillAbortScript  *redis.Script
+	schemaLockScript     *redis.Script
+	schemaUnlockScript   *redis.Script
+	rangeScript          *redis.Script
+	trimScript           *redis.Script
+	moveScript           *redis.Script
 }
 
 //If redis returns nil, that is handled as an error in the redis library - this allows to wrap commands
@@ -323,6 +603,19 @@ func streamKey(hash, stream, substream string) string {
 	return "{" + hash + "}" + stream + ":" + substream
 }
 
+//insertKey returns the key under which the idempotency key of an insert into the substream is remembered
+func insertKey(hash, stream, substream, key string) string {
+	if key == "" {
+		return ""
+	}
+	return "{" + hash + "}insertkey:" + stream + ":" + substream + ":" + key
+}
+
+//backfillKey returns the key which locks the substream while it is being backfilled
+func backfillKey(hash, stream, substream string) string {
+	return "{" + hash + "}backfill:" + stream + ":" + substream
+}
+
 //Many of the redis scripts use the same exact keys - this just abstracts that away
 func scriptkeys(hash, stream, substream string) []string {
 	return []string{streamKey(hash, stream, substream), "{" + hash + "}"}
@@ -341,12 +634,18 @@ func NewRedisConnection(opt *redis.Options) (rc *RedisConnection, err error) {
 	_, err = rclient.Ping().Result()
 
 	return &RedisConnection{
-		Redis:           rclient,
-		BatchSize:       250,
-		insertScript:    redis.NewScript(insertScript),
-		subdeleteScript: redis.NewScript(subdeleteScript),
-		rangeScript:     redis.NewScript(rangeScript),
-		trimScript:      redis.NewScript(trimScript),
+		Redis:                rclient,
+		BatchSize:            250,
+		insertScript:         redis.NewScript(insertScript),
+		subdeleteScript:      redis.NewScript(subdeleteScript),
+		backfillStartScript:  redis.NewScript(backfillStartScript),
+		backfillFinishScript: redis.NewScript(backfillFinishScript),
+		backfillAbortScript:  redis.NewScript(backfillAbortScript),
+		schemaLockScript:     redis.NewScript(schemaLockScript),
+		schemaUnlockScript:   redis.NewScript(schemaUnlockScript),
+		rangeScript:          redis.NewScript(rangeScript),
+		trimScript:           redis.NewScript(trimScript),
+		moveScript:           redis.NewScript(moveScript),
 	}, err
 }
 
@@ -362,6 +661,12 @@ func (rc *RedisConnection) Get(hash, stream, substream string) (dpa datastream.D
 
 //Insert datapoint array, writing batches to batchkey
 func (rc *RedisConnection) Insert(batchkey, hash, stream, substream string, dpa datastream.DatapointArray, restamp bool, maxDeviceSize, maxStreamSize int64) (streamlength int64, err error) {
+	return rc.InsertOnce(batchkey, hash, stream, substream, "", 0, dpa, restamp, maxDeviceSize, maxStreamSize)
+}
+
+//InsertOnce is Insert with an idempotency key. If the key was used in the window, nothing is written and
+//datastream.ErrDuplicateInsert is returned. The key is only remembered if the insert succeeds. An empty key always inserts.
+func (rc *RedisConnection) InsertOnce(batchkey, hash, stream, substream, key string, window time.Duration, dpa datastream.DatapointArray, restamp bool, maxDeviceSize, maxStreamSize int64) (streamlength int64, err error) {
 	// Make sure that the datapointarray is not empty (it panics otherwise)
 	if len(dpa) == 0 {
 		// Run StreamLength instead
@@ -369,7 +674,7 @@ func (rc *RedisConnection) Insert(batchkey, hash, stream, substream string, dpa
 	}
 
 	//remember the number of args here
-	args := make([]interface{}, 8+len(dpa))
+	args := make([]interface{}, 9+len(dpa))
 
 	args[0] = stream + ":" + substream
 	args[1] = strconv.FormatFloat(dpa[0].Timestamp, 'G', -1, 64)
@@ -383,6 +688,7 @@ func (rc *RedisConnection) Insert(batchkey, hash, stream, substream string, dpa
 	// Arg 5 will be inserted after finding data size
 	args[6] = strconv.FormatInt(maxDeviceSize, 10)
 	args[7] = strconv.FormatInt(maxStreamSize, 10)
+	args[8] = strconv.FormatInt(int64(window/time.Millisecond), 10)
 
 	datasize := int64(0)
 	for i := range dpa {
@@ -391,20 +697,126 @@ func (rc *RedisConnection) Insert(batchkey, hash, stream, substream string, dpa
 			return 0, err
 		}
 		datasize += int64(len(b))
-		args[i+8] = string(b)
+		args[i+9] = string(b)
 	}
 
 	args[5] = strconv.FormatInt(datasize, 10)
 
-	r, err := rc.insertScript.Run(rc.Redis, []string{streamKey(hash, stream, substream), "{" + hash + "}", batchkey}, args...).Result()
+	r, err := rc.insertScript.Run(rc.Redis, []string{streamKey(hash, stream, substream), "{" + hash + "}", batchkey,
+		insertKey(hash, stream, substream, key), backfillKey(hash, stream, substream)}, args...).Result()
 
 	if err != nil {
+		switch err.Error() {
+		case datastream.ErrDuplicateInsert.Error():
+			return 0, datastream.ErrDuplicateInsert
+		case datastream.ErrStreamLocked.Error():
+			return 0, datastream.ErrStreamLocked
+		}
 		return 0, err
 	}
 
 	return r.(int64), err
 }
 
+//dataSize returns the total size of the datapoints in bytes
+func dataSize(dpa datastream.DatapointArray) (int64, error) {
+	datasize := int64(0)
+	for i := range dpa {
+		b, err := dpa[i].Bytes()
+		if err != nil {
+			return 0, err
+		}
+		datasize += int64(len(b))
+	}
+	return datasize, nil
+}
+
+//StartBackfill locks the substream for a backfill of the given datapoints, and returns the index of the first datapoint
+//held in redis. Until the backfill is finished or aborted, none of the substream's batches are written to the database.
+//If the substream is already being backfilled or one of its batches is being written, datastream.ErrBackfillConflict
+//is returned. The key, if not empty, is an idempotency key which works just like the key of InsertOnce.
+func (rc *RedisConnection) StartBackfill(batchkey, processingkey, hash, stream, substream, key string, dpa datastream.DatapointArray, maxDeviceSize, maxStreamSize int64) (int64, error) {
+	datasize, err := dataSize(dpa)
+	if err != nil {
+		return 0, err
+	}
+	r, err := rc.backfillStartScript.Run(rc.Redis, []string{streamKey(hash, stream, substream), "{" + hash + "}", batchkey, processingkey,
+		insertKey(hash, stream, substream, key), backfillKey(hash, stream, substream)},
+		stream+":"+substream,
+		strconv.FormatInt(datasize, 10),
+		strconv.FormatInt(maxDeviceSize, 10),
+		strconv.FormatInt(maxStreamSize, 10),
+		strconv.FormatInt(int64(BackfillTimeout/time.Millisecond), 10)).Result()
+	if err != nil {
+		switch err.Error() {
+		case datastream.ErrBackfillConflict.Error():
+			return 0, datastream.ErrBackfillConflict
+		case datastream.ErrDuplicateInsert.Error():
+			return 0, datastream.ErrDuplicateInsert
+		case datastream.ErrStreamLocked.Error():
+			return 0, datastream.ErrStreamLocked
+		}
+		return 0, err
+	}
+	return r.(int64), nil
+}
+
+//FinishBackfill finishes a backfill started with StartBackfill. The first written datapoints of dpa were already written
+//to the database, and the rest are merged into the data held in redis. Returns the new length of the substream.
+func (rc *RedisConnection) FinishBackfill(batchkey, hash, stream, substream, key string, window time.Duration, written int, dpa datastream.DatapointArray) (int64, error) {
+	datasize, err := dataSize(dpa)
+	if err != nil {
+		return 0, err
+	}
+
+	args := make([]interface{}, 5+len(dpa)-written)
+	args[0] = stream + ":" + substream
+	args[1] = strconv.FormatInt(rc.BatchSize, 10)
+	args[2] = strconv.FormatInt(datasize, 10)
+	args[3] = strconv.Itoa(written)
+	args[4] = strconv.FormatInt(int64(window/time.Millisecond), 10)
+	for i := written; i < len(dpa); i++ {
+		b, err := dpa[i].Bytes()
+		if err != nil {
+			return 0, err
+		}
+		args[5+i-written] = string(b)
+	}
+
+	r, err := rc.backfillFinishScript.Run(rc.Redis, []string{streamKey(hash, stream, substream), "{" + hash + "}", batchkey,
+		insertKey(hash, stream, substream, key), backfillKey(hash, stream, substream)}, args...).Result()
+	if err != nil {
+		return 0, err
+	}
+	return r.(int64), nil
+}
+
+//AbortBackfill releases the lock of a backfill started with StartBackfill which did not change the database
+func (rc *RedisConnection) AbortBackfill(batchkey, hash, stream, substream string) error {
+	return wrapNil(rc.backfillAbortScript.Run(rc.Redis, []string{streamKey(hash, stream, substream), "{" + hash + "}", batchkey,
+		backfillKey(hash, stream, substream)}, stream+":"+substream, strconv.FormatInt(rc.BatchSize, 10)).Err())
+}
+
+//LockSubstream locks the substream while its stream's schema is changed, and returns its length. Until it is unlocked,
+//inserts and backfills into the substream return datastream.ErrStreamLocked. If the substream is being backfilled,
+//datastream.ErrBackfillConflict is returned.
+func (rc *RedisConnection) LockSubstream(hash, stream, substream string) (int64, error) {
+	r, err := rc.schemaLockScript.Run(rc.Redis, []string{"{" + hash + "}", backfillKey(hash, stream, substream)},
+		stream+":"+substream, strconv.FormatInt(int64(BackfillTimeout/time.Millisecond), 10)).Result()
+	if err != nil {
+		if err.Error() == datastream.ErrBackfillConflict.Error() {
+			return 0, datastream.ErrBackfillConflict
+		}
+		return 0, err
+	}
+	return r.(int64), nil
+}
+
+//UnlockSubstream releases the lock taken by LockSubstream
+func (rc *RedisConnection) UnlockSubstream(hash, stream, substream string) error {
+	return wrapNil(rc.schemaUnlockScript.Run(rc.Redis, []string{backfillKey(hash, stream, substream)}).Err())
+}
+
 //StreamLength returns the stream's length
 func (rc *RedisConnection) StreamLength(hash, stream, substream string) (int64, error) {
 	sc := rc.Redis.HGet("{"+hash+"}", "length:"+stream+":"+substream)
@@ -449,6 +861,33 @@ func (rc *RedisConnection) DeleteStream(hash string, stream string) (err error)
 	return nil
 }
 
+//MoveSubstream moves the given substream from one hash to another
+func (rc *RedisConnection) MoveSubstream(batchkey, processingkey, hash, stream, substream, newhash string) error {
+	return wrapNil(rc.moveScript.Run(rc.Redis, []string{
+		streamKey(hash, stream, substream), "{" + hash + "}",
+		streamKey(newhash, stream, substream), "{" + newhash + "}",
+		batchkey, processingkey}, stream+":"+substream).Err())
+}
+
+//MoveStream moves an entire stream and all of its substreams from one hash to another.
+//WARNING: Like DeleteStream, this is not atomic over the substreams.
+func (rc *RedisConnection) MoveStream(batchkey, processingkey, hash, stream, newhash string) error {
+	keys, err := rc.Redis.HKeys("{" + hash + "}").Result()
+	if err != nil {
+		return err
+	}
+
+	for i := range keys {
+		if len(keys[i]) > 7 && strings.HasPrefix(keys[i], "length:"+stream+":") {
+			err := rc.MoveSubstream(batchkey, processingkey, hash, stream, keys[i][8+len(stream):], newhash)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 //DeleteHash removes all streams within a hash
 func (rc *RedisConnection) DeleteHash(hash string) (err error) {
 	//First we must check all the substreams. to do this, we list the keys in hash
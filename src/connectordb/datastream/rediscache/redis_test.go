@@ -662,3 +662,55 @@ func BenchmarkRedis10Range(b *testing.B) {
 	}
 
 }
+
+func TestRedisMoveStream(t *testing.T) {
+	rc.Clear()
+
+	rc.BatchSize = 2
+
+	_, err := rc.Insert("mybatcher", "hi", "mystream", "", dpa6, false, 0, 0)
+	require.NoError(t, err)
+	_, err = rc.Insert("mybatcher", "hi", "mystream", "d", dpa6, false, 0, 0)
+	require.NoError(t, err)
+	_, err = rc.Insert("mybatcher", "hi", "otherstream", "", dpa6, false, 0, 0)
+	require.NoError(t, err)
+	size, err := rc.HashSize("hi")
+	require.NoError(t, err)
+
+	require.NoError(t, rc.MoveStream("mybatcher", "donebatch", "hi", "mystream", "ho"))
+
+	i, err := rc.StreamLength("hi", "mystream", "")
+	require.NoError(t, err)
+	require.EqualValues(t, 0, i)
+	i, err = rc.StreamLength("ho", "mystream", "")
+	require.NoError(t, err)
+	require.EqualValues(t, 5, i)
+	i, err = rc.StreamLength("ho", "mystream", "d")
+	require.NoError(t, err)
+	require.EqualValues(t, 5, i)
+	i, err = rc.StreamLength("hi", "otherstream", "")
+	require.NoError(t, err)
+	require.EqualValues(t, 5, i)
+
+	dpa, err := rc.Get("ho", "mystream", "d")
+	require.NoError(t, err)
+	require.Equal(t, dpa6.String(), dpa.String())
+
+	s1, err := rc.HashSize("hi")
+	require.NoError(t, err)
+	s2, err := rc.HashSize("ho")
+	require.NoError(t, err)
+	require.Equal(t, size, s1+s2)
+
+	// The waiting batches now point to the moved stream
+	s, err := rc.NextBatch("mybatcher", "donebatch")
+	require.NoError(t, err)
+	require.Equal(t, "{ho}mystream::0:2", s)
+
+	// Moving onto an existing stream fails
+	_, err = rc.Insert("mybatcher", "hi", "mystream", "", dpa6, false, 0, 0)
+	require.NoError(t, err)
+	require.Error(t, rc.MoveStream("mybatcher", "donebatch", "hi", "mystream", "ho"))
+
+	rc.BatchSize = 250
+}
@@ -7,6 +7,7 @@ package rediscache
 import (
 	"connectordb/datastream"
 	"strconv"
+	"time"
 )
 
 //RedisCache reads batches from a single-instance redis server
@@ -46,6 +47,59 @@ func (r RedisCache) Insert(deviceID, streamID int64, substream string, dpa datas
 		maxStreamSize)
 }
 
+//InsertOnce inserts datapoints into the redis cache, unless the idempotency key was used within the window
+func (r RedisCache) InsertOnce(deviceID, streamID int64, substream, key string, window time.Duration, dpa datastream.DatapointArray, restamp bool, maxDeviceSize int64, maxStreamSize int64) (int64, error) {
+	return r.RedisConnection.InsertOnce("BATCHLIST",
+		strconv.FormatInt(deviceID, 36),
+		strconv.FormatInt(streamID, 36),
+		substream,
+		key, window,
+		dpa,
+		restamp,
+		maxDeviceSize,
+		maxStreamSize)
+}
+
+//StartBackfill locks the stream for a backfill of the given data, and returns the index of its first cached datapoint
+func (r RedisCache) StartBackfill(deviceID, streamID int64, substream, key string, dpa datastream.DatapointArray, maxDeviceSize, maxStreamSize int64) (int64, error) {
+	return r.RedisConnection.StartBackfill("BATCHLIST", "BATCHPROCESSING",
+		strconv.FormatInt(deviceID, 36),
+		strconv.FormatInt(streamID, 36),
+		substream,
+		key,
+		dpa,
+		maxDeviceSize,
+		maxStreamSize)
+}
+
+//FinishBackfill merges the data which was not written to the database into the cache, and unlocks the stream
+func (r RedisCache) FinishBackfill(deviceID, streamID int64, substream, key string, window time.Duration, written int, dpa datastream.DatapointArray) (int64, error) {
+	return r.RedisConnection.FinishBackfill("BATCHLIST",
+		strconv.FormatInt(deviceID, 36),
+		strconv.FormatInt(streamID, 36),
+		substream,
+		key, window,
+		written, dpa)
+}
+
+//AbortBackfill unlocks a stream whose backfill failed
+func (r RedisCache) AbortBackfill(deviceID, streamID int64, substream string) error {
+	return r.RedisConnection.AbortBackfill("BATCHLIST",
+		strconv.FormatInt(deviceID, 36),
+		strconv.FormatInt(streamID, 36),
+		substream)
+}
+
+//LockStream locks the substream while the stream's schema is changed, and returns its length
+func (r RedisCache) LockStream(deviceID, streamID int64, substream string) (int64, error) {
+	return r.RedisConnection.LockSubstream(strconv.FormatInt(deviceID, 36), strconv.FormatInt(streamID, 36), substream)
+}
+
+//UnlockStream releases the lock taken by LockStream
+func (r RedisCache) UnlockStream(deviceID, streamID int64, substream string) error {
+	return r.RedisConnection.UnlockSubstream(strconv.FormatInt(deviceID, 36), strconv.FormatInt(streamID, 36), substream)
+}
+
 //DeleteDevice removes a device from the redis cache
 func (r RedisCache) DeleteDevice(deviceID int64) error {
 	return r.DeleteHash(strconv.FormatInt(deviceID, 36))
@@ -56,6 +110,14 @@ func (r RedisCache) DeleteStream(deviceID, streamID int64) error {
 	return r.RedisConnection.DeleteStream(strconv.FormatInt(deviceID, 36), strconv.FormatInt(streamID, 36))
 }
 
+//MoveStream moves a stream's cached data from one device to another
+func (r RedisCache) MoveStream(deviceID, streamID, newDeviceID int64) error {
+	return r.RedisConnection.MoveStream("BATCHLIST", "BATCHPROCESSING",
+		strconv.FormatInt(deviceID, 36),
+		strconv.FormatInt(streamID, 36),
+		strconv.FormatInt(newDeviceID, 36))
+}
+
 //DeleteSubstream removes a substream fro mthe redis cache
 func (r RedisCache) DeleteSubstream(deviceID, streamID int64, substream string) error {
 	return r.RedisConnection.DeleteSubstream(strconv.FormatInt(deviceID, 36), strconv.FormatInt(streamID, 36), substream)
@@ -5,6 +5,7 @@ Licensed under the MIT license.
 package datastream
 
 import (
+	"database/sql"
 	"errors"
 	"time"
 
@@ -44,6 +45,11 @@ type SqlStore struct {
 	delsubstream *sqlx.Stmt
 	delstream    *sqlx.Stmt
 	clearall     *sqlx.Stmt
+	batchbefore  *sqlx.Stmt
+	delbatch     *sqlx.Stmt
+	batchafter   *sqlx.Stmt
+	shiftout     *sqlx.Stmt
+	shiftin      *sqlx.Stmt
 
 	db *sqlx.DB
 
@@ -71,8 +77,13 @@ func OpenSqlStore(db *sqlx.DB) (*SqlStore, error) {
 	delsubstream, err := prepStatement(db, "DELETE FROM datastream WHERE streamid=? AND substream=?;", err)
 	delstream, err := prepStatement(db, "DELETE FROM datastream WHERE streamid=?;", err)
 	clearall, err := prepStatement(db, "DELETE FROM datastream;", err)
+	batchbefore, err := prepStatement(db, "SELECT version,endindex,data FROM datastream WHERE streamid=? AND substream=? AND endindex < ? ORDER BY endindex DESC LIMIT 1;", err)
+	delbatch, err := prepStatement(db, "DELETE FROM datastream WHERE streamid=? AND substream=? AND endindex=?;", err)
+	batchafter, err := prepStatement(db, "SELECT version,endindex,data FROM datastream WHERE streamid=? AND substream=? AND endtime > ? ORDER BY endindex ASC LIMIT 1;", err)
+	shiftout, err := prepStatement(db, "UPDATE datastream SET endindex=-(endindex+?) WHERE streamid=? AND substream=? AND endindex > ?;", err)
+	shiftin, err := prepStatement(db, "UPDATE datastream SET endindex=-endindex WHERE streamid=? AND substream=? AND endindex < 0;", err)
 
-	ss := &SqlStore{inserter, timequery, indexquery, endindex, delsubstream, delstream, clearall, db, 2}
+	ss := &SqlStore{inserter, timequery, indexquery, endindex, delsubstream, delstream, clearall, batchbefore, delbatch, batchafter, shiftout, shiftin, db, 2}
 
 	if err != nil {
 		ss.Close()
@@ -103,6 +114,21 @@ func (s *SqlStore) Close() {
 	if s.delsubstream != nil {
 		s.delsubstream.Close()
 	}
+	if s.batchbefore != nil {
+		s.batchbefore.Close()
+	}
+	if s.delbatch != nil {
+		s.delbatch.Close()
+	}
+	if s.batchafter != nil {
+		s.batchafter.Close()
+	}
+	if s.shiftout != nil {
+		s.shiftout.Close()
+	}
+	if s.shiftin != nil {
+		s.shiftin.Close()
+	}
 }
 
 //Clear the entire table of all data
@@ -127,6 +153,75 @@ func (s *SqlStore) GetEndIndex(streamID int64, substream string) (ei int64, err
 	return ei, err
 }
 
+//readBatch runs the given batch query within the transaction, and returns the end index and data of the first batch.
+//If there is no such batch, the returned array is nil.
+func readBatch(tx *sqlx.Tx, stmt *sqlx.Stmt, args ...interface{}) (int64, DatapointArray, error) {
+	var version int
+	var endindex int64
+	var data []byte
+	err := tx.Stmtx(stmt).QueryRow(args...).Scan(&version, &endindex, &data)
+	if err == sql.ErrNoRows {
+		return 0, nil, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	da, err := DecodeDatapointArray(data, version)
+	if err != nil {
+		return 0, nil, err
+	}
+	if da == nil || da.Length() == 0 || int64(da.Length()) > endindex {
+		return 0, nil, ErrorDatabaseCorrupted
+	}
+	return endindex, *da, nil
+}
+
+//batchBefore returns the last batch which ends before the given index, reading within the transaction
+func (s *SqlStore) batchBefore(tx *sqlx.Tx, streamID int64, substream string, index int64) (int64, DatapointArray, error) {
+	return readBatch(tx, s.batchbefore, streamID, substream, index)
+}
+
+//batchAt returns the batch which holds the datapoint at the given index, reading within the transaction
+func (s *SqlStore) batchAt(tx *sqlx.Tx, streamID int64, substream string, index int64) (int64, DatapointArray, error) {
+	return readBatch(tx, s.indexquery, streamID, substream, index)
+}
+
+//batchAfterTime returns the first batch which ends after the given timestamp, reading within the transaction
+func (s *SqlStore) batchAfterTime(tx *sqlx.Tx, streamID int64, substream string, timestamp float64) (int64, DatapointArray, error) {
+	return readBatch(tx, s.batchafter, streamID, substream, timestamp)
+}
+
+//shiftBatches moves all batches which end after the given index forward by n within the transaction. The batches
+//are moved to negative indices first, so that no batch is moved onto another while the rows are updated.
+func (s *SqlStore) shiftBatches(tx *sqlx.Tx, streamID int64, substream string, index, n int64) error {
+	if _, err := tx.Stmtx(s.shiftout).Exec(n, streamID, substream, index); err != nil {
+		return err
+	}
+	_, err := tx.Stmtx(s.shiftin).Exec(streamID, substream)
+	return err
+}
+
+//rewriteBatch replaces the batch which ends at the given index with the given data within the transaction. The data
+//is written in batches of the given size, the last of which ends at newindex. There must be no other batches between
+//the start of the replaced batch and newindex.
+func (s *SqlStore) rewriteBatch(tx *sqlx.Tx, streamID int64, substream string, endindex, newindex int64, batchsize int, da DatapointArray) error {
+	if _, err := tx.Stmtx(s.delbatch).Exec(streamID, substream, endindex); err != nil {
+		return err
+	}
+	startindex := newindex - int64(len(da))
+	inserter := tx.Stmtx(s.inserter)
+	for i := 0; i < len(da); i += batchsize {
+		end := i + batchsize
+		if end > len(da) {
+			end = len(da)
+		}
+		if err := s.stmtInsert(inserter, streamID, substream, startindex+int64(i), da[i:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 //Insert the given DatapointArray into the sql database given the startindex of the array for the key.
 func (s *SqlStore) Insert(streamID int64, substream string, startindex int64, da DatapointArray) error {
 	return s.stmtInsert(s.inserter, streamID, substream, startindex, da)
@@ -285,3 +285,57 @@ func BenchmarkSql1000Read(b *testing.B) {
 		r.Close()
 	}
 }
+
+func TestRewriteBatch(t *testing.T) {
+	sdb.Clear()
+
+	require.NoError(t, sdb.Insert(1, "", 0, dpa6))
+
+	tx, err := sdb.db.Beginx()
+	require.NoError(t, err)
+	endindex, batch, err := sdb.batchBefore(tx, 1, "", 6)
+	require.NoError(t, err)
+	require.EqualValues(t, 5, endindex)
+	require.True(t, dpa6.IsEqual(batch))
+
+	// The batch is split when rewritten
+	require.NoError(t, sdb.rewriteBatch(tx, 1, "", 5, 6, 3, append(DatapointArray{Datapoint{0.5, 0.5, ""}}, batch...)))
+
+	endindex, batch, err = sdb.batchAt(tx, 1, "", 3)
+	require.NoError(t, err)
+	require.EqualValues(t, 6, endindex)
+	require.True(t, dpa6[2:].IsEqual(batch))
+	endindex, batch, err = sdb.batchBefore(tx, 1, "", 0)
+	require.NoError(t, err)
+	require.Nil(t, batch)
+	require.NoError(t, tx.Commit())
+
+	i, err := sdb.GetEndIndex(1, "")
+	require.NoError(t, err)
+	require.EqualValues(t, 6, i)
+}
+
+func TestShiftBatches(t *testing.T) {
+	sdb.Clear()
+
+	require.NoError(t, sdb.Insert(1, "", 0, dpa6[:2]))
+	require.NoError(t, sdb.Insert(1, "", 2, dpa6[2:]))
+
+	tx, err := sdb.db.Beginx()
+	require.NoError(t, err)
+	endindex, batch, err := sdb.batchAfterTime(tx, 1, "", 2.0)
+	require.NoError(t, err)
+	require.EqualValues(t, 5, endindex)
+	require.True(t, dpa6[2:].IsEqual(batch))
+
+	require.NoError(t, sdb.shiftBatches(tx, 1, "", 2, 3))
+	endindex, batch, err = sdb.batchAt(tx, 1, "", 2)
+	require.NoError(t, err)
+	require.EqualValues(t, 8, endindex)
+	require.True(t, dpa6[2:].IsEqual(batch))
+	endindex, batch, err = sdb.batchBefore(tx, 1, "", 8)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, endindex)
+	require.True(t, dpa6[:2].IsEqual(batch))
+	require.NoError(t, tx.Commit())
+}
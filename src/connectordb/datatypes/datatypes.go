@@ -0,0 +1,268 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package datatypes
+
+/*
+Package datatypes holds the registry of known stream datatypes. A stream's datatype is a free-form string,
+but if it matches a registered datatype, the stream gets a default schema (and its schema must be compatible
+with the datatype), unit conversion on read, and a sensible default interpolator when used in datasets.
+*/
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+var (
+	//ErrUnknownUnit is returned when a conversion to an unsupported unit is requested
+	ErrUnknownUnit = errors.New("The datatype does not support the given unit")
+	//ErrNoUnits is returned when a unit conversion is requested on a datatype without units
+	ErrNoUnits = errors.New("The stream's datatype does not have units")
+	//ErrIncompatibleSchema is returned when a stream's schema can't hold the data of its datatype
+	ErrIncompatibleSchema = errors.New("The schema is incompatible with the stream's datatype")
+
+	//RegistryLock is locked when reading/writing the Registry
+	RegistryLock = &sync.RWMutex{}
+	//Registry holds all of the known datatypes, indexed by name
+	Registry = map[string]Datatype{}
+)
+
+//Unit describes a linear conversion from the datatype's base unit: converted = value*Scale + Offset
+type Unit struct {
+	Scale  float64 `json:"scale"`
+	Offset float64 `json:"offset"`
+}
+
+//Convert converts a value given in the base unit to this unit
+func (u Unit) Convert(v float64) float64 {
+	return v*u.Scale + u.Offset
+}
+
+//Transform returns the PipeScript transform which converts data given in the base unit to this unit.
+//The conversion is a transform so that it can be used everywhere a transform can, including live subscriptions.
+func (u Unit) Transform() string {
+	t := "$*" + strconv.FormatFloat(u.Scale, 'f', -1, 64)
+	if u.Offset > 0 {
+		t += "+" + strconv.FormatFloat(u.Offset, 'f', -1, 64)
+	} else if u.Offset < 0 {
+		t += "-" + strconv.FormatFloat(-u.Offset, 'f', -1, 64)
+	}
+	return t
+}
+
+//Datatype is a registered stream datatype
+type Datatype struct {
+	Name         string          `json:"name"`
+	Description  string          `json:"description"`
+	Schema       string          `json:"schema"`                 //The default schema of streams with this datatype
+	Interpolator string          `json:"interpolator,omitempty"` //The default interpolator to use in datasets
+	Unit         string          `json:"unit,omitempty"`         //The unit in which data is stored
+	Units        map[string]Unit `json:"units,omitempty"`        //The units the data can be converted to on read
+}
+
+//GetUnit returns the conversion to the given unit
+func (d *Datatype) GetUnit(unit string) (Unit, error) {
+	if d.Unit == "" {
+		return Unit{}, ErrNoUnits
+	}
+	if unit == d.Unit {
+		return Unit{Scale: 1}, nil
+	}
+	u, ok := d.Units[unit]
+	if !ok {
+		return Unit{}, ErrUnknownUnit
+	}
+	return u, nil
+}
+
+//UnitTransform returns the transform which converts the datatype's data to the given unit, or "" if the data
+//is already in the unit
+func (d *Datatype) UnitTransform(unit string) (string, error) {
+	u, err := d.GetUnit(unit)
+	if err != nil || unit == d.Unit {
+		return "", err
+	}
+	return u.Transform(), nil
+}
+
+//CheckSchema returns ErrIncompatibleSchema if the given schema can't hold the data of the datatype. The schema
+//can be stricter than the datatype's default schema (such as allowing a smaller range of values), but it must
+//have the same type, and objects must require the fields that the datatype requires, with compatible schemas.
+func (d *Datatype) CheckSchema(schema string) error {
+	var want, got map[string]interface{}
+	if err := json.Unmarshal([]byte(d.Schema), &want); err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(schema), &got); err != nil {
+		return err
+	}
+	if !compatible(want, got) {
+		return ErrIncompatibleSchema
+	}
+	return nil
+}
+
+// compatible returns true if data valid under the got schema has the type and required fields of the want schema
+func compatible(want, got map[string]interface{}) bool {
+	if got["type"] != want["type"] && !(want["type"] == "number" && got["type"] == "integer") {
+		return false
+	}
+	required, _ := want["required"].([]interface{})
+	if len(required) == 0 {
+		return true
+	}
+	gotRequired, _ := got["required"].([]interface{})
+	wantProperties, _ := want["properties"].(map[string]interface{})
+	gotProperties, _ := got["properties"].(map[string]interface{})
+	for _, name := range required {
+		found := false
+		for _, n := range gotRequired {
+			found = found || n == name
+		}
+		if !found {
+			return false
+		}
+		key, _ := name.(string)
+		w, _ := wantProperties[key].(map[string]interface{})
+		g, ok := gotProperties[key].(map[string]interface{})
+		if w != nil && (!ok || !compatible(w, g)) {
+			return false
+		}
+	}
+	return true
+}
+
+//Register adds the given datatype to the registry
+func Register(d Datatype) error {
+	if d.Name == "" {
+		return errors.New("A datatype must have a name")
+	}
+	RegistryLock.Lock()
+	defer RegistryLock.Unlock()
+	if _, ok := Registry[d.Name]; ok {
+		return fmt.Errorf("Datatype '%s' is already registered", d.Name)
+	}
+	Registry[d.Name] = d
+	return nil
+}
+
+//Get returns the datatype with the given name, and whether it was found
+func Get(name string) (*Datatype, bool) {
+	RegistryLock.RLock()
+	defer RegistryLock.RUnlock()
+	d, ok := Registry[name]
+	if !ok {
+		return nil, false
+	}
+	return &d, true
+}
+
+// Register the datatypes built into ConnectorDB
+func init() {
+	Register(Datatype{
+		Name:         "rating.stars",
+		Description:  "A star rating from 0 to 10",
+		Schema:       `{"type":"integer","minimum":0,"maximum":10}`,
+		Interpolator: "before",
+	})
+	Register(Datatype{
+		Name:         "location.gps",
+		Description:  "A GPS location in decimal degrees, with optional altitude (m), accuracy (m) and speed (m/s)",
+		Schema:       `{"type":"object","properties":{"latitude":{"type":"number"},"longitude":{"type":"number"},"altitude":{"type":"number"},"accuracy":{"type":"number"},"speed":{"type":"number"}},"required":["latitude","longitude"]}`,
+		Interpolator: "closest",
+	})
+	Register(Datatype{
+		Name:         "physical.elevation",
+		Description:  "An elevation above sea level, stored in meters",
+		Schema:       `{"type":"number"}`,
+		Interpolator: "closest",
+		Unit:         "m",
+		Units: map[string]Unit{
+			"ft": Unit{Scale: 1 / 0.3048},
+		},
+	})
+	Register(Datatype{
+		Name:         "health.heartrate",
+		Description:  "A heart rate in beats per minute",
+		Schema:       `{"type":"number","minimum":0}`,
+		Interpolator: "closest",
+	})
+	Register(Datatype{
+		Name:         "activity.cadence",
+		Description:  "The cadence of a run or ride, in revolutions (or steps) per minute",
+		Schema:       `{"type":"number","minimum":0}`,
+		Interpolator: "closest",
+	})
+	Register(Datatype{
+		Name:         "physical.distance",
+		Description:  "A distance, stored in meters",
+		Schema:       `{"type":"number","minimum":0}`,
+		Interpolator: "before",
+		Unit:         "m",
+		Units: map[string]Unit{
+			"km": Unit{Scale: 0.001},
+			"mi": Unit{Scale: 1 / 1609.344},
+			"ft": Unit{Scale: 1 / 0.3048},
+		},
+	})
+	Register(Datatype{
+		Name:         "physical.energy",
+		Description:  "An amount of energy, stored in kilocalories",
+		Schema:       `{"type":"number","minimum":0}`,
+		Interpolator: "before",
+		Unit:         "kcal",
+		Units: map[string]Unit{
+			"kJ": Unit{Scale: 4.184},
+		},
+	})
+	Register(Datatype{
+		Name:         "physical.weight",
+		Description:  "A body weight, stored in kilograms",
+		Schema:       `{"type":"number","minimum":0}`,
+		Interpolator: "before",
+		Unit:         "kg",
+		Units: map[string]Unit{
+			"lb": Unit{Scale: 1 / 0.45359237},
+		},
+	})
+	Register(Datatype{
+		Name:         "activity.steps",
+		Description:  "A number of steps taken",
+		Schema:       `{"type":"number","minimum":0}`,
+		Interpolator: "before",
+	})
+	Register(Datatype{
+		Name:         "activity.flights",
+		Description:  "A number of flights of stairs climbed",
+		Schema:       `{"type":"number","minimum":0}`,
+		Interpolator: "before",
+	})
+	Register(Datatype{
+		Name:         "activity.type",
+		Description:  "The type of activity being done (such as walking or in a vehicle)",
+		Schema:       `{"type":"string"}`,
+		Interpolator: "before",
+	})
+	Register(Datatype{
+		Name:         "health.sleep",
+		Description:  "A period of sleep (or time in bed), with its duration in seconds",
+		Schema:       `{"type":"object","properties":{"state":{"type":"string"},"duration":{"type":"number","minimum":0}},"required":["state","duration"]}`,
+		Interpolator: "before",
+	})
+	Register(Datatype{
+		Name:         "physical.temperature",
+		Description:  "A temperature, stored in degrees Celsius",
+		Schema:       `{"type":"number"}`,
+		Interpolator: "closest",
+		Unit:         "C",
+		Units: map[string]Unit{
+			"F": Unit{Scale: 1.8, Offset: 32},
+			"K": Unit{Scale: 1, Offset: 273.15},
+		},
+	})
+}
@@ -0,0 +1,59 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package datatypes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	d, ok := Get("physical.temperature")
+	require.True(t, ok)
+	require.Equal(t, "C", d.Unit)
+
+	_, ok = Get("notadatatype")
+	require.False(t, ok)
+
+	require.Error(t, Register(Datatype{Name: "physical.temperature"}))
+	require.Error(t, Register(Datatype{}))
+}
+
+func TestUnitTransform(t *testing.T) {
+	d, ok := Get("physical.temperature")
+	require.True(t, ok)
+
+	tf, err := d.UnitTransform("F")
+	require.NoError(t, err)
+	require.Equal(t, "$*1.8+32", tf)
+	tf, err = d.UnitTransform("C")
+	require.NoError(t, err)
+	require.Equal(t, "", tf)
+	_, err = d.UnitTransform("Q")
+	require.Equal(t, ErrUnknownUnit, err)
+
+	require.Equal(t, "$*2-1.5", Unit{Scale: 2, Offset: -1.5}.Transform())
+
+	d, ok = Get("location.gps")
+	require.True(t, ok)
+	_, err = d.UnitTransform("F")
+	require.Equal(t, ErrNoUnits, err)
+}
+
+func TestCheckSchema(t *testing.T) {
+	d, _ := Get("physical.temperature")
+	require.NoError(t, d.CheckSchema(`{"type":"number","minimum":-50}`))
+	require.NoError(t, d.CheckSchema(`{"type":"integer"}`))
+	require.Equal(t, ErrIncompatibleSchema, d.CheckSchema(`{"type":"string"}`))
+	require.Equal(t, ErrIncompatibleSchema, d.CheckSchema(`{}`))
+	require.Error(t, d.CheckSchema(`notjson`))
+
+	d, _ = Get("location.gps")
+	require.NoError(t, d.CheckSchema(d.Schema))
+	require.NoError(t, d.CheckSchema(`{"type":"object","properties":{"latitude":{"type":"number"},"longitude":{"type":"number"},"name":{"type":"string"}},"required":["latitude","longitude","name"]}`))
+	require.Equal(t, ErrIncompatibleSchema, d.CheckSchema(`{"type":"object","properties":{"latitude":{"type":"number"}},"required":["latitude"]}`))
+	require.Equal(t, ErrIncompatibleSchema, d.CheckSchema(`{"type":"object","properties":{"latitude":{"type":"string"},"longitude":{"type":"number"}},"required":["latitude","longitude"]}`))
+}
@@ -4,6 +4,7 @@ import (
 	pconfig "config/permissions"
 	"connectordb/authoperator/permissions"
 	"connectordb/users"
+	"connectordb/webhook"
 	"errors"
 	"fmt"
 
@@ -60,7 +61,11 @@ func (db *Database) CountDevices() (int64, error) {
 
 // ReadAllDevicesByUserID returns all devices that belong to the given user
 func (db *Database) ReadAllDevicesByUserID(userID int64) ([]*users.Device, error) {
-	return db.Userdb.ReadDevicesForUserID(userID)
+	devs, err := db.Userdb.ReadDevicesForUserID(userID)
+	for i := range devs {
+		db.fillPresence(devs[i])
+	}
+	return devs, err
 }
 
 // CreateDeviceByUserID creates a new device for the given user. It ensures that the permitted number
@@ -76,18 +81,35 @@ func (db *Database) CreateDeviceByUserID(d *users.DeviceMaker) error {
 	if err = d.Validate(int(maxstream)); err != nil {
 		return err
 	}
+	if d.WebhookRules != "" {
+		if _, err = webhook.ParseRules(d.WebhookRules); err != nil {
+			return err
+		}
+	}
 	d.Devicelimit = maxdev
 	return db.Userdb.CreateDevice(d)
 }
 
 // ReadDeviceByID reads the given device
 func (db *Database) ReadDeviceByID(deviceID int64) (*users.Device, error) {
-	return db.Userdb.ReadDeviceByID(deviceID)
+	dev, err := db.Userdb.ReadDeviceByID(deviceID)
+	if err == nil {
+		db.fillPresence(dev)
+	}
+	return dev, err
 }
 
 // ReadDeviceByUserID reads a device given its user id and device name
 func (db *Database) ReadDeviceByUserID(userID int64, devicename string) (*users.Device, error) {
-	return db.Userdb.ReadDeviceForUserByName(userID, devicename)
+	dev, err := db.Userdb.ReadDeviceForUserByName(userID, devicename)
+	if err == users.ErrDeviceNotFound {
+		// The name might be the old name of a renamed device
+		return db.readDeviceByAlias(userID, devicename)
+	}
+	if err == nil {
+		db.fillPresence(dev)
+	}
+	return dev, err
 }
 
 // UpdateDeviceByID updates the device with the given map of update fields
@@ -107,7 +129,7 @@ func (db *Database) UpdateDeviceByID(deviceID int64, updates map[string]interfac
 	}
 
 	if d.Name != oldname {
-		return errors.New("ConnectorDB does not support modification of device names")
+		return errors.New("Devices are renamed with a rename, not an update")
 	}
 
 	if !d.Public && waspublic {
@@ -134,6 +156,18 @@ func (db *Database) UpdateDeviceByID(deviceID int64, updates map[string]interfac
 		}
 		d.APIKey = newkey.String()
 	}
+	if d.WebhookToken == "" {
+		newkey, err := uuid.NewV4()
+		if err != nil {
+			return fmt.Errorf("Failed to generate webhook token: %s", err.Error())
+		}
+		d.WebhookToken = newkey.String()
+	}
+	if d.WebhookRules != "" {
+		if _, err = webhook.ParseRules(d.WebhookRules); err != nil {
+			return err
+		}
+	}
 
 	return db.Userdb.UpdateDevice(d)
 }
@@ -150,6 +184,9 @@ func (db *Database) DeleteDeviceByID(deviceID int64) error {
 	if d.Name == "user" || d.Name == "meta" {
 		return errors.New(d.Name + " device cannot be deleted")
 	}
+	if db.trash.period > 0 {
+		return db.Userdb.TrashDevice(deviceID, now())
+	}
 
 	err = db.Userdb.DeleteDevice(deviceID)
 	if err == nil {
@@ -0,0 +1,172 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package connectordb
+
+import (
+	"connectordb/datastream"
+	"connectordb/operator"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"util/datapoint"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// ExportInfo contains the information necessary for an importer to import the database
+type ExportInfo struct {
+	Version     int    // The export format version
+	ConnectorDB string // The version of ConnectorDB that generated the export
+	Format      string `json:",omitempty"` // The format of the stream data if not json. Only json exports can be imported.
+}
+
+// ExportVersion is the version of the export format
+const ExportVersion = 2
+
+// ExportFormats gives the file extension of each format in which stream data can be exported
+var ExportFormats = map[string]string{
+	"json":    ".json",
+	"parquet": ".parquet",
+	"arrow":   ".arrows",
+}
+
+//WriteStreamDataToFile writes the given DataRange to a file as a json array
+func WriteStreamDataToFile(filename string, dr datastream.DataRange) error {
+	jreader, err := datapoint.NewJsonArrayReader(dr)
+	if err == io.EOF {
+		// There is no data in the stream
+		return ioutil.WriteFile(filename, []byte("[]"), 0666)
+	}
+	if err != nil {
+		return err
+	}
+	defer jreader.Close()
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, jreader)
+	return err
+
+}
+
+//WriteStreamData writes the given DataRange to the file with the given name (without extension) in the given format.
+//The schema is the JSON schema of the data, which gives the columns of parquet and arrow files.
+func WriteStreamData(filename string, dr datastream.DataRange, schema, format string) error {
+	if format == "json" {
+		return WriteStreamDataToFile(filename+ExportFormats[format], dr)
+	}
+	defer dr.Close()
+
+	f, err := os.Create(filename + ExportFormats[format])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if format == "parquet" {
+		return datapoint.WriteParquet(f, dr, schema)
+	}
+	return datapoint.WriteArrow(f, dr, schema)
+}
+
+// writeJSONFile writes the object as indented json to the given file
+func writeJSONFile(filename string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, b, 0700)
+}
+
+// WriteExportInfo writes the ExportInfo of an export in the given format to the export directory. It is
+// written after all of the data, so that an import knows the exporter version
+func WriteExportInfo(dir string, format string) error {
+	return writeJSONFile(path.Join(dir, "connectordb.json"), ExportInfo{
+		Version:     ExportVersion,
+		ConnectorDB: Version,
+		Format:      format,
+	})
+}
+
+// ExportUser writes the given user, along with all of their devices and streams, and the data of each stream,
+// into a new subdirectory of dir named after the user. Only what the operator has permission to read is exported.
+func ExportUser(o operator.Operator, dir string, username string, format string) error {
+	usr, err := o.ReadUser(username)
+	if err != nil {
+		return err
+	}
+	usrdir := path.Join(dir, usr.Name)
+
+	if err = os.Mkdir(usrdir, 0700); err != nil {
+		return err
+	}
+	if err = writeJSONFile(path.Join(usrdir, "user.json"), usr); err != nil {
+		return err
+	}
+
+	dev, err := o.ReadAllDevicesByUserID(usr.UserID)
+	if err != nil {
+		return err
+	}
+	for d := range dev {
+		log.Info("............. ", usr.Name, "/", dev[d].Name)
+		devdir := path.Join(usrdir, dev[d].Name)
+
+		if err = os.Mkdir(devdir, 0700); err != nil {
+			return err
+		}
+		if err = writeJSONFile(path.Join(devdir, "device.json"), dev[d]); err != nil {
+			return err
+		}
+
+		strm, err := o.ReadAllStreamsByDeviceID(dev[d].DeviceID)
+		if err != nil {
+			return err
+		}
+		for s := range strm {
+			log.Debug("............. ", usr.Name, "/", dev[d].Name, "/", strm[s].Name)
+			sdir := path.Join(devdir, strm[s].Name)
+
+			if err = os.Mkdir(sdir, 0700); err != nil {
+				return err
+			}
+			if err = writeJSONFile(path.Join(sdir, "stream.json"), strm[s]); err != nil {
+				return err
+			}
+
+			// The schema is only known if all of the data has the stream's current schema
+			schema := strm[s].Schema
+			if strm[s].SchemaVersion > 0 {
+				schema = ""
+			}
+
+			// Now we write the stream's data, and if it exists, the downlink stream
+			dr, err := o.GetStreamIndexRangeByID(strm[s].StreamID, "", 0, 0, "")
+			if err != nil {
+				return err
+			}
+			if err = WriteStreamData(path.Join(sdir, "data"), dr, schema, format); err != nil {
+				return err
+			}
+
+			if strm[s].Downlink {
+				dr, err := o.GetStreamIndexRangeByID(strm[s].StreamID, "downlink", 0, 0, "")
+				if err != nil {
+					return err
+				}
+				if err = WriteStreamData(path.Join(sdir, "downlink"), dr, schema, format); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
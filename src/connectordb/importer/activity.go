@@ -0,0 +1,95 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"connectordb/datastream"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Sample is a single recorded point of an activity. Values which were not recorded are nil.
+type Sample struct {
+	Timestamp float64
+
+	Latitude  *float64
+	Longitude *float64
+	Elevation *float64 // meters
+	HeartRate *float64 // beats per minute
+	Cadence   *float64 // revolutions per minute
+}
+
+// ActivityParsers holds the parsers of activity file formats, indexed by the format's file extension
+var ActivityParsers = map[string]func(r io.Reader) ([]Sample, error){
+	"gpx": ParseGPX,
+	"tcx": ParseTCX,
+	"fit": ParseFIT,
+}
+
+// ActivityFormat returns the format of the activity file with the given name. If the file extension is not a known
+// format, the format is detected from the file's beginning.
+func ActivityFormat(filename string, head []byte) (string, error) {
+	format := strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), "."))
+	if _, ok := ActivityParsers[format]; ok {
+		return format, nil
+	}
+	switch {
+	case len(head) >= 12 && string(head[8:12]) == ".FIT":
+		return "fit", nil
+	case bytes.Contains(head, []byte("<gpx")):
+		return "gpx", nil
+	case bytes.Contains(head, []byte("<TrainingCenterDatabase")):
+		return "tcx", nil
+	}
+	return "", fmt.Errorf("Could not recognize the format of the activity file '%s'", filename)
+}
+
+// ParseActivity parses the activity file with the given name (used to find its format), and returns its data
+// grouped by kind: location, elevation, heartrate and cadence
+func ParseActivity(filename string, r io.Reader) (map[string]datastream.DatapointArray, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(512)
+	format, err := ActivityFormat(filename, head)
+	if err != nil {
+		return nil, err
+	}
+	samples, err := ActivityParsers[format](br)
+	if err != nil {
+		return nil, err
+	}
+	return ActivityDatapoints(samples), nil
+}
+
+// ActivityDatapoints converts an activity's samples into datapoints grouped by kind. Locations include the
+// altitude when the sample has an elevation.
+func ActivityDatapoints(samples []Sample) map[string]datastream.DatapointArray {
+	data := make(map[string]datastream.DatapointArray)
+	add := func(kind string, t float64, v interface{}) {
+		data[kind] = append(data[kind], datastream.Datapoint{Timestamp: t, Data: v})
+	}
+	for _, s := range samples {
+		if s.Latitude != nil && s.Longitude != nil {
+			loc := map[string]interface{}{"latitude": *s.Latitude, "longitude": *s.Longitude}
+			if s.Elevation != nil {
+				loc["altitude"] = *s.Elevation
+			}
+			add("location", s.Timestamp, loc)
+		}
+		if s.Elevation != nil {
+			add("elevation", s.Timestamp, *s.Elevation)
+		}
+		if s.HeartRate != nil {
+			add("heartrate", s.Timestamp, *s.HeartRate)
+		}
+		if s.Cadence != nil {
+			add("cadence", s.Timestamp, *s.Cadence)
+		}
+	}
+	return data
+}
@@ -0,0 +1,150 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package importer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testGPX = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1" xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v1">
+ <trk><name>Morning Run</name><trkseg>
+  <trkpt lat="40.1" lon="-88.2"><ele>220.5</ele><time>2016-05-01T10:00:00Z</time>
+   <extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>120</gpxtpx:hr><gpxtpx:cad>80</gpxtpx:cad></gpxtpx:TrackPointExtension></extensions>
+  </trkpt>
+  <trkpt lat="40.2" lon="-88.3"><time>2016-05-01T10:00:01.5Z</time></trkpt>
+  <trkpt lat="40.3" lon="-88.4"><ele>221</ele></trkpt>
+ </trkseg></trk>
+</gpx>`
+
+var testTCX = `<?xml version="1.0" encoding="UTF-8"?>
+<TrainingCenterDatabase xmlns="http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2">
+ <Activities><Activity Sport="Running"><Id>2016-05-01T10:00:00Z</Id><Lap StartTime="2016-05-01T10:00:00Z"><Track>
+  <Trackpoint>
+   <Time>2016-05-01T10:00:00Z</Time>
+   <Position><LatitudeDegrees>40.1</LatitudeDegrees><LongitudeDegrees>-88.2</LongitudeDegrees></Position>
+   <AltitudeMeters>220.5</AltitudeMeters>
+   <HeartRateBpm><Value>120</Value></HeartRateBpm>
+   <Extensions><TPX xmlns="http://www.garmin.com/xmlschemas/ActivityExtension/v2"><RunCadence>85</RunCadence></TPX></Extensions>
+  </Trackpoint>
+  <Trackpoint><Time>2016-05-01T10:00:05Z</Time><HeartRateBpm><Value>125</Value></HeartRateBpm><Cadence>90</Cadence></Trackpoint>
+ </Track></Lap></Activity></Activities>
+</TrainingCenterDatabase>`
+
+// fitFile builds a FIT file with a definition of record messages, and the given data messages
+func fitFile(records ...[]byte) []byte {
+	var body bytes.Buffer
+	// Definition of local message 0: record, little endian, fields timestamp, lat, long, altitude, heart rate, cadence
+	body.Write([]byte{0x40, 0, 0, 20, 0, 6, 253, 4, 0x86, 0, 4, 0x85, 1, 4, 0x85, 2, 2, 0x84, 3, 1, 2, 4, 1, 2})
+	// Definition of local message 1: a file_id message (global 0), which is skipped
+	body.Write([]byte{0x41, 0, 0, 0, 0, 1, 1, 2, 0x84})
+	body.Write([]byte{0x01, 0xFF, 0x00})
+	for _, r := range records {
+		body.Write(r)
+	}
+
+	header := []byte{12, 0x10, 0, 0, 0, 0, 0, 0, '.', 'F', 'I', 'T'}
+	binary.LittleEndian.PutUint32(header[4:8], uint32(body.Len()))
+	return append(append(header, body.Bytes()...), 0, 0)
+}
+
+func fitRecord(timestamp uint32, lat, long int32, alt uint16, hr, cad byte) []byte {
+	b := make([]byte, 1+4+4+4+2+1+1)
+	binary.LittleEndian.PutUint32(b[1:], timestamp)
+	binary.LittleEndian.PutUint32(b[5:], uint32(lat))
+	binary.LittleEndian.PutUint32(b[9:], uint32(long))
+	binary.LittleEndian.PutUint16(b[13:], alt)
+	b[15] = hr
+	b[16] = cad
+	return b
+}
+
+func TestParseGPX(t *testing.T) {
+	s, err := ParseGPX(strings.NewReader(testGPX))
+	require.NoError(t, err)
+	require.Len(t, s, 2)
+
+	require.Equal(t, 1462096800., s[0].Timestamp)
+	require.Equal(t, 40.1, *s[0].Latitude)
+	require.Equal(t, -88.2, *s[0].Longitude)
+	require.Equal(t, 220.5, *s[0].Elevation)
+	require.Equal(t, 120., *s[0].HeartRate)
+	require.Equal(t, 80., *s[0].Cadence)
+
+	require.Equal(t, 1462096801.5, s[1].Timestamp)
+	require.Nil(t, s[1].Elevation)
+	require.Nil(t, s[1].HeartRate)
+}
+
+func TestParseTCX(t *testing.T) {
+	s, err := ParseTCX(strings.NewReader(testTCX))
+	require.NoError(t, err)
+	require.Len(t, s, 2)
+
+	require.Equal(t, 1462096800., s[0].Timestamp)
+	require.Equal(t, 40.1, *s[0].Latitude)
+	require.Equal(t, 220.5, *s[0].Elevation)
+	require.Equal(t, 120., *s[0].HeartRate)
+	require.Equal(t, 85., *s[0].Cadence)
+
+	require.Nil(t, s[1].Latitude)
+	require.Equal(t, 125., *s[1].HeartRate)
+	require.Equal(t, 90., *s[1].Cadence)
+}
+
+func TestParseFIT(t *testing.T) {
+	// 40 degrees is 477218588 semicircles, and an altitude of 220m is stored as (220+500)*5
+	f := fitFile(
+		fitRecord(830000000, 477218588, -477218588, 3600, 120, 80),
+		fitRecord(830000001, 0x7FFFFFFF, 0x7FFFFFFF, 0xFFFF, 121, 0xFF),
+	)
+	s, err := ParseFIT(bytes.NewReader(f))
+	require.NoError(t, err)
+	require.Len(t, s, 2)
+
+	require.Equal(t, 1461065600., s[0].Timestamp)
+	require.InDelta(t, 40., *s[0].Latitude, 1e-6)
+	require.InDelta(t, -40., *s[0].Longitude, 1e-6)
+	require.Equal(t, 220., *s[0].Elevation)
+	require.Equal(t, 120., *s[0].HeartRate)
+	require.Equal(t, 80., *s[0].Cadence)
+
+	require.Equal(t, 1461065601., s[1].Timestamp)
+	require.Nil(t, s[1].Latitude)
+	require.Nil(t, s[1].Elevation)
+	require.Nil(t, s[1].Cadence)
+	require.Equal(t, 121., *s[1].HeartRate)
+
+	_, err = ParseFIT(strings.NewReader(testGPX))
+	require.Equal(t, ErrNotFIT, err)
+}
+
+func TestParseActivity(t *testing.T) {
+	f, err := ActivityFormat("run.GPX", nil)
+	require.NoError(t, err)
+	require.Equal(t, "gpx", f)
+	f, err = ActivityFormat("upload", []byte(testTCX))
+	require.NoError(t, err)
+	require.Equal(t, "tcx", f)
+	f, err = ActivityFormat("upload", fitFile())
+	require.NoError(t, err)
+	require.Equal(t, "fit", f)
+	_, err = ActivityFormat("upload.txt", []byte("hello"))
+	require.Error(t, err)
+
+	data, err := ParseActivity("run", strings.NewReader(testGPX))
+	require.NoError(t, err)
+	require.Len(t, data["location"], 2)
+	require.Equal(t, map[string]interface{}{"latitude": 40.1, "longitude": -88.2, "altitude": 220.5}, data["location"][0].Data)
+	require.Equal(t, map[string]interface{}{"latitude": 40.2, "longitude": -88.3}, data["location"][1].Data)
+	require.Len(t, data["elevation"], 1)
+	require.Len(t, data["heartrate"], 1)
+	require.Len(t, data["cadence"], 1)
+}
@@ -0,0 +1,109 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package importer
+
+import (
+	"connectordb/datastream"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// appleHealthTime is the format of the dates in an Apple Health export
+const appleHealthTime = "2006-01-02 15:04:05 -0700"
+
+// HealthType maps an Apple Health quantity type to a kind of imported data
+type HealthType struct {
+	Kind  string
+	Units map[string]float64 // The factor which converts each of the type's units to the kind's unit
+}
+
+// AppleHealthTypes holds the Apple Health record types which are imported, indexed by the record type.
+// Sleep analysis is imported separately, since it is a category rather than a quantity.
+var AppleHealthTypes = map[string]HealthType{
+	"HKQuantityTypeIdentifierHeartRate":              {"heartrate", map[string]float64{"count/min": 1}},
+	"HKQuantityTypeIdentifierStepCount":              {"steps", map[string]float64{"count": 1}},
+	"HKQuantityTypeIdentifierFlightsClimbed":         {"flights", map[string]float64{"count": 1}},
+	"HKQuantityTypeIdentifierDistanceWalkingRunning": {"distance", map[string]float64{"m": 1, "km": 1000, "mi": 1609.344, "ft": 0.3048}},
+	"HKQuantityTypeIdentifierActiveEnergyBurned":     {"energy", map[string]float64{"kcal": 1, "Cal": 1, "kJ": 1 / 4.184}},
+	"HKQuantityTypeIdentifierBodyMass":               {"weight", map[string]float64{"kg": 1, "g": 0.001, "lb": 0.45359237}},
+}
+
+const appleHealthSleep = "HKCategoryTypeIdentifierSleepAnalysis"
+
+// healthRecord returns the kind and datapoint of an Apple Health record. If the record is not imported,
+// the kind is empty.
+func healthRecord(attrs []xml.Attr) (string, datastream.Datapoint, error) {
+	a := make(map[string]string, len(attrs))
+	for _, attr := range attrs {
+		a[attr.Name.Local] = attr.Value
+	}
+
+	ht, ok := AppleHealthTypes[a["type"]]
+	if !ok && a["type"] != appleHealthSleep {
+		return "", datastream.Datapoint{}, nil
+	}
+
+	start, err := time.Parse(appleHealthTime, a["startDate"])
+	if err != nil {
+		return "", datastream.Datapoint{}, err
+	}
+	dp := datastream.Datapoint{Timestamp: float64(start.UnixNano()) * 1e-9}
+
+	if !ok {
+		end, err := time.Parse(appleHealthTime, a["endDate"])
+		if err != nil {
+			return "", datastream.Datapoint{}, err
+		}
+		dp.Data = map[string]interface{}{
+			"state":    strings.ToLower(strings.TrimPrefix(a["value"], "HKCategoryValueSleepAnalysis")),
+			"duration": end.Sub(start).Seconds(),
+		}
+		return "sleep", dp, nil
+	}
+
+	factor, ok := ht.Units[a["unit"]]
+	if !ok {
+		// Values in units which we can't convert are skipped
+		return "", datastream.Datapoint{}, nil
+	}
+	v, err := strconv.ParseFloat(a["value"], 64)
+	if err != nil {
+		return "", datastream.Datapoint{}, err
+	}
+	dp.Data = v * factor
+	return ht.Kind, dp, nil
+}
+
+// ImportAppleHealth streams the records of an Apple Health export.xml file into the writer. The record types in
+// AppleHealthTypes and sleep analysis are imported, at the time each record starts.
+func ImportAppleHealth(r io.Reader, w *ChunkWriter) error {
+	dec := xml.NewDecoder(r)
+	for {
+		t, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		e, ok := t.(xml.StartElement)
+		if !ok || e.Name.Local != "Record" {
+			continue
+		}
+		w.Record()
+		kind, dp, err := healthRecord(e.Attr)
+		if err != nil {
+			return err
+		}
+		if kind != "" {
+			if err = w.Add(kind, dp); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,201 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package importer
+
+import (
+	"bytes"
+	"connectordb/datastream"
+	"connectordb/operator"
+	"io"
+	"regexp"
+	"sort"
+	"time"
+)
+
+var (
+	// ChunkSize is the number of datapoints of a kind which are inserted at once by streaming imports
+	ChunkSize = 5000
+
+	// BackfillSize is the number of out of order datapoints of a kind which are held back by streaming imports
+	// before they are backfilled into the stream at once
+	BackfillSize = 100000
+
+	// ProgressInterval is the time between progress reports of streaming imports
+	ProgressInterval = 5 * time.Second
+)
+
+// Progress is called during a streaming import with the number of records read and datapoints inserted so far
+type Progress func(records, inserted int64)
+
+// ChunkWriter inserts the data of a streaming import into its streams in chunks, as the data is parsed, so that
+// multi-gigabyte exports are never held in memory. Streams are created as in Import, but since the data is too
+// large to check against the streams' existing data, it is inserted as-is.
+type ChunkWriter struct {
+	o          operator.PathOperator
+	devicepath string
+	streams    map[string]string
+	backfill   bool
+	progress   Progress
+
+	ids       map[string]int64
+	backfills map[string]bool // Whether the stream of each kind has backfill enabled
+	pending   map[string]datastream.DatapointArray
+	late      map[string]datastream.DatapointArray
+	last      map[string]float64
+	results   map[string]*Result
+
+	records      int64
+	inserted     int64
+	lastProgress time.Time
+}
+
+// NewChunkWriter creates a writer which inserts each kind of data into the stream of the device given for it in
+// streams. Kinds without a stream are skipped. Backfill is as in Import. The progress function can be nil.
+func NewChunkWriter(o operator.PathOperator, devicepath string, streams map[string]string, backfill bool, progress Progress) *ChunkWriter {
+	return &ChunkWriter{
+		o:            o,
+		devicepath:   devicepath,
+		streams:      streams,
+		backfill:     backfill,
+		progress:     progress,
+		ids:          make(map[string]int64),
+		backfills:    make(map[string]bool),
+		pending:      make(map[string]datastream.DatapointArray),
+		late:         make(map[string]datastream.DatapointArray),
+		last:         make(map[string]float64),
+		results:      make(map[string]*Result),
+		lastProgress: time.Now(),
+	}
+}
+
+// Record counts a record read by the parser, and reports the progress if it is time to do so
+func (w *ChunkWriter) Record() {
+	w.records++
+	if w.progress != nil && time.Since(w.lastProgress) > ProgressInterval {
+		w.lastProgress = time.Now()
+		w.progress(w.records, w.inserted)
+	}
+}
+
+// Add adds a datapoint of the given kind, inserting the kind's datapoints once there is a full chunk of them
+func (w *ChunkWriter) Add(kind string, dp datastream.Datapoint) error {
+	if name, ok := w.streams[kind]; !ok || name == "" {
+		return nil
+	}
+	w.pending[kind] = append(w.pending[kind], dp)
+	if len(w.pending[kind]) >= ChunkSize {
+		return w.insert(kind, false)
+	}
+	return nil
+}
+
+// insert inserts the pending datapoints of the kind. Exports are not necessarily in order, so each chunk is sorted,
+// and its datapoints which are older than the data already inserted are held back. Backfilling rewrites the stream
+// after the backfilled data, so the held back datapoints are backfilled together once there are BackfillSize of
+// them, or when flush is set. Streams without backfill skip the datapoints older than their data instead.
+func (w *ChunkWriter) insert(kind string, flush bool) error {
+	if len(w.pending[kind]) == 0 && (!flush || len(w.late[kind]) == 0) {
+		return nil
+	}
+	r, ok := w.results[kind]
+	if !ok {
+		r = &Result{Stream: w.devicepath + "/" + w.streams[kind]}
+		w.results[kind] = r
+		s, err := w.o.ReadOrCreateStream(r.Stream, kindStream(kind, w.backfill))
+		if err != nil {
+			r.Error = err.Error()
+			return err
+		}
+		w.ids[kind] = s.StreamID
+		w.backfills[kind] = s.Backfill
+		if !s.Backfill {
+			last, err := lastTimestamp(w.o, r.Stream)
+			if err != nil {
+				r.Error = err.Error()
+				return err
+			}
+			w.last[kind] = last
+		}
+	}
+
+	dpa := w.pending[kind]
+	w.pending[kind] = nil
+	dpa.SortByTime()
+	if last, ok := w.last[kind]; ok {
+		n := sort.Search(len(dpa), func(i int) bool { return dpa[i].Timestamp >= last })
+		if w.backfills[kind] {
+			w.late[kind] = append(w.late[kind], dpa[:n]...)
+		} else {
+			r.Late += n
+		}
+		dpa = dpa[n:]
+	}
+	if err := w.write(kind, r, dpa); err != nil {
+		return err
+	}
+
+	late := w.late[kind]
+	if len(late) > 0 && (flush || len(late) >= BackfillSize) {
+		w.late[kind] = nil
+		late.SortByTime()
+		return w.write(kind, r, late)
+	}
+	return nil
+}
+
+// write inserts sorted datapoints into the stream of the kind. Datapoints older than the stream's data are only
+// given to streams with backfill, which merge them into their data.
+func (w *ChunkWriter) write(kind string, r *Result, dpa datastream.DatapointArray) error {
+	if len(dpa) == 0 {
+		return nil
+	}
+	if err := w.o.InsertStreamByID(w.ids[kind], "", dpa, false); err != nil {
+		r.Error = err.Error()
+		return err
+	}
+	r.Inserted += len(dpa)
+	w.inserted += int64(len(dpa))
+	if last, ok := w.last[kind]; !ok || dpa[len(dpa)-1].Timestamp > last {
+		w.last[kind] = dpa[len(dpa)-1].Timestamp
+	}
+	return nil
+}
+
+// Close inserts the remaining datapoints, and returns the results of the import
+func (w *ChunkWriter) Close() (map[string]*Result, error) {
+	for kind := range w.pending {
+		if err := w.insert(kind, true); err != nil {
+			return w.results, err
+		}
+	}
+	if w.progress != nil {
+		w.progress(w.records, w.inserted)
+	}
+	return w.results, nil
+}
+
+// ExportHeadSize is the number of bytes at the beginning of a file needed to find its export format. Apple Health
+// exports start with a long document type definition.
+const ExportHeadSize = 64 * 1024
+
+// ExportImporters holds the streaming importers of large exports, indexed by format
+var ExportImporters = map[string]func(r io.Reader, w *ChunkWriter) error{
+	"takeout":     ImportTakeout,
+	"applehealth": ImportAppleHealth,
+}
+
+var takeoutHead = regexp.MustCompile(`^\s*\{\s*"locations"\s*:`)
+
+// ExportFormat returns the format of a large export (takeout or applehealth) given the beginning of the file,
+// or an empty string if the file is not a known export
+func ExportFormat(head []byte) string {
+	switch {
+	case takeoutHead.Match(head):
+		return "takeout"
+	case bytes.Contains(head, []byte("<HealthData")):
+		return "applehealth"
+	}
+	return ""
+}
@@ -0,0 +1,65 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package importer
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportFormat(t *testing.T) {
+	require.Equal(t, "takeout", ExportFormat([]byte("{\n  \"locations\" : [ {")))
+	require.Equal(t, "applehealth", ExportFormat([]byte(`<?xml version="1.0"?><!DOCTYPE HealthData [ ]><HealthData locale="en_US">`)))
+	require.Equal(t, "", ExportFormat([]byte(testGPX)))
+	require.Equal(t, "", ExportFormat([]byte(`{"hello": "world"}`)))
+}
+
+func TestTakeoutTime(t *testing.T) {
+	ts, err := takeoutTime("1462096800500", "")
+	require.NoError(t, err)
+	require.Equal(t, 1462096800.5, ts)
+	ts, err = takeoutTime("", "2016-05-01T10:00:00.500Z")
+	require.NoError(t, err)
+	require.Equal(t, 1462096800.5, ts)
+	_, err = takeoutTime("", "")
+	require.Error(t, err)
+}
+
+func healthAttrs(attrs ...string) []xml.Attr {
+	var a []xml.Attr
+	for i := 0; i < len(attrs); i += 2 {
+		a = append(a, xml.Attr{Name: xml.Name{Local: attrs[i]}, Value: attrs[i+1]})
+	}
+	return a
+}
+
+func TestHealthRecord(t *testing.T) {
+	kind, dp, err := healthRecord(healthAttrs("type", "HKQuantityTypeIdentifierBodyMass", "unit", "lb", "value", "100",
+		"startDate", "2016-05-01 03:00:00 -0700", "endDate", "2016-05-01 03:00:00 -0700"))
+	require.NoError(t, err)
+	require.Equal(t, "weight", kind)
+	require.Equal(t, 1462096800., dp.Timestamp)
+	require.InDelta(t, 45.359237, dp.Data.(float64), 1e-9)
+
+	kind, dp, err = healthRecord(healthAttrs("type", "HKCategoryTypeIdentifierSleepAnalysis", "value", "HKCategoryValueSleepAnalysisAsleep",
+		"startDate", "2016-05-01 03:00:00 -0700", "endDate", "2016-05-01 04:30:00 -0700"))
+	require.NoError(t, err)
+	require.Equal(t, "sleep", kind)
+	require.Equal(t, map[string]interface{}{"state": "asleep", "duration": 5400.}, dp.Data)
+
+	// Unknown types and units are skipped
+	kind, _, err = healthRecord(healthAttrs("type", "HKQuantityTypeIdentifierBodyMass", "unit", "stone", "value", "10",
+		"startDate", "2016-05-01 03:00:00 -0700"))
+	require.NoError(t, err)
+	require.Equal(t, "", kind)
+	kind, _, err = healthRecord(healthAttrs("type", "HKQuantityTypeIdentifierDietaryWater", "unit", "mL", "value", "10"))
+	require.NoError(t, err)
+	require.Equal(t, "", kind)
+
+	_, _, err = healthRecord(healthAttrs("type", "HKQuantityTypeIdentifierStepCount", "unit", "count", "value", "10", "startDate", "yesterday"))
+	require.Error(t, err)
+}
@@ -0,0 +1,50 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package importer
+
+import (
+	"bufio"
+	"connectordb/operator"
+	"os"
+)
+
+// DefaultStreams returns the default stream of each kind of data, which is named after the kind
+func DefaultStreams() map[string]string {
+	streams := make(map[string]string, len(Kinds))
+	for kind := range Kinds {
+		streams[kind] = kind
+	}
+	return streams
+}
+
+// ImportFile imports the file with the given name into streams of the device. Activity files are imported whole,
+// skipping data which the streams already have. Large exports (Google Takeout and Apple Health) are streamed into
+// the database in chunks, reporting their progress to the given function (which can be nil). Backfill is as in Import.
+func ImportFile(o operator.PathOperator, devicepath, filename string, streams map[string]string, backfill bool, progress Progress) (map[string]*Result, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReaderSize(f, ExportHeadSize)
+	head, _ := r.Peek(ExportHeadSize)
+
+	if format := ExportFormat(head); format != "" {
+		w := NewChunkWriter(o, devicepath, streams, backfill, progress)
+		err = ExportImporters[format](r, w)
+		results, cerr := w.Close()
+		if err == nil {
+			err = cerr
+		}
+		return results, err
+	}
+
+	data, err := ParseActivity(filename, r)
+	if err != nil {
+		return nil, err
+	}
+	return Import(o, devicepath, data, streams, backfill), nil
+}
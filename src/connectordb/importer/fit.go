@@ -0,0 +1,196 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package importer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// ErrNotFIT is returned when parsing a file which is not a FIT file
+var ErrNotFIT = errors.New("The file is not a FIT file")
+
+const (
+	// fitEpoch is the unix time of FIT timestamp 0 (1989-12-31 00:00:00 UTC)
+	fitEpoch = 631065600
+
+	fitRecordMessage = 20 // The global message number of record messages
+	fitTimestamp     = 253
+
+	fitLatitude         = 0
+	fitLongitude        = 1
+	fitAltitude         = 2
+	fitHeartRate        = 3
+	fitCadence          = 4
+	fitEnhancedAltitude = 78
+
+	fitSemicircles = 180. / (1 << 31)
+)
+
+type fitField struct {
+	num  byte
+	size byte
+}
+
+type fitDefinition struct {
+	order   binary.ByteOrder
+	global  uint16
+	fields  []fitField
+	devsize int // The total size of the developer fields, which are skipped
+}
+
+// fitValue returns the unsigned value of a field, and whether it is valid (FIT marks missing values by setting all bits,
+// or the largest positive value for signed fields)
+func fitValue(order binary.ByteOrder, b []byte, signed bool) (uint64, bool) {
+	switch len(b) {
+	case 1:
+		return uint64(b[0]), b[0] != 0xFF && !(signed && b[0] == 0x7F)
+	case 2:
+		v := order.Uint16(b)
+		return uint64(v), v != 0xFFFF && !(signed && v == 0x7FFF)
+	case 4:
+		v := order.Uint32(b)
+		return uint64(v), v != 0xFFFFFFFF && !(signed && v == 0x7FFFFFFF)
+	}
+	return 0, false
+}
+
+func fitFloat(v float64) *float64 {
+	return &v
+}
+
+// ParseFIT parses the record messages of a FIT activity file
+func ParseFIT(r io.Reader) ([]Sample, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(br, header); err != nil || string(header[8:12]) != ".FIT" || header[0] < 12 {
+		return nil, ErrNotFIT
+	}
+	// Skip the rest of the header (the optional header CRC)
+	if _, err := br.Discard(int(header[0]) - 12); err != nil {
+		return nil, ErrNotFIT
+	}
+	remaining := int64(binary.LittleEndian.Uint32(header[4:8]))
+	data := &io.LimitedReader{R: br, N: remaining}
+
+	var definitions [16]*fitDefinition
+	var samples []Sample
+	var timestamp uint32
+	buf := make([]byte, 256)
+
+	for data.N > 0 {
+		if _, err := io.ReadFull(data, buf[:1]); err != nil {
+			return nil, err
+		}
+		h := buf[0]
+
+		compressed := h&0x80 != 0
+		local := h & 0x0F
+		if compressed {
+			local = (h >> 5) & 0x03
+		}
+
+		if !compressed && h&0x40 != 0 {
+			// Definition message
+			if _, err := io.ReadFull(data, buf[:5]); err != nil {
+				return nil, err
+			}
+			d := &fitDefinition{order: binary.LittleEndian}
+			if buf[1] == 1 {
+				d.order = binary.BigEndian
+			}
+			d.global = d.order.Uint16(buf[2:4])
+			d.fields = make([]fitField, buf[4])
+			for i := range d.fields {
+				if _, err := io.ReadFull(data, buf[:3]); err != nil {
+					return nil, err
+				}
+				d.fields[i] = fitField{buf[0], buf[1]}
+			}
+			if h&0x20 != 0 {
+				if _, err := io.ReadFull(data, buf[:1]); err != nil {
+					return nil, err
+				}
+				for n := int(buf[0]); n > 0; n-- {
+					if _, err := io.ReadFull(data, buf[:3]); err != nil {
+						return nil, err
+					}
+					d.devsize += int(buf[1])
+				}
+			}
+			definitions[local] = d
+			continue
+		}
+
+		// Data message
+		d := definitions[local]
+		if d == nil {
+			return nil, fmt.Errorf("FIT data message of undefined local type %d", local)
+		}
+		if compressed {
+			offset := uint32(h & 0x1F)
+			t := timestamp&^0x1F + offset
+			if offset < timestamp&0x1F {
+				t += 0x20
+			}
+			timestamp = t
+		}
+
+		s := Sample{}
+		hasTime := compressed
+		for _, f := range d.fields {
+			b := buf[:f.size]
+			if _, err := io.ReadFull(data, b); err != nil {
+				return nil, err
+			}
+			if f.num == fitTimestamp {
+				if v, ok := fitValue(d.order, b, false); ok {
+					timestamp = uint32(v)
+					hasTime = true
+				}
+				continue
+			}
+			if d.global != fitRecordMessage {
+				continue
+			}
+			switch f.num {
+			case fitLatitude, fitLongitude:
+				if v, ok := fitValue(d.order, b, true); ok && f.size == 4 {
+					deg := fitFloat(float64(int32(uint32(v))) * fitSemicircles)
+					if f.num == fitLatitude {
+						s.Latitude = deg
+					} else {
+						s.Longitude = deg
+					}
+				}
+			case fitAltitude, fitEnhancedAltitude:
+				if v, ok := fitValue(d.order, b, false); ok && (s.Elevation == nil || f.num == fitEnhancedAltitude) {
+					s.Elevation = fitFloat(float64(v)/5 - 500)
+				}
+			case fitHeartRate:
+				if v, ok := fitValue(d.order, b, false); ok {
+					s.HeartRate = fitFloat(float64(v))
+				}
+			case fitCadence:
+				if v, ok := fitValue(d.order, b, false); ok {
+					s.Cadence = fitFloat(float64(v))
+				}
+			}
+		}
+		if _, err := io.CopyN(ioutil.Discard, data, int64(d.devsize)); err != nil {
+			return nil, err
+		}
+
+		if d.global == fitRecordMessage && hasTime {
+			s.Timestamp = float64(timestamp) + fitEpoch
+			samples = append(samples, s)
+		}
+	}
+	return samples, nil
+}
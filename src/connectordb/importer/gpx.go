@@ -0,0 +1,51 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package importer
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+type gpxPoint struct {
+	Latitude  float64  `xml:"lat,attr"`
+	Longitude float64  `xml:"lon,attr"`
+	Elevation *float64 `xml:"ele"`
+	Time      string   `xml:"time"`
+
+	// Garmin's TrackPointExtension holds the heart rate and cadence
+	HeartRate *float64 `xml:"extensions>TrackPointExtension>hr"`
+	Cadence   *float64 `xml:"extensions>TrackPointExtension>cad"`
+}
+
+type gpxFile struct {
+	Points []gpxPoint `xml:"trk>trkseg>trkpt"`
+}
+
+// ParseGPX parses the track points of a GPX file. Points without a time are skipped.
+func ParseGPX(r io.Reader) ([]Sample, error) {
+	var f gpxFile
+	if err := xml.NewDecoder(r).Decode(&f); err != nil {
+		return nil, err
+	}
+	samples := make([]Sample, 0, len(f.Points))
+	for i := range f.Points {
+		p := &f.Points[i]
+		t, err := time.Parse(time.RFC3339, p.Time)
+		if err != nil {
+			continue
+		}
+		samples = append(samples, Sample{
+			Timestamp: float64(t.UnixNano()) * 1e-9,
+			Latitude:  &p.Latitude,
+			Longitude: &p.Longitude,
+			Elevation: p.Elevation,
+			HeartRate: p.HeartRate,
+			Cadence:   p.Cadence,
+		})
+	}
+	return samples, nil
+}
@@ -0,0 +1,159 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package importer
+
+/*
+Package importer converts files exported by other services and devices into ConnectorDB datapoints. Each parser
+returns its data grouped by kind (such as location or heartrate), and Import writes each kind into a chosen stream.
+*/
+
+import (
+	"connectordb/datastream"
+	"connectordb/operator"
+	"connectordb/users"
+	"math"
+)
+
+// InsertBatchSize is the maximum number of datapoints inserted into a stream at once
+var InsertBatchSize = 5000
+
+// Kind is a type of data which can be imported
+type Kind struct {
+	Datatype    string // The datatype of streams created for the kind, which gives them their schema
+	Description string // The description of streams created for the kind
+}
+
+// Kinds holds the known kinds of imported data, indexed by name
+var Kinds = map[string]Kind{
+	"location":  Kind{"location.gps", "Imported GPS locations"},
+	"heartrate": Kind{"health.heartrate", "Imported heart rate"},
+	"cadence":   Kind{"activity.cadence", "Imported cadence"},
+	"elevation": Kind{"physical.elevation", "Imported elevation"},
+	"activity":  Kind{"activity.type", "Imported activity types"},
+	"steps":     Kind{"activity.steps", "Imported step counts"},
+	"flights":   Kind{"activity.flights", "Imported flights of stairs climbed"},
+	"distance":  Kind{"physical.distance", "Imported walking and running distance"},
+	"energy":    Kind{"physical.energy", "Imported active energy burned"},
+	"weight":    Kind{"physical.weight", "Imported body weight"},
+	"sleep":     Kind{"health.sleep", "Imported sleep analysis"},
+}
+
+// Result is the result of importing one kind of data
+type Result struct {
+	Stream     string `json:"stream"`          // The path of the stream into which the data was imported
+	Inserted   int    `json:"inserted"`        // The number of datapoints inserted
+	Duplicates int    `json:"duplicates"`      // The number of datapoints skipped, since the stream already had them
+	Late       int    `json:"late,omitempty"`  // The number of datapoints skipped, since they are older than the data of a stream without backfill
+	Error      string `json:"error,omitempty"` // Set if the import of this kind failed
+}
+
+// Import inserts each kind of data into the stream of the device given for it in streams. Kinds without a stream
+// are not imported. Streams which don't exist are created with the kind's datatype. Datapoints with the timestamp
+// of a datapoint already in the stream are skipped, so importing the same file twice does not duplicate its data.
+//
+// Datapoints older than the data of a stream are only inserted if the stream has backfill enabled, and are skipped
+// otherwise. Streams are created with backfill if it is set, so that older files can be imported after newer ones.
+// Backfilling shifts the indices of the stream's later datapoints, so it is off unless asked for.
+func Import(o operator.PathOperator, devicepath string, data map[string]datastream.DatapointArray, streams map[string]string, backfill bool) map[string]*Result {
+	results := make(map[string]*Result)
+	for kind, dpa := range data {
+		name, ok := streams[kind]
+		if !ok || name == "" || len(dpa) == 0 {
+			continue
+		}
+		r := &Result{Stream: devicepath + "/" + name}
+		if err := importStream(o, r, kind, dpa, backfill); err != nil {
+			r.Error = err.Error()
+		}
+		results[kind] = r
+	}
+	return results
+}
+
+// kindStream returns the stream which is created for a kind of data if its stream does not exist
+func kindStream(kind string, backfill bool) *users.StreamMaker {
+	k := Kinds[kind]
+	return &users.StreamMaker{Stream: users.Stream{
+		Datatype:    k.Datatype,
+		Description: k.Description,
+		Backfill:    backfill,
+	}}
+}
+
+func importStream(o operator.PathOperator, r *Result, kind string, dpa datastream.DatapointArray, backfill bool) error {
+	s, err := o.ReadOrCreateStream(r.Stream, kindStream(kind, backfill))
+	if err != nil {
+		return err
+	}
+	last := math.Inf(-1)
+	if !s.Backfill {
+		if last, err = lastTimestamp(o, r.Stream); err != nil {
+			return err
+		}
+	}
+
+	// Files are not guaranteed to be in order, and can have several samples at the same time
+	dpa.SortByTime()
+
+	existing, err := readTimestamps(o, r.Stream, dpa[0].Timestamp, dpa[len(dpa)-1].Timestamp)
+	if err != nil {
+		return err
+	}
+	insert := make(datastream.DatapointArray, 0, len(dpa))
+	for _, dp := range dpa {
+		if existing[dp.Timestamp] {
+			r.Duplicates++
+			continue
+		}
+		if dp.Timestamp < last {
+			r.Late++
+			continue
+		}
+		existing[dp.Timestamp] = true
+		insert = append(insert, dp)
+	}
+
+	for len(insert) > 0 {
+		n := len(insert)
+		if n > InsertBatchSize {
+			n = InsertBatchSize
+		}
+		if err = o.InsertStream(r.Stream, insert[:n], false); err != nil {
+			return err
+		}
+		r.Inserted += n
+		insert = insert[n:]
+	}
+	return nil
+}
+
+// lastTimestamp returns the timestamp of the stream's last datapoint, or -Inf if the stream is empty
+func lastTimestamp(o operator.PathOperator, streampath string) (float64, error) {
+	dr, err := o.GetStreamIndexRange(streampath, -1, 0, "")
+	if err != nil {
+		return 0, err
+	}
+	defer dr.Close()
+	dp, err := dr.Next()
+	if err != nil || dp == nil {
+		return math.Inf(-1), err
+	}
+	return dp.Timestamp, nil
+}
+
+// readTimestamps returns the timestamps of the stream's datapoints between t1 and t2 (inclusive)
+func readTimestamps(o operator.PathOperator, streampath string, t1, t2 float64) (map[float64]bool, error) {
+	dr, err := o.GetStreamTimeRange(streampath, math.Nextafter(t1, math.Inf(-1)), t2, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	defer dr.Close()
+	timestamps := make(map[float64]bool)
+	dp, err := dr.Next()
+	for ; dp != nil && err == nil; dp, err = dr.Next() {
+		timestamps[dp.Timestamp] = true
+	}
+	return timestamps, err
+}
@@ -0,0 +1,176 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package importer_test
+
+import (
+	"config"
+	"connectordb"
+	"connectordb/datastream"
+	"connectordb/importer"
+	"connectordb/users"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var db *connectordb.Database
+
+func init() {
+	tdb, err := connectordb.Open(config.TestConfiguration.Options())
+	if err != nil {
+		log.Fatal(err)
+	}
+	db = tdb
+	go db.RunWriter()
+}
+
+func TestImport(t *testing.T) {
+	db.Clear()
+	require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: "tst", Email: "root@localhost", Password: "mypass", Role: "user", Public: true}}))
+	require.NoError(t, db.CreateDevice("tst/tst", &users.DeviceMaker{}))
+	o, err := db.AsDevice("tst/tst")
+	require.NoError(t, err)
+
+	data := map[string]datastream.DatapointArray{
+		"heartrate": datastream.DatapointArray{{Timestamp: 3., Data: 120.}, {Timestamp: 1., Data: 100.}, {Timestamp: 2., Data: 110.}},
+		"cadence":   datastream.DatapointArray{{Timestamp: 1., Data: 80.}},
+	}
+	streams := map[string]string{"heartrate": "hr", "cadence": ""}
+
+	res := importer.Import(o, "tst/tst", data, streams, true)
+	require.Len(t, res, 1)
+	require.Equal(t, &importer.Result{Stream: "tst/tst/hr", Inserted: 3}, res["heartrate"])
+
+	s, err := o.ReadStream("tst/tst/hr")
+	require.NoError(t, err)
+	require.Equal(t, "health.heartrate", s.Datatype)
+	require.True(t, s.Backfill)
+
+	// Importing overlapping data skips the datapoints which are already there, and backfills older data
+	data["heartrate"] = datastream.DatapointArray{{Timestamp: 0.5, Data: 90.}, {Timestamp: 2., Data: 110.}, {Timestamp: 4., Data: 130.}}
+	res = importer.Import(o, "tst/tst", data, streams, false)
+	require.Equal(t, &importer.Result{Stream: "tst/tst/hr", Inserted: 2, Duplicates: 1}, res["heartrate"])
+
+	dr, err := o.GetStreamIndexRange("tst/tst/hr", 0, 0, "")
+	require.NoError(t, err)
+	var timestamps []float64
+	for dp, err := dr.Next(); dp != nil && err == nil; dp, err = dr.Next() {
+		timestamps = append(timestamps, dp.Timestamp)
+	}
+	dr.Close()
+	require.Equal(t, []float64{0.5, 1, 2, 3, 4}, timestamps)
+
+	// Streams are created without backfill unless asked for, and skip the datapoints older than their data
+	streams["heartrate"] = "hr2"
+	data["heartrate"] = datastream.DatapointArray{{Timestamp: 3., Data: 120.}}
+	res = importer.Import(o, "tst/tst", data, streams, false)
+	require.Equal(t, &importer.Result{Stream: "tst/tst/hr2", Inserted: 1}, res["heartrate"])
+	s, err = o.ReadStream("tst/tst/hr2")
+	require.NoError(t, err)
+	require.False(t, s.Backfill)
+
+	data["heartrate"] = datastream.DatapointArray{{Timestamp: 1., Data: 100.}, {Timestamp: 3., Data: 120.}, {Timestamp: 4., Data: 130.}}
+	res = importer.Import(o, "tst/tst", data, streams, false)
+	require.Equal(t, &importer.Result{Stream: "tst/tst/hr2", Inserted: 1, Duplicates: 1, Late: 1}, res["heartrate"])
+	streams["heartrate"] = "hr"
+
+	// Invalid data fails the import of its kind
+	data["heartrate"] = datastream.DatapointArray{{Timestamp: 5., Data: "fast"}}
+	res = importer.Import(o, "tst/tst", data, streams, false)
+	require.NotEmpty(t, res["heartrate"].Error)
+}
+
+var testTakeout = `{
+  "locations" : [ {
+    "timestampMs" : "1000",
+    "latitudeE7" : 401000000,
+    "longitudeE7" : -882000000,
+    "accuracy" : 10
+  }, {
+    "timestampMs" : "3000",
+    "latitudeE7" : 401100000,
+    "longitudeE7" : -882100000,
+    "activity" : [ {
+      "timestampMs" : "2500",
+      "activity" : [ { "type" : "STILL", "confidence" : 20 }, { "type" : "WALKING", "confidence" : 75 } ]
+    } ]
+  }, {
+    "timestamp" : "1970-01-01T00:00:02Z",
+    "latitudeE7" : 401200000,
+    "longitudeE7" : -882200000
+  } ]
+}`
+
+var testHealth = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE HealthData [
+<!ELEMENT HealthData (ExportDate,Me,(Record|Workout)*)>
+]>
+<HealthData locale="en_US">
+ <ExportDate value="2016-05-02 10:00:00 -0700"/>
+ <Record type="HKQuantityTypeIdentifierStepCount" unit="count" value="120" startDate="2016-05-01 10:00:00 -0700" endDate="2016-05-01 10:05:00 -0700"/>
+ <Record type="HKQuantityTypeIdentifierHeartRate" unit="count/min" value="72" startDate="2016-05-01 10:00:00 -0700" endDate="2016-05-01 10:00:00 -0700"/>
+ <Record type="HKQuantityTypeIdentifierStepCount" unit="count" value="80" startDate="2016-05-01 09:00:00 -0700" endDate="2016-05-01 09:05:00 -0700"/>
+ <Record type="HKQuantityTypeIdentifierDietaryWater" unit="mL" value="250" startDate="2016-05-01 09:00:00 -0700" endDate="2016-05-01 09:00:00 -0700"/>
+</HealthData>`
+
+func readAll(t *testing.T, o interface {
+	GetStreamIndexRange(string, int64, int64, string) (datastream.DataRange, error)
+}, streampath string) datastream.DatapointArray {
+	dr, err := o.GetStreamIndexRange(streampath, 0, 0, "")
+	require.NoError(t, err)
+	defer dr.Close()
+	var dpa datastream.DatapointArray
+	for dp, err := dr.Next(); dp != nil && err == nil; dp, err = dr.Next() {
+		dpa = append(dpa, *dp)
+	}
+	return dpa
+}
+
+func TestImportExport(t *testing.T) {
+	db.Clear()
+	require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: "tst", Email: "root@localhost", Password: "mypass", Role: "user", Public: true}}))
+	require.NoError(t, db.CreateDevice("tst/tst", &users.DeviceMaker{}))
+	o, err := db.AsDevice("tst/tst")
+	require.NoError(t, err)
+
+	// A small chunk size makes the out of order location be inserted in a later chunk
+	defer func(c int) { importer.ChunkSize = c }(importer.ChunkSize)
+	importer.ChunkSize = 2
+
+	var records int64
+	streams := importer.DefaultStreams()
+	streams["activity"] = "moving"
+	w := importer.NewChunkWriter(o, "tst/tst", streams, true, func(r, i int64) { records = r })
+	require.NoError(t, importer.ImportTakeout(strings.NewReader(testTakeout), w))
+	res, err := w.Close()
+	require.NoError(t, err)
+	require.EqualValues(t, 3, records)
+	require.Equal(t, &importer.Result{Stream: "tst/tst/location", Inserted: 3}, res["location"])
+	require.Equal(t, &importer.Result{Stream: "tst/tst/moving", Inserted: 1}, res["activity"])
+
+	dpa := readAll(t, o, "tst/tst/location")
+	require.Len(t, dpa, 3)
+	require.Equal(t, []float64{1, 2, 3}, []float64{dpa[0].Timestamp, dpa[1].Timestamp, dpa[2].Timestamp})
+	require.Equal(t, map[string]interface{}{"latitude": 40.1, "longitude": -88.2, "accuracy": 10.}, dpa[0].Data)
+	dpa = readAll(t, o, "tst/tst/moving")
+	require.Equal(t, datastream.DatapointArray{{Timestamp: 2.5, Data: "walking"}}, dpa)
+
+	w = importer.NewChunkWriter(o, "tst/tst", importer.DefaultStreams(), false, nil)
+	require.NoError(t, importer.ImportAppleHealth(strings.NewReader(testHealth), w))
+	res, err = w.Close()
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	require.Equal(t, 2, res["steps"].Inserted)
+	require.Equal(t, 1, res["heartrate"].Inserted)
+
+	s, err := o.ReadStream("tst/tst/steps")
+	require.NoError(t, err)
+	require.Equal(t, "activity.steps", s.Datatype)
+	dpa = readAll(t, o, "tst/tst/steps")
+	require.Len(t, dpa, 2)
+	require.Equal(t, 80., dpa[0].Data)
+}
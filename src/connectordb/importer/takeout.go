@@ -0,0 +1,135 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package importer
+
+import (
+	"connectordb/datastream"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotTakeout is returned when a file is not a Google Takeout location history
+var ErrNotTakeout = errors.New("The file is not a Google Takeout location history (Records.json)")
+
+type takeoutActivity struct {
+	Type       string  `json:"type"`
+	Confidence float64 `json:"confidence"`
+}
+
+type takeoutLocation struct {
+	TimestampMs string   `json:"timestampMs"`
+	Timestamp   string   `json:"timestamp"` // Newer exports have an RFC3339 timestamp rather than timestampMs
+	LatitudeE7  *int64   `json:"latitudeE7"`
+	LongitudeE7 *int64   `json:"longitudeE7"`
+	Accuracy    *float64 `json:"accuracy"`
+	Altitude    *float64 `json:"altitude"`
+	Velocity    *float64 `json:"velocity"`
+
+	Activity []struct {
+		TimestampMs string            `json:"timestampMs"`
+		Timestamp   string            `json:"timestamp"`
+		Activity    []takeoutActivity `json:"activity"`
+	} `json:"activity"`
+}
+
+// takeoutTime returns the time of a Takeout record, given either as milliseconds or as an RFC3339 time
+func takeoutTime(ms, ts string) (float64, error) {
+	if ms != "" {
+		v, err := strconv.ParseInt(ms, 10, 64)
+		return float64(v) / 1000, err
+	}
+	t, err := time.Parse(time.RFC3339, ts)
+	return float64(t.UnixNano()) * 1e-9, err
+}
+
+// addTakeoutLocation adds the location and most likely activities of a Takeout record
+func addTakeoutLocation(l *takeoutLocation, w *ChunkWriter) error {
+	if l.LatitudeE7 != nil && l.LongitudeE7 != nil {
+		t, err := takeoutTime(l.TimestampMs, l.Timestamp)
+		if err != nil {
+			return err
+		}
+		loc := map[string]interface{}{
+			"latitude":  float64(*l.LatitudeE7) / 1e7,
+			"longitude": float64(*l.LongitudeE7) / 1e7,
+		}
+		if l.Accuracy != nil {
+			loc["accuracy"] = *l.Accuracy
+		}
+		if l.Altitude != nil {
+			loc["altitude"] = *l.Altitude
+		}
+		if l.Velocity != nil {
+			loc["speed"] = *l.Velocity
+		}
+		if err = w.Add("location", datastream.Datapoint{Timestamp: t, Data: loc}); err != nil {
+			return err
+		}
+	}
+
+	for _, a := range l.Activity {
+		if len(a.Activity) == 0 {
+			continue
+		}
+		t, err := takeoutTime(a.TimestampMs, a.Timestamp)
+		if err != nil {
+			return err
+		}
+		best := a.Activity[0]
+		for _, v := range a.Activity[1:] {
+			if v.Confidence > best.Confidence {
+				best = v
+			}
+		}
+		if err = w.Add("activity", datastream.Datapoint{Timestamp: t, Data: strings.ToLower(best.Type)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportTakeout streams the location history of a Google Takeout Records.json file into the writer, adding
+// locations and the most likely activity at each time
+func ImportTakeout(r io.Reader, w *ChunkWriter) error {
+	dec := json.NewDecoder(r)
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return ErrNotTakeout
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if key != "locations" {
+			var skip json.RawMessage
+			if err = dec.Decode(&skip); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if t, err := dec.Token(); err != nil || t != json.Delim('[') {
+			return ErrNotTakeout
+		}
+		for dec.More() {
+			var l takeoutLocation
+			if err = dec.Decode(&l); err != nil {
+				return err
+			}
+			w.Record()
+			if err = addTakeoutLocation(&l, w); err != nil {
+				return err
+			}
+		}
+		if _, err = dec.Token(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
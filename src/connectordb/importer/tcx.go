@@ -0,0 +1,56 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package importer
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+type tcxPoint struct {
+	Time      string   `xml:"Time"`
+	Latitude  *float64 `xml:"Position>LatitudeDegrees"`
+	Longitude *float64 `xml:"Position>LongitudeDegrees"`
+	Elevation *float64 `xml:"AltitudeMeters"`
+	HeartRate *float64 `xml:"HeartRateBpm>Value"`
+	Cadence   *float64 `xml:"Cadence"`
+
+	// Runs have their cadence in the activity extension
+	RunCadence *float64 `xml:"Extensions>TPX>RunCadence"`
+}
+
+type tcxFile struct {
+	Points []tcxPoint `xml:"Activities>Activity>Lap>Track>Trackpoint"`
+}
+
+// ParseTCX parses the track points of all activities in a TCX file. Points without a time are skipped.
+func ParseTCX(r io.Reader) ([]Sample, error) {
+	var f tcxFile
+	if err := xml.NewDecoder(r).Decode(&f); err != nil {
+		return nil, err
+	}
+	samples := make([]Sample, 0, len(f.Points))
+	for i := range f.Points {
+		p := &f.Points[i]
+		t, err := time.Parse(time.RFC3339, p.Time)
+		if err != nil {
+			continue
+		}
+		s := Sample{
+			Timestamp: float64(t.UnixNano()) * 1e-9,
+			Latitude:  p.Latitude,
+			Longitude: p.Longitude,
+			Elevation: p.Elevation,
+			HeartRate: p.HeartRate,
+			Cadence:   p.Cadence,
+		}
+		if s.Cadence == nil {
+			s.Cadence = p.RunCadence
+		}
+		samples = append(samples, s)
+	}
+	return samples, nil
+}
@@ -12,11 +12,15 @@ import (
 	"connectordb/query"
 	"connectordb/users"
 	"errors"
+	"time"
 )
 
 var (
 	// ErrTimestampOrder is thrown when the tiemstamps are not increasing
 	ErrTimestampOrder = errors.New("Timestamps are not ordered!")
+
+	// ErrInsertKey is thrown when the idempotency key of an insert is too long
+	ErrInsertKey = errors.New("The insert key can be at most 128 characters long")
 )
 
 func (db *Database) getStreamPath(strm *users.Stream) (*users.User, *users.Device, string, error) {
@@ -52,6 +56,26 @@ func (db *Database) TimeToIndexStreamByID(streamID int64, substream string, time
 
 //InsertStreamByID inserts into the stream given by the ID
 func (db *Database) InsertStreamByID(streamID int64, substream string, data datastream.DatapointArray, restamp bool) error {
+	return db.InsertStreamOnceByID(streamID, substream, "", data, restamp)
+}
+
+//InsertStreamOnceByID inserts into the stream given by the ID. If the key is not empty, and an insert with the same key
+//was already made into the substream, the data is not written again. Data of ephemeral streams is not deduplicated.
+func (db *Database) InsertStreamOnceByID(streamID int64, substream string, key string, data datastream.DatapointArray, restamp bool) error {
+	if len(key) > 128 {
+		return ErrInsertKey
+	}
+	err := db.insertStreamOnce(streamID, substream, key, data, restamp)
+	// While the stream's schema is changed, the stream is locked. The insert is retried once the change is done,
+	// so that the data is validated against the new schema
+	for i := 0; err == datastream.ErrStreamLocked && i < datastream.BackfillRetries; i++ {
+		time.Sleep(time.Duration(10*(i+1)) * time.Millisecond)
+		err = db.insertStreamOnce(streamID, substream, key, data, restamp)
+	}
+	return err
+}
+
+func (db *Database) insertStreamOnce(streamID int64, substream string, key string, data datastream.DatapointArray, restamp bool) error {
 	strm, err := db.ReadStreamByID(streamID)
 	if err != nil {
 		return err
@@ -73,16 +97,31 @@ func (db *Database) InsertStreamByID(streamID int64, substream string, data data
 		return err
 	}
 
+	index := int64(-1)
 	if !strm.Ephemeral {
 
 		r := permissions.GetUserRole(pconfig.Get(), u)
-		_, err = db.DataStream.Insert(strm.DeviceID, strm.StreamID, substream, data, restamp, r.MaxDeviceSize, r.MaxStreamSize)
+		var length int64
+		shifted := false
+		if strm.Backfill && !restamp {
+			// The stream accepts old datapoints, which are put in their place in the stream
+			length, shifted, err = db.DataStream.BackfillOnce(key, strm.DeviceID, strm.StreamID, substream, data, r.MaxDeviceSize, r.MaxStreamSize)
+		} else {
+			length, err = db.DataStream.InsertOnce(key, strm.DeviceID, strm.StreamID, substream, data, restamp, r.MaxDeviceSize, r.MaxStreamSize)
+		}
+		if err == datastream.ErrDuplicateInsert {
+			// The data was already inserted (and published) by an earlier request with this key
+			return nil
+		}
 		if err != nil {
 			return err
 		}
+		if !shifted {
+			index = length - int64(len(data))
+		}
 	}
 
-	return db.Messenger.Publish(streampath, messenger.Message{streampath, "", data})
+	return db.Messenger.Publish(streampath, messenger.Message{Stream: streampath, Data: data, Index: index})
 }
 
 //GetStreamTimeRangeByID reads time range by ID
@@ -231,3 +231,57 @@ func TestStreamIO(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, int64(0), l, "Timebatch has residual data from deleted stream")
 }
+
+func TestStreamBackfill(t *testing.T) {
+	Tdb.Clear()
+	db := Tdb
+
+	require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: "tst", Email: "root@localhost", Password: "mypass", Role: "user", Public: true},
+		Devices: map[string]*users.DeviceMaker{
+			"tst": &users.DeviceMaker{Streams: map[string]*users.StreamMaker{
+				"tst":      &users.StreamMaker{Stream: users.Stream{Schema: `{"type": "number"}`}},
+				"backfill": &users.StreamMaker{Stream: users.Stream{Schema: `{"type": "number"}`, Backfill: true}},
+			}},
+		},
+	}))
+
+	data := datastream.DatapointArray{
+		datastream.Datapoint{Timestamp: 1.0, Data: 1.0},
+		datastream.Datapoint{Timestamp: 3.0, Data: 3.0}}
+	old := datastream.DatapointArray{datastream.Datapoint{Timestamp: 2.0, Data: 2.0}}
+
+	// Streams don't accept old datapoints by default
+	require.NoError(t, db.InsertStream("tst/tst/tst", data, false))
+	require.Error(t, db.InsertStream("tst/tst/tst", old, false))
+
+	require.NoError(t, db.InsertStream("tst/tst/backfill", data, false))
+	require.NoError(t, db.InsertStream("tst/tst/backfill", old, false))
+
+	l, err := db.LengthStream("tst/tst/backfill")
+	require.NoError(t, err)
+	require.EqualValues(t, 3, l)
+
+	dr, err := db.GetStreamIndexRange("tst/tst/backfill", 0, 0, "")
+	require.NoError(t, err)
+	for i := 1; i <= 3; i++ {
+		dp, err := dr.Next()
+		require.NoError(t, err)
+		require.EqualValues(t, float64(i), dp.Timestamp)
+	}
+	dr.Close()
+
+	// Backfilled datapoints move the start of the schema versions after them
+	s, err := db.ReadStream("tst/tst/backfill")
+	require.NoError(t, err)
+	require.NoError(t, db.UpdateStreamSchemaByID(s.StreamID, `{"type": "number", "minimum": 0}`, ""))
+	require.NoError(t, db.InsertStream("tst/tst/backfill", datastream.DatapointArray{datastream.Datapoint{Timestamp: 5.0, Data: 5.0}}, false))
+	require.NoError(t, db.InsertStream("tst/tst/backfill", datastream.DatapointArray{
+		datastream.Datapoint{Timestamp: 0.5, Data: 0.5},
+		datastream.Datapoint{Timestamp: 4.0, Data: 4.0}}, false))
+
+	versions, err := db.ReadStreamSchemasByID(s.StreamID)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	require.EqualValues(t, 0, versions[0].StartIndex)
+	require.EqualValues(t, 5, versions[1].StartIndex)
+}
@@ -7,7 +7,7 @@ import (
 
 // DeviceAuthOperator logs in the given device object
 func (db *Database) DeviceAuthOperator(dev *users.Device) (*authoperator.AuthOperator, error) {
-	o, err := AddMetaLog(dev.UserID, db)
+	o, err := AddMetaLog(dev, db, db.Messenger)
 	if err != nil {
 		return nil, err
 	}
@@ -49,6 +49,16 @@ func (db *Database) DeviceLogin(apikey string) (*authoperator.AuthOperator, erro
 	return db.DeviceAuthOperator(dev)
 }
 
+// WebhookLogin logs in as the device with the given webhook token
+func (db *Database) WebhookLogin(token string) (*authoperator.AuthOperator, error) {
+	dev, err := db.Userdb.ReadDeviceByWebhookToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.DeviceAuthOperator(dev)
+}
+
 // Nobody returns the operator of a "nobody" - it will behave as someone who has "nobody" permissions
 func (db *Database) Nobody() *authoperator.AuthOperator {
 	return authoperator.NewNobody(db)
@@ -0,0 +1,29 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package messenger
+
+import (
+	"strings"
+
+	"github.com/nats-io/nats"
+)
+
+// commandRouting is the prefix of the routing of downlink commands
+const commandRouting = "_commands"
+
+func commandSubject(streampath string) string {
+	return commandRouting + "." + strings.Replace(streampath, "/", ".", -1)
+}
+
+//PublishCommand publishes a downlink command sent to the given stream
+func (m *Messenger) PublishCommand(streampath string, cmd interface{}) error {
+	return m.SendEconn.Publish(commandSubject(streampath), cmd)
+}
+
+//SubscribeCommands subscribes to the downlink commands sent to the given stream. The handler is a function
+//taking a pointer to the command type, such as func(c *users.Command), which is called with each command.
+func (m *Messenger) SubscribeCommands(streampath string, handler nats.Handler) (*nats.Subscription, error) {
+	return m.RecvEconn.Subscribe(commandSubject(streampath), handler)
+}
@@ -11,4 +11,12 @@ type Message struct {
 	Stream    string                    `json:"stream" msgpack:"s,omitempty"`
 	Transform string                    `json:"transform,omitempty" msgpack:"t,omitempty"`
 	Data      datastream.DatapointArray `json:"data" msgpack:"d,omitempty"`
+
+	// Index is the index in the stream of the first datapoint in Data, which allows subscribers to resume
+	// a subscription from where they left off. It is -1 for ephemeral streams, which do not store data.
+	Index int64 `json:"index,omitempty" msgpack:"i,omitempty"`
+
+	// Pattern is the wildcard pattern of the subscription which received the message, such as "user/*/stream".
+	// It is set by the subscriber, and is not sent over NATS.
+	Pattern string `json:"pattern,omitempty" msgpack:"-"`
 }
@@ -35,7 +35,7 @@ func TestMessenger(t *testing.T) {
 	//We bind a timeout to the channel, since we want the test to fail if no messages come through
 	go func() {
 		time.Sleep(2 * time.Second)
-		recvchan <- Message{"TIMEOUT", "", []datastream.Datapoint{}}
+		recvchan <- Message{Stream: "TIMEOUT", Data: []datastream.Datapoint{}}
 	}()
 
 	_, err = msg2.Subscribe("user1/device1/stream1", recvchan)
@@ -46,7 +46,7 @@ func TestMessenger(t *testing.T) {
 	msg2.Flush()
 
 	//Now, publish a message
-	err = msg.Publish("user1/device1/stream1/", Message{"user1/device1/stream1", "", []datastream.Datapoint{datastream.Datapoint{Data: "Hello"}}})
+	err = msg.Publish("user1/device1/stream1/", Message{Stream: "user1/device1/stream1", Data: []datastream.Datapoint{datastream.Datapoint{Data: "Hello"}}})
 	require.NoError(t, err)
 
 	m := <-recvchan
@@ -57,7 +57,7 @@ func TestMessenger(t *testing.T) {
 	require.NoError(t, err)
 
 	msg2.Flush()
-	require.NoError(t, msg.Publish("user1/device2/stream2", Message{"user1/device2/stream2", "", []datastream.Datapoint{datastream.Datapoint{Data: "Hi"}}}))
+	require.NoError(t, msg.Publish("user1/device2/stream2", Message{Stream: "user1/device2/stream2", Data: []datastream.Datapoint{datastream.Datapoint{Data: "Hi"}}}))
 
 	m = <-recvchan
 	require.Equal(t, m.Stream, "user1/device2/stream2")
@@ -0,0 +1,57 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package messenger
+
+import (
+	"strings"
+
+	"github.com/nats-io/nats"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// metaRouting is the prefix of the routing of metadata events. User names start with a letter,
+// so the events can't be confused with the data of a stream.
+const metaRouting = "_meta"
+
+//MetaEvent is published whenever a user, device or stream is created, updated, deleted, moved, or purged from the trash
+type MetaEvent struct {
+	Type      string   `json:"type" msgpack:"y"`                       // "user", "device" or "stream"
+	Action    string   `json:"action" msgpack:"a"`                     // "create", "update", "delete", "move" or "purge"
+	Path      string   `json:"path" msgpack:"p"`                       // The path of the changed user, device or stream
+	From      string   `json:"from,omitempty" msgpack:"r,omitempty"`   // The previous path of a moved device or stream
+	Fields    []string `json:"fields,omitempty" msgpack:"f,omitempty"` // The fields changed by an update
+	Actor     string   `json:"actor" msgpack:"o"`                      // The device which made the change, empty for the server
+	Timestamp float64  `json:"t" msgpack:"t"`
+}
+
+//User returns the name of the user which owns the changed object
+func (e *MetaEvent) User() string {
+	if i := strings.Index(e.Path, "/"); i != -1 {
+		return e.Path[:i]
+	}
+	return e.Path
+}
+
+//PublishMeta publishes the metadata event. Events are routed by the user which owns the changed object.
+func (m *Messenger) PublishMeta(e *MetaEvent) error {
+	return m.SendEconn.Publish(metaRouting+"."+e.User(), e)
+}
+
+//SubscribeMeta subscribes to the metadata events of all users. The filter, if not nil, is called with each event,
+//and the event is only sent to the channel if it returns true. Events are dropped if the channel is full, so that
+//a slow subscriber can't block the delivery of events.
+func (m *Messenger) SubscribeMeta(filter func(*MetaEvent) bool, chn chan MetaEvent) (*nats.Subscription, error) {
+	return m.RecvEconn.Subscribe(metaRouting+".*", func(e *MetaEvent) {
+		if filter != nil && !filter(e) {
+			return
+		}
+		select {
+		case chn <- *e:
+		default:
+			log.Warnf("Dropped %s %s event of %s: the subscriber is not reading events", e.Action, e.Type, e.Path)
+		}
+	})
+}
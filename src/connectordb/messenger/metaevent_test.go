@@ -0,0 +1,48 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package messenger
+
+import (
+	"config"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscribeMeta(t *testing.T) {
+	msg, err := ConnectMessenger(&config.TestOptions.NatsOptions, nil)
+	require.NoError(t, err)
+	defer msg.Close()
+
+	recvchan := make(chan MetaEvent, 1)
+	_, err = msg.SubscribeMeta(func(e *MetaEvent) bool {
+		return e.Type == "stream"
+	}, recvchan)
+	require.NoError(t, err)
+	msg.Flush()
+
+	require.NoError(t, msg.PublishMeta(&MetaEvent{Type: "device", Action: "create", Path: "user1/device1"}))
+	require.NoError(t, msg.PublishMeta(&MetaEvent{Type: "stream", Action: "create", Path: "user1/device1/stream1"}))
+	// The channel is full, so the event is dropped rather than blocking the subscription
+	require.NoError(t, msg.PublishMeta(&MetaEvent{Type: "stream", Action: "create", Path: "user1/device1/stream2"}))
+	msg.Flush()
+	time.Sleep(100 * time.Millisecond)
+
+	select {
+	case e := <-recvchan:
+		require.Equal(t, "user1/device1/stream1", e.Path)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Subscription timed out")
+	}
+
+	require.NoError(t, msg.PublishMeta(&MetaEvent{Type: "stream", Action: "delete", Path: "user1/device1/stream1"}))
+	select {
+	case e := <-recvchan:
+		require.Equal(t, "delete", e.Action)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Subscription timed out")
+	}
+}
@@ -0,0 +1,70 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package messenger
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/nats-io/nats"
+)
+
+// ErrBadPattern is returned when a subscription pattern is not of the form user/device/stream[/substream]
+var ErrBadPattern = errors.New("Subscription patterns must be of the form user/device/stream or user/device/stream/substream, where each element is a name or *")
+
+//IsPattern returns true if the path contains wildcards, such as "user/*/stream"
+func IsPattern(path string) bool {
+	return strings.Contains(path, "*")
+}
+
+//ValidatePattern checks that the pattern is a stream path in which each element is either a name or the * wildcard
+func ValidatePattern(pattern string) error {
+	p := strings.Split(pattern, "/")
+	if len(p) < 3 || len(p) > 4 {
+		return ErrBadPattern
+	}
+	for _, e := range p {
+		if e == "" || strings.Contains(e, ">") || strings.HasPrefix(e, "_") || e != "*" && strings.Contains(e, "*") {
+			return ErrBadPattern
+		}
+	}
+	return nil
+}
+
+//MatchPattern returns true if the stream path matches the pattern
+func MatchPattern(pattern, path string) bool {
+	p := strings.Split(pattern, "/")
+	s := strings.Split(path, "/")
+	if len(p) != len(s) {
+		return false
+	}
+	for i := range p {
+		if p[i] != "*" && p[i] != s[i] {
+			return false
+		}
+	}
+	return true
+}
+
+//SubscribePattern subscribes to all streams matching the pattern. The pattern is a stream path where
+//elements can be replaced with *, such as "user/*/heartrate". The filter, if not nil, is called with each message,
+//and the message is only sent to the channel if it returns true. Messages sent to the channel have their Pattern set.
+//Internal subjects, such as downlink commands, start with _ and are never matched.
+func (m *Messenger) SubscribePattern(pattern string, filter func(*Message) bool, chn chan Message) (*nats.Subscription, error) {
+	if err := ValidatePattern(pattern); err != nil {
+		return nil, err
+	}
+	routing := strings.Replace(pattern, "/", ".", -1)
+	return m.RecvEconn.Subscribe(routing, func(subject string, msg *Message) {
+		if strings.HasPrefix(subject, "_") {
+			return
+		}
+		if filter != nil && !filter(msg) {
+			return
+		}
+		msg.Pattern = pattern
+		chn <- *msg
+	})
+}
@@ -0,0 +1,74 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package messenger
+
+import (
+	"config"
+	"connectordb/datastream"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPattern(t *testing.T) {
+	require.True(t, IsPattern("user/*/stream"))
+	require.False(t, IsPattern("user/device/stream"))
+
+	require.NoError(t, ValidatePattern("*/*/*"))
+	require.NoError(t, ValidatePattern("user/*/stream/downlink"))
+	require.Error(t, ValidatePattern("user/*"))
+	require.Error(t, ValidatePattern("user/>"))
+	require.Error(t, ValidatePattern("user/dev*/stream"))
+	require.Error(t, ValidatePattern("user//stream"))
+	require.Error(t, ValidatePattern("_commands/*/*/*"))
+
+	require.True(t, MatchPattern("user/*/stream", "user/device/stream"))
+	require.False(t, MatchPattern("user/*/stream", "user/device/stream2"))
+	require.False(t, MatchPattern("user/*/stream", "user/device/stream/downlink"))
+}
+
+func TestSubscribePattern(t *testing.T) {
+	msg, err := ConnectMessenger(&config.TestOptions.NatsOptions, nil)
+	require.NoError(t, err)
+	defer msg.Close()
+
+	recvchan := make(chan Message, 10)
+	_, err = msg.SubscribePattern("user1/*", nil, recvchan)
+	require.Error(t, err)
+
+	_, err = msg.SubscribePattern("user1/*/stream1", func(m *Message) bool {
+		return m.Stream != "user1/device2/stream1"
+	}, recvchan)
+	require.NoError(t, err)
+	msg.Flush()
+
+	dpa := []datastream.Datapoint{datastream.Datapoint{Data: "Hello"}}
+	require.NoError(t, msg.Publish("user1/device2/stream1", Message{Stream: "user1/device2/stream1", Data: dpa}))
+	require.NoError(t, msg.Publish("user1/device1/stream2", Message{Stream: "user1/device1/stream2", Data: dpa}))
+	require.NoError(t, msg.Publish("user1/device1/stream1", Message{Stream: "user1/device1/stream1", Data: dpa}))
+
+	select {
+	case m := <-recvchan:
+		require.Equal(t, "user1/device1/stream1", m.Stream)
+		require.Equal(t, "user1/*/stream1", m.Pattern)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Subscription timed out")
+	}
+
+	// Internal subjects, such as downlink commands, don't match wildcards
+	_, err = msg.SubscribePattern("*/*/*/*", nil, recvchan)
+	require.NoError(t, err)
+	msg.Flush()
+	require.NoError(t, msg.PublishCommand("user1/device1/stream1", map[string]string{"command": "Hello"}))
+	require.NoError(t, msg.Publish("user1/device1/stream1/downlink", Message{Stream: "user1/device1/stream1/downlink", Data: dpa}))
+
+	select {
+	case m := <-recvchan:
+		require.Equal(t, "user1/device1/stream1/downlink", m.Stream)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Subscription timed out")
+	}
+}
@@ -0,0 +1,45 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package messenger
+
+import (
+	"strings"
+
+	"github.com/nats-io/nats"
+)
+
+// presenceRouting is the prefix of the routing of device presence changes
+const presenceRouting = "_presence"
+
+//PresenceEvent is published whenever a device goes online or offline
+type PresenceEvent struct {
+	Device   string  `json:"device" msgpack:"d"`   // The path of the device
+	Online   bool    `json:"online" msgpack:"o"`   // Whether the device is now online
+	LastSeen float64 `json:"lastseen" msgpack:"l"` // The time that the device was last seen by the server
+}
+
+//User returns the name of the user which owns the device
+func (e *PresenceEvent) User() string {
+	if i := strings.Index(e.Device, "/"); i != -1 {
+		return e.Device[:i]
+	}
+	return e.Device
+}
+
+//PublishPresence publishes the presence change of a device. Events are routed by the user which owns the device.
+func (m *Messenger) PublishPresence(e *PresenceEvent) error {
+	return m.SendEconn.Publish(presenceRouting+"."+e.User(), e)
+}
+
+//SubscribePresence subscribes to the presence changes of all devices. The filter, if not nil, is called with each event,
+//and the event is only sent to the channel if it returns true.
+func (m *Messenger) SubscribePresence(filter func(*PresenceEvent) bool, chn chan PresenceEvent) (*nats.Subscription, error) {
+	return m.RecvEconn.Subscribe(presenceRouting+".*", func(e *PresenceEvent) {
+		if filter != nil && !filter(e) {
+			return
+		}
+		chn <- *e
+	})
+}
@@ -2,32 +2,98 @@ package connectordb
 
 import (
 	"connectordb/datastream"
+	"connectordb/messenger"
 	"connectordb/operator"
 	"connectordb/pathwrapper"
 	"connectordb/users"
+	"sort"
 	"strings"
 
 	log "github.com/Sirupsen/logrus"
 )
 
+// metaActions gives the action of the MetaEvent published for each command of the metalog
+var metaActions = map[string]string{
+	"CreateUser":   "create",
+	"CreateDevice": "create",
+	"CreateStream": "create",
+	// To clients, something restored from the trash was created again, and a clone is a new stream
+	"Restore":     "create",
+	"CloneStream": "create",
+
+	"UpdateUser":         "update",
+	"UpdateDevice":       "update",
+	"UpdateStream":       "update",
+	"UpdateStreamSchema": "update",
+
+	"DeleteUser":   "delete",
+	"DeleteDevice": "delete",
+	"DeleteStream": "delete",
+
+	"RenameDevice":   "move",
+	"TransferDevice": "move",
+	"MoveStream":     "move",
+
+	"Purge": "purge",
+}
+
+// publishMeta publishes the change made by the given device on the messenger, so that clients can follow changes
+// in real time. The from path is only given for devices and streams which were moved to the path.
+func publishMeta(msg *messenger.Messenger, actor string, cmd string, path string, from string, updates map[string]interface{}) {
+	action, ok := metaActions[cmd]
+	if !ok {
+		log.WithFields(log.Fields{"cmd": cmd, "arg": path}).Error("Metalog event has an unknown command")
+		return
+	}
+	e := &messenger.MetaEvent{Action: action, Path: path, From: from, Actor: actor, Timestamp: datastream.NewDatapoint().Timestamp}
+	switch strings.Count(path, "/") {
+	case 0:
+		e.Type = "user"
+	case 1:
+		e.Type = "device"
+	default:
+		e.Type = "stream"
+	}
+	for k := range updates {
+		e.Fields = append(e.Fields, k)
+	}
+	sort.Strings(e.Fields)
+
+	if err := msg.PublishMeta(e); err != nil {
+		log.WithFields(log.Fields{"cmd": cmd, "arg": path}).Error("Metalog event publish failed: ", err)
+	}
+}
+
 // MetaLog logs device/stream creation and such to a meta log device.
 // It must be used OVER an authoperator, since the authoperator specifies the user for
-// which to write the metalog
+// which to write the metalog. Each change is also published as a MetaEvent on the messenger.
+//
+// The database's admin operator is also a MetaLog, which only publishes the changes, since they are not made by a device.
 type MetaLog struct {
 	operator.Operator
 	pathwrapper.Wrapper
 	metalogID int64
 	name      string
+
+	actor string               // The path of the device making the changes. It is empty for the admin operator.
+	msg   *messenger.Messenger // The messenger on which to publish changes. No events are published if nil.
 }
 
-// AddMetaLog logs metalog information
-func AddMetaLog(userID int64, o operator.Operator) (MetaLog, error) {
-	usr, err := o.AdminOperator().ReadUserByID(userID)
+// adminMetaLog publishes the changes made through the database's admin operator, such as from the shell or by imports
+func adminMetaLog(db *Database) MetaLog {
+	ml := MetaLog{Operator: db, msg: db.Messenger}
+	ml.Wrapper = pathwrapper.Wrap(ml)
+	return ml
+}
+
+// AddMetaLog logs metalog information for changes made by the given device
+func AddMetaLog(dev *users.Device, o operator.Operator, msg *messenger.Messenger) (MetaLog, error) {
+	usr, err := o.AdminOperator().ReadUserByID(dev.UserID)
 	if err != nil {
 		return MetaLog{}, err
 	}
 
-	ml := MetaLog{Operator: o, name: usr.Name}
+	ml := MetaLog{Operator: o, name: usr.Name, actor: usr.Name + "/" + dev.Name, msg: msg}
 	ml.Wrapper = pathwrapper.Wrap(ml)
 	err = ml.checkcreate(usr.Name + "/meta/log")
 	if err != nil {
@@ -54,9 +120,35 @@ func (m MetaLog) checkcreate(path string) error {
 	return nil
 }
 
-func (m MetaLog) writeLog(cmd string, arg string) {
+// publishEvent publishes the change on the messenger. The from path is only given for devices and streams
+// which were moved to the path.
+func (m MetaLog) publishEvent(cmd string, path string, from string, updates map[string]interface{}) {
+	if m.msg != nil {
+		publishMeta(m.msg, m.actor, cmd, path, from, updates)
+	}
+}
+
+func (m MetaLog) writeLog(cmd string, arg string, updates map[string]interface{}) {
+	m.publishEvent(cmd, arg, "", updates)
+	m.insertLog(cmd, map[string]string{"cmd": cmd, "arg": arg}, arg)
+}
+
+// writeMove logs the move of a device or stream to a new path. If the path is owned by another user,
+// the move is logged for both users.
+func (m MetaLog) writeMove(cmd string, from string, to string) {
+	m.publishEvent(cmd, to, from, nil)
+	m.insertLog(cmd, map[string]string{"cmd": cmd, "arg": to, "from": from}, to, from)
+}
+
+// insertLog writes the data to this object's metalog, and to the metalogs of the owners of the given paths
+func (m MetaLog) insertLog(cmd string, data map[string]string, paths ...string) {
+	if m.metalogID == 0 {
+		// The admin operator has no metalog of its own
+		return
+	}
+	arg := paths[0]
 	dp := datastream.NewDatapoint()
-	dp.Data = map[string]string{"cmd": cmd, "arg": arg}
+	dp.Data = data
 	dp.Sender = m.Name()
 	dpa := datastream.DatapointArray{dp}
 
@@ -66,41 +158,58 @@ func (m MetaLog) writeLog(cmd string, arg string) {
 		log.WithFields(log.Fields{"cmd": cmd, "arg": arg, "o": m.Name()}).Error("Metalog insert failed: ", err)
 	}
 
-	//Next, make sure that the owner of the arg also gets inserted if not this
-	i := strings.Index(arg, "/")
-	if i != -1 {
-		arg = arg[:i]
-	}
-	//Make sure same user name - and if the user was deleted, then its devices don't exist
-	if !strings.HasPrefix(arg+"/", m.name) && cmd != "DeleteUser" {
-		m.checkcreate(arg + "/meta/log")
-		//different user!
-		err := m.AdminOperator().InsertStream(arg+"/meta/log", dpa, true)
-		if err != nil {
+	//Next, make sure that the owners of the paths also get inserted if not this
+	logged := map[string]bool{}
+	for _, owner := range paths {
+		i := strings.Index(owner, "/")
+		if i != -1 {
+			owner = owner[:i]
+		}
+		//Make sure same user name - and if the user was deleted, then its devices don't exist
+		if !strings.HasPrefix(owner+"/", m.name) && cmd != "DeleteUser" && !logged[owner] {
+			logged[owner] = true
+			m.checkcreate(owner + "/meta/log")
+			//different user!
+			err := m.AdminOperator().InsertStream(owner+"/meta/log", dpa, true)
+			if err != nil {
 
+			}
 		}
 	}
 }
 
-func (m MetaLog) logUserID(userID int64, cmd string) {
+func (m MetaLog) logUserID(userID int64, cmd string, updates map[string]interface{}) {
 	u, err := m.AdminOperator().ReadUserByID(userID)
 	if err == nil {
-		m.writeLog(cmd, u.Name)
+		m.writeLog(cmd, u.Name, updates)
 	}
 }
 
-func (m MetaLog) logDeviceID(deviceID int64, cmd string) {
+func (m MetaLog) logDeviceID(deviceID int64, cmd string, updates map[string]interface{}) {
 	d, err := m.AdminOperator().ReadDeviceByID(deviceID)
 	if err != nil {
 		return
 	}
 	u, err := m.AdminOperator().ReadUserByID(d.UserID)
 	if err == nil {
-		m.writeLog(cmd, u.Name+"/"+d.Name)
+		m.writeLog(cmd, u.Name+"/"+d.Name, updates)
+	}
+}
+
+// streamPath returns the path of the stream with the given name on the given device, or "" if the device is not found
+func (m MetaLog) streamPath(deviceID int64, name string) string {
+	d, err := m.AdminOperator().ReadDeviceByID(deviceID)
+	if err != nil {
+		return ""
+	}
+	u, err := m.AdminOperator().ReadUserByID(d.UserID)
+	if err != nil {
+		return ""
 	}
+	return u.Name + "/" + d.Name + "/" + name
 }
 
-func (m MetaLog) logStreamID(streamID int64, cmd string) {
+func (m MetaLog) logStreamID(streamID int64, cmd string, updates map[string]interface{}) {
 	s, err := m.AdminOperator().ReadStreamByID(streamID)
 	if err != nil {
 		log.Errorf("Metalog couldn't find stream %d", streamID)
@@ -113,7 +222,7 @@ func (m MetaLog) logStreamID(streamID int64, cmd string) {
 	}
 	u, err := m.AdminOperator().ReadUserByID(d.UserID)
 	if err == nil {
-		m.writeLog(cmd, u.Name+"/"+d.Name+"/"+s.Name)
+		m.writeLog(cmd, u.Name+"/"+d.Name+"/"+s.Name, updates)
 	} else {
 		log.Errorf("Metalog couldn't find user %d", d.UserID)
 	}
@@ -122,7 +231,7 @@ func (m MetaLog) logStreamID(streamID int64, cmd string) {
 func (m MetaLog) CreateUser(u *users.UserMaker) error {
 	err := m.Operator.CreateUser(u)
 	if err == nil {
-		m.writeLog("CreateUser", u.Name)
+		m.writeLog("CreateUser", u.Name, nil)
 	}
 	return err
 }
@@ -130,15 +239,20 @@ func (m MetaLog) CreateUser(u *users.UserMaker) error {
 func (m MetaLog) UpdateUserByID(userID int64, updates map[string]interface{}) error {
 	err := m.Operator.UpdateUserByID(userID, updates)
 	if err == nil {
-		m.logUserID(userID, "UpdateUser")
+		m.logUserID(userID, "UpdateUser", updates)
 	}
 	return err
 }
 func (m MetaLog) DeleteUserByID(userID int64) error {
 	u, _ := m.AdminOperator().ReadUserByID(userID)
 	err := m.Operator.DeleteUserByID(userID)
-	if err == nil && u != nil && u.Name != m.name {
-		m.writeLog("DeleteUser", u.Name)
+	if err == nil && u != nil {
+		if u.Name != m.name {
+			m.writeLog("DeleteUser", u.Name, nil)
+		} else {
+			// The user's metalog was deleted along with the user
+			m.publishEvent("DeleteUser", u.Name, "", nil)
+		}
 	}
 	return err
 }
@@ -147,7 +261,7 @@ func (m MetaLog) CreateDeviceByUserID(d *users.DeviceMaker) error {
 	if err == nil {
 		dev, err := m.AdminOperator().ReadDeviceByUserID(d.UserID, d.Name)
 		if err == nil {
-			m.logDeviceID(dev.DeviceID, "CreateDevice")
+			m.logDeviceID(dev.DeviceID, "CreateDevice", nil)
 		}
 	}
 	return err
@@ -155,7 +269,7 @@ func (m MetaLog) CreateDeviceByUserID(d *users.DeviceMaker) error {
 func (m MetaLog) UpdateDeviceByID(deviceID int64, updates map[string]interface{}) error {
 	err := m.Operator.UpdateDeviceByID(deviceID, updates)
 	if err == nil {
-		m.logDeviceID(deviceID, "UpdateDevice")
+		m.logDeviceID(deviceID, "UpdateDevice", updates)
 	}
 	return err
 }
@@ -167,7 +281,40 @@ func (m MetaLog) DeleteDeviceByID(deviceID int64) error {
 	}
 	err = m.Operator.DeleteDeviceByID(deviceID)
 	if err == nil && u != nil {
-		m.writeLog("DeleteDevice", u.Name+"/"+d.Name)
+		m.writeLog("DeleteDevice", u.Name+"/"+d.Name, nil)
+	}
+	return err
+}
+func (m MetaLog) RestoreTrash(path string) error {
+	err := m.Operator.RestoreTrash(path)
+	if err == nil {
+		m.writeLog("Restore", path, nil)
+	}
+	return err
+}
+func (m MetaLog) RenameDeviceByID(deviceID int64, name string, alias bool) error {
+	var u *users.User
+	d, err := m.AdminOperator().ReadDeviceByID(deviceID)
+	if err == nil {
+		u, _ = m.AdminOperator().ReadUserByID(d.UserID)
+	}
+	err = m.Operator.RenameDeviceByID(deviceID, name, alias)
+	if err == nil && u != nil {
+		m.writeMove("RenameDevice", u.Name+"/"+d.Name, u.Name+"/"+name)
+	}
+	return err
+}
+func (m MetaLog) TransferDeviceByID(deviceID int64, userID int64) error {
+	var u *users.User
+	d, err := m.AdminOperator().ReadDeviceByID(deviceID)
+	if err == nil {
+		u, _ = m.AdminOperator().ReadUserByID(d.UserID)
+	}
+	err = m.Operator.TransferDeviceByID(deviceID, userID)
+	if err == nil && u != nil {
+		if to, err := m.AdminOperator().ReadUserByID(userID); err == nil {
+			m.writeMove("TransferDevice", u.Name+"/"+d.Name, to.Name+"/"+d.Name)
+		}
 	}
 	return err
 }
@@ -176,7 +323,7 @@ func (m MetaLog) CreateStreamByDeviceID(s *users.StreamMaker) error {
 	if err == nil {
 		strm, err := m.AdminOperator().ReadStreamByDeviceID(s.DeviceID, s.Name)
 		if err == nil {
-			m.logStreamID(strm.StreamID, "CreateStream")
+			m.logStreamID(strm.StreamID, "CreateStream", nil)
 		}
 	}
 	return err
@@ -184,7 +331,43 @@ func (m MetaLog) CreateStreamByDeviceID(s *users.StreamMaker) error {
 func (m MetaLog) UpdateStreamByID(streamID int64, updates map[string]interface{}) error {
 	err := m.Operator.UpdateStreamByID(streamID, updates)
 	if err == nil {
-		m.logStreamID(streamID, "UpdateStream")
+		m.logStreamID(streamID, "UpdateStream", updates)
+	}
+	return err
+}
+func (m MetaLog) MoveStreamByID(streamID int64, deviceID int64, name string, alias bool) error {
+	var from string
+	s, err := m.AdminOperator().ReadStreamByID(streamID)
+	if err == nil {
+		from = m.streamPath(s.DeviceID, s.Name)
+	}
+	err = m.Operator.MoveStreamByID(streamID, deviceID, name, alias)
+	if err == nil && from != "" {
+		if to := m.streamPath(deviceID, name); to != "" {
+			m.writeMove("MoveStream", from, to)
+		}
+	}
+	return err
+}
+func (m MetaLog) CloneStreamByID(streamID int64, deviceID int64, name string, t1 float64, t2 float64) error {
+	var from string
+	s, err := m.AdminOperator().ReadStreamByID(streamID)
+	if err == nil {
+		from = m.streamPath(s.DeviceID, s.Name)
+	}
+	err = m.Operator.CloneStreamByID(streamID, deviceID, name, t1, t2)
+	if err == nil && from != "" {
+		if to := m.streamPath(deviceID, name); to != "" {
+			m.publishEvent("CloneStream", to, "", nil)
+			m.insertLog("CloneStream", map[string]string{"cmd": "CloneStream", "arg": to, "from": from}, to, from)
+		}
+	}
+	return err
+}
+func (m MetaLog) UpdateStreamSchemaByID(streamID int64, schema string, upgrade string) error {
+	err := m.Operator.UpdateStreamSchemaByID(streamID, schema, upgrade)
+	if err == nil {
+		m.logStreamID(streamID, "UpdateStreamSchema", map[string]interface{}{"schema": schema})
 	}
 	return err
 }
@@ -202,7 +385,7 @@ func (m MetaLog) DeleteStreamByID(streamID int64, substream string) error {
 	err = m.Operator.DeleteStreamByID(streamID, substream)
 	if err == nil && u != nil {
 
-		m.writeLog("DeleteStream", u.Name+"/"+d.Name+"/"+s.Name)
+		m.writeLog("DeleteStream", u.Name+"/"+d.Name+"/"+s.Name, nil)
 	}
 	return err
 }
@@ -36,7 +36,7 @@ func TestUserlog(t *testing.T) {
 	//The message timeout
 	go func() {
 		time.Sleep(5 * time.Second)
-		recvchan <- messenger.Message{"TIMEOUT", "", []datastream.Datapoint{}}
+		recvchan <- messenger.Message{Stream: "TIMEOUT", Data: []datastream.Datapoint{}}
 	}()
 
 	o.CreateDevice("streamdb_test/mydevice", &users.DeviceMaker{})
@@ -73,3 +73,40 @@ func TestUserlog(t *testing.T) {
 	ensureUserlog(t, <-recvchan, "DeleteUser", "starry_eyed_userlog")
 
 }
+
+func TestAdminMetaEvents(t *testing.T) {
+	Tdb.Clear()
+	db := Tdb
+
+	recvchan := make(chan messenger.MetaEvent, 10)
+	sub, err := db.SubscribeMeta(func(e *messenger.MetaEvent) bool {
+		return e.User() == "streamdb_admin"
+	}, recvchan)
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	ensureEvent := func(action, path string) {
+		select {
+		case e := <-recvchan:
+			require.Equal(t, action, e.Action)
+			require.Equal(t, path, e.Path)
+			require.Equal(t, "", e.Actor)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("No %s event for %s", action, path)
+		}
+	}
+
+	// Changes made by the server are published, without an actor
+	o := db.AdminOperator()
+	require.NoError(t, o.CreateUser(&users.UserMaker{User: users.User{Name: "streamdb_admin", Email: "root@localhost", Password: "mypass", Role: "user", Public: true}}))
+	ensureEvent("create", "streamdb_admin")
+
+	require.NoError(t, o.CreateDevice("streamdb_admin/mydevice", &users.DeviceMaker{}))
+	ensureEvent("create", "streamdb_admin/mydevice")
+
+	require.NoError(t, o.RenameDevice("streamdb_admin/mydevice", "yourdevice", false))
+	ensureEvent("move", "streamdb_admin/yourdevice")
+
+	require.NoError(t, o.DeleteDevice("streamdb_admin/yourdevice"))
+	ensureEvent("delete", "streamdb_admin/yourdevice")
+}
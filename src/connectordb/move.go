@@ -0,0 +1,123 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package connectordb
+
+import (
+	pconfig "config/permissions"
+	"connectordb/authoperator/permissions"
+	"connectordb/users"
+	"errors"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// RenameDeviceByID renames the device. Its streams and their data are keyed by ID, so they are unaffected.
+// If alias is true, the old name keeps resolving to the device, so that paths using it keep working.
+func (db *Database) RenameDeviceByID(deviceID int64, name string, alias bool) error {
+	d, err := db.ReadDeviceByID(deviceID)
+	if err != nil {
+		return err
+	}
+
+	// The meta and user devices are found by their names, so they can't be renamed
+	if d.Name == "user" || d.Name == "meta" {
+		return errors.New(d.Name + " device cannot be renamed")
+	}
+	return db.Userdb.MoveDevice(deviceID, name, alias)
+}
+
+// TransferDeviceByID gives the device, along with its streams, to another user. The device must fit within the
+// new owner's device limits, and its streams within the new owner's stream limit. The data is held by device,
+// so it stays in place.
+func (db *Database) TransferDeviceByID(deviceID int64, userID int64) error {
+	d, err := db.ReadDeviceByID(deviceID)
+	if err != nil {
+		return err
+	}
+	if d.Name == "user" || d.Name == "meta" {
+		return errors.New(d.Name + " device cannot be transferred")
+	}
+	if d.UserID == userID {
+		return nil
+	}
+
+	maxdev, maxstream, err := db.checkIfAddingDeviceWillExceedPrivateLimit(d.Public, userID)
+	if err != nil {
+		return err
+	}
+	if maxdev > 0 {
+		devs, err := db.ReadAllDevicesByUserID(userID)
+		if err != nil {
+			return err
+		}
+		if int64(len(devs)) >= maxdev {
+			return errors.New("Can't transfer device: Device number limit exceeded.")
+		}
+	}
+	if maxstream > 0 {
+		streams, err := db.ReadAllStreamsByDeviceID(deviceID)
+		if err != nil {
+			return err
+		}
+		if int64(len(streams)) > maxstream {
+			return errors.New("Can't transfer device: The device has more streams than the user may have on a device.")
+		}
+	}
+	return db.Userdb.TransferDevice(deviceID, userID)
+}
+
+// MoveStreamByID moves the stream to the given device and name, along with its data.
+// If alias is true, the old path keeps resolving to the stream, so that paths using it keep working.
+func (db *Database) MoveStreamByID(streamID, deviceID int64, name string, alias bool) error {
+	s, err := db.ReadStreamByID(streamID)
+	if err != nil {
+		return err
+	}
+
+	if deviceID != s.DeviceID {
+		// The stream is created on the device it is moved to, so it must fit in the device's stream limit
+		dev, err := db.ReadDeviceByID(deviceID)
+		if err != nil {
+			return err
+		}
+		u, err := db.ReadUserByID(dev.UserID)
+		if err != nil {
+			return err
+		}
+		r := permissions.GetUserRole(pconfig.Get(), u)
+		if r.MaxStreams > 0 {
+			streams, err := db.ReadAllStreamsByDeviceID(deviceID)
+			if err != nil {
+				return err
+			}
+			if int64(len(streams)) >= r.MaxStreams {
+				return errors.New("Cannot move stream: Exceeded maximum stream number for device.")
+			}
+		}
+	}
+
+	if err = db.Userdb.MoveStream(streamID, deviceID, name, alias); err != nil {
+		return err
+	}
+
+	// The data in the cache is held by device, so it must follow the stream
+	err = db.DataStream.MoveStream(s.DeviceID, streamID, deviceID)
+	if err != nil {
+		// Put the stream back with its data
+		if err2 := db.Userdb.MoveStream(streamID, s.DeviceID, s.Name, false); err2 != nil {
+			log.Errorf("Failed to move stream %d back to device %d: %s", streamID, s.DeviceID, err2.Error())
+		}
+	}
+	return err
+}
+
+// readDeviceByAlias reads the device which had the given name before it was renamed
+func (db *Database) readDeviceByAlias(userID int64, devicename string) (*users.Device, error) {
+	dev, err := db.Userdb.ReadDeviceByAlias(userID, devicename)
+	if err == nil {
+		db.fillPresence(dev)
+	}
+	return dev, err
+}
@@ -0,0 +1,151 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package connectordb
+
+import (
+	"connectordb/datastream"
+	"connectordb/users"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMove(t *testing.T) {
+	Tdb.Clear()
+	db := Tdb
+
+	require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: "myuser", Email: "email@email", Password: "test", Role: "user", Public: true}}))
+	require.NoError(t, db.CreateDevice("myuser/mydevice", &users.DeviceMaker{}))
+	require.NoError(t, db.CreateDevice("myuser/other", &users.DeviceMaker{}))
+	require.NoError(t, db.CreateStream("myuser/mydevice/mystream", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"number"}`}}))
+	require.NoError(t, db.InsertStream("myuser/mydevice/mystream", datastream.DatapointArray{datastream.Datapoint{Timestamp: 1, Data: 1.0}}, false))
+
+	require.Error(t, db.RenameDevice("myuser/user", "notuser", false))
+	require.Error(t, db.RenameDevice("myuser/mydevice", "other", false))
+
+	// Renaming with an alias keeps the old path working
+	require.NoError(t, db.RenameDevice("myuser/mydevice", "newdevice", true))
+	d, err := db.ReadDevice("myuser/mydevice")
+	require.NoError(t, err)
+	require.Equal(t, "newdevice", d.Name)
+	l, err := db.LengthStream("myuser/newdevice/mystream")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), l)
+
+	// Moving the stream to another device brings its data along
+	require.Error(t, db.MoveStream("myuser/newdevice/mystream", "myuser/nodevice/mystream", false))
+	require.NoError(t, db.MoveStream("myuser/newdevice/mystream", "myuser/other/moved", false))
+	_, err = db.ReadStream("myuser/newdevice/mystream")
+	require.Error(t, err)
+	l, err = db.LengthStream("myuser/other/moved")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), l)
+	require.NoError(t, db.InsertStream("myuser/other/moved", datastream.DatapointArray{datastream.Datapoint{Timestamp: 2, Data: 2.0}}, false))
+	l, err = db.LengthStream("myuser/other/moved")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), l)
+
+	// A real device takes precedence over an alias
+	require.NoError(t, db.CreateDevice("myuser/mydevice", &users.DeviceMaker{}))
+	d, err = db.ReadDevice("myuser/mydevice")
+	require.NoError(t, err)
+	require.Equal(t, "mydevice", d.Name)
+}
+
+func TestTransferDevice(t *testing.T) {
+	Tdb.Clear()
+	db := Tdb
+
+	require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: "admin", Email: "admin@email", Password: "test", Role: "admin", Public: true}}))
+	require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: "myuser", Email: "email@email", Password: "test", Role: "user", Public: true}}))
+	require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: "other", Email: "other@email", Password: "test", Role: "user", Public: true}}))
+	require.NoError(t, db.CreateDevice("myuser/mydevice", &users.DeviceMaker{}))
+	require.NoError(t, db.CreateStream("myuser/mydevice/mystream", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"number"}`}}))
+	require.NoError(t, db.InsertStream("myuser/mydevice/mystream", datastream.DatapointArray{datastream.Datapoint{Timestamp: 1, Data: 1.0}}, false))
+
+	require.Error(t, db.TransferDevice("myuser/meta", "other"))
+	require.Error(t, db.TransferDevice("myuser/mydevice", "nobody"))
+
+	o, err := db.AsUser("admin")
+	require.NoError(t, err)
+	l1, err := db.LengthStream("myuser/meta/log")
+	require.NoError(t, err)
+
+	require.NoError(t, o.TransferDevice("myuser/mydevice", "other"))
+	_, err = db.ReadDevice("myuser/mydevice")
+	require.Error(t, err)
+	l, err := db.LengthStream("other/mydevice/mystream")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), l)
+
+	// The transfer is in the logs of both users
+	l2, err := db.LengthStream("myuser/meta/log")
+	require.NoError(t, err)
+	require.Equal(t, l1+1, l2)
+	dr, err := db.GetStreamIndexRange("other/meta/log", -1, 0, "")
+	require.NoError(t, err)
+	dp, err := dr.Next()
+	require.NoError(t, err)
+	require.Equal(t, "TransferDevice", dp.Data.(map[string]interface{})["cmd"])
+	require.Equal(t, "myuser/mydevice", dp.Data.(map[string]interface{})["from"])
+	dr.Close()
+
+	// The device can't be given to a user which already has a device with its name
+	require.NoError(t, db.CreateDevice("myuser/mydevice", &users.DeviceMaker{}))
+	require.Error(t, db.TransferDevice("other/mydevice", "myuser"))
+}
+
+func TestCloneStream(t *testing.T) {
+	Tdb.Clear()
+	db := Tdb
+
+	require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: "myuser", Email: "email@email", Password: "test", Role: "user", Public: true}}))
+	require.NoError(t, db.CreateDevice("myuser/mydevice", &users.DeviceMaker{}))
+	require.NoError(t, db.CreateStream("myuser/mydevice/mystream", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"number"}`, Nickname: "hi"}}))
+	require.NoError(t, db.InsertStream("myuser/mydevice/mystream", datastream.DatapointArray{
+		datastream.Datapoint{Timestamp: 1, Data: 1.0},
+		datastream.Datapoint{Timestamp: 2, Data: 2.0},
+		datastream.Datapoint{Timestamp: 3, Data: 3.0},
+	}, false))
+
+	require.Error(t, db.CloneStream("myuser/mydevice/mystream", "myuser/mydevice/mystream", 0, 0))
+
+	require.NoError(t, db.CloneStream("myuser/mydevice/mystream", "myuser/mydevice/copy", 0, 0))
+	s, err := db.ReadStream("myuser/mydevice/copy")
+	require.NoError(t, err)
+	require.Equal(t, "hi", s.Nickname)
+	l, err := db.LengthStream("myuser/mydevice/copy")
+	require.NoError(t, err)
+	require.Equal(t, int64(3), l)
+
+	// Only the given time range is copied, and the copy is independent of the original
+	require.NoError(t, db.CloneStream("myuser/mydevice/mystream", "myuser/mydevice/partial", 1.5, 2.5))
+	l, err = db.LengthStream("myuser/mydevice/partial")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), l)
+	require.NoError(t, db.InsertStream("myuser/mydevice/partial", datastream.DatapointArray{datastream.Datapoint{Timestamp: 4, Data: 4.0}}, false))
+	l, err = db.LengthStream("myuser/mydevice/mystream")
+	require.NoError(t, err)
+	require.Equal(t, int64(3), l)
+
+	// The schema history is copied, so that the copied data is upgraded in the same way as the original's
+	require.NoError(t, db.UpdateStreamSchema("myuser/mydevice/mystream", `{"type":"boolean"}`, "$ > 2"))
+	require.NoError(t, db.InsertStream("myuser/mydevice/mystream", datastream.DatapointArray{datastream.Datapoint{Timestamp: 4, Data: false}}, false))
+	require.NoError(t, db.CloneStream("myuser/mydevice/mystream", "myuser/mydevice/upgraded", 1.5, 0))
+	versions, err := db.ReadStreamSchemas("myuser/mydevice/upgraded")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	require.EqualValues(t, 0, versions[0].StartIndex)
+	require.EqualValues(t, 2, versions[1].StartIndex)
+
+	dr, err := db.GetStreamIndexRange("myuser/mydevice/upgraded", 0, 0, "")
+	require.NoError(t, err)
+	defer dr.Close()
+	for _, d := range []bool{false, true, false} {
+		dp, err := dr.Next()
+		require.NoError(t, err)
+		require.Equal(t, d, dp.Data)
+	}
+}
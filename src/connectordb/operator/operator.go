@@ -45,6 +45,10 @@ type Operator interface {
 	UpdateDeviceByID(deviceID int64, updates map[string]interface{}) error
 	DeleteDeviceByID(deviceID int64) error
 
+	// SeenDeviceByID records that the device just talked to the server. It is used to track the
+	// last seen time and online status of devices.
+	SeenDeviceByID(deviceID int64) error
+
 	ReadAllStreamsByDeviceID(deviceID int64) ([]*users.Stream, error)
 	ReadAllStreamsByUserID(userID int64, public, downlink, hidden bool) ([]*users.DevStream, error)
 	CreateStreamByDeviceID(*users.StreamMaker) error
@@ -53,11 +57,46 @@ type Operator interface {
 	UpdateStreamByID(streamID int64, updates map[string]interface{}) error
 	DeleteStreamByID(streamID int64, substream string) error // The substream represents things like the downlink
 
+	// Deleted users, devices and streams are moved to the trash when there is a trash period. ReadTrash lists what the
+	// user has in the trash by path, and RestoreTrash takes the given path out of the trash along with what it owns.
+	ReadTrash(username string) ([]*users.TrashItem, error)
+	RestoreTrash(path string) error
+
+	// Devices can be renamed, and streams moved to another device or name, keeping their data. If alias is true,
+	// the old path keeps resolving to the device or stream.
+	RenameDeviceByID(deviceID int64, name string, alias bool) error
+	MoveStreamByID(streamID int64, deviceID int64, name string, alias bool) error
+
+	// A device can be given to another user along with its streams, and a stream can be copied to a new stream,
+	// optionally only the data between t1 and t2.
+	TransferDeviceByID(deviceID int64, userID int64) error
+	CloneStreamByID(streamID int64, deviceID int64, name string, t1 float64, t2 float64) error
+
+	// Each change of a stream's schema is recorded as a new schema version. The upgrade is an optional pipescript
+	// transform which converts data of the previous version to the new schema. CheckStreamSchemaByID is a dry run,
+	// which returns the number of existing datapoints that would violate the proposed schema after upgrading.
+	ReadStreamSchemasByID(streamID int64) ([]*users.StreamSchema, error)
+	UpdateStreamSchemaByID(streamID int64, schema string, upgrade string) error
+	CheckStreamSchemaByID(streamID int64, substream string, schema string, upgrade string) (*users.SchemaCheck, error)
+
+	// Downlink streams have a queue of commands, which devices acknowledge by updating the command's status
+	// to delivered, and then to done or failed along with a result. Commands which expire before they are done
+	// are marked as expired.
+	CreateCommandByID(streamID int64, c *users.Command) error
+	ReadCommandByID(commandID int64) (*users.Command, error)
+	ReadCommandsByID(streamID int64, status string) ([]*users.Command, error)
+	UpdateCommandByID(commandID int64, status string, result string) error
+	SubscribeCommandsByID(streamID int64, chn chan users.Command) (*nats.Subscription, error)
+
 	//These operations concern themselves with the IO of a stream
 	LengthStreamByID(streamID int64, substream string) (int64, error)
 	TimeToIndexStreamByID(streamID int64, substream string, time float64) (int64, error)
 	InsertStreamByID(streamID int64, substream string, data datastream.DatapointArray, restamp bool) error
 
+	// InsertStreamOnceByID is InsertStreamByID with an idempotency key. A repeated insert with the same key
+	// succeeds without writing the data again, so that clients can safely retry inserts which timed out.
+	InsertStreamOnceByID(streamID int64, substream string, key string, data datastream.DatapointArray, restamp bool) error
+
 	/**GetStreamTimeRangeByID Reads all datapoints in the given time range (t1, t2]
 
 	t1,t2 - Unix time in seconds with up to ns resolution
@@ -87,6 +126,13 @@ type Operator interface {
 	SubscribeDeviceByID(deviceID int64, chn chan messenger.Message) (*nats.Subscription, error)
 	SubscribeStreamByID(streamID int64, substream string, chn chan messenger.Message) (*nats.Subscription, error)
 
+	// SubscribePattern subscribes to all streams matching the given pattern, such as "user/*/stream".
+	// The filter, if not nil, decides whether each message is sent to the channel.
+	SubscribePattern(pattern string, filter func(*messenger.Message) bool, chn chan messenger.Message) (*nats.Subscription, error)
+
+	// SubscribeMeta subscribes to the events published when users, devices and streams are created, updated or deleted
+	SubscribeMeta(filter func(*messenger.MetaEvent) bool, chn chan messenger.MetaEvent) (*nats.Subscription, error)
+
 	// CountUsers returns the number of existing users in the database at the
 	// time of calling or an error if the database could not be reached.
 	CountUsers() (int64, error)
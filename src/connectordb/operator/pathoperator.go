@@ -25,18 +25,35 @@ type PathOperator interface {
 	ReadDevice(devicepath string) (*users.Device, error)
 	UpdateDevice(devicepath string, updates map[string]interface{}) error
 	DeleteDevice(devicepath string) error
+	RenameDevice(devicepath string, name string, alias bool) error
+	TransferDevice(devicepath string, username string) error
 
 	ReadDeviceStreams(devicepath string) ([]*users.Stream, error)
 	ReadUserStreams(username string, public, downlink, hidden bool) ([]*users.DevStream, error)
 	CreateStream(streampath string, s *users.StreamMaker) error
 	ReadStream(streampath string) (*users.Stream, error)
+	ReadOrCreateStream(streampath string, s *users.StreamMaker) (*users.Stream, error)
 	UpdateStream(streampath string, updates map[string]interface{}) error
 	DeleteStream(streampath string) error
+	MoveStream(streampath string, newpath string, alias bool) error
+	CloneStream(streampath string, newpath string, t1 float64, t2 float64) error
+
+	ReadStreamSchemas(streampath string) ([]*users.StreamSchema, error)
+	UpdateStreamSchema(streampath string, schema string, upgrade string) error
+	CheckStreamSchema(streampath string, schema string, upgrade string) (*users.SchemaCheck, error)
+
+	CreateCommand(streampath string, c *users.Command) error
+	ReadCommand(streampath string, commandID int64) (*users.Command, error)
+	ReadCommands(streampath string, status string) ([]*users.Command, error)
+	UpdateCommand(streampath string, commandID int64, status string, result string) error
+	SubscribeCommands(streampath string, chn chan users.Command) (*nats.Subscription, error)
 
 	GetStreamIndexRange(streampath string, i1 int64, i2 int64, transform string) (datastream.DataRange, error)
 	GetStreamTimeRange(streampath string, t1 float64, t2 float64, limit int64, transform string) (datastream.DataRange, error)
 	GetShiftedStreamTimeRange(streampath string, t1 float64, t2 float64, ishift, limit int64, transform string) (datastream.DataRange, error)
 	InsertStream(streampath string, data datastream.DatapointArray, restamp bool) error
+	InsertStreamOnce(streampath string, key string, data datastream.DatapointArray, restamp bool) error
+	InsertStreams(key string, data map[string]datastream.DatapointArray, restamp bool) map[string]error
 	LengthStream(streampath string) (int64, error)
 
 	Subscribe(path string, chn chan messenger.Message) (*nats.Subscription, error)
@@ -0,0 +1,65 @@
+package pathwrapper
+
+import (
+	"connectordb/authoperator/permissions"
+	"connectordb/datastream"
+	"connectordb/users"
+	"util"
+
+	pconfig "config/permissions"
+	log "github.com/Sirupsen/logrus"
+)
+
+// readOrCreateStream reads the stream which is being inserted into. If the stream does not exist, and its device
+// has auto_create_streams set (and its roles allow it), the stream is created with a schema inferred from the data.
+// The stream is created through the operator, so that permissions are checked and the creation is logged.
+func (w Wrapper) readOrCreateStream(streampath string, data datastream.DatapointArray) (*users.Stream, error) {
+	_, devicepath, streampath, _, _, err := util.SplitStreamPath(streampath)
+	if err != nil {
+		return nil, err
+	}
+	strm, err := w.AdminOperator().ReadStream(streampath)
+	if err != users.ErrStreamNotFound {
+		return strm, err
+	}
+
+	dev, err := w.AdminOperator().ReadDevice(devicepath)
+	if err != nil || !dev.AutoCreateStreams {
+		return nil, users.ErrStreamNotFound
+	}
+	usr, err := w.AdminOperator().ReadUserByID(dev.UserID)
+	if err != nil {
+		return nil, users.ErrStreamNotFound
+	}
+	perm := pconfig.Get()
+	if !permissions.GetUserRole(perm, usr).CanAutoCreateStreams || !permissions.GetDeviceRole(perm, dev).CanAutoCreateStreams {
+		return nil, users.ErrStreamNotFound
+	}
+
+	return w.createStream(streampath, &users.StreamMaker{Stream: users.Stream{Schema: datastream.InferSchema(data)}})
+}
+
+// ReadOrCreateStream reads the stream at the given path, creating it from the given StreamMaker if it does not exist
+func (w Wrapper) ReadOrCreateStream(streampath string, s *users.StreamMaker) (*users.Stream, error) {
+	strm, err := w.ReadStream(streampath)
+	if err != users.ErrStreamNotFound {
+		return strm, err
+	}
+	return w.createStream(streampath, s)
+}
+
+// createStream creates the stream through the operator, so that permissions are checked and the creation is logged,
+// and returns the new stream
+func (w Wrapper) createStream(streampath string, s *users.StreamMaker) (*users.Stream, error) {
+	err := w.CreateStream(streampath, s)
+	if err == nil {
+		log.WithFields(log.Fields{"stream": streampath, "schema": s.Schema}).Info("Created stream")
+	}
+
+	// If the stream was created by a concurrent insert, the create fails, but the stream exists
+	strm, rerr := w.AdminOperator().ReadStream(streampath)
+	if rerr != nil && err != nil {
+		return nil, err
+	}
+	return strm, rerr
+}
@@ -0,0 +1,58 @@
+package pathwrapper
+
+import (
+	"connectordb/users"
+
+	"github.com/nats-io/nats"
+)
+
+//CreateCommand queues the command on the given downlink stream
+func (w Wrapper) CreateCommand(streampath string, c *users.Command) error {
+	s, err := w.AdminOperator().ReadStream(streampath)
+	if err != nil {
+		return err
+	}
+	return w.CreateCommandByID(s.StreamID, c)
+}
+
+//ReadCommand reads the command of the given stream
+func (w Wrapper) ReadCommand(streampath string, commandID int64) (*users.Command, error) {
+	s, err := w.AdminOperator().ReadStream(streampath)
+	if err != nil {
+		return nil, err
+	}
+	c, err := w.ReadCommandByID(commandID)
+	if err != nil {
+		return nil, err
+	}
+	if c.StreamID != s.StreamID {
+		return nil, users.ErrCommandNotFound
+	}
+	return c, nil
+}
+
+//ReadCommands reads the commands queued on the given stream which have the given status, or all of them if status is empty
+func (w Wrapper) ReadCommands(streampath string, status string) ([]*users.Command, error) {
+	s, err := w.AdminOperator().ReadStream(streampath)
+	if err != nil {
+		return nil, err
+	}
+	return w.ReadCommandsByID(s.StreamID, status)
+}
+
+//UpdateCommand sets the status and result of the command of the given stream
+func (w Wrapper) UpdateCommand(streampath string, commandID int64, status string, result string) error {
+	if _, err := w.ReadCommand(streampath, commandID); err != nil {
+		return err
+	}
+	return w.UpdateCommandByID(commandID, status, result)
+}
+
+//SubscribeCommands subscribes to the commands sent to the given stream
+func (w Wrapper) SubscribeCommands(streampath string, chn chan users.Command) (*nats.Subscription, error) {
+	s, err := w.AdminOperator().ReadStream(streampath)
+	if err != nil {
+		return nil, err
+	}
+	return w.SubscribeCommandsByID(s.StreamID, chn)
+}
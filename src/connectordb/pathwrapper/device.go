@@ -52,6 +52,28 @@ func (w Wrapper) UpdateDevice(devicepath string, updates map[string]interface{})
 	return w.UpdateDeviceByID(dev.DeviceID, updates)
 }
 
+// RenameDevice gives the device at the given path a new name
+func (w Wrapper) RenameDevice(devicepath string, name string, alias bool) error {
+	dev, err := w.AdminOperator().ReadDevice(devicepath)
+	if err != nil {
+		return err
+	}
+	return w.RenameDeviceByID(dev.DeviceID, name, alias)
+}
+
+// TransferDevice gives the device at the given path to the given user
+func (w Wrapper) TransferDevice(devicepath string, username string) error {
+	dev, err := w.AdminOperator().ReadDevice(devicepath)
+	if err != nil {
+		return err
+	}
+	u, err := w.AdminOperator().ReadUser(username)
+	if err != nil {
+		return err
+	}
+	return w.TransferDeviceByID(dev.DeviceID, u.UserID)
+}
+
 //DeleteDevice deletes an existing device
 func (w Wrapper) DeleteDevice(devicepath string) error {
 	dev, err := w.AdminOperator().ReadDevice(devicepath)
@@ -2,9 +2,13 @@ package pathwrapper
 
 import (
 	"connectordb/datastream"
+	"sync"
 	"util"
 )
 
+// BulkInsertWorkers is the maximum number of streams which are inserted into at the same time by InsertStreams
+const BulkInsertWorkers = 8
+
 //LengthStream returns the total number of datapoints in the given stream
 func (w Wrapper) LengthStream(streampath string) (int64, error) {
 	_, _, streampath, _, substream, err := util.SplitStreamPath(streampath)
@@ -33,17 +37,56 @@ func (w Wrapper) TimeToIndexStream(streampath string, time float64) (int64, erro
 
 //InsertStream inserts the given array of datapoints into the given stream.
 func (w Wrapper) InsertStream(streampath string, data datastream.DatapointArray, restamp bool) error {
-	_, _, streampath, _, substream, err := util.SplitStreamPath(streampath)
+	_, _, _, _, substream, err := util.SplitStreamPath(streampath)
 	if err != nil {
 		return err
 	}
-	strm, err := w.AdminOperator().ReadStream(streampath)
+	strm, err := w.readOrCreateStream(streampath, data)
 	if err != nil {
 		return err
 	}
 	return w.InsertStreamByID(strm.StreamID, substream, data, restamp)
 }
 
+//InsertStreamOnce inserts the given array of datapoints into the given stream, unless an insert with the same key was already made.
+func (w Wrapper) InsertStreamOnce(streampath string, key string, data datastream.DatapointArray, restamp bool) error {
+	_, _, _, _, substream, err := util.SplitStreamPath(streampath)
+	if err != nil {
+		return err
+	}
+	strm, err := w.readOrCreateStream(streampath, data)
+	if err != nil {
+		return err
+	}
+	return w.InsertStreamOnceByID(strm.StreamID, substream, key, data, restamp)
+}
+
+//InsertStreams inserts into multiple streams at once. The data is a map of stream paths to the datapoints to insert.
+//The inserts are independent of each other, and are run concurrently. The returned map has the errors
+//of the streams into which the insert failed, and is empty if all inserts succeeded. The optional key is used
+//as the idempotency key of each of the inserts.
+func (w Wrapper) InsertStreams(key string, data map[string]datastream.DatapointArray, restamp bool) map[string]error {
+	failed := make(map[string]error)
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	workers := make(chan struct{}, BulkInsertWorkers)
+	for streampath, dpa := range data {
+		wg.Add(1)
+		workers <- struct{}{}
+		go func(streampath string, dpa datastream.DatapointArray) {
+			defer wg.Done()
+			if err := w.InsertStreamOnce(streampath, key, dpa, restamp); err != nil {
+				lock.Lock()
+				failed[streampath] = err
+				lock.Unlock()
+			}
+			<-workers
+		}(streampath, dpa)
+	}
+	wg.Wait()
+	return failed
+}
+
 //GetStreamTimeRange Reads the given stream by time range
 func (w Wrapper) GetStreamTimeRange(streampath string, t1 float64, t2 float64, limit int64, transform string) (datastream.DataRange, error) {
 	_, _, streampath, _, substream, err := util.SplitStreamPath(streampath)
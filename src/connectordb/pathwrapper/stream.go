@@ -62,6 +62,49 @@ func (w Wrapper) UpdateStream(streampath string, updates map[string]interface{})
 	return w.UpdateStreamByID(s.StreamID, updates)
 }
 
+// MoveStream moves the stream to the new path, which can be on another device
+func (w Wrapper) MoveStream(streampath string, newpath string, alias bool) error {
+	// Substreams can't be moved on their own
+	_, _, _, _, substream, err := util.SplitStreamPath(streampath)
+	if err != nil || substream != "" {
+		return util.ErrBadPath
+	}
+	_, devicepath, _, streamname, substream, err := util.SplitStreamPath(newpath)
+	if err != nil || substream != "" {
+		return util.ErrBadPath
+	}
+	s, err := w.AdminOperator().ReadStream(streampath)
+	if err != nil {
+		return err
+	}
+	dev, err := w.AdminOperator().ReadDevice(devicepath)
+	if err != nil {
+		return err
+	}
+	return w.MoveStreamByID(s.StreamID, dev.DeviceID, streamname, alias)
+}
+
+// CloneStream copies the stream and its data between t1 and t2 to the new path
+func (w Wrapper) CloneStream(streampath string, newpath string, t1 float64, t2 float64) error {
+	_, _, _, _, substream, err := util.SplitStreamPath(streampath)
+	if err != nil || substream != "" {
+		return util.ErrBadPath
+	}
+	_, devicepath, _, streamname, substream, err := util.SplitStreamPath(newpath)
+	if err != nil || substream != "" {
+		return util.ErrBadPath
+	}
+	s, err := w.AdminOperator().ReadStream(streampath)
+	if err != nil {
+		return err
+	}
+	dev, err := w.AdminOperator().ReadDevice(devicepath)
+	if err != nil {
+		return err
+	}
+	return w.CloneStreamByID(s.StreamID, dev.DeviceID, streamname, t1, t2)
+}
+
 //DeleteStream deletes the given stream given its path
 func (w Wrapper) DeleteStream(streampath string) error {
 	_, _, streampath, _, substream, err := util.SplitStreamPath(streampath)
@@ -74,3 +117,34 @@ func (w Wrapper) DeleteStream(streampath string) error {
 	}
 	return w.DeleteStreamByID(s.StreamID, substream)
 }
+
+// ReadStreamSchemas returns the schema versions of the given stream
+func (w Wrapper) ReadStreamSchemas(streampath string) ([]*users.StreamSchema, error) {
+	s, err := w.AdminOperator().ReadStream(streampath)
+	if err != nil {
+		return nil, err
+	}
+	return w.ReadStreamSchemasByID(s.StreamID)
+}
+
+// UpdateStreamSchema sets a new schema version for the given stream
+func (w Wrapper) UpdateStreamSchema(streampath string, schema string, upgrade string) error {
+	s, err := w.AdminOperator().ReadStream(streampath)
+	if err != nil {
+		return err
+	}
+	return w.UpdateStreamSchemaByID(s.StreamID, schema, upgrade)
+}
+
+// CheckStreamSchema checks the data of the given stream (or substream) against a proposed schema
+func (w Wrapper) CheckStreamSchema(streampath string, schema string, upgrade string) (*users.SchemaCheck, error) {
+	_, _, streampath, _, substream, err := util.SplitStreamPath(streampath)
+	if err != nil {
+		return nil, err
+	}
+	s, err := w.AdminOperator().ReadStream(streampath)
+	if err != nil {
+		return nil, err
+	}
+	return w.CheckStreamSchemaByID(s.StreamID, substream, schema, upgrade)
+}
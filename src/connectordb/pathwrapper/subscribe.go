@@ -39,8 +39,12 @@ func (w Wrapper) SubscribeStream(streampath string, chn chan messenger.Message)
 	return w.SubscribeStreamByID(strm.StreamID, substream, chn)
 }
 
-//Subscribe given a path, attempts to subscribe to it and its children
+//Subscribe given a path, attempts to subscribe to it and its children. Paths with wildcards, such as "user/*/stream",
+//subscribe to all matching streams.
 func (w Wrapper) Subscribe(path string, chn chan messenger.Message) (*nats.Subscription, error) {
+	if messenger.IsPattern(path) {
+		return w.SubscribePattern(path, nil, chn)
+	}
 	switch strings.Count(path, "/") {
 	default:
 		return w.SubscribeStream(path, chn)
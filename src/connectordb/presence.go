@@ -0,0 +1,172 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package connectordb
+
+import (
+	"connectordb/datastream"
+	"connectordb/messenger"
+	"connectordb/users"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// presence keeps track of the devices which are currently online. A device's last seen time is held in memory
+// while it is online, and is periodically written to the database, so that reads don't hit the database on each request.
+type presence struct {
+	sync.Mutex
+
+	timeout float64 // The number of seconds after which a device that was not seen goes offline
+	log     bool    // Whether presence changes are written to the user's meta/presence stream
+
+	seen  map[int64]float64 // The last seen times of online devices
+	saved map[int64]float64 // The last seen times that were written to the database
+
+	done chan struct{}
+}
+
+// presenceSchema is the schema of the meta/presence stream
+const presenceSchema = `{"type": "object", "properties": {"device": {"type": "string"},"online": {"type": "boolean"}},"required": ["device","online"]}`
+
+// SeenDeviceByID records that the device just talked to the server. If the device was offline, it goes online.
+func (db *Database) SeenDeviceByID(deviceID int64) error {
+	if db.presence.timeout <= 0 {
+		return nil
+	}
+	t := now()
+
+	db.presence.Lock()
+	_, online := db.presence.seen[deviceID]
+	db.presence.seen[deviceID] = t
+	if !online {
+		db.presence.saved[deviceID] = t
+	}
+	db.presence.Unlock()
+
+	if online {
+		return nil
+	}
+	return db.setPresence(deviceID, true, t)
+}
+
+// fillPresence sets the presence fields of the device. Devices which were seen by another ConnectorDB process
+// are online if their last seen time in the database is within the timeout.
+func (db *Database) fillPresence(d *users.Device) {
+	if db.presence.timeout <= 0 {
+		return
+	}
+	db.presence.Lock()
+	t, online := db.presence.seen[d.DeviceID]
+	db.presence.Unlock()
+
+	if online {
+		d.LastSeen = t
+	}
+	d.Online = now()-d.LastSeen < db.presence.timeout
+}
+
+// setPresence writes the device's last seen time, and publishes the presence change
+func (db *Database) setPresence(deviceID int64, online bool, lastseen float64) error {
+	if err := db.Userdb.UpdateDeviceLastSeen(deviceID, lastseen); err != nil {
+		return err
+	}
+	dev, err := db.Userdb.ReadDeviceByID(deviceID)
+	if err != nil {
+		return err
+	}
+	usr, err := db.Userdb.ReadUserById(dev.UserID)
+	if err != nil {
+		return err
+	}
+
+	e := &messenger.PresenceEvent{Device: usr.Name + "/" + dev.Name, Online: online, LastSeen: lastseen}
+	if err = db.Messenger.PublishPresence(e); err != nil {
+		return err
+	}
+	if db.presence.log {
+		return db.logPresence(usr.Name, e)
+	}
+	return nil
+}
+
+// logPresence writes the presence change to the user's meta/presence stream, creating the stream if it doesn't exist
+func (db *Database) logPresence(username string, e *messenger.PresenceEvent) error {
+	streampath := username + "/meta/presence"
+	if _, err := db.ReadStream(streampath); err != nil {
+		err = db.AdminOperator().CreateStream(streampath, &users.StreamMaker{Stream: users.Stream{
+			Description: "The times that the user's devices went online and offline",
+			Schema:      presenceSchema,
+			Icon:        "material:wifi",
+		}})
+		if err != nil {
+			return err
+		}
+	}
+
+	dp := datastream.NewDatapoint()
+	dp.Data = map[string]interface{}{"device": e.Device, "online": e.Online}
+	return db.InsertStream(streampath, datastream.DatapointArray{dp}, true)
+}
+
+// seenElsewhere returns true if the device was seen within the timeout by another ConnectorDB process, which
+// saved a later last seen time to the database. Such a device is still online, and the other process sets it offline.
+func (db *Database) seenElsewhere(deviceID int64, lastseen float64) bool {
+	dev, err := db.Userdb.ReadDeviceByID(deviceID)
+	return err == nil && dev.LastSeen > lastseen && now()-dev.LastSeen < db.presence.timeout
+}
+
+// checkPresence sets devices which were not seen within the timeout offline, and saves the last seen
+// times of the devices which are still online. When running multiple frontends, a device that moved to
+// another frontend is only dropped from this one's presence, since it is still online.
+func (db *Database) checkPresence() {
+	t := now()
+	offline := make(map[int64]float64)
+	changed := make(map[int64]float64)
+
+	db.presence.Lock()
+	for id, lastseen := range db.presence.seen {
+		if t-lastseen >= db.presence.timeout {
+			offline[id] = lastseen
+			delete(db.presence.seen, id)
+			delete(db.presence.saved, id)
+		} else if db.presence.saved[id] != lastseen {
+			changed[id] = lastseen
+			db.presence.saved[id] = lastseen
+		}
+	}
+	db.presence.Unlock()
+
+	for id, lastseen := range offline {
+		if db.seenElsewhere(id, lastseen) {
+			continue
+		}
+		if err := db.setPresence(id, false, lastseen); err != nil && err != users.ErrDeviceNotFound {
+			log.WithField("deviceid", id).Warn("Failed to set device offline: ", err)
+		}
+	}
+	for id, lastseen := range changed {
+		db.Userdb.UpdateDeviceLastSeen(id, lastseen)
+	}
+}
+
+// runPresence periodically checks for devices that went offline, until the database is closed
+func (db *Database) runPresence(done chan struct{}) {
+	// Check often enough that a device goes offline at most a quarter timeout late
+	interval := time.Duration(db.presence.timeout*float64(time.Second)) / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			db.checkPresence()
+		}
+	}
+}
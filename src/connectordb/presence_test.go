@@ -0,0 +1,109 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package connectordb
+
+import (
+	"connectordb/messenger"
+	"connectordb/users"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPresence(t *testing.T) {
+	Tdb.Clear()
+	db := Tdb
+
+	db.presence.log = true
+	defer func() { db.presence.log = false }()
+
+	require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: "myuser", Email: "email@email", Password: "test", Role: "user", Public: true}}))
+	require.NoError(t, db.CreateDevice("myuser/mydevice", &users.DeviceMaker{}))
+
+	dev, err := db.ReadDevice("myuser/mydevice")
+	require.NoError(t, err)
+	require.False(t, dev.Online)
+	require.Equal(t, float64(0), dev.LastSeen)
+
+	recvchan := make(chan messenger.PresenceEvent, 2)
+	sub, err := db.Messenger.SubscribePresence(func(e *messenger.PresenceEvent) bool {
+		return e.User() == "myuser"
+	}, recvchan)
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+	time.Sleep(100 * time.Millisecond)
+
+	require.NoError(t, db.SeenDeviceByID(dev.DeviceID))
+
+	select {
+	case e := <-recvchan:
+		require.Equal(t, "myuser/mydevice", e.Device)
+		require.True(t, e.Online)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Did not get online event")
+	}
+
+	dev, err = db.ReadDevice("myuser/mydevice")
+	require.NoError(t, err)
+	require.True(t, dev.Online)
+	require.True(t, dev.LastSeen > 0)
+
+	// Seeing an online device does not publish anything
+	require.NoError(t, db.SeenDeviceByID(dev.DeviceID))
+
+	// A device seen by another frontend after this one is not set offline
+	db.presence.Lock()
+	db.presence.seen[dev.DeviceID] = dev.LastSeen - 2*db.presence.timeout
+	db.presence.Unlock()
+	db.checkPresence()
+
+	select {
+	case e := <-recvchan:
+		t.Fatalf("Got presence event of a device seen by another frontend: %v", e)
+	case <-time.After(500 * time.Millisecond):
+	}
+	dev, err = db.ReadDevice("myuser/mydevice")
+	require.NoError(t, err)
+	require.True(t, dev.Online)
+
+	// Pretend that the device was last seen long ago
+	require.NoError(t, db.SeenDeviceByID(dev.DeviceID))
+	select {
+	case e := <-recvchan:
+		require.True(t, e.Online)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Did not get online event")
+	}
+	require.NoError(t, db.Userdb.UpdateDeviceLastSeen(dev.DeviceID, dev.LastSeen-2*db.presence.timeout))
+	db.presence.Lock()
+	db.presence.seen[dev.DeviceID] = dev.LastSeen - 2*db.presence.timeout
+	db.presence.Unlock()
+	db.checkPresence()
+
+	select {
+	case e := <-recvchan:
+		require.Equal(t, "myuser/mydevice", e.Device)
+		require.False(t, e.Online)
+		require.Equal(t, dev.LastSeen-2*db.presence.timeout, e.LastSeen)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Did not get offline event")
+	}
+
+	dev, err = db.ReadDevice("myuser/mydevice")
+	require.NoError(t, err)
+	require.False(t, dev.Online)
+
+	devs, err := db.ReadAllDevicesByUserID(dev.UserID)
+	require.NoError(t, err)
+	for i := range devs {
+		require.False(t, devs[i].Online)
+	}
+
+	// All changes were written to the meta stream
+	l, err := db.LengthStream("myuser/meta/presence")
+	require.NoError(t, err)
+	require.Equal(t, int64(3), l)
+}
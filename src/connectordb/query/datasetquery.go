@@ -6,6 +6,7 @@ package query
 
 import (
 	"connectordb/datastream"
+	"connectordb/datatypes"
 	"errors"
 	"fmt"
 
@@ -25,6 +26,23 @@ var (
 	TDatasetMinDt = float64(1e-3)
 )
 
+//defaultInterpolator returns the default interpolator of the given stream's datatype, or "" if there is none
+func defaultInterpolator(o Operator, streampath string) string {
+	sr, ok := o.(StreamReader)
+	if !ok {
+		return ""
+	}
+	s, err := sr.ReadStream(streampath)
+	if err != nil {
+		return ""
+	}
+	dt, ok := datatypes.Get(s.Datatype)
+	if !ok {
+		return ""
+	}
+	return dt.Interpolator
+}
+
 //DatasetQueryElement specifies the information necessary to generate a single column of a Dataset
 type DatasetQueryElement struct {
 	StreamQuery                 // Allows to query the stream by its own values
@@ -78,8 +96,12 @@ func (dqe *DatasetQueryElement) Get(o Operator, tstart float64) (dre *DatasetRan
 		return nil, err
 	}
 
-	//The element's datarange is ready - set up the interpolator
-	intpltr, err := interpolator.Parse(dqe.Interpolator, &DatapointIterator{dr})
+	//The element's datarange is ready - set up the interpolator, using the datatype's default if none was given
+	ipltr := dqe.Interpolator
+	if ipltr == "" && dqe.Stream != "" {
+		ipltr = defaultInterpolator(o, dqe.Stream)
+	}
+	intpltr, err := interpolator.Parse(ipltr, &DatapointIterator{dr})
 	if err != nil {
 		dr.Close()
 		return nil, err
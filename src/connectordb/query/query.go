@@ -6,6 +6,7 @@ package query
 
 import (
 	"connectordb/datastream"
+	"connectordb/users"
 	"errors"
 )
 
@@ -17,12 +18,19 @@ type Operator interface {
 	GetShiftedStreamTimeRange(streampath string, t1 float64, t2 float64, ishift, limit int64, transform string) (datastream.DataRange, error)
 }
 
+//StreamReader is optionally implemented by an Operator. If the Operator given to a dataset query implements it,
+//dataset elements that do not specify an interpolator use the default interpolator of their stream's datatype
+type StreamReader interface {
+	ReadStream(streampath string) (*users.Stream, error)
+}
+
 //StreamQuery contains all the necessary information to perform a query on the given stream. It is the structure used
 //to encode a query for merge and dataset. It uses the Operator's functions internally.
 //Note that while both index-based and time based elements are in the struct, it is only valid to use one at a time
 type StreamQuery struct {
 	Stream    string  `json:"stream"`              //The stream name in form usr/dev/stream
 	Transform string  `json:"transform,omitempty"` //The transform to perform on the stream
+	Unit      string  `json:"unit,omitempty"`      //The unit of the stream's datatype to convert the data to, before the transform
 	I1        int64   `json:"i1,omitempty"`        //The first index to get
 	I2        int64   `json:"i2,omitempty"`        //The end index of the range to get
 	T1        float64 `json:"t1,omitempty"`        //The start time of the range to get
@@ -46,6 +54,17 @@ func (s *StreamQuery) HasRange() bool {
 
 //Run runs the query that the struct encodes on the given operator.
 func (s *StreamQuery) Run(qm Operator) (datastream.DataRange, error) {
+	transform := s.Transform
+	if s.Unit != "" {
+		sr, ok := qm.(StreamReader)
+		if !ok {
+			return nil, ErrUnitOperator
+		}
+		var err error
+		if transform, err = UnitTransform(sr, s.Stream, s.Unit, s.Transform); err != nil {
+			return nil, err
+		}
+	}
 
 	if s.T1 != 0 || s.T2 != 0 || s.Limit != 0 {
 		//First check that only one method of querying is active
@@ -56,11 +75,11 @@ func (s *StreamQuery) Run(qm Operator) (datastream.DataRange, error) {
 
 		//Alright, query by time
 		if s.indexbacktrack > 0 {
-			return qm.GetShiftedStreamTimeRange(s.Stream, s.T1, s.T2, -s.indexbacktrack, s.Limit, s.Transform)
+			return qm.GetShiftedStreamTimeRange(s.Stream, s.T1, s.T2, -s.indexbacktrack, s.Limit, transform)
 		}
-		return qm.GetStreamTimeRange(s.Stream, s.T1, s.T2, s.Limit, s.Transform)
+		return qm.GetStreamTimeRange(s.Stream, s.T1, s.T2, s.Limit, transform)
 	}
 
 	//The query method is by integer (or no query method is chosen, meaning whole stream)
-	return qm.GetStreamIndexRange(s.Stream, s.I1, s.I2, s.Transform)
+	return qm.GetStreamIndexRange(s.Stream, s.I1, s.I2, transform)
 }
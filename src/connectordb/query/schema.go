@@ -0,0 +1,110 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package query
+
+import "encoding/json"
+
+// valueInterpolators are the interpolators which return datapoints of the interpolated stream unchanged,
+// so that the dataset column has the stream's schema
+var valueInterpolators = map[string]bool{
+	"":        true,
+	"before":  true,
+	"after":   true,
+	"closest": true,
+}
+
+// schema returns the JSON schema of the datapoints returned by the query, or nil if it is not known. It is known
+// only if there is no transform, and all of the stream's data has the current schema.
+func (s *StreamQuery) schema(o Operator) map[string]interface{} {
+	sr, ok := o.(StreamReader)
+	if !ok || s.Transform != "" {
+		return nil
+	}
+	strm, err := sr.ReadStream(s.Stream)
+	if err != nil || strm.SchemaVersion > 0 {
+		return nil
+	}
+	var schema map[string]interface{}
+	if json.Unmarshal([]byte(strm.Schema), &schema) != nil {
+		return nil
+	}
+	return schema
+}
+
+// mergeSchema returns the schema of the merged streams if all of them have the same known schema
+func mergeSchema(o Operator, sq []*StreamQuery) map[string]interface{} {
+	var schema map[string]interface{}
+	first := ""
+	for i := range sq {
+		s := sq[i].schema(o)
+		if s == nil {
+			return nil
+		}
+		if i == 0 {
+			schema, first = s, marshalSchema(s)
+		} else if marshalSchema(s) != first {
+			return nil
+		}
+	}
+	return schema
+}
+
+// MergeSchema returns the JSON schema of the datapoints of a merge, or "" if it is not known
+func MergeSchema(o Operator, sq []*StreamQuery) string {
+	return marshalSchema(mergeSchema(o, sq))
+}
+
+// Schema returns the JSON schema of a stream query's datapoints, or "" if it is not known
+func (s *StreamQuery) Schema(o Operator) string {
+	return marshalSchema(s.schema(o))
+}
+
+// Schema returns the JSON schema of the dataset's datapoints. The dataset's datapoints are objects with a property
+// for each element of the dataset (and x for datasets based on a stream), whose schema is known if the element has no
+// transform and is interpolated by a value of the stream. Properties with unknown schemas accept anything.
+func (d *DatasetQuery) Schema(o Operator) string {
+	if d.PostTransform != "" {
+		return ""
+	}
+	properties := make(map[string]interface{})
+	for key, e := range d.Dataset {
+		var s map[string]interface{}
+		if valueInterpolators[e.Interpolator] {
+			if e.Stream != "" {
+				s = e.StreamQuery.schema(o)
+			} else {
+				s = mergeSchema(o, e.Merge)
+			}
+		}
+		if s == nil {
+			s = map[string]interface{}{}
+		}
+		properties[key] = s
+	}
+	if d.IsValid() || len(d.Merge) > 0 {
+		var s map[string]interface{}
+		if d.IsValid() {
+			s = d.StreamQuery.schema(o)
+		} else {
+			s = mergeSchema(o, d.Merge)
+		}
+		if s == nil {
+			s = map[string]interface{}{}
+		}
+		properties["x"] = s
+	}
+	return marshalSchema(map[string]interface{}{"type": "object", "properties": properties})
+}
+
+func marshalSchema(s map[string]interface{}) string {
+	if s == nil {
+		return ""
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
@@ -0,0 +1,61 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package query
+
+import (
+	"connectordb/users"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+//SchemaOperator is a MockOperator which also reads the streams' schemas
+type SchemaOperator struct {
+	MockOperator
+	Streams map[string]*users.Stream
+}
+
+func (m *SchemaOperator) ReadStream(streampath string) (*users.Stream, error) {
+	s, ok := m.Streams[streampath]
+	if !ok {
+		return nil, errors.New("Could not find stream " + streampath)
+	}
+	return s, nil
+}
+
+func TestSchema(t *testing.T) {
+	o := &SchemaOperator{Streams: map[string]*users.Stream{
+		"u/d/s1": &users.Stream{Schema: `{"type":"number"}`},
+		"u/d/s2": &users.Stream{Schema: `{"type":"number"}`},
+		"u/d/s3": &users.Stream{Schema: `{"type":"string"}`},
+		"u/d/s4": &users.Stream{Schema: `{"type":"string"}`, SchemaVersion: 1},
+	}}
+
+	require.Equal(t, `{"type":"number"}`, (&StreamQuery{Stream: "u/d/s1"}).Schema(o))
+	require.Equal(t, "", (&StreamQuery{Stream: "u/d/s1", Transform: "$>1"}).Schema(o))
+	require.Equal(t, "", (&StreamQuery{Stream: "u/d/s4"}).Schema(o))
+	require.Equal(t, "", (&StreamQuery{Stream: "u/d/nope"}).Schema(o))
+	require.Equal(t, "", (&StreamQuery{Stream: "u/d/s1"}).Schema(NewMockOperator(nil)))
+
+	require.Equal(t, `{"type":"number"}`, MergeSchema(o, []*StreamQuery{{Stream: "u/d/s1"}, {Stream: "u/d/s2"}}))
+	require.Equal(t, "", MergeSchema(o, []*StreamQuery{{Stream: "u/d/s1"}, {Stream: "u/d/s3"}}))
+
+	d := &DatasetQuery{
+		StreamQuery: StreamQuery{Stream: "u/d/s3"},
+		Dataset: map[string]*DatasetQueryElement{
+			"a": &DatasetQueryElement{StreamQuery: StreamQuery{Stream: "u/d/s1"}},
+			"b": &DatasetQueryElement{StreamQuery: StreamQuery{Stream: "u/d/s1"}, Interpolator: "count"},
+			"c": &DatasetQueryElement{Merge: []*StreamQuery{{Stream: "u/d/s1"}, {Stream: "u/d/s2"}}, Interpolator: "before"},
+		},
+	}
+	require.Equal(t, `{"properties":{"a":{"type":"number"},"b":{},"c":{"type":"number"},"x":{"type":"string"}},"type":"object"}`, d.Schema(o))
+
+	d.StreamQuery.Stream = ""
+	require.Equal(t, `{"properties":{"a":{"type":"number"},"b":{},"c":{"type":"number"}},"type":"object"}`, d.Schema(o))
+
+	d.PostTransform = "$"
+	require.Equal(t, "", d.Schema(o))
+}
@@ -129,7 +129,7 @@ func (t *TransformRange) Next() (*datastream.Datapoint, error) {
 }
 
 //NewTransformRange generates a transform range from a transfrom pipeline
-func NewTransformRange(dr datastream.ExtendedDataRange, transformpipeline string) (*TransformRange, error) {
+func NewTransformRange(dr datastream.DataRange, transformpipeline string) (*TransformRange, error) {
 	t, err := pipescript.Parse(transformpipeline)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,38 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package query
+
+import (
+	"connectordb/datatypes"
+	"errors"
+)
+
+//ErrUnitOperator is returned when a unit conversion is requested from an Operator which can't read streams
+var ErrUnitOperator = errors.New("Unit conversion is not supported by the operator")
+
+//UnitTransform returns the transform which converts the data of the given stream from the unit of its datatype
+//to the given unit, and then runs the given transform on it. Since the conversion is itself a transform, the result
+//can be used anywhere a transform is, so that all reads of the data (ranges, datasets and subscriptions) share it.
+func UnitTransform(o StreamReader, streampath string, unit string, transform string) (string, error) {
+	if unit == "" {
+		return transform, nil
+	}
+	s, err := o.ReadStream(streampath)
+	if err != nil {
+		return "", err
+	}
+	dt, ok := datatypes.Get(s.Datatype)
+	if !ok {
+		return "", datatypes.ErrNoUnits
+	}
+	ut, err := dt.UnitTransform(unit)
+	if err != nil || ut == "" {
+		return transform, err
+	}
+	if transform == "" {
+		return ut, nil
+	}
+	return ut + " | " + transform, nil
+}
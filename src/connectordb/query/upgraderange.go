@@ -0,0 +1,83 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package query
+
+import (
+	"connectordb/datastream"
+	"connectordb/users"
+
+	"github.com/connectordb/pipescript"
+)
+
+//UpgradeRange is a DataRange which upgrades datapoints written under older versions of a stream's schema
+//to the latest version, by running the upgrade transforms of all the versions that came after the datapoint.
+//Upgrade transforms are expected to map each datapoint to a single datapoint - if a transform returns
+//nothing, the datapoint is skipped.
+type UpgradeRange struct {
+	Data datastream.DataRange
+
+	index   int64                // The index of the next datapoint in Data
+	starts  []int64              // The starting index of each schema version
+	upgrade []*pipescript.Script // The upgrade transform of each schema version (nil if there is none)
+}
+
+//Close closes the underlying DataRange
+func (r *UpgradeRange) Close() {
+	r.Data.Close()
+}
+
+//Next returns the next datapoint, upgraded to the latest schema version
+func (r *UpgradeRange) Next() (*datastream.Datapoint, error) {
+	for {
+		dp, err := r.Data.Next()
+		if err != nil || dp == nil {
+			return dp, err
+		}
+		index := r.index
+		r.index++
+
+		// Find the schema version that the datapoint was written with
+		v := 0
+		for v+1 < len(r.starts) && r.starts[v+1] <= index {
+			v++
+		}
+
+		dpa := datastream.DatapointArray{*dp}
+		for i := v + 1; i < len(r.upgrade) && len(dpa) > 0; i++ {
+			if r.upgrade[i] != nil {
+				res, err := TransformArray(r.upgrade[i], &dpa)
+				if err != nil {
+					return nil, err
+				}
+				dpa = *res
+			}
+		}
+		if len(dpa) > 0 {
+			return &dpa[0], nil
+		}
+	}
+}
+
+//NewUpgradeRange upgrades the datapoints of the given substream's DataRange, which starts at the given index,
+//using the given schema versions, ordered by version
+func NewUpgradeRange(dr datastream.DataRange, startindex int64, substream string, versions []*users.StreamSchema) (*UpgradeRange, error) {
+	r := &UpgradeRange{
+		Data:    dr,
+		index:   startindex,
+		starts:  make([]int64, len(versions)),
+		upgrade: make([]*pipescript.Script, len(versions)),
+	}
+	for i := range versions {
+		r.starts[i] = versions[i].GetStartIndex(substream)
+		if versions[i].Upgrade != "" {
+			s, err := pipescript.Parse(versions[i].Upgrade)
+			if err != nil {
+				return nil, err
+			}
+			r.upgrade[i] = s
+		}
+	}
+	return r, nil
+}
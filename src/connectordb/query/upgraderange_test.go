@@ -0,0 +1,60 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package query
+
+import (
+	"connectordb/datastream"
+	"connectordb/users"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpgradeRange(t *testing.T) {
+	dpa := datastream.DatapointArray{
+		datastream.Datapoint{Timestamp: 1, Data: 1},
+		datastream.Datapoint{Timestamp: 2, Data: 2},
+		datastream.Datapoint{Timestamp: 3, Data: 3},
+		datastream.Datapoint{Timestamp: 4, Data: 4},
+	}
+
+	versions := []*users.StreamSchema{
+		&users.StreamSchema{Version: 0, StartIndex: 0},
+		&users.StreamSchema{Version: 1, StartIndex: 2, Upgrade: "$ >= 2"},
+		&users.StreamSchema{Version: 2, StartIndex: 3},
+	}
+
+	// The range starts at index 1, so the first datapoint is of version 0, and is upgraded
+	ur, err := NewUpgradeRange(datastream.NewDatapointArrayRange(dpa[1:], 1), 1, "", versions)
+	require.NoError(t, err)
+
+	dp, err := ur.Next()
+	require.NoError(t, err)
+	require.Equal(t, true, dp.Data)
+	dp, err = ur.Next()
+	require.NoError(t, err)
+	require.Equal(t, 3, dp.Data)
+	dp, err = ur.Next()
+	require.NoError(t, err)
+	require.Equal(t, 4, dp.Data)
+	dp, err = ur.Next()
+	require.NoError(t, err)
+	require.Nil(t, dp)
+	ur.Close()
+
+	// The downlink has its own start indices
+	ur, err = NewUpgradeRange(datastream.NewDatapointArrayRange(dpa, 0), 0, "downlink", versions)
+	require.NoError(t, err)
+	for i := 0; i < len(dpa); i++ {
+		dp, err = ur.Next()
+		require.NoError(t, err)
+		require.Equal(t, dpa[i].Data, dp.Data)
+	}
+
+	_, err = NewUpgradeRange(datastream.NewDatapointArrayRange(dpa, 0), 0, "", []*users.StreamSchema{
+		&users.StreamSchema{Upgrade: "notatransform("},
+	})
+	require.Error(t, err)
+}
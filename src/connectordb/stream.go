@@ -3,6 +3,7 @@ package connectordb
 import (
 	pconfig "config/permissions"
 	"connectordb/authoperator/permissions"
+	"connectordb/datatypes"
 	"connectordb/users"
 	"errors"
 )
@@ -37,13 +38,32 @@ func (db *Database) CreateStreamByDeviceID(s *users.StreamMaker) error {
 
 	r := permissions.GetUserRole(perm, u)
 
+	// Streams of a known datatype which were not given a schema get the datatype's default schema
+	if s.Schema == "" || s.Schema == "{}" {
+		if dt, ok := datatypes.Get(s.Datatype); ok {
+			s.Schema = dt.Schema
+		}
+	}
+
 	if err = s.Validate(); err != nil {
 		return err
 	}
+	if err = checkDatatype(&s.Stream); err != nil {
+		return err
+	}
 	s.Streamlimit = r.MaxStreams
 	return db.Userdb.CreateStream(s)
 }
 
+// checkDatatype makes sure that the schema of a stream with a registered datatype can hold the datatype's data,
+// so that the datatype's units and interpolator can be used on the stream
+func checkDatatype(s *users.Stream) error {
+	if dt, ok := datatypes.Get(s.Datatype); ok {
+		return dt.CheckSchema(s.Schema)
+	}
+	return nil
+}
+
 // ReadStreamByID reads the given stream
 func (db *Database) ReadStreamByID(streamID int64) (*users.Stream, error) {
 	return db.Userdb.ReadStreamByID(streamID)
@@ -51,7 +71,12 @@ func (db *Database) ReadStreamByID(streamID int64) (*users.Stream, error) {
 
 // ReadStreamByDeviceID reads the given stream by its device ID and stream name
 func (db *Database) ReadStreamByDeviceID(deviceID int64, streamname string) (*users.Stream, error) {
-	return db.Userdb.ReadStreamByDeviceIDAndName(deviceID, streamname)
+	s, err := db.Userdb.ReadStreamByDeviceIDAndName(deviceID, streamname)
+	if err == users.ErrStreamNotFound {
+		// The stream might have been moved, leaving its old path as an alias
+		return db.Userdb.ReadStreamByAlias(deviceID, streamname)
+	}
+	return s, err
 }
 
 // UpdateStreamByID updates the given stream
@@ -63,6 +88,8 @@ func (db *Database) UpdateStreamByID(streamID int64, updates map[string]interfac
 
 	oldname := s.Name
 	olddownlink := s.Downlink
+	oldschema := s.Schema
+	olddatatype := s.Datatype
 
 	err = WriteObjectFromMap(s, updates)
 	if err != nil {
@@ -70,11 +97,23 @@ func (db *Database) UpdateStreamByID(streamID int64, updates map[string]interfac
 	}
 
 	if s.Name != oldname {
-		return errors.New("ConnectorDB does not support modification of stream names")
+		return errors.New("Streams are renamed with a move, not an update")
+	}
+	if s.Schema != oldschema || s.Datatype != olddatatype {
+		if err = checkDatatype(s); err != nil {
+			return err
+		}
 	}
 
-	// The stream schema is validated in users
-	err = db.Userdb.UpdateStream(s)
+	// The stream schema is validated in users. Changing the schema records a new schema version
+	if s.Schema != oldschema {
+		if err = s.ValidityCheck(); err != nil {
+			return err
+		}
+		err = db.updateStreamSchema(s, oldschema, "")
+	} else {
+		err = db.Userdb.UpdateStream(s)
+	}
 
 	// If the stream is no longer downlink, delete the downlink substream
 	if err == nil && olddownlink && !s.Downlink {
@@ -93,6 +132,8 @@ func (db *Database) DeleteStreamByID(streamID int64, substream string) error {
 	if substream != "" {
 		//We just delete the substream
 		err = db.DataStream.DeleteSubstream(strm.DeviceID, strm.StreamID, substream)
+	} else if db.trash.period > 0 {
+		err = db.Userdb.TrashStream(streamID, now())
 	} else {
 		err = db.Userdb.DeleteStream(streamID)
 		if err == nil {
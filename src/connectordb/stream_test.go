@@ -1,6 +1,9 @@
 package connectordb
 
 import (
+	"connectordb/datastream"
+	"connectordb/datatypes"
+	"connectordb/query"
 	"connectordb/users"
 	"testing"
 
@@ -104,3 +107,34 @@ func TestReadUserStreams(t *testing.T) {
 	require.Equal(t, 0, len(s))
 
 }
+
+func TestStreamDatatype(t *testing.T) {
+	Tdb.Clear()
+	db := Tdb
+
+	require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: "myuser", Email: "email@email", Password: "test", Role: "user", Public: true}}))
+	require.NoError(t, db.CreateDevice("myuser/mydevice", &users.DeviceMaker{}))
+
+	// The schema of a stream with a registered datatype must fit the datatype
+	require.Equal(t, datatypes.ErrIncompatibleSchema, db.CreateStream("myuser/mydevice/temp", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"string"}`, Datatype: "physical.temperature"}}))
+	require.NoError(t, db.CreateStream("myuser/mydevice/temp", &users.StreamMaker{Stream: users.Stream{Datatype: "physical.temperature"}}))
+	require.Equal(t, datatypes.ErrIncompatibleSchema, db.UpdateStream("myuser/mydevice/temp", map[string]interface{}{"schema": `{"type":"boolean"}`}))
+	require.NoError(t, db.CreateStream("myuser/mydevice/other", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"string"}`}}))
+	require.Equal(t, datatypes.ErrIncompatibleSchema, db.UpdateStream("myuser/mydevice/other", map[string]interface{}{"datatype": "physical.temperature"}))
+
+	// Units are converted by queries
+	require.NoError(t, db.InsertStream("myuser/mydevice/temp", datastream.DatapointArray{
+		datastream.Datapoint{Timestamp: 1, Data: 100.0},
+		datastream.Datapoint{Timestamp: 2, Data: 0.0},
+	}, false))
+	dr, err := (&query.StreamQuery{Stream: "myuser/mydevice/temp", Unit: "F"}).Run(db)
+	require.NoError(t, err)
+	defer dr.Close()
+	for _, v := range []float64{212, 32} {
+		dp, err := dr.Next()
+		require.NoError(t, err)
+		require.Equal(t, v, dp.Data)
+	}
+	_, err = (&query.StreamQuery{Stream: "myuser/mydevice/temp", Unit: "lb"}).Run(db)
+	require.Equal(t, datatypes.ErrUnknownUnit, err)
+}
@@ -0,0 +1,153 @@
+package connectordb
+
+import (
+	"connectordb/datastream"
+	"connectordb/query"
+	"connectordb/schema"
+	"connectordb/users"
+
+	"github.com/connectordb/pipescript"
+)
+
+// ReadStreamSchemasByID returns the schema versions of the stream. Streams whose schema was never changed
+// have no recorded versions
+func (db *Database) ReadStreamSchemasByID(streamID int64) ([]*users.StreamSchema, error) {
+	return db.Userdb.ReadStreamSchemas(streamID)
+}
+
+// UpdateStreamSchemaByID sets a new schema for the stream, recording it as a new schema version.
+// The upgrade is an optional pipescript transform which converts data written under the previous version
+// to the new schema.
+func (db *Database) UpdateStreamSchemaByID(streamID int64, schema string, upgrade string) error {
+	s, err := db.ReadStreamByID(streamID)
+	if err != nil {
+		return err
+	}
+	oldschema := s.Schema
+	s.Schema = schema
+
+	// Make sure that both the schema and the upgrade transform are valid before changing anything
+	if err = s.ValidityCheck(); err != nil {
+		return err
+	}
+	if err = checkDatatype(s); err != nil {
+		return err
+	}
+	if upgrade != "" {
+		if _, err = pipescript.Parse(upgrade); err != nil {
+			return err
+		}
+	}
+
+	return db.updateStreamSchema(s, oldschema, upgrade)
+}
+
+// updateStreamSchema writes the stream, which has its schema already changed, and records the new schema version.
+// The stream is locked while this happens, so that no data can be inserted between reading the index
+// at which the new version starts and writing the version.
+func (db *Database) updateStreamSchema(s *users.Stream, oldschema string, upgrade string) error {
+	substreams := []string{""}
+	if s.Downlink {
+		substreams = append(substreams, "downlink")
+	}
+	return db.DataStream.LockStream(s.DeviceID, s.StreamID, substreams, func(lengths []int64) error {
+		var versions []*users.StreamSchema
+
+		// The schema a stream was created with is only recorded once it is first changed
+		if s.SchemaVersion == 0 {
+			versions = append(versions, &users.StreamSchema{
+				StreamID: s.StreamID,
+				Schema:   oldschema,
+			})
+		}
+
+		// The new version starts at the current end of the stream
+		ss := &users.StreamSchema{
+			StreamID:   s.StreamID,
+			Version:    s.SchemaVersion + 1,
+			Schema:     s.Schema,
+			Upgrade:    upgrade,
+			StartIndex: lengths[0],
+		}
+		if s.Downlink {
+			ss.DownlinkIndex = lengths[1]
+		}
+
+		s.SchemaVersion = ss.Version
+		return db.Userdb.UpdateStreamSchema(s, append(versions, ss)...)
+	})
+}
+
+// upgradeRange runs the schema upgrade transforms on the given range of the stream's substream, which starts at startindex
+func (db *Database) upgradeRange(s *users.Stream, substream string, startindex int64, dr datastream.DataRange, extra ...*users.StreamSchema) (datastream.DataRange, error) {
+	versions, err := db.ReadStreamSchemasByID(s.StreamID)
+	if err != nil {
+		dr.Close()
+		return nil, err
+	}
+	if len(versions) == 0 {
+		if len(extra) == 0 {
+			return dr, nil
+		}
+		// The stream was never changed, so all existing data is of version 0
+		versions = []*users.StreamSchema{&users.StreamSchema{StreamID: s.StreamID}}
+	}
+	versions = append(versions, extra...)
+	ur, err := query.NewUpgradeRange(dr, startindex, substream, versions)
+	if err != nil {
+		dr.Close()
+		return nil, err
+	}
+	return ur, nil
+}
+
+// CheckStreamSchemaByID performs a dry run of a schema change: it upgrades all of the substream's existing data
+// to the latest schema version, runs the given upgrade transform on it, and counts the datapoints
+// that do not conform to the proposed schema.
+func (db *Database) CheckStreamSchemaByID(streamID int64, substream string, proposed string, upgrade string) (*users.SchemaCheck, error) {
+	s, err := db.ReadStreamByID(streamID)
+	if err != nil {
+		return nil, err
+	}
+	sch, err := schema.NewSchema(proposed)
+	if err != nil {
+		return nil, users.ErrInvalidSchema
+	}
+	length, err := db.DataStream.StreamLength(s.DeviceID, s.StreamID, substream)
+	if err != nil {
+		return nil, err
+	}
+
+	dr, err := db.DataStream.IRange(s.DeviceID, s.StreamID, substream, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// The proposed change is treated as a new version starting at the end of the stream,
+	// so that all existing data is run through its upgrade transform
+	udr, err := db.upgradeRange(s, substream, 0, dr, &users.StreamSchema{
+		Version:       s.SchemaVersion + 1,
+		Upgrade:       upgrade,
+		StartIndex:    length,
+		DownlinkIndex: length,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer udr.Close()
+
+	res := &users.SchemaCheck{}
+	for {
+		dp, err := udr.Next()
+		if err != nil {
+			return nil, err
+		}
+		if dp == nil {
+			return res, nil
+		}
+		res.Checked++
+		if !sch.IsValid(dp.Data) {
+			res.Violations++
+		}
+	}
+}
@@ -47,3 +47,13 @@ func (db *Database) SubscribeStreamByID(streamID int64, substream string, chn ch
 	}
 	return db.Messenger.Subscribe(routing, chn)
 }
+
+//SubscribePattern subscribes to all streams matching the pattern, where elements of the stream path can be *
+func (db *Database) SubscribePattern(pattern string, filter func(*messenger.Message) bool, chn chan messenger.Message) (*nats.Subscription, error) {
+	return db.Messenger.SubscribePattern(pattern, filter, chn)
+}
+
+//SubscribeMeta subscribes to the metadata change events of all users
+func (db *Database) SubscribeMeta(filter func(*messenger.MetaEvent) bool, chn chan messenger.MetaEvent) (*nats.Subscription, error) {
+	return db.Messenger.SubscribeMeta(filter, chn)
+}
@@ -30,10 +30,10 @@ func TestSubscribe(t *testing.T) {
 	//We bind a timeout to the channel, since we want the test to fail if no messages come through
 	go func() {
 		time.Sleep(2 * time.Second)
-		recvchan <- messenger.Message{"TIMEOUT", "", []datastream.Datapoint{}}
-		recvchan2 <- messenger.Message{"TIMEOUT", "", []datastream.Datapoint{}}
-		recvchan3 <- messenger.Message{"TIMEOUT", "", []datastream.Datapoint{}}
-		recvchan4 <- messenger.Message{"TIMEOUT", "", []datastream.Datapoint{}}
+		recvchan <- messenger.Message{Stream: "TIMEOUT", Data: []datastream.Datapoint{}}
+		recvchan2 <- messenger.Message{Stream: "TIMEOUT", Data: []datastream.Datapoint{}}
+		recvchan3 <- messenger.Message{Stream: "TIMEOUT", Data: []datastream.Datapoint{}}
+		recvchan4 <- messenger.Message{Stream: "TIMEOUT", Data: []datastream.Datapoint{}}
 	}()
 
 	_, err := db.Subscribe("tst", recvchan)
@@ -75,9 +75,9 @@ func TestSubscribe(t *testing.T) {
 	require.Equal(t, m.Data[0].Data, "2")
 
 	time.Sleep(100 * time.Millisecond)
-	recvchan <- messenger.Message{"GOOD", "", []datastream.Datapoint{}}
-	recvchan2 <- messenger.Message{"GOOD", "", []datastream.Datapoint{}}
-	recvchan3 <- messenger.Message{"GOOD", "", []datastream.Datapoint{}}
+	recvchan <- messenger.Message{Stream: "GOOD", Data: []datastream.Datapoint{}}
+	recvchan2 <- messenger.Message{Stream: "GOOD", Data: []datastream.Datapoint{}}
+	recvchan3 <- messenger.Message{Stream: "GOOD", Data: []datastream.Datapoint{}}
 
 	m = <-recvchan
 	require.Equal(t, m.Stream, "GOOD", "A downlink should not be triggered")
@@ -87,3 +87,32 @@ func TestSubscribe(t *testing.T) {
 	require.Equal(t, m.Stream, "GOOD", "A downlink should not be triggered")
 
 }
+
+func TestSubscribeIndex(t *testing.T) {
+	Tdb.Clear()
+	db := Tdb
+
+	require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: "tst", Email: "email@email", Password: "mypass", Role: "user", Public: true}}))
+	require.NoError(t, db.CreateDevice("tst/tst", &users.DeviceMaker{}))
+	require.NoError(t, db.CreateStream("tst/tst/tst", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"string"}`, Downlink: true}}))
+
+	recvchan := make(chan messenger.Message, 3)
+	_, err := db.Subscribe("tst/tst/tst", recvchan)
+	require.NoError(t, err)
+	_, err = db.Subscribe("tst/tst/tst/downlink", recvchan)
+	require.NoError(t, err)
+	db.Messenger.Flush()
+
+	// Each message holds the index of its first datapoint in the substream
+	require.NoError(t, db.InsertStream("tst/tst/tst", []datastream.Datapoint{{Timestamp: 1, Data: "1"}}, false))
+	require.NoError(t, db.InsertStream("tst/tst/tst", []datastream.Datapoint{{Timestamp: 2, Data: "2"}, {Timestamp: 3, Data: "3"}}, false))
+	require.NoError(t, db.InsertStream("tst/tst/tst/downlink", []datastream.Datapoint{{Timestamp: 4, Data: "4"}}, false))
+	for _, i := range []int64{0, 1, 0} {
+		select {
+		case m := <-recvchan:
+			require.Equal(t, i, m.Index)
+		case <-time.After(2 * time.Second):
+			t.Fatal("Subscription timed out")
+		}
+	}
+}
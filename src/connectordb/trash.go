@@ -0,0 +1,276 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package connectordb
+
+import (
+	"connectordb/users"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+var (
+	//ErrNotInTrash is returned when restoring a path which is not in the trash
+	ErrNotInTrash = errors.New("There is nothing in the trash with the given path")
+	//ErrParentInTrash is returned when restoring a device or stream whose owner is still in the trash
+	ErrParentInTrash = errors.New("The owner of the given path is in the trash, and must be restored first")
+	//ErrRestoreNameTaken is returned when restoring a path whose name was given to something else after it was deleted
+	ErrRestoreNameTaken = errors.New("Something else now has the name of the given path, so it can't be restored")
+)
+
+// trash holds the settings of soft deletion. Deleted users, devices and streams are moved to the trash, and are
+// only deleted for good by the purger once they have been in the trash for longer than the trash period.
+type trash struct {
+	period float64 // The number of seconds for which deleted things can be restored. 0 deletes immediately.
+
+	done chan struct{}
+}
+
+type trashSorter []*users.TrashItem
+
+func (s trashSorter) Len() int           { return len(s) }
+func (s trashSorter) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s trashSorter) Less(i, j int) bool { return s[i].Path < s[j].Path }
+
+// readUserWithTrash reads the user with the given name, or the user last deleted with the name if there is none
+func (db *Database) readUserWithTrash(username string) (*users.User, error) {
+	u, err := db.Userdb.ReadUserByName(username)
+	if err != users.ErrUserNotFound {
+		return u, err
+	}
+	return db.Userdb.ReadTrashedUserByName(username)
+}
+
+// ReadTrash lists the user's devices and streams which are in the trash, along with the user itself if it was deleted.
+// The devices and streams which were deleted along with the user or device that owns them are not listed separately,
+// since they are restored with their owner.
+func (db *Database) ReadTrash(username string) ([]*users.TrashItem, error) {
+	u, err := db.readUserWithTrash(username)
+	if err != nil {
+		return nil, err
+	}
+	t, err := db.Userdb.ReadTrash(u.UserID)
+	if err != nil {
+		return nil, err
+	}
+	devices, err := db.Userdb.ReadDevicesForUserID(u.UserID)
+	if err != nil {
+		return nil, err
+	}
+	devmap := make(map[int64]*users.Device)
+	for _, d := range append(devices, t.Devices...) {
+		devmap[d.DeviceID] = d
+	}
+
+	items := make([]*users.TrashItem, 0, len(t.Users)+len(t.Devices)+len(t.Streams))
+	if u.Deleted != 0 {
+		items = append(items, &users.TrashItem{
+			Path:    u.Name,
+			Type:    "user",
+			Deleted: u.Deleted,
+			Purge:   u.Deleted + db.trash.period,
+			UserID:  u.UserID,
+			Public:  u.Public,
+		})
+	}
+	for _, d := range t.Devices {
+		if d.Deleted == u.Deleted {
+			continue
+		}
+		items = append(items, &users.TrashItem{
+			Path:     u.Name + "/" + d.Name,
+			Type:     "device",
+			Deleted:  d.Deleted,
+			Purge:    d.Deleted + db.trash.period,
+			UserID:   u.UserID,
+			DeviceID: d.DeviceID,
+			Public:   d.Public,
+		})
+	}
+	for _, s := range t.Streams {
+		d, ok := devmap[s.DeviceID]
+		if !ok || s.Deleted == d.Deleted {
+			continue
+		}
+		items = append(items, &users.TrashItem{
+			Path:     u.Name + "/" + d.Name + "/" + s.Name,
+			Type:     "stream",
+			Deleted:  s.Deleted,
+			Purge:    s.Deleted + db.trash.period,
+			UserID:   u.UserID,
+			DeviceID: d.DeviceID,
+			StreamID: s.StreamID,
+			Public:   d.Public,
+		})
+	}
+	// Sorting by path puts owners before the things they own
+	sort.Sort(trashSorter(items))
+	return items, nil
+}
+
+// RestoreTrash takes the user, device or stream with the given path out of the trash, along with everything that
+// was deleted with it. If several things with the path are in the trash, the one deleted last is restored.
+func (db *Database) RestoreTrash(path string) error {
+	parts := strings.Split(path, "/")
+	items, err := db.ReadTrash(parts[0])
+	if err != nil {
+		return err
+	}
+	var item *users.TrashItem
+	for _, it := range items {
+		if it.Path == path && (item == nil || it.Deleted > item.Deleted) {
+			item = it
+		}
+	}
+
+	// Names are only unique outside of the trash, so the name might have been taken since the deletion
+	switch {
+	case item == nil:
+		if len(parts) == 1 {
+			if _, err = db.Userdb.ReadTrashedUserByName(path); err == nil {
+				return ErrRestoreNameTaken
+			}
+		}
+		return ErrNotInTrash
+	case item.Type == "user":
+		return db.Userdb.RestoreUser(item.UserID)
+	case item.Type == "device":
+		if _, err = db.Userdb.ReadUserById(item.UserID); err != nil {
+			return ErrParentInTrash
+		}
+		if _, err = db.Userdb.ReadDeviceForUserByName(item.UserID, parts[1]); err == nil {
+			return ErrRestoreNameTaken
+		}
+		return db.Userdb.RestoreDevice(item.DeviceID)
+	default:
+		if _, err = db.Userdb.ReadDeviceByID(item.DeviceID); err != nil {
+			return ErrParentInTrash
+		}
+		if _, err = db.Userdb.ReadStreamByDeviceIDAndName(item.DeviceID, parts[2]); err == nil {
+			return ErrRestoreNameTaken
+		}
+		return db.Userdb.RestoreStream(item.StreamID)
+	}
+}
+
+// trashPaths returns the paths of the users, devices and streams in the given part of the trash, by their IDs.
+// Devices and streams deleted along with their owner are in the trash along with it, so their owners are either
+// in t, or not deleted.
+func (db *Database) trashPaths(t *users.Trash) (userpaths, devicepaths, streampaths map[int64]string) {
+	userpaths = make(map[int64]string)
+	devicepaths = make(map[int64]string)
+	streampaths = make(map[int64]string)
+	for _, u := range t.Users {
+		userpaths[u.UserID] = u.Name
+	}
+	userPath := func(userID int64) string {
+		if p, ok := userpaths[userID]; ok {
+			return p
+		}
+		if u, err := db.Userdb.ReadUserById(userID); err == nil {
+			userpaths[userID] = u.Name
+			return u.Name
+		}
+		return ""
+	}
+	for _, d := range t.Devices {
+		if p := userPath(d.UserID); p != "" {
+			devicepaths[d.DeviceID] = p + "/" + d.Name
+		}
+	}
+	for _, s := range t.Streams {
+		p, ok := devicepaths[s.DeviceID]
+		if !ok {
+			if d, err := db.Userdb.ReadDeviceByID(s.DeviceID); err == nil {
+				if up := userPath(d.UserID); up != "" {
+					p = up + "/" + d.Name
+				}
+			}
+		}
+		if p != "" {
+			streampaths[s.StreamID] = p + "/" + s.Name
+		}
+	}
+	return userpaths, devicepaths, streampaths
+}
+
+// PurgeTrash deletes everything which has been in the trash for longer than the trash period. Each purged user,
+// device and stream is published as a metadata event.
+func (db *Database) PurgeTrash() error {
+	t, err := db.Userdb.ReadTrashBefore(now() - db.trash.period)
+	if err != nil {
+		return err
+	}
+	userpaths, devicepaths, streampaths := db.trashPaths(t)
+	purged := func(path string) {
+		if path != "" {
+			publishMeta(db.Messenger, "", "Purge", path, "", nil)
+		}
+	}
+
+	// Streams go first, so that the data of streams deleted along with their device is removed too
+	for _, s := range t.Streams {
+		err = db.DataStream.DeleteStream(s.DeviceID, s.StreamID)
+		if err == nil {
+			err = db.Userdb.DeleteStream(s.StreamID)
+		}
+		if err != nil && err != users.ErrNothingToDelete {
+			return err
+		}
+		purged(streampaths[s.StreamID])
+	}
+	for _, d := range t.Devices {
+		err = db.Userdb.DeleteDevice(d.DeviceID)
+		if err == nil {
+			err = db.DataStream.DeleteDevice(d.DeviceID)
+		}
+		if err != nil && err != users.ErrNothingToDelete {
+			return err
+		}
+		purged(devicepaths[d.DeviceID])
+	}
+	for _, u := range t.Users {
+		if err = db.Userdb.DeleteUser(u.UserID); err != nil && err != users.ErrNothingToDelete {
+			return err
+		}
+		purged(userpaths[u.UserID])
+	}
+
+	if len(t.Users)+len(t.Devices)+len(t.Streams) > 0 {
+		log.Infof("Purged %d users, %d devices and %d streams from the trash", len(t.Users), len(t.Devices), len(t.Streams))
+	}
+	return nil
+}
+
+// runTrash periodically purges the trash and the old downlink commands, until the database is closed
+func (db *Database) runTrash(done chan struct{}) {
+	// Purge often enough that things are deleted at most a quarter trash period late. Without a trash period,
+	// only things left over from when there was one need purging, so an hourly check is plenty.
+	interval := time.Duration(db.trash.period*float64(time.Second)) / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+	if interval > time.Hour || db.trash.period <= 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := db.PurgeTrash(); err != nil {
+				log.Warn("Failed to purge trash: ", err)
+			}
+			if err := db.PurgeCommands(); err != nil {
+				log.Warn("Failed to purge commands: ", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package connectordb
+
+import (
+	"connectordb/datastream"
+	"connectordb/users"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrash(t *testing.T) {
+	Tdb.Clear()
+	db := Tdb
+
+	defer func(period float64) { db.trash.period = period }(db.trash.period)
+	db.trash.period = 60
+
+	require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: "myuser", Email: "email@email", Password: "test", Role: "user", Public: true}}))
+	require.NoError(t, db.CreateDevice("myuser/mydevice", &users.DeviceMaker{}))
+	require.NoError(t, db.CreateStream("myuser/mydevice/mystream", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"number"}`}}))
+	require.NoError(t, db.CreateStream("myuser/mydevice/other", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"number"}`}}))
+	require.NoError(t, db.InsertStream("myuser/mydevice/mystream", datastream.DatapointArray{datastream.Datapoint{Timestamp: 1, Data: 1.0}}, false))
+
+	// A deleted stream is hidden, but keeps its data
+	require.NoError(t, db.DeleteStream("myuser/mydevice/mystream"))
+	_, err := db.ReadStream("myuser/mydevice/mystream")
+	require.Error(t, err)
+	require.Error(t, db.DeleteStream("myuser/mydevice/mystream"))
+
+	trash, err := db.ReadTrash("myuser")
+	require.NoError(t, err)
+	require.Len(t, trash, 1)
+	require.Equal(t, "myuser/mydevice/mystream", trash[0].Path)
+	require.Equal(t, "stream", trash[0].Type)
+	require.InDelta(t, trash[0].Deleted+60, trash[0].Purge, 0.001)
+
+	require.Equal(t, ErrNotInTrash, db.RestoreTrash("myuser/mydevice/other"))
+	require.NoError(t, db.RestoreTrash("myuser/mydevice/mystream"))
+	l, err := db.LengthStream("myuser/mydevice/mystream")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), l)
+
+	// Deleting the device moves its streams with it, and the stream can't be restored without the device
+	require.NoError(t, db.DeleteStream("myuser/mydevice/other"))
+	require.NoError(t, db.DeleteDevice("myuser/mydevice"))
+	_, err = db.ReadStream("myuser/mydevice/mystream")
+	require.Error(t, err)
+
+	trash, err = db.ReadTrash("myuser")
+	require.NoError(t, err)
+	require.Len(t, trash, 2)
+	require.Equal(t, "myuser/mydevice", trash[0].Path)
+	require.Equal(t, "myuser/mydevice/other", trash[1].Path)
+	require.Equal(t, ErrParentInTrash, db.RestoreTrash("myuser/mydevice/other"))
+
+	// Restoring the device only restores the streams that were deleted with it
+	require.NoError(t, db.RestoreTrash("myuser/mydevice"))
+	_, err = db.ReadStream("myuser/mydevice/mystream")
+	require.NoError(t, err)
+	_, err = db.ReadStream("myuser/mydevice/other")
+	require.Error(t, err)
+
+	// A deleted user can't log in until restored
+	require.NoError(t, db.DeleteUser("myuser"))
+	_, err = db.ReadUser("myuser")
+	require.Error(t, err)
+	_, err = db.UserLogin("myuser", "test")
+	require.Error(t, err)
+	trash, err = db.ReadTrash("myuser")
+	require.NoError(t, err)
+	require.Len(t, trash, 2)
+	require.Equal(t, "myuser", trash[0].Path)
+	require.NoError(t, db.RestoreTrash("myuser"))
+	_, err = db.UserLogin("myuser", "test")
+	require.NoError(t, err)
+
+	// The names of deleted things can be used again, after which the deleted ones can't be restored
+	require.NoError(t, db.DeleteStream("myuser/mydevice/mystream"))
+	require.NoError(t, db.CreateStream("myuser/mydevice/mystream", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"string"}`}}))
+	require.Equal(t, ErrRestoreNameTaken, db.RestoreTrash("myuser/mydevice/mystream"))
+	s, err := db.ReadStream("myuser/mydevice/mystream")
+	require.NoError(t, err)
+	require.Equal(t, `{"type":"string"}`, s.Schema)
+
+	// Nothing is purged until the trash period passed
+	require.NoError(t, db.PurgeTrash())
+	trash, err = db.ReadTrash("myuser")
+	require.NoError(t, err)
+	require.Len(t, trash, 2)
+
+	db.trash.period = 0
+	require.NoError(t, db.PurgeTrash())
+	trash, err = db.ReadTrash("myuser")
+	require.NoError(t, err)
+	require.Len(t, trash, 0)
+	require.Equal(t, ErrNotInTrash, db.RestoreTrash("myuser/mydevice/other"))
+
+	// A new user can get the name and email of a deleted user
+	db.trash.period = 60
+	require.NoError(t, db.DeleteUser("myuser"))
+	require.NoError(t, db.CreateUser(&users.UserMaker{User: users.User{Name: "myuser", Email: "email@email", Password: "test2", Role: "user"}}))
+	require.Equal(t, ErrRestoreNameTaken, db.RestoreTrash("myuser"))
+	_, err = db.UserLogin("myuser", "test2")
+	require.NoError(t, err)
+}
@@ -106,8 +106,11 @@ func (db *Database) UpdateUserByID(userID int64, update map[string]interface{})
 }
 
 // DeleteUserByID removes the user with the given UserID. It propagates deletion to add devices
-// and streams that the user owns
+// and streams that the user owns. With a trash period, they are all moved to the trash instead.
 func (db *Database) DeleteUserByID(userID int64) error {
+	if db.trash.period > 0 {
+		return db.Userdb.TrashUser(userID, now())
+	}
 
 	// First take all the devices that the user owns, and delete them
 	devices, err := db.ReadAllDevicesByUserID(userID)
@@ -64,6 +64,11 @@ func (userdb *AccountingMiddleware) ReadDeviceByAPIKey(Key string) (*Device, err
 	return userdb.UserDatabase.ReadDeviceByAPIKey(Key)
 }
 
+func (userdb *AccountingMiddleware) ReadDeviceByWebhookToken(token string) (*Device, error) {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.ReadDeviceByWebhookToken(token)
+}
+
 func (userdb *AccountingMiddleware) ReadDeviceByID(DeviceID int64) (*Device, error) {
 	atomic.AddUint64(&userdb.databaseCalls, 1)
 	return userdb.UserDatabase.ReadDeviceByID(DeviceID)
@@ -114,6 +119,11 @@ func (userdb *AccountingMiddleware) UpdateDevice(device *Device) error {
 	return userdb.UserDatabase.UpdateDevice(device)
 }
 
+func (userdb *AccountingMiddleware) UpdateDeviceLastSeen(deviceID int64, lastseen float64) error {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.UpdateDeviceLastSeen(deviceID, lastseen)
+}
+
 func (userdb *AccountingMiddleware) UpdateStream(stream *Stream) error {
 	atomic.AddUint64(&userdb.databaseCalls, 1)
 	return userdb.UserDatabase.UpdateStream(stream)
@@ -123,3 +133,108 @@ func (userdb *AccountingMiddleware) UpdateUser(user *User) error {
 	atomic.AddUint64(&userdb.databaseCalls, 1)
 	return userdb.UserDatabase.UpdateUser(user)
 }
+
+func (userdb *AccountingMiddleware) UpdateStreamSchema(stream *Stream, versions ...*StreamSchema) error {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.UpdateStreamSchema(stream, versions...)
+}
+
+func (userdb *AccountingMiddleware) ReadStreamSchemas(StreamID int64) ([]*StreamSchema, error) {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.ReadStreamSchemas(StreamID)
+}
+
+func (userdb *AccountingMiddleware) CreateCommand(c *Command) error {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.CreateCommand(c)
+}
+
+func (userdb *AccountingMiddleware) ReadCommandByID(CommandID int64) (*Command, error) {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.ReadCommandByID(CommandID)
+}
+
+func (userdb *AccountingMiddleware) ReadCommands(StreamID int64, status string) ([]*Command, error) {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.ReadCommands(StreamID, status)
+}
+
+func (userdb *AccountingMiddleware) UpdateCommand(c *Command) error {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.UpdateCommand(c)
+}
+
+func (userdb *AccountingMiddleware) DeleteCommandsBefore(before float64) (int64, error) {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.DeleteCommandsBefore(before)
+}
+
+func (userdb *AccountingMiddleware) TrashUser(UserID int64, deleted float64) error {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.TrashUser(UserID, deleted)
+}
+
+func (userdb *AccountingMiddleware) TrashDevice(ID int64, deleted float64) error {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.TrashDevice(ID, deleted)
+}
+
+func (userdb *AccountingMiddleware) TrashStream(ID int64, deleted float64) error {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.TrashStream(ID, deleted)
+}
+
+func (userdb *AccountingMiddleware) RestoreUser(UserID int64) error {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.RestoreUser(UserID)
+}
+
+func (userdb *AccountingMiddleware) RestoreDevice(ID int64) error {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.RestoreDevice(ID)
+}
+
+func (userdb *AccountingMiddleware) RestoreStream(ID int64) error {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.RestoreStream(ID)
+}
+
+func (userdb *AccountingMiddleware) ReadTrash(UserID int64) (*Trash, error) {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.ReadTrash(UserID)
+}
+
+func (userdb *AccountingMiddleware) ReadTrashBefore(before float64) (*Trash, error) {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.ReadTrashBefore(before)
+}
+
+func (userdb *AccountingMiddleware) ReadTrashedUserByName(Name string) (*User, error) {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.ReadTrashedUserByName(Name)
+}
+
+func (userdb *AccountingMiddleware) MoveDevice(ID int64, name string, alias bool) error {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.MoveDevice(ID, name, alias)
+}
+
+func (userdb *AccountingMiddleware) TransferDevice(ID int64, UserID int64) error {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.TransferDevice(ID, UserID)
+}
+
+func (userdb *AccountingMiddleware) MoveStream(ID int64, DeviceID int64, name string, alias bool) error {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.MoveStream(ID, DeviceID, name, alias)
+}
+
+func (userdb *AccountingMiddleware) ReadDeviceByAlias(userid int64, name string) (*Device, error) {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.ReadDeviceByAlias(userid, name)
+}
+
+func (userdb *AccountingMiddleware) ReadStreamByAlias(DeviceID int64, name string) (*Stream, error) {
+	atomic.AddUint64(&userdb.databaseCalls, 1)
+	return userdb.UserDatabase.ReadStreamByAlias(DeviceID, name)
+}
@@ -0,0 +1,129 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+
+This file contains the renaming of devices and moving of streams. Devices and streams are
+referenced by ID everywhere except in paths, so changing the name or device only changes a row.
+The old name can be left as an alias, which keeps resolving to the device or stream as long
+as no device or stream has that name, and the name is not reused by another move.
+Transferring a device to another user also only changes its row, since its streams follow the device.
+**/
+package users
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrDeviceExists = errors.New("Device with this name already exists")
+	ErrStreamExists = errors.New("Stream with this name already exists")
+)
+
+// isUniqueError returns whether the error is a violation of a unique constraint, both in postgres and sqlite
+func isUniqueError(err error) bool {
+	return err != nil && (strings.HasPrefix(err.Error(), "pq: duplicate key value violates unique constraint ") ||
+		strings.HasPrefix(err.Error(), "UNIQUE constraint failed"))
+}
+
+// aliasTime returns the current time as the creation time of an alias
+func aliasTime() float64 {
+	return float64(time.Now().UnixNano()) / 1e9
+}
+
+// MoveDevice renames the device. If alias is true, the old name is kept as an alias of the device.
+func (userdb *SqlUserDatabase) MoveDevice(ID int64, name string, alias bool) error {
+	if !IsValidName(name) {
+		return InvalidNameError
+	}
+	dev, err := userdb.ReadDeviceByID(ID)
+	if err != nil {
+		return err
+	}
+
+	statements := []statement{
+		{`DELETE FROM devicealiases WHERE userid = ? AND (name = ? OR name = ?);`, []interface{}{dev.UserID, dev.Name, name}},
+	}
+	if alias && dev.Name != name {
+		statements = append(statements, statement{`INSERT INTO devicealiases (userid, name, deviceid, created) VALUES (?,?,?,?);`,
+			[]interface{}{dev.UserID, dev.Name, ID, aliasTime()}})
+	}
+	statements = append(statements, statement{`UPDATE devices SET name = ? WHERE deviceid = ? AND deleted = 0;`, []interface{}{name, ID}})
+
+	err = userdb.execAll(ErrDeviceNotFound, statements...)
+	if isUniqueError(err) {
+		return ErrDeviceExists
+	}
+	return err
+}
+
+// TransferDevice gives the device to the user with the given ID. The device's aliases are names of the
+// previous owner, so they are removed.
+func (userdb *SqlUserDatabase) TransferDevice(ID int64, UserID int64) error {
+	err := userdb.execAll(ErrDeviceNotFound,
+		statement{`DELETE FROM devicealiases WHERE deviceid = ? OR (userid = ? AND name IN (SELECT name FROM devices WHERE deviceid = ?));`,
+			[]interface{}{ID, UserID, ID}},
+		statement{`UPDATE devices SET userid = ? WHERE deviceid = ? AND deleted = 0;`, []interface{}{UserID, ID}},
+	)
+	if isUniqueError(err) {
+		return ErrDeviceExists
+	}
+	return err
+}
+
+// MoveStream moves the stream to the given device and name. If alias is true, the old device and name are kept
+// as an alias of the stream. The stream keeps its ID, so its schema history (which is keyed by stream ID) moves with it.
+func (userdb *SqlUserDatabase) MoveStream(ID int64, DeviceID int64, name string, alias bool) error {
+	if !IsValidName(name) {
+		return InvalidNameError
+	}
+	s, err := userdb.ReadStreamByID(ID)
+	if err != nil {
+		return err
+	}
+
+	statements := []statement{
+		{`DELETE FROM streamaliases WHERE (deviceid = ? AND name = ?) OR (deviceid = ? AND name = ?);`,
+			[]interface{}{s.DeviceID, s.Name, DeviceID, name}},
+	}
+	if alias && (s.DeviceID != DeviceID || s.Name != name) {
+		statements = append(statements, statement{`INSERT INTO streamaliases (deviceid, name, streamid, created) VALUES (?,?,?,?);`,
+			[]interface{}{s.DeviceID, s.Name, ID, aliasTime()}})
+	}
+	statements = append(statements, statement{`UPDATE streams SET deviceid = ?, name = ? WHERE streamid = ? AND deleted = 0;`,
+		[]interface{}{DeviceID, name, ID}})
+
+	err = userdb.execAll(ErrStreamNotFound, statements...)
+	if isUniqueError(err) {
+		return ErrStreamExists
+	}
+	return err
+}
+
+// ReadDeviceByAlias reads the device which had the given name before it was renamed
+func (userdb *SqlUserDatabase) ReadDeviceByAlias(userid int64, name string) (*Device, error) {
+	var dev Device
+
+	err := userdb.Get(&dev, `SELECT d.* FROM devices d INNER JOIN devicealiases a ON a.deviceid = d.deviceid
+		WHERE a.userid = ? AND a.name = ? AND d.deleted = 0 LIMIT 1;`, userid, name)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrDeviceNotFound
+	}
+	return &dev, err
+}
+
+// ReadStreamByAlias reads the stream which had the given device and name before it was moved
+func (userdb *SqlUserDatabase) ReadStreamByAlias(DeviceID int64, name string) (*Stream, error) {
+	var stream Stream
+
+	err := userdb.Get(&stream, `SELECT s.* FROM streams s INNER JOIN streamaliases a ON a.streamid = s.streamid
+		WHERE a.deviceid = ? AND a.name = ? AND s.deleted = 0 LIMIT 1;`, DeviceID, name)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrStreamNotFound
+	}
+	return &stream, err
+}
@@ -0,0 +1,119 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package users
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoveDevice(t *testing.T) {
+	for _, testdb := range testdatabases {
+		u, d, s, err := CreateUDS(testdb)
+		require.NoError(t, err)
+		d2, err := CreateTestDevice(testdb, u)
+		require.NoError(t, err)
+
+		require.Equal(t, ErrDeviceExists, testdb.MoveDevice(d.DeviceID, d2.Name, true))
+		require.Equal(t, InvalidNameError, testdb.MoveDevice(d.DeviceID, "bad/name", true))
+
+		require.NoError(t, testdb.MoveDevice(d.DeviceID, "renamed", true))
+		dev, err := testdb.ReadDeviceForUserByName(u.UserID, "renamed")
+		require.NoError(t, err)
+		require.Equal(t, d.DeviceID, dev.DeviceID)
+		_, err = testdb.ReadDeviceForUserByName(u.UserID, d.Name)
+		require.Equal(t, ErrDeviceNotFound, err)
+
+		// The old name is an alias of the device, and its streams are unchanged
+		dev, err = testdb.ReadDeviceByAlias(u.UserID, d.Name)
+		require.NoError(t, err)
+		require.Equal(t, d.DeviceID, dev.DeviceID)
+		_, err = testdb.ReadStreamByDeviceIDAndName(d.DeviceID, s.Name)
+		require.NoError(t, err)
+
+		// Renaming without an alias drops the alias of the name taken
+		require.NoError(t, testdb.MoveDevice(d2.DeviceID, d.Name, false))
+		_, err = testdb.ReadDeviceByAlias(u.UserID, d.Name)
+		require.Equal(t, ErrDeviceNotFound, err)
+		_, err = testdb.ReadDeviceByAlias(u.UserID, d2.Name)
+		require.Equal(t, ErrDeviceNotFound, err)
+	}
+}
+
+func TestMoveStream(t *testing.T) {
+	for _, testdb := range testdatabases {
+		u, d, s, err := CreateUDS(testdb)
+		require.NoError(t, err)
+		d2, err := CreateTestDevice(testdb, u)
+		require.NoError(t, err)
+		s2, err := CreateTestStream(testdb, d2)
+		require.NoError(t, err)
+
+		require.Equal(t, ErrStreamExists, testdb.MoveStream(s.StreamID, d2.DeviceID, s2.Name, true))
+		s.SchemaVersion = 1
+		require.NoError(t, testdb.UpdateStreamSchema(s, &StreamSchema{Version: 1, Schema: `{"type":"string"}`, StartIndex: 3}))
+
+		require.NoError(t, testdb.MoveStream(s.StreamID, d2.DeviceID, "moved", true))
+		strm, err := testdb.ReadStreamByDeviceIDAndName(d2.DeviceID, "moved")
+		require.NoError(t, err)
+		require.Equal(t, s.StreamID, strm.StreamID)
+		require.Equal(t, s.Schema, strm.Schema)
+
+		// The schema history moves with the stream
+		versions, err := testdb.ReadStreamSchemas(strm.StreamID)
+		require.NoError(t, err)
+		require.Len(t, versions, 1)
+		require.Equal(t, int64(3), versions[0].StartIndex)
+		_, err = testdb.ReadStreamByDeviceIDAndName(d.DeviceID, s.Name)
+		require.Equal(t, ErrStreamNotFound, err)
+
+		strm, err = testdb.ReadStreamByAlias(d.DeviceID, s.Name)
+		require.NoError(t, err)
+		require.Equal(t, s.StreamID, strm.StreamID)
+
+		// Moving the stream back takes the alias
+		require.NoError(t, testdb.MoveStream(s.StreamID, d.DeviceID, s.Name, false))
+		_, err = testdb.ReadStreamByAlias(d.DeviceID, s.Name)
+		require.Equal(t, ErrStreamNotFound, err)
+		_, err = testdb.ReadStreamByDeviceIDAndName(d.DeviceID, s.Name)
+		require.NoError(t, err)
+
+		// Aliases don't resolve to trashed streams
+		require.NoError(t, testdb.MoveStream(s.StreamID, d.DeviceID, "moved", true))
+		require.NoError(t, testdb.TrashStream(s.StreamID, 1000))
+		_, err = testdb.ReadStreamByAlias(d.DeviceID, s.Name)
+		require.Equal(t, ErrStreamNotFound, err)
+	}
+}
+
+func TestTransferDevice(t *testing.T) {
+	for _, testdb := range testdatabases {
+		u, d, s, err := CreateUDS(testdb)
+		require.NoError(t, err)
+		u2, err := CreateTestUser(testdb)
+		require.NoError(t, err)
+		d2, err := CreateTestDevice(testdb, u2)
+		require.NoError(t, err)
+
+		require.NoError(t, testdb.MoveDevice(d.DeviceID, d2.Name, true))
+		require.Equal(t, ErrDeviceExists, testdb.TransferDevice(d.DeviceID, u2.UserID))
+		require.Equal(t, ErrDeviceNotFound, testdb.TransferDevice(-1, u2.UserID))
+
+		require.NoError(t, testdb.MoveDevice(d.DeviceID, "transferred", true))
+		require.NoError(t, testdb.TransferDevice(d.DeviceID, u2.UserID))
+		dev, err := testdb.ReadDeviceForUserByName(u2.UserID, "transferred")
+		require.NoError(t, err)
+		require.Equal(t, d.DeviceID, dev.DeviceID)
+		_, err = testdb.ReadDeviceForUserByName(u.UserID, "transferred")
+		require.Equal(t, ErrDeviceNotFound, err)
+
+		// The streams follow the device, and the previous owner's aliases are gone
+		_, err = testdb.ReadStreamByDeviceIDAndName(d.DeviceID, s.Name)
+		require.NoError(t, err)
+		_, err = testdb.ReadDeviceByAlias(u.UserID, d.Name)
+		require.Equal(t, ErrDeviceNotFound, err)
+	}
+}
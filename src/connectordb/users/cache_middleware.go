@@ -151,7 +151,8 @@ func (userdb *CacheMiddleware) cacheDevice(dev *Device, err error) {
 	userdb.deviceCache.AddMany(cacheable,
 		fmt.Sprintf("id:%d", dev.DeviceID),
 		fmt.Sprintf("usr:%dname:%s", dev.UserID, dev.Name),
-		fmt.Sprintf("apikey:%s", dev.APIKey))
+		fmt.Sprintf("apikey:%s", dev.APIKey),
+		fmt.Sprintf("webhook:%s", dev.WebhookToken))
 }
 
 func (userdb *CacheMiddleware) readUser(key string) (user User, ok bool) {
@@ -203,6 +204,43 @@ func (userdb *CacheMiddleware) DeleteUser(UserID int64) error {
 	return userdb.UserDatabase.DeleteUser(UserID)
 }
 
+func (userdb *CacheMiddleware) TrashDevice(Id int64, deleted float64) error {
+	userdb.clearCachedDevice(Id)
+
+	return userdb.UserDatabase.TrashDevice(Id, deleted)
+}
+
+func (userdb *CacheMiddleware) TrashStream(Id int64, deleted float64) error {
+	userdb.clearCachedStream(Id)
+
+	return userdb.UserDatabase.TrashStream(Id, deleted)
+}
+
+func (userdb *CacheMiddleware) TrashUser(UserID int64, deleted float64) error {
+	// Do this first, since the user's streams are hidden once it is trashed
+	userdb.clearCachedUser(UserID)
+
+	return userdb.UserDatabase.TrashUser(UserID, deleted)
+}
+
+func (userdb *CacheMiddleware) MoveDevice(Id int64, name string, alias bool) error {
+	err := userdb.UserDatabase.MoveDevice(Id, name, alias)
+	userdb.clearCachedDevice(Id)
+	return err
+}
+
+func (userdb *CacheMiddleware) TransferDevice(Id int64, UserID int64) error {
+	err := userdb.UserDatabase.TransferDevice(Id, UserID)
+	userdb.clearCachedDevice(Id)
+	return err
+}
+
+func (userdb *CacheMiddleware) MoveStream(Id int64, DeviceID int64, name string, alias bool) error {
+	err := userdb.UserDatabase.MoveStream(Id, DeviceID, name, alias)
+	userdb.clearCachedStream(Id)
+	return err
+}
+
 func (userdb *CacheMiddleware) Login(Username, Password string) (*User, *Device, error) {
 	user, dev, err := userdb.UserDatabase.Login(Username, Password)
 
@@ -229,6 +267,19 @@ func (userdb *CacheMiddleware) ReadDeviceByAPIKey(Key string) (*Device, error) {
 	return dev, err
 }
 
+func (userdb *CacheMiddleware) ReadDeviceByWebhookToken(token string) (*Device, error) {
+	cacheDev, ok := userdb.readDevice("webhook:" + token)
+	if ok {
+		return &cacheDev, nil
+	}
+
+	dev, err := userdb.UserDatabase.ReadDeviceByWebhookToken(token)
+
+	userdb.cacheDevice(dev, err)
+
+	return dev, err
+}
+
 func (userdb *CacheMiddleware) ReadDeviceByID(DeviceID int64) (*Device, error) {
 	cacheDev, ok := userdb.readDevice(fmt.Sprintf("id:%d", DeviceID))
 	if ok {
@@ -366,6 +417,12 @@ func (userdb *CacheMiddleware) UpdateDevice(device *Device) error {
 	return err
 }
 
+func (userdb *CacheMiddleware) UpdateDeviceLastSeen(deviceID int64, lastseen float64) error {
+	err := userdb.UserDatabase.UpdateDeviceLastSeen(deviceID, lastseen)
+	userdb.clearCachedDevice(deviceID)
+	return err
+}
+
 func (userdb *CacheMiddleware) UpdateStream(stream *Stream) error {
 	if stream == nil {
 		return InvalidPointerError
@@ -376,6 +433,16 @@ func (userdb *CacheMiddleware) UpdateStream(stream *Stream) error {
 	return err
 }
 
+func (userdb *CacheMiddleware) UpdateStreamSchema(stream *Stream, versions ...*StreamSchema) error {
+	if stream == nil {
+		return InvalidPointerError
+	}
+
+	err := userdb.UserDatabase.UpdateStreamSchema(stream, versions...)
+	userdb.clearCachedStream(stream.StreamID)
+	return err
+}
+
 func (userdb *CacheMiddleware) UpdateUser(user *User) error {
 	if user == nil {
 		return InvalidPointerError
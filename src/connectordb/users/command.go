@@ -0,0 +1,179 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package users
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+)
+
+// The states of a downlink command. A command starts out pending, is delivered to the device, and the device
+// then posts back whether it succeeded. Commands which are not done before they expire are marked as expired.
+const (
+	CommandPending   = "pending"
+	CommandDelivered = "delivered"
+	CommandDone      = "done"
+	CommandFailed    = "failed"
+	CommandExpired   = "expired"
+)
+
+var (
+	// ErrCommandNotFound is returned when the command does not exist
+	ErrCommandNotFound = errors.New("The command does not exist")
+
+	// ErrCommandFinished is returned when updating a command which is done, failed or expired
+	ErrCommandFinished = errors.New("The command is already finished")
+)
+
+// Command is a command queued on a downlink stream. The command and its result are stored as JSON.
+type Command struct {
+	CommandID int64           `json:"id"`
+	StreamID  int64           `json:"-"`
+	Data      json.RawMessage `json:"data"`   // The command
+	Sender    string          `json:"sender"` // The device which sent the command
+	Status    string          `json:"status"`
+	Result    json.RawMessage `json:"result"`    // The result posted back by the device, if any
+	Timestamp float64         `json:"timestamp"` // The time at which the command was sent
+	Expires   float64         `json:"expires"`   // The time at which the command expires. 0 means that it never expires.
+	Updated   float64         `json:"updated"`   // The time of the last status change
+}
+
+// commandRow is a command as it is stored in the database, where the JSON is stored as text
+type commandRow struct {
+	CommandID int64
+	StreamID  int64
+	Data      string
+	Sender    string
+	Status    string
+	Result    string
+	Timestamp float64
+	Expires   float64
+	Updated   float64
+}
+
+// rawJSON returns the stored JSON, where an empty string means that there is none
+func rawJSON(s string) json.RawMessage {
+	if s == "" {
+		return nil
+	}
+	return json.RawMessage(s)
+}
+
+func (r *commandRow) command() *Command {
+	return &Command{
+		CommandID: r.CommandID,
+		StreamID:  r.StreamID,
+		Data:      rawJSON(r.Data),
+		Sender:    r.Sender,
+		Status:    r.Status,
+		Result:    rawJSON(r.Result),
+		Timestamp: r.Timestamp,
+		Expires:   r.Expires,
+		Updated:   r.Updated,
+	}
+}
+
+// IsFinished returns true if the command will not change status anymore
+func (c *Command) IsFinished() bool {
+	return c.Status == CommandDone || c.Status == CommandFailed || c.Status == CommandExpired
+}
+
+// CreateCommand adds the command to the queue of its stream, setting its CommandID
+func (userdb *SqlUserDatabase) CreateCommand(c *Command) error {
+	if c == nil {
+		return InvalidPointerError
+	}
+
+	query := `INSERT INTO downlinkcommands
+		(	streamid,
+			data,
+			sender,
+			status,
+			result,
+			timestamp,
+			expires,
+			updated) VALUES (?,?,?,?,?,?,?,?)`
+	args := []interface{}{c.StreamID, string(c.Data), c.Sender, c.Status, string(c.Result), c.Timestamp, c.Expires, c.Updated}
+
+	if userdb.dbtype == "sqlite3" {
+		res, err := userdb.Exec(query+";", args...)
+		if err != nil {
+			return err
+		}
+		c.CommandID, err = res.LastInsertId()
+		return err
+	}
+	// Postgres does not support LastInsertId
+	return userdb.Get(&c.CommandID, query+" RETURNING commandid;", args...)
+}
+
+// ReadCommandByID returns the command with the given ID
+func (userdb *SqlUserDatabase) ReadCommandByID(CommandID int64) (*Command, error) {
+	var c commandRow
+
+	err := userdb.Get(&c, "SELECT * FROM downlinkcommands WHERE commandid = ? LIMIT 1;", CommandID)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrCommandNotFound
+	}
+
+	return c.command(), err
+}
+
+// ReadCommands returns the commands of the given stream in the order they were sent.
+// If status is not empty, only commands with the given status are returned.
+func (userdb *SqlUserDatabase) ReadCommands(StreamID int64, status string) ([]*Command, error) {
+	var rows []*commandRow
+	var err error
+
+	if status == "" {
+		err = userdb.Select(&rows, "SELECT * FROM downlinkcommands WHERE streamid = ? ORDER BY commandid ASC;", StreamID)
+	} else {
+		err = userdb.Select(&rows, "SELECT * FROM downlinkcommands WHERE streamid = ? AND status = ? ORDER BY commandid ASC;", StreamID, status)
+	}
+
+	if err == sql.ErrNoRows {
+		err = nil
+	}
+
+	commands := make([]*Command, 0, len(rows))
+	for _, r := range rows {
+		commands = append(commands, r.command())
+	}
+	return commands, err
+}
+
+// UpdateCommand sets the status and result of the command. Finished commands are not updated, so that a command
+// finished concurrently keeps its status, and ErrCommandFinished is returned.
+func (userdb *SqlUserDatabase) UpdateCommand(c *Command) error {
+	if c == nil {
+		return InvalidPointerError
+	}
+
+	res, err := userdb.Exec(`UPDATE downlinkcommands SET
+		status = ?, result = ?, updated = ? WHERE commandid = ? AND status NOT IN (?, ?, ?);`,
+		c.Status, string(c.Result), c.Updated, c.CommandID, CommandDone, CommandFailed, CommandExpired)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		return ErrCommandFinished
+	}
+	return err
+}
+
+// DeleteCommandsBefore deletes the commands which finished or expired before the given time,
+// returning the number of deleted commands
+func (userdb *SqlUserDatabase) DeleteCommandsBefore(before float64) (int64, error) {
+	res, err := userdb.Exec(`DELETE FROM downlinkcommands WHERE
+		(status IN (?, ?, ?) AND updated < ?) OR (expires > 0 AND expires < ?);`,
+		CommandDone, CommandFailed, CommandExpired, before, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
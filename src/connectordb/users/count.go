@@ -8,30 +8,30 @@ package users
 
 func (userdb *SqlUserDatabase) CountUsers() (int64, error) {
 	var output int64
-	err := userdb.Get(&output, "SELECT COUNT(UserID) FROM Users;")
+	err := userdb.Get(&output, "SELECT COUNT(UserID) FROM Users WHERE Deleted = 0;")
 	return output, err
 }
 
 func (userdb *SqlUserDatabase) CountStreams() (int64, error) {
 	var output int64
-	err := userdb.Get(&output, "SELECT COUNT(StreamID) FROM Streams;")
+	err := userdb.Get(&output, "SELECT COUNT(StreamID) FROM Streams WHERE Deleted = 0;")
 	return output, err
 }
 
 func (userdb *SqlUserDatabase) CountDevices() (int64, error) {
 	var output int64
-	err := userdb.Get(&output, "SELECT COUNT(DeviceID) FROM Devices;")
+	err := userdb.Get(&output, "SELECT COUNT(DeviceID) FROM Devices WHERE Deleted = 0;")
 	return output, err
 }
 
 func (userdb *SqlUserDatabase) CountStreamsForDevice(DeviceID int64) (int64, error) {
 	var output int64
-	err := userdb.Get(&output, "SELECT COUNT(StreamID) FROM Streams WHERE DeviceID = ?;", DeviceID)
+	err := userdb.Get(&output, "SELECT COUNT(StreamID) FROM Streams WHERE DeviceID = ? AND Deleted = 0;", DeviceID)
 	return output, err
 }
 
 func (userdb *SqlUserDatabase) CountDevicesForUser(UserID int64) (int64, error) {
 	var output int64
-	err := userdb.Get(&output, "SELECT COUNT(DeviceID) FROM Devices WHERE UserID = ?;", UserID)
+	err := userdb.Get(&output, "SELECT COUNT(DeviceID) FROM Devices WHERE UserID = ? AND Deleted = 0;", UserID)
 	return output, err
 }
@@ -32,6 +32,27 @@ type Device struct {
 
 	IsVisible    bool `json:"visible" permissions:"visible"`
 	UserEditable bool `json:"user_editable" permissions:"user_editable"`
+
+	// Whether inserting into a stream of the device which doesn't exist creates the stream, with a schema
+	// inferred from the inserted data. The device's roles must also allow it.
+	AutoCreateStreams bool `json:"auto_create_streams" permissions:"auto_create_streams"`
+
+	// The stream path template of InfluxDB line protocol data written by the device. See server/restapi/influx.
+	InfluxMapping string `json:"influx_mapping" permissions:"influx_mapping"`
+
+	// Webhooks are posted to a URL containing the device's secret webhook token, which is regenerated by setting it
+	// to an empty string. The rules map the JSON payloads of webhooks to datapoints, and webhooks are only accepted
+	// when the device has rules. See connectordb/webhook.
+	WebhookToken string `json:"webhook_token" permissions:"webhook_token"`
+	WebhookRules string `json:"webhook_rules" permissions:"webhook_rules"`
+
+	// Presence is tracked by the server rather than set by the device. LastSeen is the time of the device's
+	// most recent authenticated request, and Online is true if that time is within the presence timeout.
+	LastSeen float64 `json:"lastseen" permissions:"-"`
+	Online   bool    `json:"online" db:"-" permissions:"-"`
+
+	// The time at which the device was moved to the trash, or 0. Trashed devices are hidden until restored or purged.
+	Deleted float64 `json:"deleted,omitempty" permissions:"-"`
 }
 
 // DeviceMaker is the structure used to create a device
@@ -87,6 +108,10 @@ func (userdb *SqlUserDatabase) CreateDevice(d *DeviceMaker) error {
 		apikey, _ := uuid.NewV4()
 		d.APIKey = apikey.String()
 	}
+	if d.WebhookToken == "" {
+		token, _ := uuid.NewV4()
+		d.WebhookToken = token.String()
+	}
 
 	if d.Devicelimit > 0 {
 		// TODO: This check should happen in a transaction, since the way it is done now enables timing attacks
@@ -110,10 +135,15 @@ func (userdb *SqlUserDatabase) CreateDevice(d *DeviceMaker) error {
 			enabled,
 			role,
 			isvisible,
-			usereditable
+			usereditable,
+			autocreatestreams,
+			influxmapping,
+			webhooktoken,
+			webhookrules
 		)
-			VALUES (?,?,?,?,?,?,?,?,?,?,?)`, d.Name, d.APIKey, d.UserID, d.Public,
-		d.Description, d.Icon, d.Nickname, d.Enabled, d.Role, d.IsVisible, d.UserEditable)
+			VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`, d.Name, d.APIKey, d.UserID, d.Public,
+		d.Description, d.Icon, d.Nickname, d.Enabled, d.Role, d.IsVisible, d.UserEditable, d.AutoCreateStreams, d.InfluxMapping,
+		d.WebhookToken, d.WebhookRules)
 
 	if err != nil && strings.HasPrefix(err.Error(), "pq: duplicate key value violates unique constraint ") {
 		return errors.New("Device with this name already exists")
@@ -141,7 +171,7 @@ func (userdb *SqlUserDatabase) CreateDevice(d *DeviceMaker) error {
 func (userdb *SqlUserDatabase) ReadDevicesForUserID(UserID int64) ([]*Device, error) {
 	var devices []*Device
 
-	err := userdb.Select(&devices, "SELECT * FROM devices WHERE userid = ?;", UserID)
+	err := userdb.Select(&devices, "SELECT * FROM devices WHERE userid = ? AND deleted = 0;", UserID)
 
 	if err == sql.ErrNoRows {
 		return nil, ErrDeviceNotFound
@@ -154,7 +184,7 @@ func (userdb *SqlUserDatabase) ReadDevicesForUserID(UserID int64) ([]*Device, er
 func (userdb *SqlUserDatabase) ReadDeviceForUserByName(userid int64, devicename string) (*Device, error) {
 	var dev Device
 
-	err := userdb.Get(&dev, "SELECT * FROM devices WHERE userid = ? AND name = ? LIMIT 1;", userid, devicename)
+	err := userdb.Get(&dev, "SELECT * FROM devices WHERE userid = ? AND name = ? AND deleted = 0 LIMIT 1;", userid, devicename)
 
 	if err == sql.ErrNoRows {
 		return nil, ErrDeviceNotFound
@@ -167,7 +197,7 @@ func (userdb *SqlUserDatabase) ReadDeviceForUserByName(userid int64, devicename
 func (userdb *SqlUserDatabase) ReadDeviceByID(DeviceID int64) (*Device, error) {
 	var dev Device
 
-	err := userdb.Get(&dev, "SELECT * FROM devices WHERE deviceid = ? LIMIT 1", DeviceID)
+	err := userdb.Get(&dev, "SELECT * FROM devices WHERE deviceid = ? AND deleted = 0 LIMIT 1", DeviceID)
 
 	if err == sql.ErrNoRows {
 		return nil, ErrDeviceNotFound
@@ -186,7 +216,24 @@ func (userdb *SqlUserDatabase) ReadDeviceByAPIKey(Key string) (*Device, error) {
 		return nil, errors.New("Must have non-empty api key")
 	}
 
-	err := userdb.Get(&dev, "SELECT * FROM devices WHERE apikey = ? LIMIT 1;", Key)
+	err := userdb.Get(&dev, "SELECT * FROM devices WHERE apikey = ? AND deleted = 0 LIMIT 1;", Key)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrDeviceNotFound
+	}
+
+	return &dev, err
+}
+
+// ReadDeviceByWebhookToken reads the device with the given webhook token
+func (userdb *SqlUserDatabase) ReadDeviceByWebhookToken(token string) (*Device, error) {
+	var dev Device
+
+	if token == "" {
+		return nil, errors.New("Must have non-empty webhook token")
+	}
+
+	err := userdb.Get(&dev, "SELECT * FROM devices WHERE webhooktoken = ? AND deleted = 0 LIMIT 1;", token)
 
 	if err == sql.ErrNoRows {
 		return nil, ErrDeviceNotFound
@@ -217,6 +264,10 @@ func (userdb *SqlUserDatabase) UpdateDevice(device *Device) error {
 		role = ?,
 		isvisible = ?,
 		usereditable = ?,
+		autocreatestreams = ?,
+		influxmapping = ?,
+		webhooktoken = ?,
+		webhookrules = ?,
 		public = ? WHERE deviceid = ?;`,
 		device.Name,
 		device.Nickname,
@@ -228,12 +279,28 @@ func (userdb *SqlUserDatabase) UpdateDevice(device *Device) error {
 		device.Role,
 		device.IsVisible,
 		device.UserEditable,
+		device.AutoCreateStreams,
+		device.InfluxMapping,
+		device.WebhookToken,
+		device.WebhookRules,
 		device.Public,
 		device.DeviceID)
 
 	return err
 }
 
+// UpdateDeviceLastSeen sets the time at which the device was last seen by the server
+func (userdb *SqlUserDatabase) UpdateDeviceLastSeen(deviceID int64, lastseen float64) error {
+	result, err := userdb.Exec(`UPDATE devices SET lastseen = ? WHERE deviceid = ?;`, lastseen, deviceID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
+
 // DeleteDevice removes a device from the system.
 func (userdb *SqlUserDatabase) DeleteDevice(ID int64) error {
 	result, err := userdb.Exec(`DELETE FROM devices WHERE deviceid = ?;`, ID)
@@ -68,6 +68,27 @@ func TestReadDeviceByAPIKey(t *testing.T) {
 
 }
 
+func TestReadDeviceByWebhookToken(t *testing.T) {
+	for _, testdb := range testdatabases {
+		_, dev, _, err := CreateUDS(testdb)
+		require.Nil(t, err)
+
+		token := dev.WebhookToken
+		require.NotEqual(t, "", token)
+
+		dev2, err := testdb.ReadDeviceByWebhookToken(token)
+		require.Nil(t, err)
+		assert.Equal(t, dev.DeviceID, dev2.DeviceID)
+
+		_, err = testdb.ReadDeviceByWebhookToken("")
+		assert.NotNil(t, err)
+
+		_, err = testdb.ReadDeviceByWebhookToken("notatoken")
+		assert.Equal(t, ErrDeviceNotFound, err)
+	}
+
+}
+
 func TestUpdateDevice(t *testing.T) {
 	for _, testdb := range testdatabases {
 
@@ -55,6 +55,10 @@ func (userdb *ErrorUserdb) ReadDeviceByAPIKey(Key string) (*Device, error) {
 	return nil, ErrorUserdbError
 }
 
+func (userdb *ErrorUserdb) ReadDeviceByWebhookToken(token string) (*Device, error) {
+	return nil, ErrorUserdbError
+}
+
 func (userdb *ErrorUserdb) ReadDeviceByID(DeviceID int64) (*Device, error) {
 	return nil, ErrorUserdbError
 }
@@ -99,6 +103,10 @@ func (userdb *ErrorUserdb) UpdateDevice(device *Device) error {
 	return ErrorUserdbError
 }
 
+func (userdb *ErrorUserdb) UpdateDeviceLastSeen(deviceID int64, lastseen float64) error {
+	return ErrorUserdbError
+}
+
 func (userdb *ErrorUserdb) UpdateStream(stream *Stream) error {
 	return ErrorUserdbError
 }
@@ -107,6 +115,34 @@ func (userdb *ErrorUserdb) UpdateUser(user *User) error {
 	return ErrorUserdbError
 }
 
+func (userdb *ErrorUserdb) UpdateStreamSchema(stream *Stream, versions ...*StreamSchema) error {
+	return ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) ReadStreamSchemas(StreamID int64) ([]*StreamSchema, error) {
+	return nil, ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) CreateCommand(c *Command) error {
+	return ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) ReadCommandByID(CommandID int64) (*Command, error) {
+	return nil, ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) ReadCommands(StreamID int64, status string) ([]*Command, error) {
+	return nil, ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) UpdateCommand(c *Command) error {
+	return ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) DeleteCommandsBefore(before float64) (int64, error) {
+	return 0, ErrorUserdbError
+}
+
 func (userdb *ErrorUserdb) CountUsers() (int64, error) {
 	return 1, ErrorUserdbError
 }
@@ -118,3 +154,59 @@ func (userdb *ErrorUserdb) CountStreams() (int64, error) {
 func (userdb *ErrorUserdb) CountDevices() (int64, error) {
 	return 1, ErrorUserdbError
 }
+
+func (userdb *ErrorUserdb) TrashUser(UserID int64, deleted float64) error {
+	return ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) TrashDevice(ID int64, deleted float64) error {
+	return ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) TrashStream(ID int64, deleted float64) error {
+	return ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) RestoreUser(UserID int64) error {
+	return ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) RestoreDevice(ID int64) error {
+	return ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) RestoreStream(ID int64) error {
+	return ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) ReadTrash(UserID int64) (*Trash, error) {
+	return nil, ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) ReadTrashBefore(before float64) (*Trash, error) {
+	return nil, ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) ReadTrashedUserByName(Name string) (*User, error) {
+	return nil, ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) MoveDevice(ID int64, name string, alias bool) error {
+	return ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) TransferDevice(ID int64, UserID int64) error {
+	return ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) MoveStream(ID int64, DeviceID int64, name string, alias bool) error {
+	return ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) ReadDeviceByAlias(userid int64, name string) (*Device, error) {
+	return nil, ErrorUserdbError
+}
+
+func (userdb *ErrorUserdb) ReadStreamByAlias(DeviceID int64, name string) (*Stream, error) {
+	return nil, ErrorUserdbError
+}
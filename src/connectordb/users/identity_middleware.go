@@ -51,6 +51,10 @@ func (userdb *IdentityMiddleware) ReadDeviceByAPIKey(Key string) (*Device, error
 	return userdb.UserDatabase.ReadDeviceByAPIKey(Key)
 }
 
+func (userdb *IdentityMiddleware) ReadDeviceByWebhookToken(token string) (*Device, error) {
+	return userdb.UserDatabase.ReadDeviceByWebhookToken(token)
+}
+
 func (userdb *IdentityMiddleware) ReadDeviceByID(DeviceID int64) (*Device, error) {
 	return userdb.UserDatabase.ReadDeviceByID(DeviceID)
 }
@@ -91,6 +95,10 @@ func (userdb *IdentityMiddleware) UpdateDevice(device *Device) error {
 	return userdb.UserDatabase.UpdateDevice(device)
 }
 
+func (userdb *IdentityMiddleware) UpdateDeviceLastSeen(deviceID int64, lastseen float64) error {
+	return userdb.UserDatabase.UpdateDeviceLastSeen(deviceID, lastseen)
+}
+
 func (userdb *IdentityMiddleware) UpdateStream(stream *Stream) error {
 	return userdb.UserDatabase.UpdateStream(stream)
 }
@@ -98,3 +106,87 @@ func (userdb *IdentityMiddleware) UpdateStream(stream *Stream) error {
 func (userdb *IdentityMiddleware) UpdateUser(user *User) error {
 	return userdb.UserDatabase.UpdateUser(user)
 }
+
+func (userdb *IdentityMiddleware) UpdateStreamSchema(stream *Stream, versions ...*StreamSchema) error {
+	return userdb.UserDatabase.UpdateStreamSchema(stream, versions...)
+}
+
+func (userdb *IdentityMiddleware) ReadStreamSchemas(StreamID int64) ([]*StreamSchema, error) {
+	return userdb.UserDatabase.ReadStreamSchemas(StreamID)
+}
+
+func (userdb *IdentityMiddleware) CreateCommand(c *Command) error {
+	return userdb.UserDatabase.CreateCommand(c)
+}
+
+func (userdb *IdentityMiddleware) ReadCommandByID(CommandID int64) (*Command, error) {
+	return userdb.UserDatabase.ReadCommandByID(CommandID)
+}
+
+func (userdb *IdentityMiddleware) ReadCommands(StreamID int64, status string) ([]*Command, error) {
+	return userdb.UserDatabase.ReadCommands(StreamID, status)
+}
+
+func (userdb *IdentityMiddleware) UpdateCommand(c *Command) error {
+	return userdb.UserDatabase.UpdateCommand(c)
+}
+
+func (userdb *IdentityMiddleware) DeleteCommandsBefore(before float64) (int64, error) {
+	return userdb.UserDatabase.DeleteCommandsBefore(before)
+}
+
+func (userdb *IdentityMiddleware) TrashUser(UserID int64, deleted float64) error {
+	return userdb.UserDatabase.TrashUser(UserID, deleted)
+}
+
+func (userdb *IdentityMiddleware) TrashDevice(ID int64, deleted float64) error {
+	return userdb.UserDatabase.TrashDevice(ID, deleted)
+}
+
+func (userdb *IdentityMiddleware) TrashStream(ID int64, deleted float64) error {
+	return userdb.UserDatabase.TrashStream(ID, deleted)
+}
+
+func (userdb *IdentityMiddleware) RestoreUser(UserID int64) error {
+	return userdb.UserDatabase.RestoreUser(UserID)
+}
+
+func (userdb *IdentityMiddleware) RestoreDevice(ID int64) error {
+	return userdb.UserDatabase.RestoreDevice(ID)
+}
+
+func (userdb *IdentityMiddleware) RestoreStream(ID int64) error {
+	return userdb.UserDatabase.RestoreStream(ID)
+}
+
+func (userdb *IdentityMiddleware) ReadTrash(UserID int64) (*Trash, error) {
+	return userdb.UserDatabase.ReadTrash(UserID)
+}
+
+func (userdb *IdentityMiddleware) ReadTrashBefore(before float64) (*Trash, error) {
+	return userdb.UserDatabase.ReadTrashBefore(before)
+}
+
+func (userdb *IdentityMiddleware) ReadTrashedUserByName(Name string) (*User, error) {
+	return userdb.UserDatabase.ReadTrashedUserByName(Name)
+}
+
+func (userdb *IdentityMiddleware) MoveDevice(ID int64, name string, alias bool) error {
+	return userdb.UserDatabase.MoveDevice(ID, name, alias)
+}
+
+func (userdb *IdentityMiddleware) TransferDevice(ID int64, UserID int64) error {
+	return userdb.UserDatabase.TransferDevice(ID, UserID)
+}
+
+func (userdb *IdentityMiddleware) MoveStream(ID int64, DeviceID int64, name string, alias bool) error {
+	return userdb.UserDatabase.MoveStream(ID, DeviceID, name, alias)
+}
+
+func (userdb *IdentityMiddleware) ReadDeviceByAlias(userid int64, name string) (*Device, error) {
+	return userdb.UserDatabase.ReadDeviceByAlias(userid, name)
+}
+
+func (userdb *IdentityMiddleware) ReadStreamByAlias(DeviceID int64, name string) (*Stream, error) {
+	return userdb.UserDatabase.ReadStreamByAlias(DeviceID, name)
+}
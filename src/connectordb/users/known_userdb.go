@@ -55,6 +55,10 @@ func (userdb *KnownUserdb) ReadDeviceByAPIKey(Key string) (*Device, error) {
 	return &KnownDevice, nil
 }
 
+func (userdb *KnownUserdb) ReadDeviceByWebhookToken(token string) (*Device, error) {
+	return &KnownDevice, nil
+}
+
 func (userdb *KnownUserdb) ReadDeviceByID(DeviceID int64) (*Device, error) {
 	return &KnownDevice, nil
 }
@@ -99,6 +103,10 @@ func (userdb *KnownUserdb) UpdateDevice(device *Device) error {
 	return nil
 }
 
+func (userdb *KnownUserdb) UpdateDeviceLastSeen(deviceID int64, lastseen float64) error {
+	return nil
+}
+
 func (userdb *KnownUserdb) UpdateStream(stream *Stream) error {
 	return nil
 }
@@ -107,6 +115,34 @@ func (userdb *KnownUserdb) UpdateUser(user *User) error {
 	return nil
 }
 
+func (userdb *KnownUserdb) UpdateStreamSchema(stream *Stream, versions ...*StreamSchema) error {
+	return nil
+}
+
+func (userdb *KnownUserdb) ReadStreamSchemas(StreamID int64) ([]*StreamSchema, error) {
+	return []*StreamSchema{}, nil
+}
+
+func (userdb *KnownUserdb) CreateCommand(c *Command) error {
+	return nil
+}
+
+func (userdb *KnownUserdb) ReadCommandByID(CommandID int64) (*Command, error) {
+	return nil, ErrCommandNotFound
+}
+
+func (userdb *KnownUserdb) ReadCommands(StreamID int64, status string) ([]*Command, error) {
+	return []*Command{}, nil
+}
+
+func (userdb *KnownUserdb) UpdateCommand(c *Command) error {
+	return nil
+}
+
+func (userdb *KnownUserdb) DeleteCommandsBefore(before float64) (int64, error) {
+	return 0, nil
+}
+
 func (userdb *KnownUserdb) CountUsers() (int64, error) {
 	return 1, nil
 }
@@ -118,3 +154,59 @@ func (userdb *KnownUserdb) CountStreams() (int64, error) {
 func (userdb *KnownUserdb) CountDevices() (int64, error) {
 	return 1, nil
 }
+
+func (userdb *KnownUserdb) TrashUser(UserID int64, deleted float64) error {
+	return nil
+}
+
+func (userdb *KnownUserdb) TrashDevice(ID int64, deleted float64) error {
+	return nil
+}
+
+func (userdb *KnownUserdb) TrashStream(ID int64, deleted float64) error {
+	return nil
+}
+
+func (userdb *KnownUserdb) RestoreUser(UserID int64) error {
+	return nil
+}
+
+func (userdb *KnownUserdb) RestoreDevice(ID int64) error {
+	return nil
+}
+
+func (userdb *KnownUserdb) RestoreStream(ID int64) error {
+	return nil
+}
+
+func (userdb *KnownUserdb) ReadTrash(UserID int64) (*Trash, error) {
+	return &Trash{Users: []*User{&KnownUser}, Devices: []*Device{&KnownDevice}, Streams: []*Stream{&KnownStream}}, nil
+}
+
+func (userdb *KnownUserdb) ReadTrashBefore(before float64) (*Trash, error) {
+	return &Trash{Users: []*User{&KnownUser}, Devices: []*Device{&KnownDevice}, Streams: []*Stream{&KnownStream}}, nil
+}
+
+func (userdb *KnownUserdb) ReadTrashedUserByName(Name string) (*User, error) {
+	return &KnownUser, nil
+}
+
+func (userdb *KnownUserdb) MoveDevice(ID int64, name string, alias bool) error {
+	return nil
+}
+
+func (userdb *KnownUserdb) TransferDevice(ID int64, UserID int64) error {
+	return nil
+}
+
+func (userdb *KnownUserdb) MoveStream(ID int64, DeviceID int64, name string, alias bool) error {
+	return nil
+}
+
+func (userdb *KnownUserdb) ReadDeviceByAlias(userid int64, name string) (*Device, error) {
+	return &KnownDevice, nil
+}
+
+func (userdb *KnownUserdb) ReadStreamByAlias(DeviceID int64, name string) (*Stream, error) {
+	return &KnownStream, nil
+}
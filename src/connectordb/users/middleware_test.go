@@ -210,6 +210,23 @@ func TestMiddlewareReadDeviceByAPIKey(t *testing.T) {
 	}
 }
 
+func TestMiddlewareReadDeviceByWebhookToken(t *testing.T) {
+	var testcases = GetCommonTestcases()
+
+	for index, testcase := range testcases {
+		testCounter := AccountingMiddleware{testcase.Test, 0}
+		testResult, testError := testCounter.ReadDeviceByWebhookToken("")
+		baseResult, baseError := testcase.Base.ReadDeviceByWebhookToken("")
+
+		numCalls := testCounter.GetNumberOfCalls()
+
+		prefix := "TestMiddlewareReadDeviceByWebhookToken"
+		AssertEqMiddlewareTest(t, testError, baseError, prefix+" Errors", index)
+		AssertEqMiddlewareTest(t, &testResult, &baseResult, prefix+" Result", index)
+		AssertEqMiddlewareTest(t, numCalls, testcase.NumCalls, prefix+" #Calls", index)
+	}
+}
+
 func TestMiddlewareReadDeviceByID(t *testing.T) {
 	var testcases = GetCommonTestcases()
 
@@ -380,3 +397,33 @@ func TestMiddlewareLogin(t *testing.T) {
 		AssertEqMiddlewareTest(t, numCalls, testcase.NumCalls, prefix+" #Calls", index)
 	}
 }
+
+func TestMiddlewareTrashStream(t *testing.T) {
+	var testcases = GetCommonTestcases()
+
+	for index, testcase := range testcases {
+		testCounter := AccountingMiddleware{testcase.Test, 0}
+		testError := testCounter.TrashStream(1, 1000)
+		baseError := testcase.Base.TrashStream(1, 1000)
+
+		numCalls := testCounter.GetNumberOfCalls()
+
+		AssertEqMiddlewareTest(t, testError, baseError, "TestMiddlewareTrashStream Errors", index)
+		AssertEqMiddlewareTest(t, numCalls, testcase.NumCalls, "TestMiddlewareTrashStream #Calls", index)
+	}
+}
+
+func TestMiddlewareMoveStream(t *testing.T) {
+	var testcases = GetCommonTestcases()
+
+	for index, testcase := range testcases {
+		testCounter := AccountingMiddleware{testcase.Test, 0}
+		testError := testCounter.MoveStream(1, 1, "moved", true)
+		baseError := testcase.Base.MoveStream(1, 1, "moved", true)
+
+		numCalls := testCounter.GetNumberOfCalls()
+
+		AssertEqMiddlewareTest(t, testError, baseError, "TestMiddlewareMoveStream Errors", index)
+		AssertEqMiddlewareTest(t, numCalls, testcase.NumCalls, "TestMiddlewareMoveStream #Calls", index)
+	}
+}
@@ -34,6 +34,15 @@ type Stream struct {
 	DeviceID    int64  `json:"-" permissions:"-"`
 	Ephemeral   bool   `json:"ephemeral" permissions:"ephemeral"`
 	Downlink    bool   `json:"downlink" permissions:"downlink"`
+
+	// Backfill allows inserting datapoints older than the stream's most recent datapoint at their correct position.
+	// Since this shifts the indices of later datapoints, it must be explicitly enabled.
+	Backfill bool `json:"backfill" permissions:"backfill"`
+
+	SchemaVersion int64 `json:"schemaversion" permissions:"-"` // The current version of the schema (see StreamSchema)
+
+	// The time at which the stream was moved to the trash, or 0. Trashed streams are hidden until restored or purged.
+	Deleted float64 `json:"deleted,omitempty" permissions:"-"`
 }
 
 // The struct passed in to create a stream
@@ -160,8 +169,9 @@ func (userdb *SqlUserDatabase) CreateStream(s *StreamMaker) error {
 			icon,
 			nickname,
 			ephemeral,
-			downlink) VALUES (?,?,?,?,?,?,?,?,?);`, s.Name, minSchema, s.DeviceID,
-		s.Description, s.Datatype, s.Icon, s.Nickname, s.Ephemeral, s.Downlink)
+			downlink,
+			backfill) VALUES (?,?,?,?,?,?,?,?,?,?);`, s.Name, minSchema, s.DeviceID,
+		s.Description, s.Datatype, s.Icon, s.Nickname, s.Ephemeral, s.Downlink, s.Backfill)
 
 	if err != nil && strings.HasPrefix(err.Error(), "pq: duplicate key value violates unique constraint ") {
 		return errors.New("Stream with this name already exists")
@@ -174,7 +184,7 @@ func (userdb *SqlUserDatabase) CreateStream(s *StreamMaker) error {
 func (userdb *SqlUserDatabase) ReadStreamByID(StreamID int64) (*Stream, error) {
 	var stream Stream
 
-	err := userdb.Get(&stream, "SELECT * FROM streams WHERE streamid = ? LIMIT 1;", StreamID)
+	err := userdb.Get(&stream, "SELECT * FROM streams WHERE streamid = ? AND deleted = 0 LIMIT 1;", StreamID)
 
 	if err == sql.ErrNoRows {
 		return nil, ErrStreamNotFound
@@ -188,7 +198,7 @@ func (userdb *SqlUserDatabase) ReadStreamByID(StreamID int64) (*Stream, error) {
 func (userdb *SqlUserDatabase) ReadStreamByDeviceIDAndName(DeviceID int64, streamName string) (*Stream, error) {
 	var stream Stream
 
-	err := userdb.Get(&stream, "SELECT * FROM streams WHERE deviceid = ? AND name = ? LIMIT 1;", DeviceID, streamName)
+	err := userdb.Get(&stream, "SELECT * FROM streams WHERE deviceid = ? AND name = ? AND deleted = 0 LIMIT 1;", DeviceID, streamName)
 
 	if err == sql.ErrNoRows {
 		return nil, ErrStreamNotFound
@@ -200,7 +210,7 @@ func (userdb *SqlUserDatabase) ReadStreamByDeviceIDAndName(DeviceID int64, strea
 func (userdb *SqlUserDatabase) ReadStreamsByDevice(DeviceID int64) ([]*Stream, error) {
 	var streams []*Stream
 
-	err := userdb.Select(&streams, "SELECT * FROM streams WHERE deviceid = ?;", DeviceID)
+	err := userdb.Select(&streams, "SELECT * FROM streams WHERE deviceid = ? AND deleted = 0;", DeviceID)
 
 	if err == sql.ErrNoRows {
 		return nil, ErrStreamNotFound
@@ -231,7 +241,9 @@ func (userdb *SqlUserDatabase) UpdateStream(stream *Stream) error {
 		datatype= ?,
 		deviceid = ?,
 		ephemeral = ?,
-		downlink = ?
+		downlink = ?,
+		backfill = ?,
+		schemaversion = ?
 		WHERE streamid= ?;`,
 		stream.Name,
 		stream.Nickname,
@@ -242,6 +254,8 @@ func (userdb *SqlUserDatabase) UpdateStream(stream *Stream) error {
 		stream.DeviceID,
 		stream.Ephemeral,
 		stream.Downlink,
+		stream.Backfill,
+		stream.SchemaVersion,
 		stream.StreamID)
 
 	return err
@@ -268,7 +282,7 @@ func (userdb *SqlUserDatabase) ReadStreamsByUser(UserID int64, public, downlink,
 
 	query := `SELECT s.*, d.name AS device FROM streams s
 		INNER JOIN devices d ON s.deviceid = d.deviceid
-		WHERE d.userid = ?`
+		WHERE d.userid = ? AND s.deleted = 0 AND d.deleted = 0`
 
 	// sqlite has issues with TRUE/FALSE, so we let sqlx take care of converting
 	// things into the correct variable types: we send booleans in as parameters
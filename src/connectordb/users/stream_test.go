@@ -70,6 +70,40 @@ func TestUpdateStream(t *testing.T) {
 	}
 }
 
+func TestUpdateStreamSchema(t *testing.T) {
+	for _, testdb := range testdatabases {
+		_, _, stream, err := CreateUDS(testdb)
+		require.NoError(t, err)
+
+		oldschema := stream.Schema
+		stream.Schema = `{"type":"string"}`
+		stream.SchemaVersion = 1
+		require.NoError(t, testdb.UpdateStreamSchema(stream,
+			&StreamSchema{Schema: oldschema},
+			&StreamSchema{Version: 1, Schema: stream.Schema, StartIndex: 5}))
+
+		s, err := testdb.ReadStreamByID(stream.StreamID)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), s.SchemaVersion)
+		require.Equal(t, `{"type":"string"}`, s.Schema)
+		versions, err := testdb.ReadStreamSchemas(stream.StreamID)
+		require.NoError(t, err)
+		require.Len(t, versions, 2)
+		require.Equal(t, int64(5), versions[1].StartIndex)
+
+		// Nothing is written if one of the versions fails
+		stream.Schema = `{"type":"boolean"}`
+		stream.SchemaVersion = 2
+		require.Error(t, testdb.UpdateStreamSchema(stream, &StreamSchema{Version: 1, Schema: stream.Schema}))
+		s, err = testdb.ReadStreamByID(stream.StreamID)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), s.SchemaVersion)
+		require.Equal(t, `{"type":"string"}`, s.Schema)
+
+		require.Equal(t, InvalidPointerError, testdb.UpdateStreamSchema(nil))
+	}
+}
+
 func TestDeleteStream(t *testing.T) {
 
 	for _, testdb := range testdatabases {
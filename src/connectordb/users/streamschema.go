@@ -0,0 +1,135 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package users
+
+import (
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// StreamSchema is a single version of a stream's schema. Each time a stream's schema is changed,
+// a new version is recorded along with the length of the stream at the time of the change, so that
+// every datapoint can be matched with the schema it was written under.
+type StreamSchema struct {
+	StreamID      int64  `json:"-"`
+	Version       int64  `json:"version"`
+	Schema        string `json:"schema"`
+	Upgrade       string `json:"upgrade"`       // PipeScript transform converting data of the previous version to this one
+	StartIndex    int64  `json:"startindex"`    // The index of the first datapoint written with this version
+	DownlinkIndex int64  `json:"downlinkindex"` // The index of the first downlink datapoint written with this version
+}
+
+// GetStartIndex returns the index of the first datapoint of the given substream which was written with this version
+func (s *StreamSchema) GetStartIndex(substream string) int64 {
+	if substream == "downlink" {
+		return s.DownlinkIndex
+	}
+	return s.StartIndex
+}
+
+// SchemaCheck is the result of checking existing data against a proposed schema
+type SchemaCheck struct {
+	Checked    int64 `json:"checked"`    // The number of datapoints checked
+	Violations int64 `json:"violations"` // The number of datapoints which do not conform to the schema
+}
+
+// UpdateStreamSchema writes the stream along with the given new versions of its schema, all in one transaction,
+// so that the stream's schemaversion always matches its recorded versions
+func (userdb *SqlUserDatabase) UpdateStreamSchema(stream *Stream, versions ...*StreamSchema) error {
+	if stream == nil {
+		return InvalidPointerError
+	}
+
+	var statements []statement
+	for _, s := range versions {
+		minSchema, err := minifyAndValidateSchema(s.Schema)
+		if err != nil {
+			return err
+		}
+		statements = append(statements, statement{`INSERT INTO streamschemas
+		(	streamid,
+			version,
+			schema,
+			upgrade,
+			startindex,
+			downlinkindex) VALUES (?,?,?,?,?,?);`, []interface{}{stream.StreamID, s.Version, minSchema,
+			s.Upgrade, s.StartIndex, s.DownlinkIndex}})
+	}
+
+	minSchema, err := minifyAndValidateSchema(stream.Schema)
+	if err != nil {
+		return err
+	}
+	// The whole stream is written, since other properties can be changed together with the schema
+	statements = append(statements, statement{`UPDATE streams SET
+		name = ?,
+		nickname = ?,
+		description = ?,
+		icon = ?,
+		schema = ?,
+		datatype = ?,
+		deviceid = ?,
+		ephemeral = ?,
+		downlink = ?,
+		backfill = ?,
+		schemaversion = ?
+		WHERE streamid = ? AND deleted = 0;`, []interface{}{stream.Name, stream.Nickname, stream.Description,
+		stream.Icon, minSchema, stream.Datatype, stream.DeviceID, stream.Ephemeral, stream.Downlink, stream.Backfill,
+		stream.SchemaVersion, stream.StreamID}})
+
+	return userdb.execAll(ErrStreamNotFound, statements...)
+}
+
+// ReadStreamSchemas returns all recorded schema versions of the given stream, ordered by version
+func (userdb *SqlUserDatabase) ReadStreamSchemas(StreamID int64) ([]*StreamSchema, error) {
+	var schemas []*StreamSchema
+
+	err := userdb.Select(&schemas, "SELECT * FROM streamschemas WHERE streamid = ? ORDER BY version ASC;", StreamID)
+
+	if err == sql.ErrNoRows {
+		err = nil
+	}
+
+	return schemas, err
+}
+
+// ShiftStreamSchemaIndices moves the start indices of a stream's schema versions after datapoints were backfilled into
+// the given substream. It is run within the transaction which rewrites the stream's data, with shift returning
+// the number of datapoints which were inserted before a given index.
+func ShiftStreamSchemaIndices(tx *sqlx.Tx, streamID int64, substream string, shift func(index int64) (int64, error)) error {
+	column := "startindex"
+	switch substream {
+	case "":
+	case "downlink":
+		column = "downlinkindex"
+	default:
+		return nil
+	}
+
+	var schemas []*StreamSchema
+	if err := tx.Select(&schemas, tx.Rebind("SELECT * FROM streamschemas WHERE streamid = ?;"), streamID); err != nil {
+		return err
+	}
+	for _, s := range schemas {
+		index := s.GetStartIndex(substream)
+		if index == 0 {
+			// The version starts with the stream, so backfilled data before its first datapoint is still its own
+			continue
+		}
+		n, err := shift(index)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			continue
+		}
+		_, err = tx.Exec(tx.Rebind("UPDATE streamschemas SET "+column+" = ? WHERE streamid = ? AND version = ?;"), index+n, streamID, s.Version)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
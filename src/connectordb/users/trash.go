@@ -0,0 +1,162 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+
+This file contains the soft deletion of users, devices and streams. Instead of deleting rows
+(which cascades to everything they own), they are marked with the time at which they were moved
+to the trash. Trashed rows are hidden from all reads until they are restored, or purged for real.
+
+Trashing a user or device also trashes everything it owns with the same timestamp, so that restoring
+it brings back exactly what was trashed with it, and not streams that were trashed separately before.
+**/
+package users
+
+import (
+	"database/sql"
+)
+
+// Trash holds the users, devices and streams which were moved to the trash
+type Trash struct {
+	Users   []*User
+	Devices []*Device
+	Streams []*Stream
+}
+
+// TrashItem is a user, device or stream in the trash, as listed to the user that owns it
+type TrashItem struct {
+	Path    string  `json:"path"`
+	Type    string  `json:"type"`    // user, device or stream
+	Deleted float64 `json:"deleted"` // The time at which it was moved to the trash
+	Purge   float64 `json:"purge"`   // The time after which it is deleted for good
+
+	UserID   int64 `json:"-"`
+	DeviceID int64 `json:"-"`
+	StreamID int64 `json:"-"`
+	Public   bool  `json:"-"` // Whether the user or device was public, which matters for permissions
+}
+
+type statement struct {
+	query string
+	args  []interface{}
+}
+
+// execAll runs the statements in a single transaction. The last statement is the one changing the
+// requested row: if it doesn't change anything, the transaction is rolled back, and notfound is returned.
+func (userdb *SqlUserDatabase) execAll(notfound error, statements ...statement) error {
+	tx, err := userdb.DB.Beginx()
+	if err != nil {
+		return err
+	}
+	var result sql.Result
+	for _, s := range statements {
+		result, err = tx.Exec(tx.Rebind(s.query), s.args...)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		tx.Rollback()
+		if err != nil {
+			return err
+		}
+		return notfound
+	}
+	return tx.Commit()
+}
+
+// TrashUser moves the user, along with its devices and streams, to the trash
+func (userdb *SqlUserDatabase) TrashUser(UserID int64, deleted float64) error {
+	return userdb.execAll(ErrUserNotFound,
+		statement{`UPDATE streams SET deleted = ? WHERE deleted = 0 AND deviceid IN
+			(SELECT deviceid FROM devices WHERE userid = ? AND deleted = 0);`, []interface{}{deleted, UserID}},
+		statement{`UPDATE devices SET deleted = ? WHERE userid = ? AND deleted = 0;`, []interface{}{deleted, UserID}},
+		statement{`UPDATE users SET deleted = ? WHERE userid = ? AND deleted = 0;`, []interface{}{deleted, UserID}},
+	)
+}
+
+// TrashDevice moves the device, along with its streams, to the trash
+func (userdb *SqlUserDatabase) TrashDevice(ID int64, deleted float64) error {
+	return userdb.execAll(ErrDeviceNotFound,
+		statement{`UPDATE streams SET deleted = ? WHERE deviceid = ? AND deleted = 0;`, []interface{}{deleted, ID}},
+		statement{`UPDATE devices SET deleted = ? WHERE deviceid = ? AND deleted = 0;`, []interface{}{deleted, ID}},
+	)
+}
+
+// TrashStream moves the stream to the trash
+func (userdb *SqlUserDatabase) TrashStream(ID int64, deleted float64) error {
+	return userdb.execAll(ErrStreamNotFound,
+		statement{`UPDATE streams SET deleted = ? WHERE streamid = ? AND deleted = 0;`, []interface{}{deleted, ID}},
+	)
+}
+
+// RestoreUser takes the user out of the trash, along with the devices and streams that were trashed with it
+func (userdb *SqlUserDatabase) RestoreUser(UserID int64) error {
+	return userdb.execAll(ErrUserNotFound,
+		statement{`UPDATE streams SET deleted = 0 WHERE deleted != 0 AND deleted = (SELECT deleted FROM users WHERE userid = ?)
+			AND deviceid IN (SELECT deviceid FROM devices WHERE userid = ?);`, []interface{}{UserID, UserID}},
+		statement{`UPDATE devices SET deleted = 0 WHERE userid = ? AND deleted != 0
+			AND deleted = (SELECT deleted FROM users WHERE userid = ?);`, []interface{}{UserID, UserID}},
+		statement{`UPDATE users SET deleted = 0 WHERE userid = ? AND deleted != 0;`, []interface{}{UserID}},
+	)
+}
+
+// RestoreDevice takes the device out of the trash, along with the streams that were trashed with it
+func (userdb *SqlUserDatabase) RestoreDevice(ID int64) error {
+	return userdb.execAll(ErrDeviceNotFound,
+		statement{`UPDATE streams SET deleted = 0 WHERE deviceid = ? AND deleted != 0
+			AND deleted = (SELECT deleted FROM devices WHERE deviceid = ?);`, []interface{}{ID, ID}},
+		statement{`UPDATE devices SET deleted = 0 WHERE deviceid = ? AND deleted != 0;`, []interface{}{ID}},
+	)
+}
+
+// RestoreStream takes the stream out of the trash
+func (userdb *SqlUserDatabase) RestoreStream(ID int64) error {
+	return userdb.execAll(ErrStreamNotFound,
+		statement{`UPDATE streams SET deleted = 0 WHERE streamid = ? AND deleted != 0;`, []interface{}{ID}},
+	)
+}
+
+// ReadTrash returns everything of the given user which is in the trash, including the user itself if it was trashed
+func (userdb *SqlUserDatabase) ReadTrash(UserID int64) (*Trash, error) {
+	var t Trash
+	err := userdb.Select(&t.Users, "SELECT * FROM users WHERE userid = ? AND deleted != 0;", UserID)
+	if err == nil {
+		err = userdb.Select(&t.Devices, "SELECT * FROM devices WHERE userid = ? AND deleted != 0;", UserID)
+	}
+	if err == nil {
+		err = userdb.Select(&t.Streams, `SELECT s.* FROM streams s INNER JOIN devices d ON s.deviceid = d.deviceid
+			WHERE d.userid = ? AND s.deleted != 0;`, UserID)
+	}
+	if err == sql.ErrNoRows {
+		err = nil
+	}
+	return &t, err
+}
+
+// ReadTrashedUserByName returns the user with the given name which was moved to the trash last. Names are only
+// unique outside of the trash, so there can be several trashed users with the name.
+func (userdb *SqlUserDatabase) ReadTrashedUserByName(Name string) (*User, error) {
+	var user User
+	err := userdb.Get(&user, "SELECT * FROM users WHERE name = ? AND deleted != 0 ORDER BY deleted DESC LIMIT 1;", Name)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	return &user, err
+}
+
+// ReadTrashBefore returns everything which was moved to the trash before the given time
+func (userdb *SqlUserDatabase) ReadTrashBefore(before float64) (*Trash, error) {
+	var t Trash
+	err := userdb.Select(&t.Users, "SELECT * FROM users WHERE deleted != 0 AND deleted < ?;", before)
+	if err == nil {
+		err = userdb.Select(&t.Devices, "SELECT * FROM devices WHERE deleted != 0 AND deleted < ?;", before)
+	}
+	if err == nil {
+		err = userdb.Select(&t.Streams, "SELECT * FROM streams WHERE deleted != 0 AND deleted < ?;", before)
+	}
+	if err == sql.ErrNoRows {
+		err = nil
+	}
+	return &t, err
+}
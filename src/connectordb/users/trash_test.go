@@ -0,0 +1,117 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package users
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrashStream(t *testing.T) {
+	for _, testdb := range testdatabases {
+		u, d, s, err := CreateUDS(testdb)
+		require.NoError(t, err)
+
+		require.NoError(t, testdb.TrashStream(s.StreamID, 1000))
+		require.Equal(t, ErrStreamNotFound, testdb.TrashStream(s.StreamID, 2000))
+
+		_, err = testdb.ReadStreamByID(s.StreamID)
+		require.Equal(t, ErrStreamNotFound, err)
+		_, err = testdb.ReadStreamByDeviceIDAndName(d.DeviceID, s.Name)
+		require.Equal(t, ErrStreamNotFound, err)
+		streams, err := testdb.ReadStreamsByDevice(d.DeviceID)
+		require.NoError(t, err)
+		require.Len(t, streams, 0)
+
+		trash, err := testdb.ReadTrash(u.UserID)
+		require.NoError(t, err)
+		require.Len(t, trash.Users, 0)
+		require.Len(t, trash.Devices, 0)
+		require.Len(t, trash.Streams, 1)
+		require.Equal(t, s.Name, trash.Streams[0].Name)
+		require.Equal(t, 1000.0, trash.Streams[0].Deleted)
+
+		trash, err = testdb.ReadTrashBefore(1000)
+		require.NoError(t, err)
+		for _, ts := range trash.Streams {
+			require.NotEqual(t, s.StreamID, ts.StreamID)
+		}
+
+		require.NoError(t, testdb.RestoreStream(s.StreamID))
+		require.Equal(t, ErrStreamNotFound, testdb.RestoreStream(s.StreamID))
+
+		s2, err := testdb.ReadStreamByID(s.StreamID)
+		require.NoError(t, err)
+		require.Equal(t, 0.0, s2.Deleted)
+	}
+}
+
+func TestTrashDevice(t *testing.T) {
+	for _, testdb := range testdatabases {
+		u, d, s, err := CreateUDS(testdb)
+		require.NoError(t, err)
+		s2, err := CreateTestStream(testdb, d)
+		require.NoError(t, err)
+
+		// The stream trashed before the device stays in the trash when the device is restored
+		require.NoError(t, testdb.TrashStream(s2.StreamID, 1000))
+		require.NoError(t, testdb.TrashDevice(d.DeviceID, 2000))
+
+		_, err = testdb.ReadDeviceByID(d.DeviceID)
+		require.Equal(t, ErrDeviceNotFound, err)
+		_, err = testdb.ReadDeviceByAPIKey(d.APIKey)
+		require.Equal(t, ErrDeviceNotFound, err)
+		_, err = testdb.ReadStreamByID(s.StreamID)
+		require.Equal(t, ErrStreamNotFound, err)
+
+		trash, err := testdb.ReadTrash(u.UserID)
+		require.NoError(t, err)
+		require.Len(t, trash.Devices, 1)
+		require.Len(t, trash.Streams, 2)
+
+		require.NoError(t, testdb.RestoreDevice(d.DeviceID))
+		_, err = testdb.ReadDeviceByID(d.DeviceID)
+		require.NoError(t, err)
+		_, err = testdb.ReadStreamByID(s.StreamID)
+		require.NoError(t, err)
+		_, err = testdb.ReadStreamByID(s2.StreamID)
+		require.Equal(t, ErrStreamNotFound, err)
+	}
+}
+
+func TestTrashUser(t *testing.T) {
+	for _, testdb := range testdatabases {
+		u, d, s, err := CreateUDS(testdb)
+		require.NoError(t, err)
+
+		require.NoError(t, testdb.TrashUser(u.UserID, 3000))
+		require.Equal(t, ErrUserNotFound, testdb.TrashUser(u.UserID, 3000))
+
+		_, err = testdb.ReadUserByName(u.Name)
+		require.Equal(t, ErrUserNotFound, err)
+		_, _, err = testdb.Login(u.Name, testPassword)
+		require.Error(t, err)
+		_, err = testdb.ReadDeviceByID(d.DeviceID)
+		require.Equal(t, ErrDeviceNotFound, err)
+		streams, err := testdb.ReadStreamsByUser(u.UserID, false, false, false)
+		require.NoError(t, err)
+		require.Len(t, streams, 0)
+
+		trash, err := testdb.ReadTrash(u.UserID)
+		require.NoError(t, err)
+		require.Len(t, trash.Users, 1)
+		require.Len(t, trash.Devices, 3) // user, meta and the test device
+		require.Len(t, trash.Streams, 1)
+
+		require.NoError(t, testdb.RestoreUser(u.UserID))
+		_, _, err = testdb.Login(u.Name, testPassword)
+		require.NoError(t, err)
+		_, err = testdb.ReadStreamByID(s.StreamID)
+		require.NoError(t, err)
+
+		require.Equal(t, ErrUserNotFound, testdb.RestoreUser(u.UserID))
+	}
+}
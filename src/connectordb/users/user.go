@@ -41,6 +41,8 @@ type User struct {
 	PasswordSalt       string `json:"password_salt" permissions:"-"`   // The password salt to be attached to the end of the password
 	PasswordHashScheme string `json:"password_scheme" permissions:"-"` // A string representing the hashing scheme used
 
+	// The time at which the user was moved to the trash, or 0. Trashed users are hidden and can't log in until restored.
+	Deleted float64 `json:"deleted,omitempty" permissions:"-"`
 }
 
 // UserMaker is the structure used to create users
@@ -237,10 +239,10 @@ func (userdb *SqlUserDatabase) CreateUser(um *UserMaker) error {
 		// and the meta device. In other databases this is done automatically through triggers (see dbsetup/dbutil/setup.go)
 		var uid int64
 
-		uidr := userdb.DB.QueryRow("SELECT userid FROM users WHERE name=?;", um.Name)
+		uidr := userdb.DB.QueryRow("SELECT userid FROM users WHERE name=? AND deleted=0;", um.Name)
 		err = uidr.Scan(&uid)
 		if err != nil {
-			userdb.Exec("DELETE FROM users WHERE name=?;", um.Name)
+			userdb.Exec("DELETE FROM users WHERE name=? AND deleted=0;", um.Name)
 			return err
 		}
 
@@ -252,19 +254,19 @@ func (userdb *SqlUserDatabase) CreateUser(um *UserMaker) error {
 		_, err = tx.Exec("INSERT INTO devices (name,userid,apikey, role, description, icon) VALUES ('user',?,?,'user','Holds manually inserted data for the user','material:person');", uid, salt)
 		if err != nil {
 			tx.Rollback()
-			userdb.Exec("DELETE FROM users WHERE name=?;", um.Name)
+			userdb.Exec("DELETE FROM users WHERE name=? AND deleted=0;", um.Name)
 			return err
 		}
 		_, err = tx.Exec("INSERT INTO devices (name, userid, apikey, description, usereditable, isvisible, icon) VALUES ('meta', ?, '','The meta device holds automatically generated streams', 0, 0,'material:bug_report');", uid)
 		if err != nil {
 			tx.Rollback()
-			userdb.Exec("DELETE FROM users WHERE name=?;", um.Name)
+			userdb.Exec("DELETE FROM users WHERE name=? AND deleted=0;", um.Name)
 			return err
 		}
 
 		err = tx.Commit()
 		if err != nil {
-			userdb.Exec("DELETE FROM users WHERE name=?;", um.Name)
+			userdb.Exec("DELETE FROM users WHERE name=? AND deleted=0;", um.Name)
 			return err
 		}
 
@@ -319,7 +321,7 @@ Returns an error along with the user and device if something went wrong
 */
 func (userdb *SqlUserDatabase) Login(Username, Password string) (*User, *Device, error) {
 	user, err := userdb.readByNameOrEmail(Username, Username)
-	if err != nil {
+	if err != nil || user.Deleted != 0 {
 		return nil, nil, ErrLoginFailed
 	}
 
@@ -361,7 +363,7 @@ func (userdb *SqlUserDatabase) readByNameOrEmail(Name, Email string) (*User, err
 func (userdb *SqlUserDatabase) ReadUserByName(Name string) (*User, error) {
 	var user User
 
-	err := userdb.Get(&user, "SELECT * FROM users WHERE name = ? LIMIT 1;", Name)
+	err := userdb.Get(&user, "SELECT * FROM users WHERE name = ? AND deleted = 0 LIMIT 1;", Name)
 
 	if err == sql.ErrNoRows {
 		return nil, ErrUserNotFound
@@ -374,7 +376,7 @@ func (userdb *SqlUserDatabase) ReadUserByName(Name string) (*User, error) {
 // id.
 func (userdb *SqlUserDatabase) ReadUserById(UserID int64) (*User, error) {
 	var user User
-	err := userdb.Get(&user, "SELECT * FROM users WHERE userid = ? LIMIT 1;", UserID)
+	err := userdb.Get(&user, "SELECT * FROM users WHERE userid = ? AND deleted = 0 LIMIT 1;", UserID)
 
 	if err == sql.ErrNoRows {
 		return nil, ErrUserNotFound
@@ -386,7 +388,7 @@ func (userdb *SqlUserDatabase) ReadUserById(UserID int64) (*User, error) {
 func (userdb *SqlUserDatabase) ReadAllUsers() ([]*User, error) {
 	var users []*User
 
-	err := userdb.Select(&users, "SELECT * FROM users")
+	err := userdb.Select(&users, "SELECT * FROM users WHERE deleted = 0;")
 
 	if err == sql.ErrNoRows {
 		return nil, ErrUserNotFound
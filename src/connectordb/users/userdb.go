@@ -48,6 +48,10 @@ func (db *SqlUserDatabase) Clear() {
 	db.Exec("DELETE FROM Users;")
 	db.Exec("DELETE FROM Devices;")
 	db.Exec("DELETE FROM Streams;")
+	db.Exec("DELETE FROM StreamSchemas;")
+	db.Exec("DELETE FROM DownlinkCommands;")
+	db.Exec("DELETE FROM DeviceAliases;")
+	db.Exec("DELETE FROM StreamAliases;")
 }
 
 func NewUserDatabase(sqldb *sqlx.DB, cache bool, cache_timeout int64, usersize int64, devsize int64, streamsize int64) UserDatabase {
@@ -22,6 +22,7 @@ type UserDatabase interface {
 	Login(Username, Password string) (*User, *Device, error)
 	ReadAllUsers() ([]*User, error)
 	ReadDeviceByAPIKey(Key string) (*Device, error)
+	ReadDeviceByWebhookToken(token string) (*Device, error)
 	ReadDeviceByID(DeviceID int64) (*Device, error)
 	ReadDeviceForUserByName(userid int64, devicename string) (*Device, error)
 	ReadDevicesForUserID(UserID int64) ([]*Device, error)
@@ -33,9 +34,39 @@ type UserDatabase interface {
 	ReadUserByName(Name string) (*User, error)
 	ReadUserOperatingDevice(user *User) (*Device, error)
 	UpdateDevice(device *Device) error
+	UpdateDeviceLastSeen(deviceID int64, lastseen float64) error
 	UpdateStream(stream *Stream) error
 	UpdateUser(user *User) error
 
+	// Soft deletion: trashed users, devices and streams are hidden from all reads until restored or purged
+	TrashUser(UserID int64, deleted float64) error
+	TrashDevice(ID int64, deleted float64) error
+	TrashStream(ID int64, deleted float64) error
+	RestoreUser(UserID int64) error
+	RestoreDevice(ID int64) error
+	RestoreStream(ID int64) error
+	ReadTrash(UserID int64) (*Trash, error)
+	ReadTrashBefore(before float64) (*Trash, error)
+	ReadTrashedUserByName(Name string) (*User, error)
+
+	// Renaming devices and moving streams, optionally keeping the old name as an alias, and giving devices to other users
+	MoveDevice(ID int64, name string, alias bool) error
+	MoveStream(ID int64, DeviceID int64, name string, alias bool) error
+	ReadDeviceByAlias(userid int64, name string) (*Device, error)
+	ReadStreamByAlias(DeviceID int64, name string) (*Stream, error)
+	TransferDevice(ID int64, UserID int64) error
+
+	// Schema versions of streams
+	UpdateStreamSchema(stream *Stream, versions ...*StreamSchema) error
+	ReadStreamSchemas(StreamID int64) ([]*StreamSchema, error)
+
+	// Command queues of downlink streams
+	CreateCommand(c *Command) error
+	ReadCommandByID(CommandID int64) (*Command, error)
+	ReadCommands(StreamID int64, status string) ([]*Command, error)
+	UpdateCommand(c *Command) error
+	DeleteCommandsBefore(before float64) (int64, error)
+
 	// Returns the total number of users in the database
 	CountUsers() (int64, error)
 	CountDevices() (int64, error)
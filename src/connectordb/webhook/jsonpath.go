@@ -0,0 +1,130 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package webhook
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pathElement is a single step of a JSONPath: an object key, an array index, or all elements of an array
+type pathElement struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// Path is a parsed JSONPath. The supported subset is the root ($), object keys (.key or ['key']),
+// array indices ([0], negative from the end) and all elements of an array ([*]).
+type Path []pathElement
+
+// ParsePath parses a JSONPath
+func ParsePath(p string) (Path, error) {
+	if !strings.HasPrefix(p, "$") {
+		return nil, fmt.Errorf("The JSONPath '%s' must start with $", p)
+	}
+	var path Path
+	s := p[1:]
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			i := strings.IndexAny(s, ".[")
+			if i == -1 {
+				i = len(s)
+			}
+			if i == 0 {
+				return nil, fmt.Errorf("Empty key in JSONPath '%s'", p)
+			}
+			path = append(path, pathElement{key: s[:i]})
+			s = s[i:]
+		case '[':
+			i := strings.Index(s, "]")
+			if i == -1 {
+				return nil, fmt.Errorf("Unterminated [ in JSONPath '%s'", p)
+			}
+			sel := s[1:i]
+			s = s[i+1:]
+			switch {
+			case sel == "*":
+				path = append(path, pathElement{wildcard: true})
+			case len(sel) >= 2 && (sel[0] == '\'' || sel[0] == '"') && sel[len(sel)-1] == sel[0]:
+				path = append(path, pathElement{key: sel[1 : len(sel)-1]})
+			default:
+				n, err := strconv.Atoi(sel)
+				if err != nil {
+					return nil, fmt.Errorf("Invalid selector [%s] in JSONPath '%s'", sel, p)
+				}
+				path = append(path, pathElement{index: n, isIndex: true})
+			}
+		default:
+			return nil, fmt.Errorf("Invalid JSONPath '%s'", p)
+		}
+	}
+	return path, nil
+}
+
+// HasWildcard returns whether the path selects multiple values
+func (p Path) HasWildcard() bool {
+	for _, e := range p {
+		if e.wildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// Get returns all of the values selected by the path in the given JSON value (as decoded by encoding/json)
+func (p Path) Get(v interface{}) []interface{} {
+	if len(p) == 0 {
+		return []interface{}{v}
+	}
+	e := p[0]
+	switch {
+	case e.wildcard:
+		a, ok := v.([]interface{})
+		if !ok {
+			return nil
+		}
+		var result []interface{}
+		for _, elem := range a {
+			result = append(result, p[1:].Get(elem)...)
+		}
+		return result
+	case e.isIndex:
+		a, ok := v.([]interface{})
+		if !ok {
+			return nil
+		}
+		i := e.index
+		if i < 0 {
+			i += len(a)
+		}
+		if i < 0 || i >= len(a) {
+			return nil
+		}
+		return p[1:].Get(a[i])
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	elem, ok := m[e.key]
+	if !ok {
+		return nil
+	}
+	return p[1:].Get(elem)
+}
+
+// GetOne returns the value selected by a path without wildcards, and whether it exists
+func (p Path) GetOne(v interface{}) (interface{}, bool) {
+	r := p.Get(v)
+	if len(r) == 0 {
+		return nil, false
+	}
+	return r[0], true
+}
@@ -0,0 +1,281 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package webhook
+
+import (
+	"connectordb/datastream"
+	"connectordb/query"
+	"connectordb/users"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/connectordb/pipescript"
+)
+
+// A Rule maps part of a webhook's JSON payload to datapoints of a stream. All of the paths
+// (except Each) are JSONPaths relative to the element that the rule is being applied to.
+type Rule struct {
+	// Each is an optional JSONPath (which may contain [*]) selecting the elements of the payload
+	// that each become a datapoint. By default, the entire payload is a single element.
+	Each string `json:"each,omitempty"`
+
+	// Stream is the name of the stream (in the webhook's device) to which the datapoints are written.
+	// It can contain JSONPaths in braces, such as "{$.sensor}", which are replaced with the value found in the element.
+	Stream string `json:"stream"`
+
+	// Value is the JSONPath of the datapoint's data. Defaults to $, the whole element.
+	Value string `json:"value,omitempty"`
+
+	// Timestamp is the JSONPath of the datapoint's timestamp. If not given, the time at which the
+	// webhook was received is used.
+	Timestamp string `json:"timestamp,omitempty"`
+
+	// TimestampUnit is the unit of numeric timestamps: s (default), ms, us or ns. Timestamps given as strings are
+	// parsed as RFC3339.
+	TimestampUnit string `json:"timestamp_unit,omitempty"`
+
+	// Transform is an optional PipeScript transform run over the datapoints of the stream before insert
+	Transform string `json:"transform,omitempty"`
+
+	each      Path
+	value     Path
+	timestamp Path
+	stream    []streamPart
+	unit      float64
+}
+
+// streamPart is either a literal part of the stream name, or a path whose value is substituted
+type streamPart struct {
+	literal string
+	path    Path
+}
+
+// Rules is the full set of rules of a device's webhook
+type Rules []*Rule
+
+var timestampUnits = map[string]float64{
+	"":   1,
+	"s":  1,
+	"ms": 1e-3,
+	"us": 1e-6,
+	"ns": 1e-9,
+}
+
+var streamPlaceholder = regexp.MustCompile(`\{[^}]*\}`)
+
+// ErrNoRules is returned when a device's webhook has no rules
+var ErrNoRules = errors.New("The webhook has no rules")
+
+// ParseRules parses and validates the JSON array of rules stored in a device
+func ParseRules(s string) (Rules, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, ErrNoRules
+	}
+	var r Rules
+	if err := json.Unmarshal([]byte(s), &r); err != nil {
+		return nil, fmt.Errorf("Could not parse webhook rules: %s", err.Error())
+	}
+	if len(r) == 0 {
+		return nil, ErrNoRules
+	}
+	for i := range r {
+		if r[i] == nil {
+			return nil, fmt.Errorf("Webhook rule %d is empty", i)
+		}
+		if err := r[i].compile(); err != nil {
+			return nil, fmt.Errorf("Webhook rule %d: %s", i, err.Error())
+		}
+	}
+	return r, nil
+}
+
+// compile parses the paths and checks the rule for validity
+func (r *Rule) compile() (err error) {
+	if r.Stream == "" {
+		return errors.New("No stream was given")
+	}
+	if r.Each != "" {
+		if r.each, err = ParsePath(r.Each); err != nil {
+			return err
+		}
+	}
+	if r.Value == "" {
+		r.value = Path{}
+	} else if r.value, err = ParsePath(r.Value); err != nil {
+		return err
+	}
+	if r.Timestamp != "" {
+		if r.timestamp, err = ParsePath(r.Timestamp); err != nil {
+			return err
+		}
+	}
+	if r.value.HasWildcard() || r.timestamp.HasWildcard() {
+		return errors.New("Only the each path can contain [*]")
+	}
+	unit, ok := timestampUnits[r.TimestampUnit]
+	if !ok {
+		return fmt.Errorf("Unknown timestamp unit '%s'", r.TimestampUnit)
+	}
+	r.unit = unit
+	if r.Transform != "" {
+		if _, err = pipescript.Parse(r.Transform); err != nil {
+			return err
+		}
+	}
+
+	r.stream = nil
+	last := 0
+	for _, loc := range streamPlaceholder.FindAllStringIndex(r.Stream, -1) {
+		if loc[0] > last {
+			r.stream = append(r.stream, streamPart{literal: r.Stream[last:loc[0]]})
+		}
+		p, err := ParsePath(r.Stream[loc[0]+1 : loc[1]-1])
+		if err != nil {
+			return err
+		}
+		if p.HasWildcard() {
+			return errors.New("Only the each path can contain [*]")
+		}
+		r.stream = append(r.stream, streamPart{path: p})
+		last = loc[1]
+	}
+	if last < len(r.Stream) {
+		r.stream = append(r.stream, streamPart{literal: r.Stream[last:]})
+	}
+	return nil
+}
+
+// streamName returns the name of the stream for the given element. The placeholders come from the payload,
+// so the name is checked to be a valid stream name, which can't reach outside of the webhook's device.
+func (r *Rule) streamName(elem interface{}) (string, error) {
+	name := ""
+	for _, p := range r.stream {
+		if p.path == nil {
+			name += p.literal
+			continue
+		}
+		v, ok := p.path.GetOne(elem)
+		if !ok {
+			return "", errors.New("The stream name placeholder was not found")
+		}
+		value := ""
+		switch s := v.(type) {
+		case string:
+			value = s
+		case float64:
+			value = strconv.FormatFloat(s, 'f', -1, 64)
+		case bool:
+			value = strconv.FormatBool(s)
+		default:
+			return "", errors.New("The stream name placeholder is not a string or number")
+		}
+		if strings.Contains(value, "/") {
+			return "", fmt.Errorf("The placeholder value '%s' can't be used in a stream name", value)
+		}
+		name += value
+	}
+	if !users.IsValidName(name) {
+		return "", fmt.Errorf("'%s' is not a valid stream name", name)
+	}
+	return name, nil
+}
+
+// time returns the timestamp of the given element
+func (r *Rule) time(elem interface{}, now time.Time) (float64, error) {
+	if r.timestamp == nil {
+		return float64(now.UnixNano()) * 1e-9, nil
+	}
+	v, ok := r.timestamp.GetOne(elem)
+	if !ok {
+		return 0, errors.New("The timestamp was not found")
+	}
+	switch t := v.(type) {
+	case float64:
+		return t * r.unit, nil
+	case string:
+		if f, err := strconv.ParseFloat(t, 64); err == nil {
+			return f * r.unit, nil
+		}
+		tm, err := time.Parse(time.RFC3339Nano, t)
+		if err != nil {
+			return 0, fmt.Errorf("Could not parse timestamp '%s'", t)
+		}
+		return float64(tm.UnixNano()) * 1e-9, nil
+	}
+	return 0, errors.New("The timestamp is not a number or string")
+}
+
+// Apply applies the rule to the payload, appending the resulting datapoints to the given map of streams.
+// Elements which do not contain the value are skipped. Errors for individual elements are returned,
+// and do not stop the rest of the payload from being processed.
+func (r *Rule) Apply(payload interface{}, now time.Time, result map[string]datastream.DatapointArray) (errs []error) {
+	elements := []interface{}{payload}
+	if r.each != nil {
+		elements = r.each.Get(payload)
+	}
+	for _, elem := range elements {
+		v, ok := r.value.GetOne(elem)
+		if !ok || v == nil {
+			continue
+		}
+		stream, err := r.streamName(elem)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ts, err := r.time(elem, now)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		result[stream] = append(result[stream], datastream.Datapoint{Timestamp: ts, Data: v})
+	}
+	return errs
+}
+
+// transform runs the rule's transform over the given datapoints
+func (r *Rule) transform(dpa datastream.DatapointArray) (datastream.DatapointArray, error) {
+	if r.Transform == "" {
+		return dpa, nil
+	}
+	// Scripts hold state, so each run gets its own
+	s, err := pipescript.Parse(r.Transform)
+	if err != nil {
+		return nil, err
+	}
+	res, err := query.TransformArray(s, &dpa)
+	if err != nil {
+		return nil, err
+	}
+	return *res, nil
+}
+
+// Apply runs all of the rules on the payload, returning the datapoints to insert into each stream.
+// The datapoints of each stream are sorted by timestamp.
+func (rs Rules) Apply(payload interface{}, now time.Time) (map[string]datastream.DatapointArray, []error) {
+	result := make(map[string]datastream.DatapointArray)
+	var errs []error
+	for _, r := range rs {
+		current := make(map[string]datastream.DatapointArray)
+		errs = append(errs, r.Apply(payload, now, current)...)
+		for stream, dpa := range current {
+			dpa, err := r.transform(dpa)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("Transform of stream '%s' failed: %s", stream, err.Error()))
+				continue
+			}
+			result[stream] = append(result[stream], dpa...)
+		}
+	}
+	for _, dpa := range result {
+		dpa.SortByTime()
+	}
+	return result, errs
+}
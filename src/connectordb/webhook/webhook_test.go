@@ -0,0 +1,136 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package webhook
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func decode(t *testing.T, s string) interface{} {
+	var v interface{}
+	require.NoError(t, json.Unmarshal([]byte(s), &v))
+	return v
+}
+
+func TestPath(t *testing.T) {
+	v := decode(t, `{"a": {"b c": [1, 2, {"d": true}]}, "e": [{"f": 1}, {"f": 2}, {"g": 3}]}`)
+
+	p, err := ParsePath("$.a['b c'][2].d")
+	require.NoError(t, err)
+	r, ok := p.GetOne(v)
+	require.True(t, ok)
+	require.Equal(t, true, r)
+
+	p, err = ParsePath("$.a[\"b c\"][-1]")
+	require.NoError(t, err)
+	r, ok = p.GetOne(v)
+	require.True(t, ok)
+	require.Equal(t, map[string]interface{}{"d": true}, r)
+
+	p, err = ParsePath("$.e[*].f")
+	require.NoError(t, err)
+	require.True(t, p.HasWildcard())
+	require.Equal(t, []interface{}{1.0, 2.0}, p.Get(v))
+
+	p, err = ParsePath("$.a.x")
+	require.NoError(t, err)
+	_, ok = p.GetOne(v)
+	require.False(t, ok)
+
+	p, err = ParsePath("$")
+	require.NoError(t, err)
+	r, ok = p.GetOne(v)
+	require.True(t, ok)
+	require.Equal(t, v, r)
+
+	for _, s := range []string{"a.b", "$..b", "$[1", "$[x]", "$a"} {
+		_, err = ParsePath(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	_, err := ParseRules("")
+	require.Equal(t, ErrNoRules, err)
+	_, err = ParseRules("[]")
+	require.Equal(t, ErrNoRules, err)
+	_, err = ParseRules("{")
+	require.Error(t, err)
+	_, err = ParseRules(`[{"value": "$.x"}]`)
+	require.Error(t, err)
+	_, err = ParseRules(`[{"stream": "s", "value": "$.x[*]"}]`)
+	require.Error(t, err)
+	_, err = ParseRules(`[{"stream": "s", "timestamp_unit": "days"}]`)
+	require.Error(t, err)
+	_, err = ParseRules(`[{"stream": "{x}"}]`)
+	require.Error(t, err)
+
+	r, err := ParseRules(`[{"stream": "s"}, {"each": "$.a[*]", "stream": "t_{$.name}", "value": "$.v", "timestamp": "$.t", "timestamp_unit": "ms"}]`)
+	require.NoError(t, err)
+	require.Len(t, r, 2)
+}
+
+func TestApply(t *testing.T) {
+	r, err := ParseRules(`[
+		{"stream": "temperature", "value": "$.temp", "timestamp": "$.time"},
+		{"each": "$.readings[*]", "stream": "sensor_{$.id}", "value": "$.v", "timestamp": "$.t", "timestamp_unit": "ms"}
+	]`)
+	require.NoError(t, err)
+
+	now := time.Unix(1000, 0)
+	data, errs := r.Apply(decode(t, `{
+		"temp": 21.5,
+		"time": "2016-01-01T00:00:00Z",
+		"readings": [
+			{"id": "a", "v": 2, "t": 2000},
+			{"id": "a", "v": 1, "t": 1000},
+			{"id": 3, "v": "hi", "t": "3000"},
+			{"id": "b", "t": 4000},
+			{"v": 5, "t": 5000},
+			{"id": "c", "v": 6, "t": "yesterday"},
+			{"id": "x/downlink", "v": 7, "t": 7000},
+			{"id": "x y", "v": 8, "t": 8000}
+		]
+	}`), now)
+	require.Len(t, errs, 4)
+	require.Len(t, data, 3)
+
+	require.Len(t, data["temperature"], 1)
+	require.Equal(t, 21.5, data["temperature"][0].Data)
+	require.Equal(t, 1451606400.0, data["temperature"][0].Timestamp)
+
+	require.Len(t, data["sensor_a"], 2)
+	require.Equal(t, 1.0, data["sensor_a"][0].Timestamp)
+	require.Equal(t, 1.0, data["sensor_a"][0].Data)
+	require.Equal(t, 2.0, data["sensor_a"][1].Timestamp)
+
+	require.Len(t, data["sensor_3"], 1)
+	require.Equal(t, "hi", data["sensor_3"][0].Data)
+	require.Equal(t, 3.0, data["sensor_3"][0].Timestamp)
+
+	// Without a timestamp path, the time of receipt is used
+	r, err = ParseRules(`[{"stream": "raw"}]`)
+	require.NoError(t, err)
+	data, errs = r.Apply(decode(t, `{"x": 1}`), now)
+	require.Len(t, errs, 0)
+	require.Len(t, data["raw"], 1)
+	require.InDelta(t, 1000.0, data["raw"][0].Timestamp, 1e-6)
+	require.Equal(t, map[string]interface{}{"x": 1.0}, data["raw"][0].Data)
+}
+
+func TestTransform(t *testing.T) {
+	r, err := ParseRules(`[{"each": "$[*]", "stream": "s", "value": "$.v", "transform": "$ > 1"}]`)
+	require.NoError(t, err)
+
+	data, errs := r.Apply(decode(t, `[{"v": 1}, {"v": 2}]`), time.Unix(0, 0))
+	require.Len(t, errs, 0)
+	require.Len(t, data["s"], 2)
+	require.Equal(t, false, data["s"][0].Data)
+	require.Equal(t, true, data["s"][1].Data)
+}
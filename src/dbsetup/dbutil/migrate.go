@@ -0,0 +1,265 @@
+package dbutil
+
+import (
+	"errors"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/jmoiron/sqlx"
+)
+
+// DBVersion is the version of the database schema used by this version of ConnectorDB
+const DBVersion = "20261102"
+
+// ErrIncompatibleDatabase is returned when the database has a version which can't be migrated to DBVersion
+var ErrIncompatibleDatabase = errors.New("The existing database is incompatible with this version of ConnectorDB")
+
+// A migration upgrades the database schema from one version to the next. The schema is a template
+// rendered the same way as dbSchema.
+type migration struct {
+	To     string
+	Schema string
+}
+
+// migrations holds the migration from each past version of the database
+var migrations = map[string]migration{
+	"20160820": {"20261019", migrate20160820},
+	"20261019": {"20261020", migrate20261019},
+	"20261020": {"20261021", migrate20261020},
+	"20261021": {"20261022", migrate20261021},
+	"20261022": {"20261023", migrate20261022},
+	"20261023": {"20261024", migrate20261023},
+	"20261024": {"20261025", migrate20261024},
+	"20261025": {"20261030", migrate20261025},
+	"20261030": {DBVersion, migrate20261030},
+}
+
+// migrate20160820 adds stream schema versions
+const migrate20160820 = `
+ALTER TABLE streams ADD COLUMN schemaversion INTEGER DEFAULT 0;
+
+CREATE TABLE streamschemas (
+	streamid INTEGER NOT NULL,
+	version INTEGER NOT NULL,
+	schema VARCHAR NOT NULL,
+	upgrade VARCHAR DEFAULT '',
+	startindex BIGINT DEFAULT 0,
+	downlinkindex BIGINT DEFAULT 0,
+	PRIMARY KEY (streamid, version),
+	FOREIGN KEY(streamid) REFERENCES streams(streamid) ON DELETE CASCADE);
+`
+
+// migrate20261019 adds the command queues of downlink streams
+const migrate20261019 = `
+CREATE TABLE downlinkcommands (
+	commandid {{.pkey_exp}},
+	streamid INTEGER NOT NULL,
+	data VARCHAR NOT NULL,
+	sender VARCHAR DEFAULT '',
+	status VARCHAR NOT NULL,
+	result VARCHAR DEFAULT '',
+	timestamp DOUBLE PRECISION,
+	expires DOUBLE PRECISION DEFAULT 0,
+	updated DOUBLE PRECISION,
+	FOREIGN KEY(streamid) REFERENCES streams(streamid) ON DELETE CASCADE);
+
+CREATE INDEX DownlinkCommandStreamIndex ON downlinkcommands (streamid, status);
+`
+
+// migrate20261020 adds the time at which devices were last seen
+const migrate20261020 = `
+ALTER TABLE devices ADD COLUMN lastseen DOUBLE PRECISION DEFAULT 0;
+`
+
+// migrate20261021 lets streams accept backfilled datapoints
+const migrate20261021 = `
+ALTER TABLE streams ADD COLUMN backfill BOOLEAN DEFAULT FALSE;
+`
+
+// migrate20261022 lets devices create streams on their first insert
+const migrate20261022 = `
+ALTER TABLE devices ADD COLUMN autocreatestreams BOOLEAN DEFAULT FALSE;
+`
+
+// migrate20261023 adds the mapping of influx measurements to the streams of devices
+const migrate20261023 = `
+ALTER TABLE devices ADD COLUMN influxmapping VARCHAR DEFAULT '';
+`
+
+// migrate20261024 adds the tokens and mapping rules of device webhooks
+const migrate20261024 = `
+ALTER TABLE devices ADD COLUMN webhooktoken VARCHAR DEFAULT '';
+ALTER TABLE devices ADD COLUMN webhookrules VARCHAR DEFAULT '';
+
+CREATE UNIQUE INDEX DeviceWebhookIndex ON devices (webhooktoken) WHERE webhooktoken!='';
+`
+
+// migrate20261025 adds the trash. Names are made unique only among the users, devices and streams
+// outside of the trash.
+const migrate20261025 = `
+ALTER TABLE users ADD COLUMN deleted DOUBLE PRECISION DEFAULT 0;
+
+ALTER TABLE devices ADD COLUMN deleted DOUBLE PRECISION DEFAULT 0;
+
+ALTER TABLE streams ADD COLUMN deleted DOUBLE PRECISION DEFAULT 0;
+
+-- Names only need to be unique outside of the trash, so that the names of deleted things can be used again
+{{if .postgres}}
+ALTER TABLE users DROP CONSTRAINT users_name_key;
+ALTER TABLE users DROP CONSTRAINT users_email_key;
+ALTER TABLE devices DROP CONSTRAINT devices_userid_name_key;
+ALTER TABLE streams DROP CONSTRAINT streams_name_deviceid_key;
+DROP INDEX UserNameIndex;
+{{else}}
+-- SQLite can't drop constraints, so the tables are copied into new ones without them. The autoincrement
+-- counters are moved to the new tables, so that the ids of purged rows are not given out again.
+CREATE TABLE users_new (
+	userid {{.pkey_exp}},
+	name VARCHAR NOT NULL,
+	nickname VARCHAR DEFAULT '',
+	email VARCHAR NOT NULL,
+	description VARCHAR(1000) DEFAULT '',
+	icon		VARCHAR(4096) DEFAULT '', -- DATA URI
+
+	public BOOLEAN DEFAULT FALSE,
+	role VARCHAR NOT NULL,
+
+	password VARCHAR NOT NULL,
+	passwordsalt VARCHAR NOT NULL,
+	passwordhashscheme VARCHAR NOT NULL,
+	deleted DOUBLE PRECISION DEFAULT 0);
+
+INSERT INTO users_new (userid, name, nickname, email, description, icon, public, role, password, passwordsalt, passwordhashscheme, deleted)
+	SELECT userid, name, nickname, email, description, icon, public, role, password, passwordsalt, passwordhashscheme, deleted FROM users;
+
+CREATE TABLE devices_new (
+	deviceid {{.pkey_exp}},
+	name VARCHAR NOT NULL,
+	nickname VARCHAR DEFAULT '',
+	description VARCHAR(1000) DEFAULT '',
+	icon		VARCHAR(4096) DEFAULT '', -- DATA URI
+
+	userid INTEGER,
+	apikey VARCHAR NOT NULL,
+	enabled BOOLEAN DEFAULT TRUE,
+
+	public BOOLEAN DEFAULT FALSE,
+
+	role VARCHAR DEFAULT '',
+
+	isvisible BOOLEAN DEFAULT TRUE,
+	usereditable BOOLEAN DEFAULT TRUE,
+	lastseen DOUBLE PRECISION DEFAULT 0,
+	autocreatestreams BOOLEAN DEFAULT FALSE,
+	influxmapping VARCHAR DEFAULT '',
+	webhooktoken VARCHAR DEFAULT '',
+	webhookrules VARCHAR DEFAULT '',
+	deleted DOUBLE PRECISION DEFAULT 0,
+	FOREIGN KEY(userid) REFERENCES users(userid) ON DELETE CASCADE);
+
+INSERT INTO devices_new (deviceid, name, nickname, description, icon, userid, apikey, enabled, public, role, isvisible,
+		usereditable, lastseen, autocreatestreams, influxmapping, webhooktoken, webhookrules, deleted)
+	SELECT deviceid, name, nickname, description, icon, userid, apikey, enabled, public, role, isvisible,
+		usereditable, lastseen, autocreatestreams, influxmapping, webhooktoken, webhookrules, deleted FROM devices;
+
+CREATE TABLE streams_new (
+	streamid {{.pkey_exp}},
+	name VARCHAR NOT NULL,
+	nickname VARCHAR NOT NULL DEFAULT '',
+	description VARCHAR(1000) DEFAULT '',
+	icon		VARCHAR(4096) DEFAULT '',
+	schema VARCHAR NOT NULL,
+	datatype VARCHAR DEFAULT '',
+	deviceid INTEGER,
+	ephemeral BOOLEAN DEFAULT FALSE,
+	downlink BOOLEAN DEFAULT FALSE,
+	backfill BOOLEAN DEFAULT FALSE,
+	schemaversion INTEGER DEFAULT 0,
+	deleted DOUBLE PRECISION DEFAULT 0,
+	FOREIGN KEY(deviceid) REFERENCES devices(deviceid) ON DELETE CASCADE);
+
+INSERT INTO streams_new (streamid, name, nickname, description, icon, schema, datatype, deviceid, ephemeral, downlink,
+		backfill, schemaversion, deleted)
+	SELECT streamid, name, nickname, description, icon, schema, datatype, deviceid, ephemeral, downlink,
+		backfill, schemaversion, deleted FROM streams;
+
+DELETE FROM sqlite_sequence WHERE name IN ('users_new', 'devices_new', 'streams_new');
+UPDATE sqlite_sequence SET name = name || '_new' WHERE name IN ('users', 'devices', 'streams');
+
+DROP TABLE users;
+DROP TABLE devices;
+DROP TABLE streams;
+ALTER TABLE users_new RENAME TO users;
+ALTER TABLE devices_new RENAME TO devices;
+ALTER TABLE streams_new RENAME TO streams;
+
+CREATE INDEX DeviceNameIndex ON devices (name);
+CREATE UNIQUE INDEX DeviceAPIIndex ON devices (apikey) WHERE apikey!='';
+CREATE INDEX DeviceUserIndex ON devices (userid);
+CREATE UNIQUE INDEX DeviceWebhookIndex ON devices (webhooktoken) WHERE webhooktoken!='';
+CREATE INDEX StreamNameIndex ON streams (name);
+CREATE INDEX StreamDeviceIndex ON streams (deviceid);
+{{end}}
+CREATE UNIQUE INDEX UserNameIndex ON users (name) WHERE deleted = 0;
+CREATE UNIQUE INDEX UserEmailIndex ON users (email) WHERE deleted = 0;
+CREATE UNIQUE INDEX DeviceUserNameIndex ON devices (userid, name) WHERE deleted = 0;
+CREATE UNIQUE INDEX StreamDeviceNameIndex ON streams (deviceid, name) WHERE deleted = 0;
+`
+
+// migrate20261030 adds the aliases of renamed devices and moved streams
+const migrate20261030 = `
+-- The old names of renamed devices and moved streams, which keep resolving to them
+CREATE TABLE devicealiases (
+	userid INTEGER NOT NULL,
+	name VARCHAR NOT NULL,
+	deviceid INTEGER NOT NULL,
+	created DOUBLE PRECISION,
+	PRIMARY KEY (userid, name),
+	FOREIGN KEY(deviceid) REFERENCES devices(deviceid) ON DELETE CASCADE);
+
+CREATE TABLE streamaliases (
+	deviceid INTEGER NOT NULL,
+	name VARCHAR NOT NULL,
+	streamid INTEGER NOT NULL,
+	created DOUBLE PRECISION,
+	PRIMARY KEY (deviceid, name),
+	FOREIGN KEY(deviceid) REFERENCES devices(deviceid) ON DELETE CASCADE,
+	FOREIGN KEY(streamid) REFERENCES streams(streamid) ON DELETE CASCADE);
+`
+
+// migrate upgrades the database to DBVersion. Each migration is run in a transaction along with the
+// change of the version, so a failed migration leaves the database as it was.
+func migrate(db *sqlx.DB, dbtype string) error {
+	var version string
+	if err := db.Get(&version, "SELECT Value FROM connectordbmeta WHERE Key='DBVersion';"); err != nil {
+		return err
+	}
+	for version != DBVersion {
+		m, ok := migrations[version]
+		if !ok {
+			return ErrIncompatibleDatabase
+		}
+		log.Infof("Migrating %s database from version %s to %s", dbtype, version, m.To)
+
+		schema, err := renderSchema(dbtype, m.Schema)
+		if err != nil {
+			return err
+		}
+		tx, err := db.Beginx()
+		if err != nil {
+			return err
+		}
+		if _, err = tx.Exec(schema); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if _, err = tx.Exec(tx.Rebind("UPDATE connectordbmeta SET Value=? WHERE Key='DBVersion';"), m.To); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+		version = m.To
+	}
+	return nil
+}
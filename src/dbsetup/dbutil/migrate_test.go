@@ -0,0 +1,95 @@
+package dbutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+// createV1Database creates a database with the schema of the first version, holding a user, device and stream
+func createV1Database(t *testing.T, uri string) {
+	db, err := sqlx.Open("sqlite3", uri)
+	require.NoError(t, err)
+	defer db.Close()
+	schema, err := getSchemaString("sqlite3")
+	require.NoError(t, err)
+	_, err = db.Exec(schema)
+	require.NoError(t, err)
+
+	_, err = db.Exec(`INSERT INTO users (name, email, role, password, passwordsalt, passwordhashscheme) VALUES ('myuser','my@email','user','','','');
+		INSERT INTO devices (name, userid, apikey) VALUES ('mydevice', 1, 'key');
+		INSERT INTO streams (name, schema, deviceid) VALUES ('mystream', '{}', 1);`)
+	require.NoError(t, err)
+}
+
+func TestMigrate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrate")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	uri := filepath.Join(dir, "v1.db")
+
+	createV1Database(t, uri)
+	db, err := OpenDatabase("sqlite3", uri)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var version string
+	require.NoError(t, db.Get(&version, "SELECT Value FROM connectordbmeta WHERE Key='DBVersion';"))
+	require.Equal(t, DBVersion, version)
+
+	// The existing rows get the defaults of the new columns, and the new tables exist
+	var deleted float64
+	var backfill bool
+	require.NoError(t, db.Get(&deleted, "SELECT deleted FROM devices WHERE name='mydevice';"))
+	require.NoError(t, db.Get(&backfill, "SELECT backfill FROM streams WHERE name='mystream';"))
+	require.Equal(t, 0.0, deleted)
+	require.False(t, backfill)
+	_, err = db.Exec("INSERT INTO streamschemas (streamid, version, schema) VALUES (1, 1, '{}');")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO devicealiases (userid, name, deviceid) VALUES (1, 'old', 1);")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO streamaliases (deviceid, name, streamid) VALUES (1, 'old', 1);")
+	require.NoError(t, err)
+
+	// Names are only unique outside of the trash
+	_, err = db.Exec("UPDATE users SET deleted=1 WHERE name='myuser';")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO users (name, email, role, password, passwordsalt, passwordhashscheme) VALUES ('myuser','my@email','user','','','');")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO users (name, email, role, password, passwordsalt, passwordhashscheme) VALUES ('myuser','other@email','user','','','');")
+	require.Error(t, err)
+	_, err = db.Exec("INSERT INTO streams (name, schema, deviceid) VALUES ('mystream', '{}', 1);")
+	require.Error(t, err)
+
+	// A new database has the same schema
+	uri2 := filepath.Join(dir, "new.db")
+	require.NoError(t, SetupDatabase("sqlite3", uri2))
+	db2, err := OpenDatabase("sqlite3", uri2)
+	require.NoError(t, err)
+	defer db2.Close()
+	require.NoError(t, db2.Get(&version, "SELECT Value FROM connectordbmeta WHERE Key='DBVersion';"))
+	require.Equal(t, DBVersion, version)
+
+	// Databases of unknown versions are not touched
+	_, err = db2.Exec("UPDATE connectordbmeta SET Value='20000101' WHERE Key='DBVersion';")
+	require.NoError(t, err)
+	_, err = OpenDatabase("sqlite3", uri2)
+	require.Equal(t, ErrIncompatibleDatabase, err)
+}
+
+func TestMigrationChain(t *testing.T) {
+	// Every version reached by a migration is either the current version, or has a migration of its own
+	for from, m := range migrations {
+		require.NotEqual(t, from, m.To)
+		if m.To != DBVersion {
+			_, ok := migrations[m.To]
+			require.True(t, ok, "no migration from version %s", m.To)
+		}
+	}
+	_, ok := migrations[DBVersion]
+	require.False(t, ok)
+}
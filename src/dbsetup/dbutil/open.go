@@ -1,13 +1,11 @@
 package dbutil
 
 import (
-	"errors"
-
 	log "github.com/Sirupsen/logrus"
 	"github.com/jmoiron/sqlx"
 )
 
-// OpenDatabase opens an alread-created database
+// OpenDatabase opens an alread-created database, migrating it to the current version if it is older
 func OpenDatabase(dbtype, uri string) (*sqlx.DB, error) {
 	log.Debugf("Opening %s database at %s", dbtype, uri)
 	db, err := sqlx.Open(dbtype, uri)
@@ -15,18 +13,10 @@ func OpenDatabase(dbtype, uri string) (*sqlx.DB, error) {
 		return nil, err
 	}
 
-	// Now let's query the database version to make sure we can read it!
-	var version string
-	row := db.QueryRowx("SELECT Value FROM connectordbmeta WHERE Key='DBVersion';")
-	if row.Err() != nil {
-		return nil, row.Err()
-	}
-	err = row.Scan(&version)
-	if err != nil {
+	// Now let's make sure that we can read the database
+	if err = migrate(db, dbtype); err != nil {
+		db.Close()
 		return nil, err
 	}
-	if version != "20160820" {
-		return nil, errors.New("The existing database is incompatible with this version of ConnectorDB")
-	}
 	return db, nil
 }
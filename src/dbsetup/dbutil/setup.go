@@ -2,8 +2,8 @@ package dbutil
 
 import (
 	"bytes"
-	"html/template"
 	"os"
+	"text/template"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/jmoiron/sqlx"
@@ -12,7 +12,8 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
-// This is the relevant schema used in ConnectorDB.
+// This is the schema of the first version of the database. Databases are then migrated to the current
+// version (see migrate.go), so that new and existing databases end up with the same schema.
 const dbSchema = `
 
 CREATE TABLE connectordbmeta (
@@ -126,19 +127,25 @@ SET search_path = public;
 `
 
 func getSchemaString(dbtype string) (string, error) {
+	return renderSchema(dbtype, dbSchema)
+}
+
+// renderSchema fills in the database-specific parts of the given schema template
+func renderSchema(dbtype string, schema string) (string, error) {
 	templateParams := make(map[string]string)
 	if dbtype == "postgres" {
+		templateParams["postgres"] = "true"
 		templateParams["pkey_exp"] = "SERIAL PRIMARY KEY"
 	} else {
 		templateParams["pkey_exp"] = "INTEGER PRIMARY KEY AUTOINCREMENT"
 	}
-	schemaTemplate, err := template.New("dbschema").Parse(dbSchema)
+	schemaTemplate, err := template.New("dbschema").Parse(schema)
 	if err != nil {
 		return "", err
 	}
 	var doc bytes.Buffer
-	schemaTemplate.Execute(&doc, templateParams)
-	return doc.String(), nil
+	err = schemaTemplate.Execute(&doc, templateParams)
+	return doc.String(), err
 }
 
 // SetupDatabase creates the ConnectorDB database schema
@@ -162,9 +169,13 @@ func SetupDatabase(dbtype, uri string) error {
 
 	// If it is a postgres database, set up the built-in functions
 	if dbtype == "postgres" {
-		_, err = db.Exec(postgresFunctions)
+		if _, err = db.Exec(postgresFunctions); err != nil {
+			db.Close()
+			return err
+		}
 	}
 
+	err = migrate(db, dbtype)
 	db.Close()
 
 	return err
@@ -0,0 +1,110 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package metrics
+
+import (
+	"connectordb/datastream"
+	"math"
+	"sort"
+	"sync"
+)
+
+// Aggregator combines the metrics received during a flush interval
+type Aggregator struct {
+	sync.Mutex
+
+	counters map[string]float64
+	timers   map[string][]float64
+
+	// Gauges keep their value between flushes (so that deltas can be applied), but are only written when changed
+	gauges  map[string]float64
+	changed map[string]bool
+
+	// Gauge values which came with a timestamp are written at that time
+	timed map[string]datastream.DatapointArray
+}
+
+// NewAggregator creates an empty aggregator
+func NewAggregator() *Aggregator {
+	return &Aggregator{
+		counters: make(map[string]float64),
+		timers:   make(map[string][]float64),
+		gauges:   make(map[string]float64),
+		changed:  make(map[string]bool),
+		timed:    make(map[string]datastream.DatapointArray),
+	}
+}
+
+// Add adds a metric to the current flush interval
+func (a *Aggregator) Add(m Metric) {
+	a.Lock()
+	defer a.Unlock()
+	switch m.Type {
+	case Counter:
+		a.counters[m.Name] += m.Value / m.SampleRate
+	case Gauge:
+		if m.Delta {
+			a.gauges[m.Name] += m.Value
+		} else {
+			a.gauges[m.Name] = m.Value
+		}
+		if m.Timestamp != 0 {
+			a.timed[m.Name] = append(a.timed[m.Name], datastream.Datapoint{Timestamp: m.Timestamp, Data: a.gauges[m.Name]})
+		} else {
+			a.changed[m.Name] = true
+		}
+	case Timer:
+		a.timers[m.Name] = append(a.timers[m.Name], m.Value)
+	}
+}
+
+// summarize returns the statistics of a timer's values
+func summarize(values []float64) map[string]interface{} {
+	sort.Float64s(values)
+	sum := 0.
+	for _, v := range values {
+		sum += v
+	}
+	n := len(values)
+	return map[string]interface{}{
+		"count":  n,
+		"sum":    sum,
+		"min":    values[0],
+		"max":    values[n-1],
+		"mean":   sum / float64(n),
+		"median": values[n/2],
+		"p90":    values[int(math.Ceil(0.9*float64(n)))-1],
+	}
+}
+
+// Flush returns the datapoints of each metric which was received since the previous flush, ordered by time,
+// and starts a new flush interval. Counters are numbers, gauges are their current values, and timers are
+// objects of statistics of the interval's values, all at the given time. Gauge values with their own timestamp
+// are returned as they were received.
+func (a *Aggregator) Flush(t float64) map[string]datastream.DatapointArray {
+	a.Lock()
+	defer a.Unlock()
+
+	result := make(map[string]datastream.DatapointArray, len(a.counters)+len(a.changed)+len(a.timers)+len(a.timed))
+	for name, v := range a.counters {
+		result[name] = append(result[name], datastream.Datapoint{Timestamp: t, Data: v})
+	}
+	for name := range a.changed {
+		result[name] = append(result[name], datastream.Datapoint{Timestamp: t, Data: a.gauges[name]})
+	}
+	for name, v := range a.timers {
+		result[name] = append(result[name], datastream.Datapoint{Timestamp: t, Data: summarize(v)})
+	}
+	for name, dpa := range a.timed {
+		result[name] = append(result[name], dpa...)
+		result[name].SortByTime()
+	}
+
+	a.counters = make(map[string]float64)
+	a.timers = make(map[string][]float64)
+	a.changed = make(map[string]bool)
+	a.timed = make(map[string]datastream.DatapointArray)
+	return result
+}
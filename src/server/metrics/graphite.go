@@ -0,0 +1,33 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package metrics
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseGraphite parses a line of the Graphite plaintext protocol:
+//	metric.path value [timestamp]
+// Graphite metrics are gauges, which are written with their timestamp in seconds. Values without a timestamp
+// (or with a timestamp of -1, which Graphite takes to be the time the value was received) are written at the next flush.
+func ParseGraphite(line string) ([]Metric, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || len(fields) > 3 {
+		return nil, fmt.Errorf("Invalid Graphite metric '%s'", line)
+	}
+	v, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid value of Graphite metric '%s'", line)
+	}
+	m := Metric{Name: fields[0], Type: Gauge, Value: v, SampleRate: 1}
+	if len(fields) == 3 && fields[2] != "-1" {
+		if m.Timestamp, err = strconv.ParseFloat(fields[2], 64); err != nil || m.Timestamp <= 0 {
+			return nil, fmt.Errorf("Invalid timestamp of Graphite metric '%s'", line)
+		}
+	}
+	return []Metric{m}, nil
+}
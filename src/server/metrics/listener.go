@@ -0,0 +1,205 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package metrics
+
+import (
+	"bufio"
+	"config"
+	"connectordb"
+	"connectordb/datastream"
+	"connectordb/users"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// MaxPacketSize is the largest UDP packet which is read by the listeners
+const MaxPacketSize = 65535
+
+// Listener receives metrics on UDP or TCP, and writes them into the streams of its device at each flush
+type Listener struct {
+	db     *connectordb.Database
+	cfg    config.Listener
+	parse  Parser
+	agg    *Aggregator
+	logger *log.Entry
+
+	// The streams which are known to exist, so that they are not read before each flush
+	streamLock sync.Mutex
+	streams    map[string]bool
+
+	packetConn net.PacketConn
+	listener   net.Listener
+	done       chan bool
+}
+
+// NewListener creates a listener with the given configuration, which parses its input with the given parser
+func NewListener(db *connectordb.Database, cfg config.Listener, name string, parse Parser) *Listener {
+	return &Listener{
+		db:      db,
+		cfg:     cfg,
+		parse:   parse,
+		agg:     NewAggregator(),
+		logger:  log.WithFields(log.Fields{"listener": name, "device": cfg.Device}),
+		streams: make(map[string]bool),
+		done:    make(chan bool),
+	}
+}
+
+// Start opens the listener's socket, and starts receiving and flushing metrics
+func (l *Listener) Start() (err error) {
+	addr := l.cfg.GetListenAddress()
+	if l.cfg.Protocol == "udp" {
+		if l.packetConn, err = net.ListenPacket("udp", addr); err != nil {
+			return err
+		}
+		go l.servePackets()
+	} else {
+		if l.listener, err = net.Listen("tcp", addr); err != nil {
+			return err
+		}
+		go l.serveConnections()
+	}
+	go l.runFlush()
+
+	l.logger.Infof("Listening for metrics on %s (%s)", addr, l.cfg.Protocol)
+	return nil
+}
+
+// Close stops the listener, writing the metrics that were not yet flushed
+func (l *Listener) Close() {
+	close(l.done)
+	if l.packetConn != nil {
+		l.packetConn.Close()
+	}
+	if l.listener != nil {
+		l.listener.Close()
+	}
+	if err := l.Flush(); err != nil {
+		l.logger.Error(err.Error())
+	}
+}
+
+// Receive parses the given lines, and adds their metrics to the current flush interval
+func (l *Listener) Receive(lines string) {
+	for _, line := range strings.Split(lines, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		metrics, err := l.parse(line)
+		if err != nil {
+			l.logger.Debug(err.Error())
+			continue
+		}
+		for _, m := range metrics {
+			l.agg.Add(m)
+		}
+	}
+}
+
+func (l *Listener) servePackets() {
+	buf := make([]byte, MaxPacketSize)
+	for {
+		n, _, err := l.packetConn.ReadFrom(buf)
+		if err != nil {
+			select {
+			case <-l.done:
+				return
+			default:
+			}
+			l.logger.Warn(err.Error())
+			continue
+		}
+		l.Receive(string(buf[:n]))
+	}
+}
+
+func (l *Listener) serveConnections() {
+	for {
+		conn, err := l.listener.Accept()
+		if err != nil {
+			select {
+			case <-l.done:
+				return
+			default:
+			}
+			l.logger.Warn(err.Error())
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		go l.serveConnection(conn)
+	}
+}
+
+func (l *Listener) serveConnection(conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		l.Receive(scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		l.logger.Debugf("Connection from %s: %s", conn.RemoteAddr().String(), err.Error())
+	}
+}
+
+func (l *Listener) runFlush() {
+	ticker := time.NewTicker(time.Duration(l.cfg.FlushInterval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+			if err := l.Flush(); err != nil {
+				l.logger.Error(err.Error())
+			}
+		}
+	}
+}
+
+// Flush writes the metrics received since the previous flush into the device's streams. The streams of new
+// metrics are created with a schema inferred from their first datapoint.
+func (l *Listener) Flush() error {
+	points := l.agg.Flush(float64(time.Now().UnixNano()) * 1e-9)
+	if len(points) == 0 {
+		return nil
+	}
+
+	o, err := l.db.AsDevice(l.cfg.Device)
+	if err != nil {
+		return err
+	}
+
+	data := make(map[string]datastream.DatapointArray, len(points))
+	for name, dpa := range points {
+		data[l.cfg.Device+"/"+StreamName(name)] = dpa
+	}
+
+	l.streamLock.Lock()
+	defer l.streamLock.Unlock()
+	for streampath, dpa := range data {
+		if l.streams[streampath] {
+			continue
+		}
+		_, err = o.ReadOrCreateStream(streampath, &users.StreamMaker{Stream: users.Stream{Schema: datastream.InferSchema(dpa)}})
+		if err != nil {
+			l.logger.WithField("stream", streampath).Warn(err.Error())
+			delete(data, streampath)
+			continue
+		}
+		l.streams[streampath] = true
+	}
+
+	for streampath, err := range o.InsertStreams("", data, false) {
+		// The stream might have been deleted, so it is checked again on the next flush
+		delete(l.streams, streampath)
+		l.logger.WithField("stream", streampath).Warn(err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package metrics
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+// MetricType is the type of a metric, which decides how its values are aggregated
+type MetricType int
+
+const (
+	// Counter values are summed over the flush interval
+	Counter MetricType = iota
+	// Gauge values are kept between flushes, and written when they change
+	Gauge
+	// Timer values are summarized over the flush interval
+	Timer
+)
+
+// Metric is a single value received by a listener
+type Metric struct {
+	Name  string
+	Type  MetricType
+	Value float64
+
+	SampleRate float64 // The fraction of counter events which were sent (1 if not sampled)
+	Delta      bool    // A gauge delta adds to the gauge's value rather than setting it
+	Timestamp  float64 // The time at which a gauge had its value. 0 means the value is written at the next flush.
+}
+
+// Parser parses a single line of a metrics protocol
+type Parser func(line string) ([]Metric, error)
+
+// MaxStreamName is the maximum length of a stream name
+const MaxStreamName = 29
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// StreamName returns the name of the stream into which a metric is written. Metric names which are valid stream names
+// are used as they are. Other names (such as Graphite paths, which contain dots) have their invalid characters
+// replaced with underscores and are shortened to fit, and get a hash of the full metric name appended, so that
+// different metrics never end up in the same stream.
+func StreamName(metric string) string {
+	name := []byte(metric)
+	valid := len(name) > 0 && len(name) <= MaxStreamName && isLetter(name[0])
+	for i, c := range name {
+		if !(isLetter(c) || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			name[i] = '_'
+			valid = false
+		}
+	}
+	if valid {
+		return metric
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(metric))
+	suffix := fmt.Sprintf("_%08x", h.Sum32())
+
+	s := strings.Trim(string(name), "_")
+	if s == "" {
+		s = "m"
+	} else if !isLetter(s[0]) {
+		s = "m_" + s
+	}
+	if len(s) > MaxStreamName-len(suffix) {
+		s = strings.TrimRight(s[:MaxStreamName-len(suffix)], "_")
+	}
+	return s + suffix
+}
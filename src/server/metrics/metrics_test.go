@@ -0,0 +1,109 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStatsD(t *testing.T) {
+	m, err := ParseStatsD("app.requests:3|c|@0.5")
+	require.NoError(t, err)
+	require.Equal(t, []Metric{{Name: "app.requests", Type: Counter, Value: 3, SampleRate: 0.5}}, m)
+
+	m, err = ParseStatsD("temp:-2|g|#host:a")
+	require.NoError(t, err)
+	require.Equal(t, []Metric{{Name: "temp", Type: Gauge, Value: -2, SampleRate: 1, Delta: true}}, m)
+
+	m, err = ParseStatsD("query:12.5|ms")
+	require.NoError(t, err)
+	require.Equal(t, []Metric{{Name: "query", Type: Timer, Value: 12.5, SampleRate: 1}}, m)
+
+	for _, l := range []string{"app", ":1|c", "app:1", "app:x|c", "app:1|s", "app:1|c|@2"} {
+		_, err = ParseStatsD(l)
+		require.Error(t, err, l)
+	}
+}
+
+func TestParseGraphite(t *testing.T) {
+	m, err := ParseGraphite("servers.a.load 0.5 1434055562")
+	require.NoError(t, err)
+	require.Equal(t, []Metric{{Name: "servers.a.load", Type: Gauge, Value: 0.5, SampleRate: 1, Timestamp: 1434055562}}, m)
+
+	m, err = ParseGraphite("load 2")
+	require.NoError(t, err)
+	require.Equal(t, []Metric{{Name: "load", Type: Gauge, Value: 2, SampleRate: 1}}, m)
+
+	m, err = ParseGraphite("load 2 -1")
+	require.NoError(t, err)
+	require.Equal(t, 0., m[0].Timestamp)
+
+	for _, l := range []string{"load", "load x", "load 1 2 3", "load 1 x", "load 1 0"} {
+		_, err = ParseGraphite(l)
+		require.Error(t, err, l)
+	}
+}
+
+func TestStreamName(t *testing.T) {
+	require.Equal(t, "load", StreamName("load"))
+	require.Equal(t, "cpu-0", StreamName("cpu-0"))
+
+	// Names which had to be changed get a hash of the metric, so that they don't collide
+	require.Equal(t, "servers_a_load_f891c7d8", StreamName("servers.a.load"))
+	require.Equal(t, "servers_a_load_87c0db63", StreamName("servers_a.load"))
+	require.Equal(t, "m_5xx_730d3800", StreamName("5xx"))
+	require.Equal(t, "m_0ac31c19", StreamName("..."))
+	require.Equal(t, "a_very_long_metric_n_e04cf6c8", StreamName("a.very.long.metric.name.which.is.truncated"))
+}
+
+func TestAggregator(t *testing.T) {
+	a := NewAggregator()
+	a.Add(Metric{Name: "hits", Type: Counter, Value: 1, SampleRate: 0.5})
+	a.Add(Metric{Name: "hits", Type: Counter, Value: 3, SampleRate: 1})
+	a.Add(Metric{Name: "temp", Type: Gauge, Value: 10, SampleRate: 1})
+	a.Add(Metric{Name: "temp", Type: Gauge, Value: -3, SampleRate: 1, Delta: true})
+	for _, v := range []float64{5, 1, 3, 2, 4} {
+		a.Add(Metric{Name: "query", Type: Timer, Value: v, SampleRate: 1})
+	}
+
+	res := a.Flush(10)
+	require.Len(t, res, 3)
+	require.Equal(t, 10., res["hits"][0].Timestamp)
+	require.Equal(t, 5., res["hits"][0].Data)
+	require.Equal(t, 7., res["temp"][0].Data)
+	require.Equal(t, map[string]interface{}{
+		"count":  5,
+		"sum":    15.,
+		"min":    1.,
+		"max":    5.,
+		"mean":   3.,
+		"median": 3.,
+		"p90":    5.,
+	}, res["query"][0].Data)
+
+	// Gauges keep their value, but are only written when changed
+	require.Len(t, a.Flush(20), 0)
+	a.Add(Metric{Name: "temp", Type: Gauge, Value: 1, SampleRate: 1, Delta: true})
+	res = a.Flush(30)
+	require.Len(t, res, 1)
+	require.Equal(t, 8., res["temp"][0].Data)
+
+	// Gauges with a timestamp are written at their own time
+	a.Add(Metric{Name: "load", Type: Gauge, Value: 2, SampleRate: 1, Timestamp: 35})
+	a.Add(Metric{Name: "load", Type: Gauge, Value: 1, SampleRate: 1, Timestamp: 32})
+	a.Add(Metric{Name: "load", Type: Gauge, Value: 3, SampleRate: 1})
+	res = a.Flush(40)
+	require.Len(t, res, 1)
+	require.Len(t, res["load"], 3)
+	require.Equal(t, 32., res["load"][0].Timestamp)
+	require.Equal(t, 1., res["load"][0].Data)
+	require.Equal(t, 35., res["load"][1].Timestamp)
+	require.Equal(t, 2., res["load"][1].Data)
+	require.Equal(t, 40., res["load"][2].Timestamp)
+	require.Equal(t, 3., res["load"][2].Data)
+	require.Len(t, a.Flush(50), 0)
+}
@@ -0,0 +1,55 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package metrics
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseStatsD parses a line of the StatsD protocol:
+//	name:value|type[|@samplerate]
+// Counters (c), gauges (g) and timers (ms, h) are supported. Gauge values with an explicit sign are deltas.
+func ParseStatsD(line string) ([]Metric, error) {
+	parts := strings.Split(line, "|")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("StatsD metric '%s' has no type", line)
+	}
+	i := strings.LastIndex(parts[0], ":")
+	if i <= 0 {
+		return nil, fmt.Errorf("Invalid StatsD metric '%s'", line)
+	}
+	m := Metric{Name: parts[0][:i], SampleRate: 1}
+	value := parts[0][i+1:]
+
+	switch parts[1] {
+	case "c":
+		m.Type = Counter
+	case "g":
+		m.Type = Gauge
+		m.Delta = strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-")
+	case "ms", "h":
+		m.Type = Timer
+	default:
+		return nil, fmt.Errorf("Unsupported StatsD metric type '%s'", parts[1])
+	}
+
+	var err error
+	if m.Value, err = strconv.ParseFloat(value, 64); err != nil {
+		return nil, fmt.Errorf("Invalid value of StatsD metric '%s'", line)
+	}
+
+	// Any further sections are either the sample rate or extensions (such as tags) which are ignored
+	for _, p := range parts[2:] {
+		if strings.HasPrefix(p, "@") {
+			if m.SampleRate, err = strconv.ParseFloat(p[1:], 64); err != nil || m.SampleRate <= 0 || m.SampleRate > 1 {
+				return nil, fmt.Errorf("Invalid sample rate of StatsD metric '%s'", line)
+			}
+		}
+	}
+
+	return []Metric{m}, nil
+}
@@ -0,0 +1,92 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package crud
+
+import (
+	"connectordb/authoperator"
+	"connectordb/users"
+	"encoding/json"
+	"net/http"
+	"server/restapi/restcore"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// commandRequest is the body of requests which send a command to a downlink stream
+type commandRequest struct {
+	Data json.RawMessage `json:"data"`
+	TTL  float64         `json:"ttl"` // The number of seconds after which the command expires. 0 means that it never expires.
+}
+
+// commandUpdate is the body of requests with which devices acknowledge commands
+type commandUpdate struct {
+	Status string          `json:"status"`
+	Result json.RawMessage `json:"result"`
+}
+
+func getCommandID(request *http.Request) (int64, error) {
+	return strconv.ParseInt(mux.Vars(request)["command"], 10, 64)
+}
+
+//ListCommands returns the commands queued on the stream, optionally only the ones with the given status
+func ListCommands(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	_, _, _, streampath := restcore.GetStreamPath(request)
+
+	c, err := o.ReadCommands(streampath, request.URL.Query().Get("status"))
+	return restcore.JSONWriter(writer, c, logger, err)
+}
+
+//SendCommand queues a command on the downlink stream
+func SendCommand(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	_, _, _, streampath := restcore.GetStreamPath(request)
+
+	var cr commandRequest
+	err := restcore.UnmarshalRequest(request, &cr)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
+	}
+	c := &users.Command{Data: cr.Data}
+	if cr.TTL > 0 {
+		c.Expires = float64(time.Now().UnixNano())*1e-9 + cr.TTL
+	}
+	if err = o.CreateCommand(streampath, c); err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	return restcore.JSONWriter(writer, c, logger, nil)
+}
+
+//ReadCommand returns the command with its status and result
+func ReadCommand(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	_, _, _, streampath := restcore.GetStreamPath(request)
+	id, err := getCommandID(request)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
+	}
+
+	c, err := o.ReadCommand(streampath, id)
+	return restcore.JSONWriter(writer, c, logger, err)
+}
+
+//UpdateCommand is used by devices to acknowledge a command, and to post its result
+func UpdateCommand(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	_, _, _, streampath := restcore.GetStreamPath(request)
+	id, err := getCommandID(request)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
+	}
+
+	var cu commandUpdate
+	if err = restcore.UnmarshalRequest(request, &cu); err != nil {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
+	}
+	if err = o.UpdateCommand(streampath, id, cu.Status, string(cu.Result)); err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	return ReadCommand(o, writer, request, logger)
+}
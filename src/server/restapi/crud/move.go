@@ -0,0 +1,83 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package crud
+
+import (
+	"connectordb/authoperator"
+	"errors"
+	"server/restapi/restcore"
+
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+//RenameDevice gives the device the name in the "name" query parameter. With alias=true, the old name keeps working.
+func RenameDevice(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	usrname, _, devpath := getDevicePath(request)
+	q := request.URL.Query()
+	name := q.Get("name")
+	if name == "" {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, errors.New("The new name of the device must be given"), false)
+	}
+
+	if err := o.RenameDevice(devpath, name, q.Get("alias") == "true"); err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	d, err := o.ReadDevice(usrname + "/" + name)
+	return restcore.JSONWriter(writer, d, logger, err)
+}
+
+//MoveStream moves the stream to the path in the "to" query parameter. With alias=true, the old path keeps working.
+func MoveStream(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	_, _, _, streampath := restcore.GetStreamPath(request)
+	q := request.URL.Query()
+	to := q.Get("to")
+	if to == "" {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, errors.New("The path to which to move the stream must be given"), false)
+	}
+
+	if err := o.MoveStream(streampath, to, q.Get("alias") == "true"); err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	s, err := o.ReadStream(to)
+	return restcore.JSONWriter(writer, s, logger, err)
+}
+
+//TransferDevice gives the device to the user in the "to" query parameter
+func TransferDevice(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	_, devname, devpath := getDevicePath(request)
+	to := request.URL.Query().Get("to")
+	if to == "" {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, errors.New("The user to which to transfer the device must be given"), false)
+	}
+
+	if err := o.TransferDevice(devpath, to); err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	d, err := o.ReadDevice(to + "/" + devname)
+	return restcore.JSONWriter(writer, d, logger, err)
+}
+
+//CloneStream copies the stream to the path in the "to" query parameter. If t1 or t2 are given, only the data
+//in the time range is copied.
+func CloneStream(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	_, _, _, streampath := restcore.GetStreamPath(request)
+	q := request.URL.Query()
+	to := q.Get("to")
+	if to == "" {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, errors.New("The path to which to clone the stream must be given"), false)
+	}
+	t1, t2, _, err := restcore.ParseTRange(q)
+	if err != nil && err != restcore.ErrCantParse {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
+	}
+
+	if err = o.CloneStream(streampath, to, t1, t2); err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	s, err := o.ReadStream(to)
+	return restcore.JSONWriter(writer, s, logger, err)
+}
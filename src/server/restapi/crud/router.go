@@ -21,11 +21,15 @@ func Router(db *connectordb.Database, prefix *mux.Router) *mux.Router {
 	prefix.StrictSlash(true)
 
 	prefix.HandleFunc("/", restcore.Authenticator(ListUsers, db)).Queries("q", "ls")
+	prefix.HandleFunc("/", restcore.Authenticator(BulkInsert, db)).Methods("POST") //Restamp off
+	prefix.HandleFunc("/", restcore.Authenticator(BulkInsert, db)).Methods("PUT")  //Restamp on
 
 	//User CRUD
 	prefix.HandleFunc("/{user}", restcore.Authenticator(ListDevices, db)).Methods("GET").Queries("q", "ls")
 	prefix.HandleFunc("/{user}", restcore.Authenticator(ListDevices, db)).Methods("GET").Queries("q", "devices")
 	prefix.HandleFunc("/{user}", restcore.Authenticator(ListUserStreams, db)).Methods("GET").Queries("q", "streams")
+	prefix.HandleFunc("/{user}", restcore.Authenticator(ListTrash, db)).Methods("GET").Queries("q", "trash")
+	prefix.HandleFunc("/{user}", restcore.Authenticator(RestoreTrash, db)).Methods("PUT").Queries("q", "restore")
 	prefix.HandleFunc("/{user}", restcore.Authenticator(ReadUser, db)).Methods("GET")
 	prefix.HandleFunc("/{user}", restcore.Authenticator(CreateUser, db)).Methods("POST")
 	prefix.HandleFunc("/{user}", restcore.Authenticator(UpdateUser, db)).Methods("PUT")
@@ -35,18 +39,34 @@ func Router(db *connectordb.Database, prefix *mux.Router) *mux.Router {
 	prefix.HandleFunc("/{user}/{device}", restcore.Authenticator(ListStreams, db)).Methods("GET").Queries("q", "ls")
 	prefix.HandleFunc("/{user}/{device}", restcore.Authenticator(ListStreams, db)).Methods("GET").Queries("q", "streams")
 	prefix.HandleFunc("/{user}/{device}", restcore.Authenticator(ReadDevice, db)).Methods("GET")
+	prefix.HandleFunc("/{user}/{device}", restcore.Authenticator(RestoreTrash, db)).Methods("PUT").Queries("q", "restore")
+	prefix.HandleFunc("/{user}/{device}", restcore.Authenticator(RenameDevice, db)).Methods("PUT").Queries("q", "rename")
+	prefix.HandleFunc("/{user}/{device}", restcore.Authenticator(TransferDevice, db)).Methods("PUT").Queries("q", "transfer")
 	prefix.HandleFunc("/{user}/{device}", restcore.Authenticator(CreateDevice, db)).Methods("POST")
 	prefix.HandleFunc("/{user}/{device}", restcore.Authenticator(UpdateDevice, db)).Methods("PUT")
 	prefix.HandleFunc("/{user}/{device}", restcore.Authenticator(DeleteDevice, db)).Methods("DELETE")
 
 	//Stream CRUD
 	prefix.HandleFunc("/{user}/{device}/{stream}", restcore.Authenticator(ReadStream, db)).Methods("GET")
+	prefix.HandleFunc("/{user}/{device}/{stream}", restcore.Authenticator(RestoreTrash, db)).Methods("PUT").Queries("q", "restore")
+	prefix.HandleFunc("/{user}/{device}/{stream}", restcore.Authenticator(MoveStream, db)).Methods("PUT").Queries("q", "move")
+	prefix.HandleFunc("/{user}/{device}/{stream}", restcore.Authenticator(CloneStream, db)).Methods("PUT").Queries("q", "clone")
 	prefix.HandleFunc("/{user}/{device}/{stream}", restcore.Authenticator(CreateStream, db)).Methods("POST")
 	prefix.HandleFunc("/{user}/{device}/{stream}", restcore.Authenticator(UpdateStream, db)).Methods("PUT")
 	prefix.HandleFunc("/{user}/{device}/{stream}", restcore.Authenticator(DeleteStream, db)).Methods("DELETE")
 
+	prefix.HandleFunc("/{user}/{device}/{stream}/schema", restcore.Authenticator(ReadStreamSchemas, db)).Methods("GET")
+	prefix.HandleFunc("/{user}/{device}/{stream}/schema", restcore.Authenticator(UpdateStreamSchema, db)).Methods("PUT")
+	prefix.HandleFunc("/{user}/{device}/{stream}/schema/check", restcore.Authenticator(CheckStreamSchema, db)).Methods("POST")
+
+	prefix.HandleFunc("/{user}/{device}/{stream}/commands", restcore.Authenticator(ListCommands, db)).Methods("GET")
+	prefix.HandleFunc("/{user}/{device}/{stream}/commands", restcore.Authenticator(SendCommand, db)).Methods("POST")
+	prefix.HandleFunc("/{user}/{device}/{stream}/commands/{command}", restcore.Authenticator(ReadCommand, db)).Methods("GET")
+	prefix.HandleFunc("/{user}/{device}/{stream}/commands/{command}", restcore.Authenticator(UpdateCommand, db)).Methods("POST")
+
 	prefix.HandleFunc("/{user}/{device}/{stream}/data", restcore.Authenticator(StreamLength, db)).Methods("GET").Queries("q", "length")
 	prefix.HandleFunc("/{user}/{device}/{stream}/data", restcore.Authenticator(StreamTime2Index, db)).Methods("GET").Queries("q", "time2index")
+	prefix.HandleFunc("/{user}/{device}/{stream}/data", restcore.Authenticator(StreamSSE, db)).Methods("GET").Queries("subscribe", "sse")
 	prefix.HandleFunc("/{user}/{device}/{stream}/data", restcore.Authenticator(StreamRange, db)).Methods("GET")
 	prefix.HandleFunc("/{user}/{device}/{stream}/data", restcore.Authenticator(WriteStream, db)).Methods("POST") //Restamp off
 	prefix.HandleFunc("/{user}/{device}/{stream}/data", restcore.Authenticator(WriteStream, db)).Methods("PUT")  //Restamp on
@@ -0,0 +1,197 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package crud
+
+import (
+	"config"
+	"connectordb/authoperator"
+	"connectordb/datastream"
+	"connectordb/messenger"
+	"connectordb/query"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"server/restapi/restcore"
+	"server/webcore"
+	"strconv"
+	"time"
+
+	"github.com/connectordb/pipescript"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// ErrStreamingUnsupported is returned when the connection does not support streaming responses
+var ErrStreamingUnsupported = errors.New("The connection does not support streaming responses")
+
+// sseReplayBatchSize is the maximum number of datapoints in each event when replaying a stream's history
+const sseReplayBatchSize = 1000
+
+// sseWriter writes server-sent events
+type sseWriter struct {
+	writer  http.ResponseWriter
+	flusher http.Flusher
+	stream  string
+
+	transform string
+	tf        *pipescript.Script
+}
+
+// Write sends the message as an event, after running the transform on it. The event's id is the stream index
+// following the message, so that a client which reconnects with Last-Event-ID resumes after the message.
+func (w *sseWriter) Write(m messenger.Message) error {
+	next := m.Index + int64(len(m.Data))
+	if w.tf != nil {
+		dpa, err := query.TransformArray(w.tf, &m.Data)
+		if err != nil {
+			return err
+		}
+		if dpa.Length() <= 0 {
+			return nil
+		}
+		m.Transform = w.transform
+		m.Data = *dpa
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if m.Index >= 0 {
+		_, err = fmt.Fprintf(w.writer, "id: %d\ndata: %s\n\n", next, b)
+	} else {
+		_, err = fmt.Fprintf(w.writer, "data: %s\n\n", b)
+	}
+	w.flusher.Flush()
+	return err
+}
+
+// Keepalive sends a comment, which keeps proxies from closing the connection
+func (w *sseWriter) Keepalive() error {
+	_, err := w.writer.Write([]byte(": keepalive\n\n"))
+	w.flusher.Flush()
+	return err
+}
+
+// Replay sends the stream's data starting at index i1, and returns the index following the data
+func (w *sseWriter) Replay(o *authoperator.AuthOperator, i1 int64) (int64, error) {
+	dr, err := o.GetStreamIndexRange(w.stream, i1, 0, "")
+	if err != nil {
+		return i1, err
+	}
+	defer dr.Close()
+
+	for {
+		dpa := make(datastream.DatapointArray, 0, sseReplayBatchSize)
+		for len(dpa) < sseReplayBatchSize {
+			dp, err := dr.Next()
+			if err != nil {
+				return i1, err
+			}
+			if dp == nil {
+				break
+			}
+			dpa = append(dpa, *dp)
+		}
+		if len(dpa) == 0 {
+			return i1, nil
+		}
+		if err = w.Write(messenger.Message{Stream: w.stream, Data: dpa, Index: i1}); err != nil {
+			return i1, err
+		}
+		i1 += int64(len(dpa))
+	}
+}
+
+//StreamSSE subscribes to the stream, sending its data as server-sent events. If the client reconnects
+//with a Last-Event-ID header, the data inserted since the event is sent before the live data.
+func StreamSSE(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	_, _, _, streampath := restcore.GetStreamPath(request)
+	transform := request.URL.Query().Get("transform")
+
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		return restcore.WriteError(writer, logger, http.StatusInternalServerError, ErrStreamingUnsupported, true)
+	}
+
+	w := &sseWriter{writer: writer, flusher: flusher, stream: streampath, transform: transform}
+	if transform != "" {
+		tf, err := pipescript.Parse(transform)
+		if err != nil {
+			return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
+		}
+		w.tf = tf
+	}
+
+	resume := int64(-1)
+	if id := request.Header.Get("Last-Event-ID"); id != "" {
+		i, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
+		}
+		resume = i
+	}
+
+	// The subscription is made before the history is read, so no data is lost between the two
+	c := make(chan messenger.Message, config.Get().Websocket.MessageBuffer)
+	subs, err := o.Subscribe(streampath, c)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	defer subs.Unsubscribe()
+
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.Header().Set("X-Accel-Buffering", "no")
+	writer.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	next := resume
+	if resume >= 0 {
+		if next, err = w.Replay(o, resume); err != nil {
+			logger.Warningln(err)
+			return webcore.INFO, "SSE replay failed"
+		}
+	}
+
+	closed := request.Context().Done()
+	ticker := time.NewTicker(config.Get().Websocket.PingPeriod * time.Second)
+	defer ticker.Stop()
+
+	logger.Debugln("Running SSE subscription")
+	for {
+		select {
+		case m := <-c:
+			// Drop datapoints which were already sent while replaying
+			if next >= 0 && m.Index >= 0 {
+				if skip := next - m.Index; skip > 0 {
+					if skip >= int64(len(m.Data)) {
+						continue
+					}
+					m.Data = m.Data[skip:]
+					m.Index += skip
+				}
+				next = m.Index + int64(len(m.Data))
+			}
+			if err = w.Write(m); err != nil {
+				logger.Warningln(err)
+				return webcore.INFO, "SSE closed"
+			}
+		case <-ticker.C:
+			if err = w.Keepalive(); err != nil {
+				return webcore.DEBUG, "SSE closed"
+			}
+			if _, err = o.ReadStream(streampath); err != nil {
+				// The stream was deleted, or we lost access to it
+				return webcore.INFO, "SSE subscription invalidated"
+			}
+		case <-closed:
+			return webcore.DEBUG, "SSE closed"
+		case <-webcore.ShutdownChannel:
+			webcore.ShutdownChannel <- true
+			return webcore.DEBUG, "SSE closed on shutdown"
+		}
+	}
+}
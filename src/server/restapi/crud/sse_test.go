@@ -0,0 +1,127 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package crud
+
+import (
+	"config"
+	"connectordb"
+	"connectordb/datastream"
+	"connectordb/users"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+var Tdb *connectordb.Database
+
+func init() {
+	db, err := connectordb.Open(config.TestConfiguration.Options())
+	if err != nil {
+		log.Fatal(err)
+	}
+	Tdb = db
+	go db.RunWriter()
+}
+
+// setupSSETest creates a stream holding 5 datapoints
+func setupSSETest(t *testing.T) {
+	Tdb.Clear()
+	require.NoError(t, Tdb.CreateUser(&users.UserMaker{User: users.User{Name: "myuser", Email: "my@email", Password: "test", Role: "user", Public: true}}))
+	require.NoError(t, Tdb.CreateDevice("myuser/mydevice", &users.DeviceMaker{}))
+	require.NoError(t, Tdb.CreateStream("myuser/mydevice/mystream", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"number"}`}}))
+	require.NoError(t, Tdb.InsertStream("myuser/mydevice/mystream", datastream.DatapointArray{
+		datastream.Datapoint{Timestamp: 1, Data: 1.0},
+		datastream.Datapoint{Timestamp: 2, Data: 2.0},
+		datastream.Datapoint{Timestamp: 3, Data: 3.0},
+		datastream.Datapoint{Timestamp: 4, Data: 4.0},
+		datastream.Datapoint{Timestamp: 5, Data: 5.0},
+	}, false))
+}
+
+// runSSE runs the SSE handler for the stream until the given context is done, and returns the response
+func runSSE(t *testing.T, ctx context.Context, query string, lastEventID string) *httptest.ResponseRecorder {
+	o, err := Tdb.AsUser("myuser")
+	require.NoError(t, err)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/{user}/{device}/{stream}/data", func(writer http.ResponseWriter, request *http.Request) {
+		StreamSSE(o, writer, request, log.WithField("test", t.Name()))
+	})
+
+	req, err := http.NewRequest("GET", "/myuser/mydevice/mystream/data?subscribe=sse"+query, nil)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req.WithContext(ctx))
+	return w
+}
+
+func TestSSEReplay(t *testing.T) {
+	setupSSETest(t)
+
+	// The handler returns once the client is gone
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	w := runSSE(t, ctx, "", "3")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	body := w.Body.String()
+	require.True(t, strings.HasPrefix(body, "id: 5\ndata: "), body)
+	require.Contains(t, body, `"t":4`)
+	require.Contains(t, body, `"t":5`)
+	require.NotContains(t, body, `"t":3`)
+
+	// Nothing is replayed without a Last-Event-ID
+	w = runSSE(t, ctx, "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "", w.Body.String())
+}
+
+func TestSSESubscribe(t *testing.T) {
+	setupSSETest(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- runSSE(t, ctx, "&transform=$*2", "")
+	}()
+
+	time.Sleep(500 * time.Millisecond)
+	require.NoError(t, Tdb.InsertStream("myuser/mydevice/mystream", datastream.DatapointArray{
+		datastream.Datapoint{Timestamp: 6, Data: 6.0},
+	}, false))
+	time.Sleep(500 * time.Millisecond)
+	cancel()
+
+	select {
+	case w := <-done:
+		body := w.Body.String()
+		require.True(t, strings.HasPrefix(body, "id: 6\ndata: "), body)
+		require.Contains(t, body, `"d":12`)
+		require.Contains(t, body, `"transform":"$*2"`)
+	case <-time.After(5 * time.Second):
+		t.Fatal("The SSE handler did not return after the client closed the connection")
+	}
+}
+
+func TestSSEErrors(t *testing.T) {
+	setupSSETest(t)
+
+	w := runSSE(t, context.Background(), "&transform=$+", "")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	w = runSSE(t, context.Background(), "", "notanumber")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
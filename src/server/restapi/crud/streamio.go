@@ -7,6 +7,7 @@ package crud
 import (
 	"connectordb/authoperator"
 	"connectordb/datastream"
+	"connectordb/query"
 	"errors"
 	"fmt"
 	"net/http"
@@ -15,6 +16,7 @@ import (
 	"strconv"
 	"sync/atomic"
 	"time"
+	"util"
 
 	log "github.com/Sirupsen/logrus"
 )
@@ -48,7 +50,8 @@ func WriteStream(o *authoperator.AuthOperator, writer http.ResponseWriter, reque
 
 	tins := time.Now()
 
-	err = o.InsertStream(streampath, datapoints, restamp)
+	// Clients can set an idempotency key, so that retrying an insert which timed out doesn't write the data twice
+	err = o.InsertStreamOnce(streampath, request.Header.Get("Idempotency-Key"), datapoints, restamp)
 	if err != nil {
 		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
 	}
@@ -71,20 +74,144 @@ func WriteStream(o *authoperator.AuthOperator, writer http.ResponseWriter, reque
 	return lvl, querylog
 }
 
+//BulkInsertResult is the result of the insert into one of the streams of a bulk insert
+type BulkInsertResult struct {
+	Inserted int    `json:"inserted"`        // The number of datapoints written to the stream
+	Error    string `json:"error,omitempty"` // Set if the insert into the stream failed
+}
+
+//BulkInsert inserts into multiple streams with one request. The body is an object of stream paths to datapoint arrays.
+//Each stream's insert succeeds or fails on its own, and the response has the result of each stream.
+func BulkInsert(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	var data map[string]datastream.DatapointArray
+	err := restcore.UnmarshalRequest(request, &data)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
+	}
+	restamp := request.Method == "PUT"
+
+	tins := time.Now()
+	failed := o.InsertStreams(request.Header.Get("Idempotency-Key"), data, restamp)
+
+	inserted := 0
+	results := make(map[string]BulkInsertResult, len(data))
+	for streampath, dpa := range data {
+		if err, ok := failed[streampath]; ok {
+			results[streampath] = BulkInsertResult{Error: err.Error()}
+		} else {
+			results[streampath] = BulkInsertResult{Inserted: len(dpa)}
+			inserted += len(dpa)
+		}
+	}
+
+	querylog := fmt.Sprintf("Bulk insert %d into %d streams", inserted, len(data)-len(failed))
+	if restamp {
+		querylog += " (restamp)"
+	}
+
+	lvl := webcore.DEBUG
+	if len(failed) > 0 {
+		querylog += fmt.Sprintf(" - %d streams failed", len(failed))
+		lvl = webcore.INFO
+	}
+	insertTime := time.Since(tins)
+	if insertTime.Seconds() > 0.5 {
+		querylog += fmt.Sprintf(" - INSERT_TIME: %s!", insertTime.String())
+		lvl = webcore.WARNING
+	}
+
+	atomic.AddUint32(&webcore.StatsInserts, uint32(inserted))
+	if l, msg := restcore.JSONWriter(writer, results, logger, nil); msg != "" {
+		return l, msg
+	}
+	return lvl, querylog
+}
+
+//upgradeRange upgrades the data of the given range to the stream's latest schema version, and only then runs
+//the transform, so that transforms see the upgraded values. The startindex function returns the index of the range's first datapoint.
+func upgradeRange(o *authoperator.AuthOperator, streampath string, transform string, dr datastream.DataRange, startindex func() (int64, error)) (datastream.DataRange, error) {
+	_, _, _, _, substream, _ := util.SplitStreamPath(streampath)
+	versions, err := o.ReadStreamSchemas(streampath)
+	if err != nil {
+		dr.Close()
+		return nil, err
+	}
+	if len(versions) > 0 {
+		i, err := startindex()
+		if err != nil {
+			dr.Close()
+			return nil, err
+		}
+		ur, err := query.NewUpgradeRange(dr, i, substream, versions)
+		if err != nil {
+			dr.Close()
+			return nil, err
+		}
+		dr = ur
+	}
+	if transform == "" {
+		return dr, nil
+	}
+	tr, err := query.NewTransformRange(dr, transform)
+	if err != nil {
+		dr.Close()
+		return nil, err
+	}
+	return tr, nil
+}
+
+//rangeSchema returns the JSON schema of a range of the stream's data when it is requested in a columnar format.
+//The schema is not known if the data is transformed or converted, or if it can contain old versions of the schema.
+func rangeSchema(o *authoperator.AuthOperator, request *http.Request, streampath string, upgrade bool, unit, transform string) string {
+	if !restcore.IsColumnar(request) || transform != "" || unit != "" {
+		return ""
+	}
+	s, err := o.ReadStream(streampath)
+	if err != nil || s.SchemaVersion > 0 && !upgrade {
+		return ""
+	}
+	return s.Schema
+}
+
 //StreamRange gets a range of data from a stream
 func StreamRange(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
 	_, _, _, streampath := restcore.GetStreamPath(request)
 	q := request.URL.Query()
 	transform := q.Get("transform")
 
+	// Unit conversion is run as part of the transform, and if upgrading, the transform is run afterwards
+	upgrade := q.Get("upgrade") == "true"
+	unit := q.Get("unit")
+	transform, err := query.UnitTransform(o, streampath, unit, transform)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
+	}
+	rangetransform := transform
+	if upgrade {
+		rangetransform = ""
+	}
+
 	i1, i2, err := restcore.ParseIRange(q)
 	if err == nil {
 		querylog := fmt.Sprintf("irange [%d,%d)", i1, i2)
-		dr, err := o.GetStreamIndexRange(streampath, i1, i2, transform)
+		dr, err := o.GetStreamIndexRange(streampath, i1, i2, rangetransform)
+		if err == nil && upgrade {
+			dr, err = upgradeRange(o, streampath, transform, dr, func() (int64, error) {
+				if i1 >= 0 {
+					return i1, nil
+				}
+				// Negative indices are from the end of the stream
+				l, err := o.LengthStream(streampath)
+				if l+i1 < 0 {
+					return 0, err
+				}
+				return l + i1, err
+			})
+		}
 		if err == nil {
 			defer dr.Close()
 		}
-		lvl, _ := restcore.WriteJSONResult(writer, dr, logger, err)
+		lvl, _ := restcore.WriteResult(writer, request, dr, rangeSchema(o, request, streampath, upgrade, unit, transform), logger, err)
 		return lvl, querylog
 	} else if err != restcore.ErrCantParse {
 		return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
@@ -95,11 +222,16 @@ func StreamRange(o *authoperator.AuthOperator, writer http.ResponseWriter, reque
 	t1, t2, lim, err := restcore.ParseTRange(q)
 	if err == nil {
 		querylog := fmt.Sprintf("trange [%.1f,%.1f) limit=%d", t1, t2, lim)
-		dr, err := o.GetStreamTimeRange(streampath, t1, t2, lim, transform)
+		dr, err := o.GetStreamTimeRange(streampath, t1, t2, lim, rangetransform)
+		if err == nil && upgrade {
+			dr, err = upgradeRange(o, streampath, transform, dr, func() (int64, error) {
+				return o.TimeToIndexStream(streampath, t1)
+			})
+		}
 		if err == nil {
 			defer dr.Close()
 		}
-		lvl, _ := restcore.WriteJSONResult(writer, dr, logger, err)
+		lvl, _ := restcore.WriteResult(writer, request, dr, rangeSchema(o, request, streampath, upgrade, unit, transform), logger, err)
 		return lvl, querylog
 	}
 
@@ -0,0 +1,58 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package crud
+
+import (
+	"connectordb/authoperator"
+	"net/http"
+	"server/restapi/restcore"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// schemaChange is the body of requests which set or check a stream schema
+type schemaChange struct {
+	Schema  string `json:"schema"`
+	Upgrade string `json:"upgrade"` // Optional PipeScript transform which converts existing data to the new schema
+}
+
+//ReadStreamSchemas returns the schema versions of the stream
+func ReadStreamSchemas(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	_, _, _, streampath := restcore.GetStreamPath(request)
+
+	s, err := o.ReadStreamSchemas(streampath)
+	return restcore.JSONWriter(writer, s, logger, err)
+}
+
+//UpdateStreamSchema sets a new schema version for the stream
+func UpdateStreamSchema(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	_, _, _, streampath := restcore.GetStreamPath(request)
+
+	var sc schemaChange
+	err := restcore.UnmarshalRequest(request, &sc)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
+	}
+	if err = o.UpdateStreamSchema(streampath, sc.Schema, sc.Upgrade); err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+
+	return ReadStream(o, writer, request, logger)
+}
+
+//CheckStreamSchema is a dry run of a schema change - it returns the number of existing datapoints
+//which would violate the proposed schema
+func CheckStreamSchema(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	_, _, _, streampath := restcore.GetStreamPath(request)
+
+	var sc schemaChange
+	err := restcore.UnmarshalRequest(request, &sc)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
+	}
+
+	c, err := o.CheckStreamSchema(streampath, sc.Schema, sc.Upgrade)
+	return restcore.JSONWriter(writer, c, logger, err)
+}
@@ -0,0 +1,37 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package crud
+
+import (
+	"connectordb/authoperator"
+	"server/restapi/restcore"
+	"server/webcore"
+	"strings"
+
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/gorilla/mux"
+)
+
+//ListTrash lists the deleted devices and streams of the user which can still be restored
+func ListTrash(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	t, err := o.ReadTrash(mux.Vars(request)["user"])
+	return restcore.JSONWriter(writer, t, logger, err)
+}
+
+//RestoreTrash takes the user, device or stream given in the path out of the trash
+func RestoreTrash(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	v := mux.Vars(request)
+	path := strings.TrimRight(v["user"]+"/"+v["device"]+"/"+v["stream"], "/")
+
+	err := o.RestoreTrash(path)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	restcore.OK(writer)
+	return webcore.INFO, path
+}
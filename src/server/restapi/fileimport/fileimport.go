@@ -0,0 +1,104 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package fileimport
+
+import (
+	"config"
+	"connectordb"
+	"connectordb/authoperator"
+	"connectordb/importer"
+	"fmt"
+	"io"
+	"net/http"
+	"server/restapi/restcore"
+	"server/webcore"
+	"strings"
+	"sync/atomic"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/gorilla/mux"
+)
+
+// importFile returns the uploaded file and its name. Files are uploaded either as the "file" field of a multipart
+// form, or as the request body, with the file name given in the filename query parameter.
+func importFile(request *http.Request) (io.ReadCloser, string, error) {
+	if strings.HasPrefix(request.Header.Get("Content-Type"), "multipart/form-data") {
+		f, h, err := request.FormFile("file")
+		if err != nil {
+			return nil, "", err
+		}
+		return f, h.Filename, nil
+	}
+	return request.Body, request.URL.Query().Get("filename"), nil
+}
+
+// importStreams returns the name of the stream of each kind of data. Each kind is imported into the stream named
+// after it, unless the stream is given in the query. A kind given an empty stream name is not imported.
+func importStreams(request *http.Request) map[string]string {
+	q := request.URL.Query()
+	streams := importer.DefaultStreams()
+	for kind := range streams {
+		if v, ok := q[kind]; ok && len(v) > 0 {
+			streams[kind] = v[0]
+		}
+	}
+	return streams
+}
+
+// ImportActivity imports a GPX, TCX or FIT activity file into streams of the device
+func ImportActivity(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	devicepath := mux.Vars(request)["user"] + "/" + mux.Vars(request)["device"]
+	if _, err := o.ReadDevice(devicepath); err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+
+	request.Body = http.MaxBytesReader(writer, request.Body, config.Get().InsertLimitBytes)
+	f, filename, err := importFile(request)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
+	}
+	defer f.Close()
+
+	data, err := importer.ParseActivity(filename, f)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
+	}
+
+	// Backfilling shifts the indices of the streams' later datapoints, so it is only done when asked for
+	backfill := request.URL.Query().Get("backfill") == "true"
+	results := importer.Import(o, devicepath, data, importStreams(request), backfill)
+
+	inserted, failed := 0, 0
+	for _, r := range results {
+		inserted += r.Inserted
+		if r.Error != "" {
+			failed++
+		}
+	}
+	atomic.AddUint32(&webcore.StatsInserts, uint32(inserted))
+
+	querylog := fmt.Sprintf("Imported %d datapoints from '%s'", inserted, filename)
+	lvl := webcore.INFO
+	if failed > 0 {
+		querylog += fmt.Sprintf(" - %d streams failed", failed)
+		lvl = webcore.WARNING
+	}
+	if l, msg := restcore.JSONWriter(writer, results, logger, nil); msg != "" {
+		return l, msg
+	}
+	return lvl, querylog
+}
+
+// Router returns a fully formed Gorilla router given an optional prefix
+func Router(db *connectordb.Database, prefix *mux.Router) *mux.Router {
+	if prefix == nil {
+		prefix = mux.NewRouter()
+	}
+
+	prefix.HandleFunc("/{user}/{device}", restcore.Authenticator(ImportActivity, db)).Methods("POST")
+
+	return prefix
+}
@@ -0,0 +1,90 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package graphql
+
+import (
+	"config"
+	"connectordb"
+	"connectordb/authoperator"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"server/restapi/restcore"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	gql "github.com/graphql-go/graphql"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// ErrNoQuery is returned when a GraphQL request does not contain a query
+var ErrNoQuery = errors.New("No GraphQL query was given")
+
+// Request is a GraphQL query, along with its variables
+type Request struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// Run executes the request using the given root object
+func (r *Request) Run(root map[string]interface{}) *gql.Result {
+	return gql.Do(gql.Params{
+		Schema:         Schema,
+		RequestString:  r.Query,
+		RootObject:     root,
+		VariableValues: r.Variables,
+		OperationName:  r.OperationName,
+	})
+}
+
+//Query runs a GraphQL query. All fields are read through the AuthOperator, so the results contain only the
+//fields that the querying device has permission to read.
+func Query(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	var r Request
+	if request.Method == "GET" {
+		q := request.URL.Query()
+		r.Query = q.Get("query")
+		r.OperationName = q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &r.Variables); err != nil {
+				return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
+			}
+		}
+	} else if err := restcore.UnmarshalRequest(request, &r); err != nil {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
+	}
+	if r.Query == "" {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, ErrNoQuery, false)
+	}
+
+	res := r.Run(map[string]interface{}{"operator": o})
+	lvl, _ := restcore.JSONWriter(writer, res, logger, nil)
+	return lvl, r.OperationName
+}
+
+//Router returns a fully formed Gorilla router given an optional prefix
+func Router(db *connectordb.Database, prefix *mux.Router) *mux.Router {
+	upgrader = websocket.Upgrader{
+		ReadBufferSize:  config.Get().Websocket.ReadBufferSize,
+		WriteBufferSize: config.Get().Websocket.WriteBufferSize,
+		// Allow from all origins
+		CheckOrigin: func(r *http.Request) bool { return true },
+	}
+
+	if prefix == nil {
+		prefix = mux.NewRouter()
+	}
+
+	//Allow for the application to match /path and /path/ to the same place.
+	prefix.StrictSlash(true)
+
+	// Subscriptions are run over a websocket
+	prefix.HandleFunc("/", restcore.Authenticator(RunSubscriptions, db)).Headers("Upgrade", "websocket").Methods("GET")
+	prefix.HandleFunc("/", restcore.Authenticator(Query, db)).Methods("GET", "POST")
+
+	return prefix
+}
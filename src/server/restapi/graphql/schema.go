@@ -0,0 +1,425 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package graphql
+
+import (
+	"connectordb/authoperator"
+	"connectordb/datastream"
+	"connectordb/messenger"
+	"connectordb/query"
+	"errors"
+
+	"github.com/connectordb/pipescript"
+	gql "github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+var (
+	// ErrNoOperator is returned if a resolver is run without an operator in the root object
+	ErrNoOperator = errors.New("GraphQL query was run without an operator")
+
+	// ErrNoRange is returned if data is queried without a range
+	ErrNoRange = errors.New(`data needs an index range ("i1" and/or "i2") or a time range ("t1" and/or "t2")`)
+
+	// Schema is the GraphQL schema of ConnectorDB. It is generated on init
+	Schema gql.Schema
+)
+
+const (
+	// DefaultDataLimit is the number of datapoints that data returns if no limit is given
+	DefaultDataLimit = 1000
+
+	// MaxDataLimit is the most datapoints that data returns, since the whole result is held in memory
+	MaxDataLimit = 100000
+)
+
+// The root object of each GraphQL query is a map with the following keys:
+//	"operator": the *authoperator.AuthOperator through which all resolvers access the database
+//	"subscriber": (subscriptions only) the subscriber which receives subscription requests
+//	"message": (subscriptions only) the messenger.Message that is being processed
+type rootObject map[string]interface{}
+
+func getRoot(p gql.ResolveParams) rootObject {
+	r, _ := p.Info.RootValue.(map[string]interface{})
+	return r
+}
+
+func getOperator(p gql.ResolveParams) (*authoperator.AuthOperator, error) {
+	o, ok := getRoot(p)["operator"].(*authoperator.AuthOperator)
+	if !ok {
+		return nil, ErrNoOperator
+	}
+	return o, nil
+}
+
+// getPath returns the path of the user/device/stream being resolved
+func getPath(p gql.ResolveParams) string {
+	m, _ := p.Source.(map[string]interface{})
+	s, _ := m["path"].(string)
+	return s
+}
+
+// substreamPath returns the path of the stream's downlink if downlink is set
+func substreamPath(p gql.ResolveParams) string {
+	path := getPath(p)
+	if downlink, _ := p.Args["downlink"].(bool); downlink {
+		return path + "/downlink"
+	}
+	return path
+}
+
+// readRange reads up to limit datapoints of the DataRange into an array
+func readRange(dr datastream.DataRange, err error, limit int64) (datastream.DatapointArray, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer dr.Close()
+	dpa := datastream.DatapointArray{}
+	for int64(len(dpa)) < limit {
+		dp, err := dr.Next()
+		if err != nil {
+			return nil, err
+		}
+		if dp == nil {
+			return dpa, nil
+		}
+		dpa = append(dpa, *dp)
+	}
+	return dpa, nil
+}
+
+// dataLimit returns the number of datapoints to return for the given limit argument
+func dataLimit(p gql.ResolveParams) int64 {
+	limit, _ := p.Args["limit"].(int)
+	if limit <= 0 || limit > MaxDataLimit {
+		return MaxDataLimit
+	}
+	return int64(limit)
+}
+
+// The JSON scalar is used for datapoint data, which can be any valid JSON
+var jsonType = gql.NewScalar(gql.ScalarConfig{
+	Name:        "JSON",
+	Description: "Arbitrary JSON data",
+	Serialize:   func(value interface{}) interface{} { return value },
+	ParseValue:  func(value interface{}) interface{} { return value },
+	ParseLiteral: func(valueAST ast.Value) interface{} {
+		return valueAST.GetValue()
+	},
+})
+
+var datapointType = gql.NewObject(gql.ObjectConfig{
+	Name: "Datapoint",
+	Fields: gql.Fields{
+		"t": &gql.Field{
+			Type:        gql.Float,
+			Description: "The unix timestamp of the datapoint",
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				return p.Source.(datastream.Datapoint).Timestamp, nil
+			},
+		},
+		"d": &gql.Field{
+			Type:        jsonType,
+			Description: "The datapoint's data",
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				return p.Source.(datastream.Datapoint).Data, nil
+			},
+		},
+		"o": &gql.Field{
+			Type:        gql.String,
+			Description: "The device which inserted the datapoint, if it is not the stream's owner",
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				return p.Source.(datastream.Datapoint).Sender, nil
+			},
+		},
+	},
+})
+
+var streamType = gql.NewObject(gql.ObjectConfig{
+	Name: "Stream",
+	Fields: gql.Fields{
+		"path":        &gql.Field{Type: gql.String},
+		"name":        &gql.Field{Type: gql.String},
+		"nickname":    &gql.Field{Type: gql.String},
+		"description": &gql.Field{Type: gql.String},
+		"icon":        &gql.Field{Type: gql.String},
+		"schema":      &gql.Field{Type: gql.String},
+		"datatype":    &gql.Field{Type: gql.String},
+		"ephemeral":   &gql.Field{Type: gql.Boolean},
+		"downlink":    &gql.Field{Type: gql.Boolean},
+		"backfill":    &gql.Field{Type: gql.Boolean},
+		"length": &gql.Field{
+			Type:        gql.Int,
+			Description: "The number of datapoints in the stream",
+			Args: gql.FieldConfigArgument{
+				"downlink": &gql.ArgumentConfig{Type: gql.Boolean},
+			},
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				o, err := getOperator(p)
+				if err != nil {
+					return nil, err
+				}
+				return o.LengthStream(substreamPath(p))
+			},
+		},
+		"data": &gql.Field{
+			Type:        gql.NewList(datapointType),
+			Description: "A range of the stream's data. If t1 or t2 are given, the range is by time, otherwise it is by index. " +
+				"At most limit datapoints are returned",
+			Args: gql.FieldConfigArgument{
+				"i1":        &gql.ArgumentConfig{Type: gql.Int},
+				"i2":        &gql.ArgumentConfig{Type: gql.Int},
+				"t1":        &gql.ArgumentConfig{Type: gql.Float},
+				"t2":        &gql.ArgumentConfig{Type: gql.Float},
+				"limit":     &gql.ArgumentConfig{Type: gql.Int, DefaultValue: DefaultDataLimit},
+				"transform": &gql.ArgumentConfig{Type: gql.String, DefaultValue: ""},
+				"unit":      &gql.ArgumentConfig{Type: gql.String, DefaultValue: ""},
+				"downlink":  &gql.ArgumentConfig{Type: gql.Boolean},
+			},
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				o, err := getOperator(p)
+				if err != nil {
+					return nil, err
+				}
+				path := substreamPath(p)
+				transform, _ := p.Args["transform"].(string)
+				unit, _ := p.Args["unit"].(string)
+				if transform, err = query.UnitTransform(o, path, unit, transform); err != nil {
+					return nil, err
+				}
+
+				limit := dataLimit(p)
+
+				t1, hast1 := p.Args["t1"].(float64)
+				t2, hast2 := p.Args["t2"].(float64)
+				if hast1 || hast2 {
+					dr, err := o.GetStreamTimeRange(path, t1, t2, limit, transform)
+					return readRange(dr, err, limit)
+				}
+				i1, hasi1 := p.Args["i1"].(int)
+				i2, hasi2 := p.Args["i2"].(int)
+				if !hasi1 && !hasi2 {
+					return nil, ErrNoRange
+				}
+				dr, err := o.GetStreamIndexRange(path, int64(i1), int64(i2), transform)
+				return readRange(dr, err, limit)
+			},
+		},
+	},
+})
+
+var deviceType = gql.NewObject(gql.ObjectConfig{
+	Name: "Device",
+	Fields: gql.Fields{
+		"path":                &gql.Field{Type: gql.String},
+		"name":                &gql.Field{Type: gql.String},
+		"nickname":            &gql.Field{Type: gql.String},
+		"description":         &gql.Field{Type: gql.String},
+		"icon":                &gql.Field{Type: gql.String},
+		"apikey":              &gql.Field{Type: gql.String},
+		"enabled":             &gql.Field{Type: gql.Boolean},
+		"public":              &gql.Field{Type: gql.Boolean},
+		"role":                &gql.Field{Type: gql.String},
+		"visible":             &gql.Field{Type: gql.Boolean},
+		"user_editable":       &gql.Field{Type: gql.Boolean},
+		"auto_create_streams": &gql.Field{Type: gql.Boolean},
+		"influx_mapping":      &gql.Field{Type: gql.String},
+		"webhook_token":       &gql.Field{Type: gql.String},
+		"webhook_rules":       &gql.Field{Type: gql.String},
+		"lastseen": &gql.Field{
+			Type:        gql.Float,
+			Description: "The unix timestamp of the device's most recent request",
+		},
+		"online": &gql.Field{
+			Type:        gql.Boolean,
+			Description: "Whether the device made a request within the presence timeout",
+		},
+		"streams": &gql.Field{
+			Type: gql.NewList(streamType),
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				o, err := getOperator(p)
+				if err != nil {
+					return nil, err
+				}
+				devpath := getPath(p)
+				s, err := o.ReadDeviceStreamsToMap(devpath)
+				return withPaths(devpath+"/", s), err
+			},
+		},
+	},
+})
+
+var userType = gql.NewObject(gql.ObjectConfig{
+	Name: "User",
+	Fields: gql.Fields{
+		"path":        &gql.Field{Type: gql.String},
+		"name":        &gql.Field{Type: gql.String},
+		"nickname":    &gql.Field{Type: gql.String},
+		"email":       &gql.Field{Type: gql.String},
+		"description": &gql.Field{Type: gql.String},
+		"icon":        &gql.Field{Type: gql.String},
+		"role":        &gql.Field{Type: gql.String},
+		"public":      &gql.Field{Type: gql.Boolean},
+		"devices": &gql.Field{
+			Type: gql.NewList(deviceType),
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				o, err := getOperator(p)
+				if err != nil {
+					return nil, err
+				}
+				uname := getPath(p)
+				d, err := o.ReadUserDevicesToMap(uname)
+				return withPaths(uname+"/", d), err
+			},
+		},
+	},
+})
+
+// withPaths sets the "path" of each of the maps, so that their children can be resolved
+func withPaths(prefix string, m []map[string]interface{}) []map[string]interface{} {
+	for i := range m {
+		name, _ := m[i]["name"].(string)
+		m[i]["path"] = prefix + name
+	}
+	return m
+}
+
+// withPath sets the "path" of the map
+func withPath(path string, m map[string]interface{}, err error) (interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+	m["path"] = path
+	return m, nil
+}
+
+var queryType = gql.NewObject(gql.ObjectConfig{
+	Name: "Query",
+	Fields: gql.Fields{
+		"users": &gql.Field{
+			Type: gql.NewList(userType),
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				o, err := getOperator(p)
+				if err != nil {
+					return nil, err
+				}
+				u, err := o.ReadAllUsersToMap()
+				return withPaths("", u), err
+			},
+		},
+		"user": &gql.Field{
+			Type: userType,
+			Args: gql.FieldConfigArgument{
+				"name": &gql.ArgumentConfig{Type: gql.NewNonNull(gql.String)},
+			},
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				o, err := getOperator(p)
+				if err != nil {
+					return nil, err
+				}
+				name := p.Args["name"].(string)
+				u, err := o.ReadUserToMap(name)
+				return withPath(name, u, err)
+			},
+		},
+		"device": &gql.Field{
+			Type: deviceType,
+			Args: gql.FieldConfigArgument{
+				"path": &gql.ArgumentConfig{Type: gql.NewNonNull(gql.String)},
+			},
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				o, err := getOperator(p)
+				if err != nil {
+					return nil, err
+				}
+				path := p.Args["path"].(string)
+				d, err := o.ReadDeviceToMap(path)
+				return withPath(path, d, err)
+			},
+		},
+		"stream": &gql.Field{
+			Type: streamType,
+			Args: gql.FieldConfigArgument{
+				"path": &gql.ArgumentConfig{Type: gql.NewNonNull(gql.String)},
+			},
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				o, err := getOperator(p)
+				if err != nil {
+					return nil, err
+				}
+				path := p.Args["path"].(string)
+				s, err := o.ReadStreamToMap(path)
+				return withPath(path, s, err)
+			},
+		},
+	},
+})
+
+// subscriber is given the streams that a subscription operation subscribes to
+type subscriber interface {
+	Subscribe(streampath string) error
+}
+
+// The subscription type is resolved in two different ways. When the subscription is started, the root contains a subscriber,
+// and the resolvers subscribe to their streams. Afterwards, the operation is run once for each message from the messenger,
+// which is given in the root, and the resolvers return the message's datapoints if it is from their stream.
+var subscriptionType = gql.NewObject(gql.ObjectConfig{
+	Name: "Subscription",
+	Fields: gql.Fields{
+		"stream": &gql.Field{
+			Type:        gql.NewList(datapointType),
+			Description: "The datapoints inserted into the stream",
+			Args: gql.FieldConfigArgument{
+				"path":      &gql.ArgumentConfig{Type: gql.NewNonNull(gql.String)},
+				"transform": &gql.ArgumentConfig{Type: gql.String, DefaultValue: ""},
+				"unit":      &gql.ArgumentConfig{Type: gql.String, DefaultValue: ""},
+			},
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				root := getRoot(p)
+				path := p.Args["path"].(string)
+				if s, ok := root["subscriber"].(subscriber); ok {
+					return nil, s.Subscribe(path)
+				}
+				msg, ok := root["message"].(*messenger.Message)
+				if !ok || msg.Stream != path {
+					return nil, nil
+				}
+				transform, _ := p.Args["transform"].(string)
+				if unit, _ := p.Args["unit"].(string); unit != "" {
+					o, err := getOperator(p)
+					if err != nil {
+						return nil, err
+					}
+					if transform, err = query.UnitTransform(o, path, unit, transform); err != nil {
+						return nil, err
+					}
+				}
+				if transform == "" {
+					return msg.Data, nil
+				}
+				script, err := pipescript.Parse(transform)
+				if err != nil {
+					return nil, err
+				}
+				dpa, err := query.TransformArray(script, &msg.Data)
+				if err != nil {
+					return nil, err
+				}
+				return *dpa, nil
+			},
+		},
+	},
+})
+
+func init() {
+	var err error
+	Schema, err = gql.NewSchema(gql.SchemaConfig{
+		Query:        queryType,
+		Subscription: subscriptionType,
+	})
+	if err != nil {
+		panic(err)
+	}
+}
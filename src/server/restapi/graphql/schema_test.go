@@ -0,0 +1,246 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package graphql
+
+import (
+	"config"
+	"connectordb"
+	"connectordb/datastream"
+	"connectordb/messenger"
+	"connectordb/users"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+
+	logrus "github.com/Sirupsen/logrus"
+)
+
+var Tdb *connectordb.Database
+
+func init() {
+	db, err := connectordb.Open(config.TestConfiguration.Options())
+	if err != nil {
+		log.Fatal(err)
+	}
+	Tdb = db
+	go db.RunWriter()
+}
+
+// setupQueryTest creates a private user with a stream holding 5 datapoints, and a second user
+func setupQueryTest(t *testing.T) {
+	Tdb.Clear()
+	require.NoError(t, Tdb.CreateUser(&users.UserMaker{User: users.User{Name: "myuser", Email: "my@email", Password: "test", Role: "user", Public: false}}))
+	require.NoError(t, Tdb.CreateUser(&users.UserMaker{User: users.User{Name: "other", Email: "other@email", Password: "test", Role: "user", Public: true}}))
+	require.NoError(t, Tdb.CreateDevice("myuser/mydevice", &users.DeviceMaker{}))
+	require.NoError(t, Tdb.CreateStream("myuser/mydevice/mystream", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"number"}`}}))
+	require.NoError(t, Tdb.InsertStream("myuser/mydevice/mystream", datastream.DatapointArray{
+		datastream.Datapoint{Timestamp: 1, Data: 1.0},
+		datastream.Datapoint{Timestamp: 2, Data: 2.0},
+		datastream.Datapoint{Timestamp: 3, Data: 3.0},
+		datastream.Datapoint{Timestamp: 4, Data: 4.0},
+		datastream.Datapoint{Timestamp: 5, Data: 5.0},
+	}, false))
+}
+
+// runQuery runs the query as the given user, and returns the result's data as JSON, and its errors
+func runQuery(t *testing.T, username string, query string) (string, int) {
+	o, err := Tdb.AsUser(username)
+	require.NoError(t, err)
+	res := (&Request{Query: query}).Run(map[string]interface{}{"operator": o})
+	b, err := json.Marshal(res.Data)
+	require.NoError(t, err)
+	return string(b), len(res.Errors)
+}
+
+func TestQuery(t *testing.T) {
+	setupQueryTest(t)
+
+	res, nerr := runQuery(t, "myuser", `{stream(path: "myuser/mydevice/mystream") {name schema length}}`)
+	require.Equal(t, 0, nerr)
+	require.JSONEq(t, `{"stream": {"name": "mystream", "schema": "{\"type\":\"number\"}", "length": 5}}`, res)
+
+	res, nerr = runQuery(t, "myuser", `{user(name: "myuser") {devices {name streams {path}}}}`)
+	require.Equal(t, 0, nerr)
+	require.Contains(t, res, `{"name":"mydevice","streams":[{"path":"myuser/mydevice/mystream"}]}`)
+
+	_, nerr = runQuery(t, "myuser", `{stream(path: "myuser/mydevice/nostream") {name}}`)
+	require.Equal(t, 1, nerr)
+
+	// Queries without an operator fail
+	res2 := (&Request{Query: `{stream(path: "myuser/mydevice/mystream") {name}}`}).Run(map[string]interface{}{})
+	require.True(t, res2.HasErrors())
+}
+
+func TestQueryData(t *testing.T) {
+	setupQueryTest(t)
+
+	res, nerr := runQuery(t, "myuser", `{stream(path: "myuser/mydevice/mystream") {data(i1: 1, i2: 3) {t d}}}`)
+	require.Equal(t, 0, nerr)
+	require.JSONEq(t, `{"stream": {"data": [{"t": 2, "d": 2}, {"t": 3, "d": 3}]}}`, res)
+
+	res, nerr = runQuery(t, "myuser", `{stream(path: "myuser/mydevice/mystream") {data(t1: 3.5) {t}}}`)
+	require.Equal(t, 0, nerr)
+	require.JSONEq(t, `{"stream": {"data": [{"t": 4}, {"t": 5}]}}`, res)
+
+	res, nerr = runQuery(t, "myuser", `{stream(path: "myuser/mydevice/mystream") {data(i1: 0, limit: 2) {t}}}`)
+	require.Equal(t, 0, nerr)
+	require.JSONEq(t, `{"stream": {"data": [{"t": 1}, {"t": 2}]}}`, res)
+
+	res, nerr = runQuery(t, "myuser", `{stream(path: "myuser/mydevice/mystream") {data(i1: -1, transform: "$+1") {d}}}`)
+	require.Equal(t, 0, nerr)
+	require.JSONEq(t, `{"stream": {"data": [{"d": 6}]}}`, res)
+
+	// The whole stream is not read without a range
+	_, nerr = runQuery(t, "myuser", `{stream(path: "myuser/mydevice/mystream") {data {t}}}`)
+	require.Equal(t, 1, nerr)
+}
+
+func TestQueryPermissions(t *testing.T) {
+	setupQueryTest(t)
+
+	// The other user can't read the private user's streams, devices or data
+	_, nerr := runQuery(t, "other", `{stream(path: "myuser/mydevice/mystream") {name}}`)
+	require.Equal(t, 1, nerr)
+	_, nerr = runQuery(t, "other", `{device(path: "myuser/mydevice") {name}}`)
+	require.Equal(t, 1, nerr)
+	_, nerr = runQuery(t, "other", `{user(name: "myuser") {name}}`)
+	require.Equal(t, 1, nerr)
+
+	// Only the public fields of a public user are returned
+	res, nerr := runQuery(t, "myuser", `{user(name: "other") {name email}}`)
+	require.Equal(t, 0, nerr)
+	require.JSONEq(t, `{"user": {"name": "other", "email": null}}`, res)
+	res, nerr = runQuery(t, "myuser", `{user(name: "myuser") {name email}}`)
+	require.Equal(t, 0, nerr)
+	require.JSONEq(t, `{"user": {"name": "myuser", "email": "my@email"}}`, res)
+
+	// Users can't list all users
+	_, nerr = runQuery(t, "other", `{users {name}}`)
+	require.Equal(t, 1, nerr)
+}
+
+func TestQueryPresence(t *testing.T) {
+	setupQueryTest(t)
+
+	res, nerr := runQuery(t, "myuser", `{device(path: "myuser/mydevice") {lastseen online}}`)
+	require.Equal(t, 0, nerr)
+	require.JSONEq(t, `{"device": {"lastseen": 0, "online": false}}`, res)
+}
+
+func TestQueryVariables(t *testing.T) {
+	setupQueryTest(t)
+	o, err := Tdb.AsUser("myuser")
+	require.NoError(t, err)
+	logger := logrus.NewEntry(logrus.StandardLogger())
+
+	// The variables of GET requests are given as JSON in the query string
+	q := url.Values{}
+	q.Set("query", `query ($p: String!) {stream(path: $p) {name}}`)
+	q.Set("variables", `{"p": "myuser/mydevice/mystream"}`)
+	w := httptest.NewRecorder()
+	Query(o, w, httptest.NewRequest("GET", "/?"+q.Encode(), nil), logger)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"data": {"stream": {"name": "mystream"}}}`, w.Body.String())
+
+	q.Set("variables", `{"p":`)
+	w = httptest.NewRecorder()
+	Query(o, w, httptest.NewRequest("GET", "/?"+q.Encode(), nil), logger)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// testSubscriber records the streams that a subscription subscribes to
+type testSubscriber []string
+
+func (s *testSubscriber) Subscribe(streampath string) error {
+	*s = append(*s, streampath)
+	return nil
+}
+
+func TestSubscription(t *testing.T) {
+	setupQueryTest(t)
+	o, err := Tdb.AsUser("myuser")
+	require.NoError(t, err)
+
+	r := &Request{Query: `subscription {stream(path: "myuser/mydevice/mystream", transform: "$*2") {d}}`}
+
+	// Starting the subscription subscribes to its streams
+	var s testSubscriber
+	res := r.Run(map[string]interface{}{"operator": o, "subscriber": &s})
+	require.False(t, res.HasErrors())
+	require.Equal(t, testSubscriber{"myuser/mydevice/mystream"}, s)
+
+	// Messages are then run through the subscription
+	msg := messenger.Message{Stream: "myuser/mydevice/mystream", Data: datastream.DatapointArray{datastream.Datapoint{Timestamp: 6, Data: 6.0}}}
+	res = r.Run(map[string]interface{}{"operator": o, "message": &msg})
+	require.False(t, res.HasErrors())
+	b, err := json.Marshal(res.Data)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"stream": [{"d": 12}]}`, string(b))
+
+	msg.Stream = "myuser/mydevice/other"
+	res = r.Run(map[string]interface{}{"operator": o, "message": &msg})
+	b, err = json.Marshal(res.Data)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"stream": null}`, string(b))
+}
+
+func TestCheckSubscriptions(t *testing.T) {
+	setupQueryTest(t)
+	o, err := Tdb.AsUser("myuser")
+	require.NoError(t, err)
+	logger := logrus.NewEntry(logrus.StandardLogger())
+	require.NoError(t, Tdb.CreateStream("myuser/mydevice/otherstream", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"number"}`}}))
+
+	conns := make(chan *SubscriptionConnection, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := NewSubscriptionConnection(o, w, r, logger)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		conns <- c
+		c.Run()
+	}))
+	defer srv.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+	defer ws.Close()
+	c := <-conns
+
+	require.NoError(t, ws.WriteJSON(map[string]interface{}{"id": "1", "type": "start", "payload": map[string]interface{}{
+		"query": `subscription {stream(path: "myuser/mydevice/mystream") {d}}`,
+	}}))
+	require.NoError(t, ws.WriteJSON(map[string]interface{}{"id": "2", "type": "start", "payload": map[string]interface{}{
+		"query": `subscription {stream(path: "myuser/mydevice/otherstream") {d}}`,
+	}}))
+	require.NoError(t, ws.WriteJSON(map[string]interface{}{"id": "3", "type": "stop"}))
+
+	// The stop message is answered once both operations were started
+	var msg subscriptionMessage
+	require.NoError(t, ws.ReadJSON(&msg))
+	require.Equal(t, subscriptionMessage{ID: "3", Type: "complete"}, msg)
+
+	// Operations subscribed to streams that can no longer be read are stopped
+	require.NoError(t, Tdb.DeleteStream("myuser/mydevice/mystream"))
+	require.NoError(t, c.CheckSubscriptions())
+	require.NoError(t, ws.ReadJSON(&msg))
+	require.Equal(t, "1", msg.ID)
+	require.Equal(t, "error", msg.Type)
+
+	c.Lock()
+	_, ok := c.operations["1"]
+	require.False(t, ok)
+	_, ok = c.operations["2"]
+	require.True(t, ok)
+	c.Unlock()
+}
@@ -0,0 +1,350 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package graphql
+
+import (
+	"config"
+	"connectordb/authoperator"
+	"connectordb/messenger"
+	"io"
+	"net/http"
+	"server/webcore"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/nats-io/nats"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+var (
+	// upgrader is initialized in the router
+	upgrader websocket.Upgrader
+
+	//WaitGroup is the WaitGroup of subscription websockets that are currently open
+	WaitGroup = sync.WaitGroup{}
+)
+
+// subscriptionMessage is the message sent both ways on a subscription websocket. The client sends "start" messages
+// with a Request as payload, and "stop" messages. The server responds with "data", "error" and "complete" messages.
+type subscriptionMessage struct {
+	ID      string      `json:"id"`
+	Type    string      `json:"type"`
+	Payload interface{} `json:"payload,omitempty"`
+}
+
+// clientMessage is a subscriptionMessage as received from the client
+type clientMessage struct {
+	ID      string  `json:"id"`
+	Type    string  `json:"type"`
+	Payload Request `json:"payload"`
+}
+
+// operation is a running subscription
+type operation struct {
+	Request
+	ID      string
+	streams map[string]bool // The streams which the operation subscribed to
+}
+
+// SubscriptionConnection runs GraphQL subscriptions over a websocket
+type SubscriptionConnection struct {
+	sync.Mutex //Protects operations and streams
+
+	ws         *websocket.Conn
+	writeMutex sync.Mutex
+
+	operations map[string]*operation
+	streams    map[string]*nats.Subscription
+	refcount   map[string]int // The number of operations subscribed to each stream
+
+	c chan messenger.Message
+
+	logger *log.Entry
+	o      *authoperator.AuthOperator
+}
+
+// operationSubscriber subscribes to streams for an operation when it is started
+type operationSubscriber struct {
+	c  *SubscriptionConnection
+	op *operation
+}
+
+// Subscribe subscribes the operation to the given stream. It is called with the connection locked.
+func (s operationSubscriber) Subscribe(streampath string) error {
+	if s.op.streams[streampath] {
+		return nil
+	}
+	if s.c.refcount[streampath] == 0 {
+		subs, err := s.c.o.Subscribe(streampath, s.c.c)
+		if err != nil {
+			return err
+		}
+		s.c.streams[streampath] = subs
+	}
+	s.c.refcount[streampath]++
+	s.op.streams[streampath] = true
+	return nil
+}
+
+//NewSubscriptionConnection upgrades the request to a websocket which runs subscriptions
+func NewSubscriptionConnection(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (*SubscriptionConnection, error) {
+	ws, err := upgrader.Upgrade(writer, request, nil)
+	if err != nil {
+		logger.Errorln(err)
+		return nil, err
+	}
+
+	ws.SetReadLimit(config.Get().Websocket.MessageLimitBytes)
+
+	return &SubscriptionConnection{
+		ws:         ws,
+		operations: make(map[string]*operation),
+		streams:    make(map[string]*nats.Subscription),
+		refcount:   make(map[string]int),
+		c:          make(chan messenger.Message, config.Get().Websocket.MessageBuffer),
+		logger:     logger,
+		o:          o,
+	}, nil
+}
+
+func (c *SubscriptionConnection) write(msg *subscriptionMessage) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	c.ws.SetWriteDeadline(time.Now().Add(config.Get().Websocket.WriteWait * time.Second))
+	return c.ws.WriteJSON(msg)
+}
+
+func (c *SubscriptionConnection) writeControl(messageCode int) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	c.ws.SetWriteDeadline(time.Now().Add(config.Get().Websocket.WriteWait * time.Second))
+	return c.ws.WriteMessage(messageCode, []byte{})
+}
+
+// stop removes the operation's subscriptions. It is called with the connection locked.
+func (c *SubscriptionConnection) stop(op *operation) {
+	for s := range op.streams {
+		c.refcount[s]--
+		if c.refcount[s] <= 0 {
+			c.streams[s].Unsubscribe()
+			delete(c.streams, s)
+			delete(c.refcount, s)
+		}
+	}
+	delete(c.operations, op.ID)
+}
+
+//Start starts the given subscription operation
+func (c *SubscriptionConnection) Start(id string, r Request) error {
+	logger := c.logger.WithFields(log.Fields{"cmd": "start", "arg": id})
+	c.Lock()
+	if op, ok := c.operations[id]; ok {
+		c.stop(op)
+	}
+	op := &operation{r, id, make(map[string]bool)}
+	res := op.Run(map[string]interface{}{
+		"operator":   c.o,
+		"subscriber": operationSubscriber{c, op},
+	})
+	if res.HasErrors() {
+		c.stop(op)
+		c.Unlock()
+		logger.Debugln(res.Errors)
+		return c.write(&subscriptionMessage{id, "error", res.Errors})
+	}
+	c.operations[id] = op
+	c.Unlock()
+	logger.Debugln()
+	return nil
+}
+
+//Stop stops the given subscription operation
+func (c *SubscriptionConnection) Stop(id string) error {
+	c.logger.WithFields(log.Fields{"cmd": "stop", "arg": id}).Debugln()
+	c.Lock()
+	if op, ok := c.operations[id]; ok {
+		c.stop(op)
+	}
+	c.Unlock()
+	return c.write(&subscriptionMessage{ID: id, Type: "complete"})
+}
+
+//CheckSubscriptions makes sure that the subscribed streams can still be read (permissions can be lost, and streams deleted).
+//The operations subscribed to streams which can't be read are stopped, and an error is sent for each of them.
+func (c *SubscriptionConnection) CheckSubscriptions() error {
+	var stopped []*subscriptionMessage
+	c.Lock()
+	invalid := make(map[string]error)
+	for stream := range c.streams {
+		if messenger.IsPattern(stream) {
+			// Permissions of wildcard subscriptions are checked for each message
+			continue
+		}
+		if _, err := c.o.ReadStream(stream); err != nil {
+			c.logger.Warnf("Invalidated: %s", stream)
+			invalid[stream] = err
+		}
+	}
+	for id, op := range c.operations {
+		for s := range op.streams {
+			if err, ok := invalid[s]; ok {
+				c.stop(op)
+				stopped = append(stopped, &subscriptionMessage{id, "error", []map[string]interface{}{{"message": err.Error()}}})
+				break
+			}
+		}
+	}
+	c.Unlock()
+
+	for _, msg := range stopped {
+		if err := c.write(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Close stops all subscriptions and closes the websocket
+func (c *SubscriptionConnection) Close() {
+	c.Lock()
+	for _, op := range c.operations {
+		c.stop(op)
+	}
+	c.Unlock()
+	close(c.c)
+	c.ws.Close()
+	c.logger.WithField("cmd", "close").Debugln()
+}
+
+// processMessage runs all operations subscribed to the message's stream
+func (c *SubscriptionConnection) processMessage(msg messenger.Message) error {
+	c.Lock()
+	ops := make([]*operation, 0, len(c.operations))
+	for _, op := range c.operations {
+		if op.streams[msg.Stream] {
+			ops = append(ops, op)
+		}
+	}
+	c.Unlock()
+
+	for _, op := range ops {
+		res := op.Run(map[string]interface{}{
+			"operator": c.o,
+			"message":  &msg,
+		})
+		if err := c.write(&subscriptionMessage{op.ID, "data", res}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//RunReader reads the start/stop messages from the client until the websocket is closed
+func (c *SubscriptionConnection) RunReader() {
+	c.ws.SetReadDeadline(time.Now().Add(config.Get().Websocket.PongWait * time.Second))
+	c.ws.SetPongHandler(func(string) error {
+		c.ws.SetReadDeadline(time.Now().Add(config.Get().Websocket.PongWait * time.Second))
+		return nil
+	})
+
+	for {
+		var msg clientMessage
+		err := c.ws.ReadJSON(&msg)
+		if err != nil {
+			if err != io.EOF {
+				c.logger.Warningln(err)
+			}
+			return
+		}
+		switch msg.Type {
+		default:
+			c.logger.Warningln("Command not recognized:", msg.Type)
+		case "start":
+			err = c.Start(msg.ID, msg.Payload)
+		case "stop":
+			err = c.Stop(msg.ID)
+		}
+		if err != nil {
+			c.logger.Warningln(err)
+			return
+		}
+	}
+}
+
+//RunWriter writes the subscription data as well as the heartbeat pings, until done is closed
+func (c *SubscriptionConnection) RunWriter(done chan bool) {
+	ticker := time.NewTicker(config.Get().Websocket.PingPeriod * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case msg, ok := <-c.c:
+			if !ok {
+				return
+			}
+			if err := c.processMessage(msg); err != nil {
+				c.logger.Errorf("Writing failed: %s. Killing connection.", err.Error())
+				c.ws.Close()
+				return
+			}
+		case <-ticker.C:
+			if c.writeControl(websocket.PingMessage) != nil {
+				c.ws.Close()
+				return
+			}
+
+			//Now, let's make sure that the active subscriptions are still valid
+			if err := c.CheckSubscriptions(); err != nil {
+				c.logger.Errorf("Writing failed: %s. Killing connection.", err.Error())
+				c.ws.Close()
+				return
+			}
+		case <-done:
+			c.writeControl(websocket.CloseMessage)
+			return
+		case <-webcore.ShutdownChannel:
+			webcore.ShutdownChannel <- true
+			c.writeControl(websocket.CloseMessage)
+			// Closing the websocket makes the reader exit
+			c.ws.Close()
+			return
+		}
+	}
+}
+
+//Run the subscription websocket until it is closed
+func (c *SubscriptionConnection) Run() {
+	WaitGroup.Add(1)
+	defer WaitGroup.Done()
+
+	done := make(chan bool)
+	writerExit := make(chan bool)
+	go func() {
+		c.RunWriter(done)
+		close(writerExit)
+	}()
+	c.RunReader()
+	close(done)
+
+	select {
+	case <-writerExit:
+	case <-time.After(config.Get().Websocket.WriteWait * time.Second):
+		c.logger.Error("writer exit timeout")
+	}
+}
+
+//RunSubscriptions runs GraphQL subscriptions over a websocket
+func RunSubscriptions(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	conn, err := NewSubscriptionConnection(o, writer, request, logger)
+	if err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		return 3, err.Error()
+	}
+	defer conn.Close()
+	conn.Run()
+	return 0, "GraphQL websocket closed"
+}
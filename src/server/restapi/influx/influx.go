@@ -0,0 +1,185 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package influx
+
+import (
+	"bufio"
+	"compress/gzip"
+	"config"
+	"connectordb"
+	"connectordb/authoperator"
+	"connectordb/datastream"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"server/restapi/restcore"
+	"server/webcore"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	//DefaultMapping is the stream path template used for devices which have no influx_mapping set
+	DefaultMapping = "{measurement}"
+
+	//MaxLineSize is the maximum length of a single line of line protocol in bytes
+	MaxLineSize = 1024 * 1024
+
+	//ErrTooLarge is returned when the decompressed data of a write is larger than the insert limit
+	ErrTooLarge = errors.New("The write is larger than the insert limit")
+)
+
+//LineError is the error of a single line of a write
+type LineError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+//WriteResult is returned when some of the lines of a write could not be inserted
+type WriteResult struct {
+	Error   string      `json:"error"`
+	Written int         `json:"written"` // The number of lines which were inserted
+	Errors  []LineError `json:"errors"`
+}
+
+type lineSorter []LineError
+
+func (s lineSorter) Len() int           { return len(s) }
+func (s lineSorter) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s lineSorter) Less(i, j int) bool { return s[i].Line < s[j].Line }
+
+//Write inserts InfluxDB line protocol data written by the authenticated device. Each line's measurement and tags are
+//mapped to a stream path using the device's influx_mapping, and the line's fields become the datapoint's object.
+func Write(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	dev, err := o.Device()
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	mapping := dev.InfluxMapping
+	if mapping == "" {
+		mapping = DefaultMapping
+	}
+
+	precision := request.URL.Query().Get("precision")
+	if _, ok := precisions[precision]; !ok {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, fmt.Errorf("Unknown timestamp precision '%s'", precision), false)
+	}
+
+	// Both the request and its decompressed data are limited to the insert limit. The decompressed data is read
+	// up to one byte past the limit, so that a write which is too large can be told apart from one which fits exactly.
+	limit := config.Get().InsertLimitBytes
+	request.Body = http.MaxBytesReader(writer, request.Body, limit)
+	body := &io.LimitedReader{R: request.Body, N: limit + 1}
+	if request.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(request.Body)
+		if err != nil {
+			return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
+		}
+		defer gz.Close()
+		body.R = gz
+	}
+
+	now := float64(time.Now().UnixNano()) * 1e-9
+	var lineErrors []LineError
+	data := make(map[string]datastream.DatapointArray)
+	streamLines := make(map[string][]int)
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), MaxLineSize)
+	lines := 0
+	for scanner.Scan() {
+		lines++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p, err := ParseLine(line)
+		if err != nil {
+			lineErrors = append(lineErrors, LineError{lines, err.Error()})
+			continue
+		}
+		streampath, err := p.StreamPath(mapping, o.Name())
+		if err != nil {
+			lineErrors = append(lineErrors, LineError{lines, err.Error()})
+			continue
+		}
+		ts, _ := p.Time(precision, now)
+		data[streampath] = append(data[streampath], datastream.Datapoint{Timestamp: ts, Data: p.Fields})
+		streamLines[streampath] = append(streamLines[streampath], lines)
+	}
+	if err = scanner.Err(); err != nil {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
+	}
+	if body.N <= 0 {
+		return restcore.WriteError(writer, logger, http.StatusRequestEntityTooLarge, ErrTooLarge, false)
+	}
+
+	for _, dpa := range data {
+		dpa.SortByTime()
+	}
+
+	tins := time.Now()
+	failed := o.InsertStreams("", data, false)
+
+	written := 0
+	for streampath, lines := range streamLines {
+		if err, ok := failed[streampath]; ok {
+			for _, line := range lines {
+				lineErrors = append(lineErrors, LineError{line, err.Error()})
+			}
+		} else {
+			written += len(lines)
+		}
+	}
+	atomic.AddUint32(&webcore.StatsInserts, uint32(written))
+
+	querylog := fmt.Sprintf("Influx write %d into %d streams", written, len(data)-len(failed))
+	lvl := webcore.DEBUG
+	insertTime := time.Since(tins)
+	if insertTime.Seconds() > 0.5 {
+		querylog += fmt.Sprintf(" - INSERT_TIME: %s!", insertTime.String())
+		lvl = webcore.WARNING
+	}
+
+	if len(lineErrors) == 0 {
+		writer.WriteHeader(http.StatusNoContent)
+		return lvl, querylog
+	}
+
+	sort.Stable(lineSorter(lineErrors))
+	res, err := json.Marshal(WriteResult{
+		Error:   fmt.Sprintf("partial write: %d of %d lines failed", len(lineErrors), len(lineErrors)+written),
+		Written: written,
+		Errors:  lineErrors,
+	})
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusInternalServerError, err, true)
+	}
+	writer.Header().Set("Content-Length", strconv.Itoa(len(res)))
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writer.WriteHeader(http.StatusBadRequest)
+	writer.Write(res)
+	return webcore.INFO, querylog + fmt.Sprintf(" - %d lines failed", len(lineErrors))
+}
+
+//Router returns a fully formed Gorilla router given an optional prefix
+func Router(db *connectordb.Database, prefix *mux.Router) *mux.Router {
+	if prefix == nil {
+		prefix = mux.NewRouter()
+	}
+
+	prefix.HandleFunc("/write", restcore.Authenticator(Write, db)).Methods("POST")
+
+	return prefix
+}
@@ -0,0 +1,209 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package influx
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	//ErrNoFields is returned when a line has no fields
+	ErrNoFields = errors.New("The line has no fields")
+	//ErrNoMeasurement is returned when a line has no measurement
+	ErrNoMeasurement = errors.New("The line has no measurement")
+)
+
+//Point is a single line of InfluxDB line protocol
+type Point struct {
+	Measurement string
+	Tags        map[string]string
+	Fields      map[string]interface{}
+
+	Timestamp    int64 // The timestamp in the precision of the write
+	HasTimestamp bool
+}
+
+//indexUnescaped returns the index of the first c in s which is not escaped with a backslash. If quotes is set,
+//characters inside double quotes are skipped. Returns -1 if there is no such character.
+func indexUnescaped(s string, c byte, quotes bool) int {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case quotes && s[i] == '"':
+			quoted = !quoted
+		case s[i] == c && !quoted:
+			return i
+		}
+	}
+	return -1
+}
+
+//splitUnescaped splits s on each c which is not escaped (or quoted, if quotes is set)
+func splitUnescaped(s string, c byte, quotes bool) []string {
+	var result []string
+	for i := indexUnescaped(s, c, quotes); i != -1; i = indexUnescaped(s, c, quotes) {
+		result = append(result, s[:i])
+		s = s[i+1:]
+	}
+	return append(result, s)
+}
+
+var unescaper = strings.NewReplacer(`\,`, ",", `\=`, "=", `\ `, " ", `\"`, `"`, `\\`, `\`)
+
+//parseKeyValue splits a "key=value" pair
+func parseKeyValue(s string, quotes bool) (string, string, error) {
+	i := indexUnescaped(s, '=', quotes)
+	if i <= 0 {
+		return "", "", fmt.Errorf("Invalid key-value pair '%s'", s)
+	}
+	return unescaper.Replace(s[:i]), s[i+1:], nil
+}
+
+//parseFieldValue converts the field value to the type given by its syntax
+func parseFieldValue(v string) (interface{}, error) {
+	if len(v) == 0 {
+		return nil, errors.New("Missing field value")
+	}
+	if v[0] == '"' {
+		if len(v) < 2 || v[len(v)-1] != '"' {
+			return nil, fmt.Errorf("Unterminated string field value %s", v)
+		}
+		return strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(v[1 : len(v)-1]), nil
+	}
+	switch v {
+	case "t", "T", "true", "True", "TRUE":
+		return true, nil
+	case "f", "F", "false", "False", "FALSE":
+		return false, nil
+	}
+	switch v[len(v)-1] {
+	case 'i':
+		return strconv.ParseInt(v[:len(v)-1], 10, 64)
+	case 'u':
+		return strconv.ParseUint(v[:len(v)-1], 10, 64)
+	}
+	return strconv.ParseFloat(v, 64)
+}
+
+//ParseLine parses a single line of InfluxDB line protocol:
+//	measurement[,tag=value...] field=value[,field=value...] [timestamp]
+func ParseLine(line string) (*Point, error) {
+	i := indexUnescaped(line, ' ', false)
+	if i == -1 {
+		return nil, ErrNoFields
+	}
+	key, rest := line[:i], strings.TrimLeft(line[i+1:], " ")
+	i = indexUnescaped(rest, ' ', true)
+	fields, timestamp := rest, ""
+	if i != -1 {
+		fields, timestamp = rest[:i], strings.TrimSpace(rest[i+1:])
+	}
+
+	p := &Point{Tags: make(map[string]string), Fields: make(map[string]interface{})}
+
+	keys := splitUnescaped(key, ',', false)
+	p.Measurement = unescaper.Replace(keys[0])
+	if p.Measurement == "" {
+		return nil, ErrNoMeasurement
+	}
+	for _, t := range keys[1:] {
+		k, v, err := parseKeyValue(t, false)
+		if err != nil {
+			return nil, err
+		}
+		p.Tags[k] = unescaper.Replace(v)
+	}
+
+	if fields == "" {
+		return nil, ErrNoFields
+	}
+	for _, f := range splitUnescaped(fields, ',', true) {
+		k, v, err := parseKeyValue(f, true)
+		if err != nil {
+			return nil, err
+		}
+		if p.Fields[k], err = parseFieldValue(v); err != nil {
+			return nil, fmt.Errorf("Invalid value of field '%s': %s", k, err.Error())
+		}
+	}
+
+	if timestamp != "" {
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid timestamp '%s'", timestamp)
+		}
+		p.Timestamp = ts
+		p.HasTimestamp = true
+	}
+	return p, nil
+}
+
+//precisions is the number of nanoseconds in each of the timestamp precisions
+var precisions = map[string]float64{
+	"":   1,
+	"n":  1,
+	"ns": 1,
+	"u":  1e3,
+	"us": 1e3,
+	"ms": 1e6,
+	"s":  1e9,
+	"m":  60e9,
+	"h":  3600e9,
+}
+
+//Time returns the point's timestamp in seconds, given the precision of the write. Points without a timestamp
+//get the given default time.
+func (p *Point) Time(precision string, now float64) (float64, error) {
+	ns, ok := precisions[precision]
+	if !ok {
+		return 0, fmt.Errorf("Unknown timestamp precision '%s'", precision)
+	}
+	if !p.HasTimestamp {
+		return now, nil
+	}
+	return float64(p.Timestamp) * ns / 1e9, nil
+}
+
+var placeholder = regexp.MustCompile(`\{([^{}]+)\}`)
+
+//StreamPath returns the path of the stream into which the point is inserted. The mapping is a template of the path,
+//in which {measurement} is replaced with the point's measurement, and {tagname} with the value of the point's tag.
+//A mapping with no slashes is a stream of the given device, and a mapping with one slash is a device/stream
+//of the device's user.
+func (p *Point) StreamPath(mapping, devicepath string) (string, error) {
+	var err error
+	path := placeholder.ReplaceAllStringFunc(mapping, func(m string) string {
+		name := m[1 : len(m)-1]
+		v, ok := p.Tags[name]
+		if name == "measurement" {
+			v, ok = p.Measurement, true
+		}
+		if !ok && err == nil {
+			err = fmt.Errorf("The line has no tag '%s' required by the mapping '%s'", name, mapping)
+		}
+		if strings.Contains(v, "/") && err == nil {
+			err = fmt.Errorf("The value '%s' of '%s' can't be used in a stream path", v, name)
+		}
+		return v
+	})
+	if err != nil {
+		return "", err
+	}
+	switch strings.Count(path, "/") {
+	case 0:
+		return devicepath + "/" + path, nil
+	case 1:
+		return strings.Split(devicepath, "/")[0] + "/" + path, nil
+	case 2, 3:
+		return path, nil
+	}
+	return "", fmt.Errorf("The mapping '%s' gives the invalid stream path '%s'", mapping, path)
+}
@@ -0,0 +1,102 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package influx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLine(t *testing.T) {
+	p, err := ParseLine(`cpu,host=server01,region=us-west value=0.64,count=3i,free=12u,up=t,name="hi, there \"you\"" 1434055562000000000`)
+	require.NoError(t, err)
+	require.Equal(t, "cpu", p.Measurement)
+	require.Equal(t, map[string]string{"host": "server01", "region": "us-west"}, p.Tags)
+	require.Equal(t, map[string]interface{}{
+		"value": 0.64,
+		"count": int64(3),
+		"free":  uint64(12),
+		"up":    true,
+		"name":  `hi, there "you"`,
+	}, p.Fields)
+	require.True(t, p.HasTimestamp)
+	require.Equal(t, int64(1434055562000000000), p.Timestamp)
+
+	p, err = ParseLine(`my\ measurement,tag\,key=a\ b\=c value=FALSE`)
+	require.NoError(t, err)
+	require.Equal(t, "my measurement", p.Measurement)
+	require.Equal(t, map[string]string{"tag,key": "a b=c"}, p.Tags)
+	require.Equal(t, map[string]interface{}{"value": false}, p.Fields)
+	require.False(t, p.HasTimestamp)
+
+	p, err = ParseLine(`weather temp="a b" 5`)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"temp": "a b"}, p.Fields)
+	require.Equal(t, int64(5), p.Timestamp)
+
+	for _, l := range []string{
+		`cpu`,
+		`cpu `,
+		`,host=a value=1`,
+		`cpu,host value=1`,
+		`cpu value=`,
+		`cpu value=abc`,
+		`cpu value="abc`,
+		`cpu value=1 notatime`,
+		`cpu value=1.5i`,
+	} {
+		_, err = ParseLine(l)
+		require.Error(t, err, l)
+	}
+}
+
+func TestPointTime(t *testing.T) {
+	p := &Point{Timestamp: 1434055562000000000, HasTimestamp: true}
+	ts, err := p.Time("", 0)
+	require.NoError(t, err)
+	require.Equal(t, 1434055562., ts)
+
+	p.Timestamp = 1434055562500
+	ts, err = p.Time("ms", 0)
+	require.NoError(t, err)
+	require.Equal(t, 1434055562.5, ts)
+
+	p.Timestamp = 1434055562
+	ts, err = p.Time("s", 0)
+	require.NoError(t, err)
+	require.Equal(t, 1434055562., ts)
+
+	_, err = p.Time("y", 0)
+	require.Error(t, err)
+
+	p.HasTimestamp = false
+	ts, err = p.Time("ns", 12.)
+	require.NoError(t, err)
+	require.Equal(t, 12., ts)
+}
+
+func TestPointStreamPath(t *testing.T) {
+	p := &Point{Measurement: "cpu", Tags: map[string]string{"host": "server01", "bad": "a/b"}}
+
+	s, err := p.StreamPath(DefaultMapping, "usr/dev")
+	require.NoError(t, err)
+	require.Equal(t, "usr/dev/cpu", s)
+
+	s, err = p.StreamPath("{host}/{measurement}", "usr/dev")
+	require.NoError(t, err)
+	require.Equal(t, "usr/server01/cpu", s)
+
+	s, err = p.StreamPath("other/{host}/{measurement}_stats", "usr/dev")
+	require.NoError(t, err)
+	require.Equal(t, "other/server01/cpu_stats", s)
+
+	_, err = p.StreamPath("{region}/{measurement}", "usr/dev")
+	require.Error(t, err)
+	_, err = p.StreamPath("{bad}", "usr/dev")
+	require.Error(t, err)
+	_, err = p.StreamPath("a/b/c/d/{measurement}", "usr/dev")
+	require.Error(t, err)
+}
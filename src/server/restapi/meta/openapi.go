@@ -0,0 +1,527 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package meta
+
+import (
+	"connectordb"
+	"net/http"
+	"server/restapi/restcore"
+	"server/webcore"
+)
+
+// OpenAPISpec is the (subset of the) OpenAPI 3 document which describes the REST API.
+// Whenever a route is added to one of the routers, it must also be added to APISpec.
+type OpenAPISpec struct {
+	OpenAPI    string                              `json:"openapi"`
+	Info       map[string]string                   `json:"info"`
+	Servers    []map[string]string                 `json:"servers"`
+	Paths      map[string]map[string]*APIOperation `json:"paths"`
+	Components map[string]map[string]JSONSchema    `json:"components"`
+}
+
+// JSONSchema is a JSON schema used in the OpenAPI document
+type JSONSchema map[string]interface{}
+
+// APIParameter is a path or query parameter of an operation
+type APIParameter struct {
+	Name        string     `json:"name"`
+	In          string     `json:"in"`
+	Description string     `json:"description,omitempty"`
+	Required    bool       `json:"required,omitempty"`
+	Schema      JSONSchema `json:"schema"`
+}
+
+// APIContent is a request or response body
+type APIContent struct {
+	Description string                `json:"description,omitempty"`
+	Content     map[string]JSONSchema `json:"content,omitempty"`
+}
+
+// APIOperation is a single method of a path in the REST API
+type APIOperation struct {
+	OperationID string                 `json:"operationId"`
+	Summary     string                 `json:"summary"`
+	Tags        []string               `json:"tags,omitempty"`
+	Parameters  []APIParameter         `json:"parameters,omitempty"`
+	RequestBody *APIContent            `json:"requestBody,omitempty"`
+	Responses   map[string]*APIContent `json:"responses"`
+}
+
+func ref(name string) JSONSchema {
+	return JSONSchema{"$ref": "#/components/schemas/" + name}
+}
+
+func arrayOf(s JSONSchema) JSONSchema {
+	return JSONSchema{"type": "array", "items": s}
+}
+
+var (
+	stringSchema  = JSONSchema{"type": "string"}
+	integerSchema = JSONSchema{"type": "integer"}
+	numberSchema  = JSONSchema{"type": "number"}
+	booleanSchema = JSONSchema{"type": "boolean"}
+)
+
+func pathParams(names ...string) []APIParameter {
+	p := make([]APIParameter, len(names))
+	for i := range names {
+		p[i] = APIParameter{Name: names[i], In: "path", Required: true, Schema: stringSchema}
+	}
+	return p
+}
+
+func queryParam(name, description string, schema JSONSchema) APIParameter {
+	return APIParameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+func headerParam(name, description string, schema JSONSchema) APIParameter {
+	return APIParameter{Name: name, In: "header", Description: description, Schema: schema}
+}
+
+// qParam is the "q" query parameter, which selects between several operations on the same path
+func qParam(values ...string) APIParameter {
+	return APIParameter{Name: "q", In: "query", Required: true, Schema: JSONSchema{"type": "string", "enum": values}}
+}
+
+func jsonBody(s JSONSchema) *APIContent {
+	return &APIContent{Content: map[string]JSONSchema{"application/json": JSONSchema{"schema": s}}}
+}
+
+// responses returns the successful response with the given content type and schema, and the error response
+func responses(contenttype string, s JSONSchema) map[string]*APIContent {
+	return map[string]*APIContent{
+		"200": &APIContent{
+			Description: "Success",
+			Content:     map[string]JSONSchema{contenttype: JSONSchema{"schema": s}},
+		},
+		"default": &APIContent{
+			Description: "Error",
+			Content:     map[string]JSONSchema{"application/json": JSONSchema{"schema": ref("Error")}},
+		},
+	}
+}
+
+func op(tag, id, summary string, result JSONSchema, params ...APIParameter) *APIOperation {
+	return &APIOperation{
+		OperationID: id,
+		Summary:     summary,
+		Tags:        []string{tag},
+		Parameters:  params,
+		Responses:   responses("application/json", result),
+	}
+}
+
+func withBody(o *APIOperation, body JSONSchema) *APIOperation {
+	o.RequestBody = jsonBody(body)
+	return o
+}
+
+// formatParam selects the format in which a range of data is returned
+var formatParam = queryParam("format", "The format of the data: json (default), parquet or arrow (IPC stream)",
+	JSONSchema{"type": "string", "enum": []string{"json", "parquet", "arrow"}})
+
+// withColumnar adds the Parquet and Arrow outputs of the format parameter to the operation's response
+func withColumnar(o *APIOperation) *APIOperation {
+	binary := JSONSchema{"schema": JSONSchema{"type": "string", "format": "binary"}}
+	o.Responses["200"].Content["application/vnd.apache.parquet"] = binary
+	o.Responses["200"].Content["application/vnd.apache.arrow.stream"] = binary
+	return o
+}
+
+// rangeParams are the query parameters of a data range
+var rangeParams = []APIParameter{
+	queryParam("i1", "The first index of the range. Negative indices are from the end of the stream", integerSchema),
+	queryParam("i2", "The end index of the range (exclusive)", integerSchema),
+	queryParam("t1", "The start time of the range", numberSchema),
+	queryParam("t2", "The end time of the range", numberSchema),
+	queryParam("limit", "The maximum number of datapoints to return from a time range", integerSchema),
+	queryParam("transform", "A PipeScript transform to run on the data", stringSchema),
+	queryParam("downlink", "Whether to use the stream's downlink", booleanSchema),
+	queryParam("upgrade", "Whether to upgrade the data to the stream's latest schema version", booleanSchema),
+	queryParam("unit", "The unit to convert the data to", stringSchema),
+	formatParam,
+}
+
+// insertParams are the parameters of an insert
+var insertParams = []APIParameter{
+	queryParam("downlink", "Insert into the stream's downlink", booleanSchema),
+	headerParam("Idempotency-Key", "Retrying an insert with the same key acknowledges it without writing the data twice", stringSchema),
+}
+
+var (
+	userParams   = pathParams("user")
+	deviceParams = pathParams("user", "device")
+	streamParams = pathParams("user", "device", "stream")
+)
+
+// restoreParam takes the deleted user, device or stream out of the trash instead of updating it
+var restoreParam = queryParam("q", "restore", JSONSchema{"type": "string", "enum": []string{"restore"}})
+
+// aliasParam keeps the old path of a renamed device or moved stream working
+var aliasParam = queryParam("alias", "Whether the old path keeps resolving to the renamed device or moved stream", booleanSchema)
+
+var (
+	deviceUpdateParams = params(deviceParams, queryParam("q", "restore, rename or transfer", stringSchema),
+		queryParam("name", "The new name of the device", stringSchema), aliasParam,
+		queryParam("to", "The user to transfer the device to", stringSchema))
+	streamUpdateParams = params(streamParams, queryParam("q", "restore, move or clone", stringSchema),
+		queryParam("to", "The user/device/stream path to move or clone the stream to", stringSchema), aliasParam,
+		queryParam("t1", "When cloning, the start of the time range of data to copy", numberSchema),
+		queryParam("t2", "When cloning, the end of the time range of data to copy", numberSchema))
+)
+
+func params(p []APIParameter, extra ...APIParameter) []APIParameter {
+	return append(append([]APIParameter{}, p...), extra...)
+}
+
+// APISpec is the OpenAPI description of the REST API, relative to /api/v1
+var APISpec = OpenAPISpec{
+	OpenAPI: "3.0.0",
+	Info: map[string]string{
+		"title":   "ConnectorDB",
+		"version": connectordb.Version,
+	},
+	Servers: []map[string]string{{"url": "/api/v1"}},
+	Paths: map[string]map[string]*APIOperation{
+		"/": {
+			"get": op("root", "Query", "Returns the name of the authenticated device (q=this), or the number of users, devices or streams in the database", integerSchema,
+				qParam("this", "countusers", "countdevices", "countstreams")),
+		},
+		"/login": {
+			"get": op("root", "Login", "Creates a session cookie for the authenticated device", ref("OK")),
+		},
+		"/logout": {
+			"get": op("root", "Logout", "Deletes the session cookie", ref("OK")),
+		},
+		"/websocket": {
+			"get": op("root", "Websocket", "Upgrades to a websocket which allows inserting and subscribing to streams. insert_bulk inserts into multiple streams at once. Subscriptions can use wildcard patterns such as user/*/stream, subscribe_meta sends metadata change events, and subscribe_commands sends the commands of a downlink stream", JSONSchema{},
+				queryParam("protocol", "The websocket protocol version (1 or 2). Version 2 acknowledges each command", integerSchema)),
+		},
+
+		"/crud": {
+			"get": op("users", "ListUsers", "Lists all users", arrayOf(ref("User")), qParam("ls")),
+			"post": withBody(op("data", "BulkInsert", "Inserts into multiple streams, given as an object of stream paths to datapoint arrays. Each stream succeeds or fails on its own",
+				ref("BulkInsertResults"), insertParams[1:]...), ref("BulkInsert")),
+			"put": withBody(op("data", "BulkInsertRestamp", "Inserts into multiple streams, restamping datapoints which are older than their stream's most recent datapoint",
+				ref("BulkInsertResults"), insertParams[1:]...), ref("BulkInsert")),
+		},
+		"/crud/{user}": {
+			"get": op("users", "ReadUser", "Reads the user. With q=ls or q=devices lists the user's devices, with q=streams lists all of the user's streams, "+
+				"and with q=trash lists what the user has in the trash",
+				ref("User"), params(userParams,
+					queryParam("q", "ls, devices, streams or trash", stringSchema),
+					queryParam("public", "When listing streams, only list public streams", booleanSchema),
+					queryParam("downlink", "When listing streams, only list downlink streams", booleanSchema),
+					queryParam("visible", "When listing streams, only list streams of visible devices", booleanSchema))...),
+			"post":   withBody(op("users", "CreateUser", "Creates the user", ref("User"), userParams...), ref("User")),
+			"put":    withBody(op("users", "UpdateUser", "Updates the user's fields. With q=restore, restores the user from the trash", ref("User"), params(userParams, restoreParam)...), ref("User")),
+			"delete": op("users", "DeleteUser", "Deletes the user, along with its devices and streams. With a trash period, they are moved to the trash", ref("OK"), userParams...),
+		},
+		"/crud/{user}/{device}": {
+			"get": op("devices", "ReadDevice", "Reads the device. With q=ls or q=streams lists the device's streams", ref("Device"),
+				params(deviceParams, queryParam("q", "ls or streams", stringSchema))...),
+			"post":   withBody(op("devices", "CreateDevice", "Creates the device", ref("Device"), deviceParams...), ref("Device")),
+			"put":    withBody(op("devices", "UpdateDevice", "Updates the device's fields. With q=restore, restores the device from the trash, with q=rename, renames the device to name, and with q=transfer, gives the device to the user in to", ref("Device"), deviceUpdateParams...), ref("Device")),
+			"delete": op("devices", "DeleteDevice", "Deletes the device, along with its streams. With a trash period, they are moved to the trash", ref("OK"), deviceParams...),
+		},
+		"/crud/{user}/{device}/{stream}": {
+			"get":    op("streams", "ReadStream", "Reads the stream", ref("Stream"), streamParams...),
+			"post":   withBody(op("streams", "CreateStream", "Creates the stream", ref("Stream"), streamParams...), ref("Stream")),
+			"put":    withBody(op("streams", "UpdateStream", "Updates the stream's fields. With q=restore, restores the stream from the trash, with q=move, moves the stream and its data to the path in to, and with q=clone, copies them there", ref("Stream"), streamUpdateParams...), ref("Stream")),
+			"delete": op("streams", "DeleteStream", "Deletes the stream. With a trash period, it is moved to the trash", ref("OK"), streamParams...),
+		},
+		"/crud/{user}/{device}/{stream}/schema": {
+			"get": op("streams", "ReadStreamSchemas", "Lists the versions of the stream's schema", arrayOf(ref("StreamSchema")), streamParams...),
+			"put": withBody(op("streams", "UpdateStreamSchema", "Sets a new version of the stream's schema", ref("Stream"), streamParams...),
+				ref("SchemaChange")),
+		},
+		"/crud/{user}/{device}/{stream}/schema/check": {
+			"post": withBody(op("streams", "CheckStreamSchema", "Counts the existing datapoints which would violate a proposed schema",
+				ref("SchemaCheck"), streamParams...), ref("SchemaChange")),
+		},
+		"/crud/{user}/{device}/{stream}/commands": {
+			"get": op("commands", "ListCommands", "Lists the commands queued on the downlink stream", arrayOf(ref("Command")),
+				params(streamParams, queryParam("status", "Only list commands with the given status (pending, delivered, done, failed or expired)", stringSchema))...),
+			"post": withBody(op("commands", "SendCommand", "Queues a command on the downlink stream", ref("Command"), streamParams...), ref("CommandRequest")),
+		},
+		"/crud/{user}/{device}/{stream}/commands/{command}": {
+			"get": op("commands", "ReadCommand", "Reads the command, including its status and result", ref("Command"),
+				params(streamParams, pathParams("command")...)...),
+			"post": withBody(op("commands", "UpdateCommand", "Acknowledges the command, setting its status to delivered, done or failed along with an optional result",
+				ref("Command"), params(streamParams, pathParams("command")...)...), ref("CommandUpdate")),
+		},
+		"/crud/{user}/{device}/{stream}/data": {
+			"get": withColumnar(op("data", "StreamRange", "Reads a range of the stream's data. With q=length returns the stream's length, and with q=time2index the index of the given time. "+
+				"With subscribe=sse, subscribes to the stream's data as server-sent events, which can be resumed with the Last-Event-ID header",
+				arrayOf(ref("Datapoint")), params(streamParams, append([]APIParameter{
+					queryParam("q", "length or time2index", stringSchema),
+					queryParam("t", "The time to convert to an index (q=time2index)", numberSchema),
+					queryParam("subscribe", "sse", stringSchema),
+				}, rangeParams...)...)...)),
+			"post": withBody(op("data", "InsertStream", "Inserts datapoints into the stream", ref("OK"),
+				params(streamParams, insertParams...)...), arrayOf(ref("Datapoint"))),
+			"put": withBody(op("data", "InsertStreamRestamp", "Inserts datapoints into the stream, restamping any that are older than the stream's most recent datapoint",
+				ref("OK"), params(streamParams, insertParams...)...), arrayOf(ref("Datapoint"))),
+		},
+
+		"/query/dataset": {
+			"post": withColumnar(withBody(op("query", "Dataset", "Generates a dataset from multiple streams", arrayOf(ref("Datapoint")), formatParam), ref("DatasetQuery"))),
+		},
+		"/query/merge": {
+			"post": withColumnar(withBody(op("query", "Merge", "Merges multiple streams into one", arrayOf(ref("Datapoint")), formatParam), arrayOf(ref("StreamQuery")))),
+		},
+
+		"/feed/{user}/{device}/{stream}": {
+			"get": &APIOperation{OperationID: "Feed", Summary: "The stream as an Atom feed", Tags: []string{"feed"},
+				Parameters: streamParams, Responses: responses("application/atom+xml", stringSchema)},
+		},
+		"/feed/{user}/{device}/{stream}.atom": {
+			"get": &APIOperation{OperationID: "FeedAtom", Summary: "The stream as an Atom feed", Tags: []string{"feed"},
+				Parameters: streamParams, Responses: responses("application/atom+xml", stringSchema)},
+		},
+
+		"/influx/write": {
+			"post": &APIOperation{OperationID: "InfluxWrite", Summary: "Inserts InfluxDB line protocol data written by the device. Lines are mapped to streams with the device's influx_mapping",
+				Tags: []string{"data"}, Parameters: []APIParameter{
+					queryParam("precision", "The precision of the timestamps", JSONSchema{"type": "string", "enum": []string{"ns", "u", "ms", "s", "m", "h"}}),
+				},
+				RequestBody: &APIContent{Content: map[string]JSONSchema{"text/plain": JSONSchema{"schema": stringSchema}}},
+				Responses: map[string]*APIContent{
+					"204":     &APIContent{Description: "All lines were written"},
+					"default": &APIContent{Description: "Error, or the lines which failed in a partial write", Content: map[string]JSONSchema{"application/json": JSONSchema{"schema": ref("InfluxWriteResult")}}},
+				}},
+		},
+
+		"/import/{user}/{device}": {
+			"post": &APIOperation{OperationID: "ImportActivity", Summary: "Imports a GPX, TCX or FIT activity file into streams of the device, skipping datapoints which the streams already have",
+				Tags: []string{"data"}, Parameters: params(deviceParams,
+					queryParam("filename", "The name of the file given as the request body, whose extension gives its format", stringSchema),
+					queryParam("location", "The stream of locations (an empty name skips the data)", stringSchema),
+					queryParam("elevation", "The stream of elevations", stringSchema),
+					queryParam("heartrate", "The stream of heart rates", stringSchema),
+					queryParam("cadence", "The stream of cadences", stringSchema),
+					queryParam("backfill", "Whether created streams backfill data older than their own, which shifts the indices of later datapoints", booleanSchema),
+				),
+				RequestBody: &APIContent{Content: map[string]JSONSchema{
+					"application/octet-stream": JSONSchema{"schema": JSONSchema{"type": "string", "format": "binary"}},
+					"multipart/form-data": JSONSchema{"schema": JSONSchema{"type": "object", "properties": JSONSchema{
+						"file": JSONSchema{"type": "string", "format": "binary"},
+					}}},
+				}},
+				Responses: responses("application/json", ref("ImportResults"))},
+		},
+
+		"/webhook/{token}": {
+			"post": &APIOperation{OperationID: "Webhook", Summary: "Receives a JSON payload sent to the device with the given webhook token. The device's webhook_rules map the payload to datapoints of its streams",
+				Tags: []string{"data"}, Parameters: pathParams("token"),
+				RequestBody: &APIContent{Content: map[string]JSONSchema{"application/json": JSONSchema{"schema": JSONSchema{}}}},
+				Responses:   responses("application/json", ref("WebhookResult"))},
+		},
+
+		"/takeout/{user}": {
+			"get": op("takeout", "TakeoutList", "Lists the user's takeout archives, both pending and ready for download, which were requested by the device or by devices with no more read access", arrayOf(ref("Takeout")), pathParams("user")...),
+			"post": op("takeout", "TakeoutRequest", "Starts writing an archive of all of the user's data in the background. The archive gets a download url with an expiry once it is ready",
+				ref("Takeout"), append(pathParams("user"), formatParam)...),
+		},
+		"/takeout/{user}/{id}": {
+			"get": &APIOperation{OperationID: "TakeoutDownload", Summary: "Downloads the zip archive of a takeout which is ready", Tags: []string{"takeout"},
+				Parameters: pathParams("user", "id"),
+				Responses:  responses("application/zip", JSONSchema{"type": "string", "format": "binary"})},
+		},
+
+		"/meta/transforms": {
+			"get": op("meta", "TransformList", "Lists the available PipeScript transforms", JSONSchema{"type": "object"}),
+		},
+		"/meta/interpolators": {
+			"get": op("meta", "InterpolatorList", "Lists the available interpolators", JSONSchema{"type": "object"}),
+		},
+		"/meta/datatypes": {
+			"get": op("meta", "DatatypeList", "Lists the known stream datatypes", JSONSchema{"type": "object"}),
+		},
+		"/meta/version": {
+			"get": &APIOperation{OperationID: "Version", Summary: "The ConnectorDB version", Tags: []string{"meta"},
+				Responses: responses("text/plain", stringSchema)},
+		},
+		"/meta/openapi.json": {
+			"get": op("meta", "OpenAPI", "This document", JSONSchema{"type": "object"}),
+		},
+
+		"/graphql": {
+			"get": op("graphql", "GraphQLQuery", "Runs the GraphQL query given in the query parameters, or upgrades to a websocket which runs GraphQL subscriptions",
+				ref("GraphQLResult"), queryParam("query", "The GraphQL query", stringSchema), queryParam("operationName", "", stringSchema)),
+			"post": withBody(op("graphql", "GraphQL", "Runs a GraphQL query", ref("GraphQLResult")), ref("GraphQLRequest")),
+		},
+	},
+	Components: map[string]map[string]JSONSchema{
+		"schemas": {
+			"OK": stringSchema,
+			"Error": JSONSchema{"type": "object", "properties": JSONSchema{
+				"code": integerSchema,
+				"msg":  stringSchema,
+				"ref":  stringSchema,
+			}},
+			"User": JSONSchema{"type": "object", "properties": JSONSchema{
+				"name":        stringSchema,
+				"nickname":    stringSchema,
+				"email":       stringSchema,
+				"description": stringSchema,
+				"icon":        stringSchema,
+				"role":        stringSchema,
+				"public":      booleanSchema,
+				"password":    stringSchema,
+			}},
+			"Device": JSONSchema{"type": "object", "properties": JSONSchema{
+				"name":                stringSchema,
+				"nickname":            stringSchema,
+				"description":         stringSchema,
+				"icon":                stringSchema,
+				"apikey":              stringSchema,
+				"enabled":             booleanSchema,
+				"public":              booleanSchema,
+				"role":                stringSchema,
+				"visible":             booleanSchema,
+				"user_editable":       booleanSchema,
+				"auto_create_streams": booleanSchema,
+				"influx_mapping":      stringSchema,
+				"webhook_token":       stringSchema,
+				"webhook_rules":       stringSchema,
+				"lastseen":            numberSchema,
+				"online":              booleanSchema,
+			}},
+			"Stream": JSONSchema{"type": "object", "properties": JSONSchema{
+				"name":          stringSchema,
+				"nickname":      stringSchema,
+				"description":   stringSchema,
+				"icon":          stringSchema,
+				"schema":        stringSchema,
+				"datatype":      stringSchema,
+				"ephemeral":     booleanSchema,
+				"downlink":      booleanSchema,
+				"backfill":      booleanSchema,
+				"schemaversion": integerSchema,
+			}},
+			"Datapoint": JSONSchema{"type": "object", "properties": JSONSchema{
+				"t": numberSchema,
+				"d": JSONSchema{},
+				"o": stringSchema,
+			}},
+			"StreamSchema": JSONSchema{"type": "object", "properties": JSONSchema{
+				"version":       integerSchema,
+				"schema":        stringSchema,
+				"upgrade":       stringSchema,
+				"startindex":    integerSchema,
+				"downlinkindex": integerSchema,
+			}},
+			"Command": JSONSchema{"type": "object", "properties": JSONSchema{
+				"id":        integerSchema,
+				"data":      JSONSchema{},
+				"sender":    stringSchema,
+				"status":    stringSchema,
+				"result":    JSONSchema{},
+				"timestamp": numberSchema,
+				"expires":   numberSchema,
+				"updated":   numberSchema,
+			}},
+			"CommandRequest": JSONSchema{"type": "object", "properties": JSONSchema{
+				"data": JSONSchema{},
+				"ttl":  numberSchema,
+			}},
+			"CommandUpdate": JSONSchema{"type": "object", "properties": JSONSchema{
+				"status": stringSchema,
+				"result": JSONSchema{},
+			}},
+			"SchemaChange": JSONSchema{"type": "object", "properties": JSONSchema{
+				"schema":  stringSchema,
+				"upgrade": stringSchema,
+			}},
+			"BulkInsert": JSONSchema{"type": "object", "additionalProperties": arrayOf(ref("Datapoint"))},
+			"BulkInsertResults": JSONSchema{"type": "object", "additionalProperties": JSONSchema{"type": "object", "properties": JSONSchema{
+				"inserted": integerSchema,
+				"error":    stringSchema,
+			}}},
+			"ImportResults": JSONSchema{"type": "object", "additionalProperties": JSONSchema{"type": "object", "properties": JSONSchema{
+				"stream":     stringSchema,
+				"inserted":   integerSchema,
+				"duplicates": integerSchema,
+				"late":       integerSchema,
+				"error":      stringSchema,
+			}}},
+			"InfluxWriteResult": JSONSchema{"type": "object", "properties": JSONSchema{
+				"error":   stringSchema,
+				"written": integerSchema,
+				"errors": arrayOf(JSONSchema{"type": "object", "properties": JSONSchema{
+					"line":  integerSchema,
+					"error": stringSchema,
+				}}),
+			}},
+			"WebhookResult": JSONSchema{"type": "object", "properties": JSONSchema{
+				"inserted": integerSchema,
+				"errors":   arrayOf(stringSchema),
+			}},
+			"Takeout": JSONSchema{"type": "object", "properties": JSONSchema{
+				"id":        stringSchema,
+				"user":      stringSchema,
+				"device":    stringSchema,
+				"format":    stringSchema,
+				"status":    JSONSchema{"type": "string", "enum": []string{"pending", "ready", "failed"}},
+				"requested": integerSchema,
+				"expires":   integerSchema,
+				"size":      integerSchema,
+				"error":     stringSchema,
+				"url":       stringSchema,
+			}},
+			"SchemaCheck": JSONSchema{"type": "object", "properties": JSONSchema{
+				"checked":    integerSchema,
+				"violations": integerSchema,
+			}},
+			"StreamQuery": JSONSchema{"type": "object", "properties": JSONSchema{
+				"stream":    stringSchema,
+				"transform": stringSchema,
+				"unit":      stringSchema,
+				"i1":        integerSchema,
+				"i2":        integerSchema,
+				"t1":        numberSchema,
+				"t2":        numberSchema,
+				"limit":     integerSchema,
+			}},
+			"DatasetQuery": JSONSchema{"type": "object", "properties": JSONSchema{
+				"stream":        stringSchema,
+				"merge":         arrayOf(ref("StreamQuery")),
+				"t1":            numberSchema,
+				"t2":            numberSchema,
+				"i1":            integerSchema,
+				"i2":            integerSchema,
+				"limit":         integerSchema,
+				"dt":            numberSchema,
+				"posttransform": stringSchema,
+				"dataset": JSONSchema{"type": "object", "additionalProperties": JSONSchema{"type": "object", "properties": JSONSchema{
+					"stream":       stringSchema,
+					"transform":    stringSchema,
+					"unit":         stringSchema,
+					"merge":        arrayOf(ref("StreamQuery")),
+					"interpolator": stringSchema,
+					"allownil":     booleanSchema,
+				}}},
+			}},
+			"GraphQLRequest": JSONSchema{"type": "object", "properties": JSONSchema{
+				"query":         stringSchema,
+				"variables":     JSONSchema{"type": "object"},
+				"operationName": stringSchema,
+			}},
+			"GraphQLResult": JSONSchema{"type": "object", "properties": JSONSchema{
+				"data":   JSONSchema{"type": "object"},
+				"errors": arrayOf(JSONSchema{"type": "object"}),
+			}},
+		},
+	},
+}
+
+//OpenAPI returns the OpenAPI description of the REST API
+func OpenAPI(writer http.ResponseWriter, request *http.Request) {
+	l := webcore.GetRequestLogger(request, "OpenAPI")
+
+	webcore.WriteAccessControlHeaders(writer, request)
+	restcore.JSONWriter(writer, APISpec, l, nil)
+}
@@ -6,6 +6,7 @@ package meta
 
 import (
 	"connectordb"
+	"connectordb/datatypes"
 	"net/http"
 	"server/restapi/restcore"
 	"server/webcore"
@@ -40,6 +41,17 @@ func InterpolatorList(writer http.ResponseWriter, request *http.Request) {
 
 }
 
+//DatatypeList returns the list of known stream datatypes, with their default schemas and units
+func DatatypeList(writer http.ResponseWriter, request *http.Request) {
+	l := webcore.GetRequestLogger(request, "DatatypeList")
+
+	webcore.WriteAccessControlHeaders(writer, request)
+	datatypes.RegistryLock.RLock()
+	defer datatypes.RegistryLock.RUnlock()
+	restcore.JSONWriter(writer, datatypes.Registry, l, nil)
+
+}
+
 //Version returns the ConnectorDB version being run
 func Version(writer http.ResponseWriter, request *http.Request) {
 	webcore.GetRequestLogger(request, "Version")
@@ -61,7 +73,9 @@ func Router(db *connectordb.Database, prefix *mux.Router) *mux.Router {
 
 	prefix.HandleFunc("/transforms", http.HandlerFunc(TransformList)).Methods("GET")
 	prefix.HandleFunc("/interpolators", http.HandlerFunc(InterpolatorList)).Methods("GET")
+	prefix.HandleFunc("/datatypes", http.HandlerFunc(DatatypeList)).Methods("GET")
 	prefix.HandleFunc("/version", http.HandlerFunc(Version)).Methods("GET")
+	prefix.HandleFunc("/openapi.json", http.HandlerFunc(OpenAPI)).Methods("GET")
 
 	return prefix
 }
@@ -25,7 +25,11 @@ func GenerateDataset(o *authoperator.AuthOperator, writer http.ResponseWriter, r
 		return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
 	}
 	dr, err := datasetquery.Run(o)
-	return restcore.WriteJSONResult(writer, dr, logger, err)
+	schema := ""
+	if err == nil && restcore.IsColumnar(request) {
+		schema = datasetquery.Schema(o)
+	}
+	return restcore.WriteResult(writer, request, dr, schema, logger, err)
 }
 
 //MergeStreams allows to generate a dataset of multiple streams at once to simplify analysis of data
@@ -36,7 +40,11 @@ func MergeStreams(o *authoperator.AuthOperator, writer http.ResponseWriter, requ
 		return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
 	}
 	dr, err := query.Merge(o, mergequery)
-	lvl, _ := restcore.WriteJSONResult(writer, dr, logger, err)
+	schema := ""
+	if err == nil && restcore.IsColumnar(request) {
+		schema = query.MergeSchema(o, mergequery)
+	}
+	lvl, _ := restcore.WriteResult(writer, request, dr, schema, logger, err)
 	return lvl, fmt.Sprintf("Merging %d streams", len(mergequery))
 }
 
@@ -6,14 +6,26 @@ package restcore
 
 import (
 	"connectordb"
+	"connectordb/authoperator"
 	"net/http"
 	"server/webcore"
 	"sync/atomic"
 	"time"
 )
 
+//Login finds the device making a request. If the login fails, the error is written with the returned status code.
+type Login func(request *http.Request) (*authoperator.AuthOperator, int, error)
+
 //Authenticator runs authentication on a request, making sure that the REST API can handle it
 func Authenticator(apifunc webcore.APIHandler, db *connectordb.Database) http.HandlerFunc {
+	return LoginAuthenticator(apifunc, func(request *http.Request) (*authoperator.AuthOperator, int, error) {
+		o, err := webcore.Authenticate(db, request)
+		return o, http.StatusUnauthorized, err
+	})
+}
+
+//LoginAuthenticator is an Authenticator which logs in the device making the request with the given function
+func LoginAuthenticator(apifunc webcore.APIHandler, login Login) http.HandlerFunc {
 	funcname := webcore.GetFuncName(apifunc)
 	qtimer := webcore.GetQueryTimer(funcname)
 
@@ -36,13 +48,18 @@ func Authenticator(apifunc webcore.APIHandler, db *connectordb.Database) http.Ha
 
 		webcore.WriteAccessControlHeaders(writer,request)
 
-		o, err := webcore.Authenticate(db, request)
+		o, status, err := login(request)
 		if err != nil {
-			WriteError(writer, logger, http.StatusUnauthorized, err, false)
+			WriteError(writer, logger, status, err, false)
 			return
 		}
 		l := logger.WithField("dev", o.Name())
 
+		// Each authenticated request shows that the device is online
+		if err = o.Seen(); err != nil {
+			l.Warn("Failed to update presence: ", err)
+		}
+
 		//Alright, run the api function
 		loglevel, txt := apifunc(o, writer, request, l)
 
@@ -31,6 +31,13 @@ var (
 	ErrInvalidName = errors.New("The given name did not pass sanitation.")
 	ErrBadQ        = errors.New("Unrecognized query command.")
 	ErrCantParse   = errors.New("The given query cannot be parsed, since the values could not be extracted")
+	ErrFormat      = errors.New("The format must be one of json, parquet or arrow")
+
+	//ColumnarFormats are the content types of the columnar formats in which ranges of data can be written
+	ColumnarFormats = map[string]string{
+		"parquet": "application/vnd.apache.parquet",
+		"arrow":   "application/vnd.apache.arrow.stream",
+	}
 )
 
 // safetyHeaders ensures that everything in the rest interface returns no-cache
@@ -262,6 +269,43 @@ func WriteJSONResult(writer http.ResponseWriter, dr datastream.DataRange, logger
 	return webcore.DEBUG, ""
 }
 
+//IsColumnar returns whether the request's format query parameter asks for data in a columnar format
+func IsColumnar(request *http.Request) bool {
+	_, ok := ColumnarFormats[request.URL.Query().Get("format")]
+	return ok
+}
+
+//WriteResult writes a DataRange as a response in the format given by the request's format query parameter, which is
+//JSON by default. The columns of Parquet and Arrow output are given by the JSON schema of the data, which is empty if unknown.
+func WriteResult(writer http.ResponseWriter, request *http.Request, dr datastream.DataRange, schema string, logger *log.Entry, err error) (int, string) {
+	format := request.URL.Query().Get("format")
+	if format == "" || format == "json" {
+		return WriteJSONResult(writer, dr, logger, err)
+	}
+	if err != nil {
+		return WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	defer dr.Close()
+	contentType, ok := ColumnarFormats[format]
+	if !ok {
+		return WriteError(writer, logger, http.StatusBadRequest, ErrFormat, false)
+	}
+
+	safetyHeaders(writer)
+	writer.Header().Set("Content-Type", contentType)
+	writer.WriteHeader(http.StatusOK)
+	if format == "parquet" {
+		err = datapoint.WriteParquet(writer, dr, schema)
+	} else {
+		err = datapoint.WriteArrow(writer, dr, schema)
+	}
+	if err != nil {
+		logger.Errorln(err)
+		return 3, err.Error()
+	}
+	return webcore.DEBUG, ""
+}
+
 //GetStreamPath returns the relevant parts of a stream path
 func GetStreamPath(request *http.Request) (username string, devicename string, streamname string, streampath string) {
 	username = mux.Vars(request)["user"]
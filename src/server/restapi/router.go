@@ -18,9 +18,14 @@ import (
 
 	"server/restapi/crud"
 	"server/restapi/feed"
+	"server/restapi/fileimport"
+	"server/restapi/graphql"
+	"server/restapi/influx"
 	"server/restapi/meta"
 	"server/restapi/query"
 	"server/restapi/restcore"
+	"server/restapi/takeout"
+	"server/restapi/webhook"
 	"server/webcore"
 
 	log "github.com/Sirupsen/logrus"
@@ -81,6 +86,7 @@ func (r restcloser) Close() {
 	webcore.Shutdown() //This is a sort of roundabout way of shutting everything down.
 	//might want to refactor the above down a directory level at some point
 	websocketWaitGroup.Wait()
+	graphql.WaitGroup.Wait()
 }
 
 //Router returns a fully formed Gorilla router given an optional prefix
@@ -112,7 +118,12 @@ func Router(db *connectordb.Database, prefix *mux.Router) (*mux.Router, error) {
 	crud.Router(db, prefix.PathPrefix("/crud").Subrouter())
 	query.Router(db, prefix.PathPrefix("/query").Subrouter())
 	feed.Router(db, prefix.PathPrefix("/feed").Subrouter())
+	influx.Router(db, prefix.PathPrefix("/influx").Subrouter())
+	fileimport.Router(db, prefix.PathPrefix("/import").Subrouter())
+	webhook.Router(db, prefix.PathPrefix("/webhook").Subrouter())
+	takeout.Router(db, prefix.PathPrefix("/takeout").Subrouter())
 	meta.Router(db, prefix.PathPrefix("/meta").Subrouter())
+	graphql.Router(db, prefix.PathPrefix("/graphql").Subrouter())
 
 	//login and Logout of the system
 	prefix.HandleFunc("/login", restcore.Authenticator(Login, db)).Methods("GET")
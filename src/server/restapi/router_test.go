@@ -0,0 +1,40 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package restapi
+
+import (
+	"server/restapi/meta"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+// Every route of the REST API must be described in the OpenAPI document
+func TestOpenAPISpec(t *testing.T) {
+	r := mux.NewRouter()
+	_, err := Router(nil, r.PathPrefix("/api/v1").Subrouter())
+	require.NoError(t, err)
+
+	err = r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		if route.GetHandler() == nil {
+			// The route is a subrouter's prefix
+			return nil
+		}
+		tpl, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		p := strings.TrimSuffix(strings.TrimPrefix(tpl, "/api/v1"), "/")
+		if p == "" {
+			p = "/"
+		}
+		_, ok := meta.APISpec.Paths[p]
+		require.True(t, ok, "Route %s is missing from the OpenAPI document", p)
+		return nil
+	})
+	require.NoError(t, err)
+}
@@ -0,0 +1,108 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package takeout
+
+import (
+	"connectordb"
+	"connectordb/authoperator"
+	"errors"
+	"net/http"
+	"os"
+	"server/restapi/restcore"
+	"server/webcore"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/gorilla/mux"
+)
+
+//ErrNotOwner is returned when a device attempts to access the takeouts of a different user
+var ErrNotOwner = errors.New("Takeout archives can only be accessed by the user's own devices")
+
+// owner checks that the user in the request path is the user of the authenticated device
+func owner(o *authoperator.AuthOperator, request *http.Request) (string, error) {
+	u, err := o.User()
+	if err != nil {
+		return "", err
+	}
+	if u.Name != mux.Vars(request)["user"] {
+		return "", ErrNotOwner
+	}
+	return u.Name, nil
+}
+
+//ListTakeouts returns the user's takeout archives, both pending and ready for download
+func ListTakeouts(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	username, err := owner(o, request)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	t, err := List(o, username)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusInternalServerError, err, true)
+	}
+	return restcore.JSONWriter(writer, t, logger, nil)
+}
+
+//RequestTakeout starts writing an archive of all of the user's data. The archive's status can be polled
+//with ListTakeouts, and it gets a download url once it is ready.
+func RequestTakeout(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	username, err := owner(o, request)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	format := request.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if _, ok := connectordb.ExportFormats[format]; !ok {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, restcore.ErrFormat, false)
+	}
+	t, err := Start(o, username, format)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusInternalServerError, err, true)
+	}
+	restcore.JSONWriter(writer, t, logger, nil)
+	return webcore.INFO, "Takeout " + t.ID
+}
+
+//DownloadTakeout writes the zip archive of a takeout that is ready
+func DownloadTakeout(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	username, err := owner(o, request)
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	t, err := Get(o, username, mux.Vars(request)["id"])
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusNotFound, err, false)
+	}
+	if t.Status != StatusReady {
+		return restcore.WriteError(writer, logger, http.StatusNotFound, ErrNotReady, false)
+	}
+	f, err := os.Open(t.filename(".zip"))
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusNotFound, ErrNotFound, false)
+	}
+	defer f.Close()
+
+	writer.Header().Set("Content-Type", "application/zip")
+	writer.Header().Set("Content-Disposition", "attachment; filename=\"connectordb-"+t.User+"-"+time.Unix(t.Requested, 0).Format("2006-01-02")+".zip\"")
+	http.ServeContent(writer, request, "", time.Time{}, f)
+	return webcore.INFO, "Downloaded takeout " + t.ID
+}
+
+//Router returns a fully formed Gorilla router given an optional prefix
+func Router(db *connectordb.Database, prefix *mux.Router) *mux.Router {
+	if prefix == nil {
+		prefix = mux.NewRouter()
+	}
+
+	prefix.HandleFunc("/{user}", restcore.Authenticator(ListTakeouts, db)).Methods("GET")
+	prefix.HandleFunc("/{user}", restcore.Authenticator(RequestTakeout, db)).Methods("POST")
+	prefix.HandleFunc("/{user}/{id}", restcore.Authenticator(DownloadTakeout, db)).Methods("GET")
+
+	return prefix
+}
@@ -0,0 +1,371 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package takeout
+
+import (
+	"archive/zip"
+	"config"
+	"connectordb"
+	"connectordb/authoperator"
+	"connectordb/authoperator/permissions"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	pconfig "config/permissions"
+
+	"github.com/nu7hatch/gouuid"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// The status of a takeout
+const (
+	StatusPending = "pending"
+	StatusReady   = "ready"
+	StatusFailed  = "failed"
+)
+
+var (
+	//ErrNotFound is returned when the takeout does not exist, or has expired
+	ErrNotFound = errors.New("The takeout archive does not exist or has expired")
+	//ErrNotReady is returned when downloading an archive which is still being written
+	ErrNotReady = errors.New("The takeout archive is not ready yet")
+)
+
+//Takeout is a user's request for an archive of all of their data. The archive has the same structure as the
+//directory written by connectordb export, but only with the user, and only what the requesting device can read.
+//The archive can therefore only be seen by devices which can read at least as much as the requesting device.
+type Takeout struct {
+	ID        string `json:"id"`
+	User      string `json:"user"`
+	Device    string `json:"device"` // The device which requested the archive
+	Format    string `json:"format"` // The format of the stream data in the archive
+	Status    string `json:"status"`
+	Requested int64  `json:"requested"`         // The unix time at which the archive was requested
+	Expires   int64  `json:"expires,omitempty"` // The unix time after which the archive can no longer be downloaded
+	Size      int64  `json:"size,omitempty"`    // The size of the archive in bytes
+	Error     string `json:"error,omitempty"`
+	URL       string `json:"url,omitempty"` // The download link of the archive once it is ready
+}
+
+var (
+	takeoutLock sync.Mutex
+	takeouts    map[string]*Takeout
+)
+
+// filename returns the path of the takeout's file with the given extension in the takeout directory
+func (t *Takeout) filename(ext string) string {
+	return filepath.Join(config.Get().TakeoutDirectory, t.ID+ext)
+}
+
+// save writes the takeout's status next to its archive, so that it survives restarts
+func (t *Takeout) save() error {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(t.filename(".json"), b, 0600)
+}
+
+func (t *Takeout) remove() {
+	os.Remove(t.filename(".zip"))
+	os.Remove(t.filename(".json"))
+}
+
+// load reads the takeouts in the takeout directory the first time that they are needed. Archives which
+// were still being written when ConnectorDB stopped have failed, and their files are removed. The takeoutLock
+// must be held.
+func load() error {
+	if takeouts != nil {
+		return nil
+	}
+	dir := config.Get().TakeoutDirectory
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	// The temporary directories of writeArchive are only left behind by archives which were being written
+	tmpdirs, err := filepath.Glob(filepath.Join(dir, ".*"))
+	if err != nil {
+		return err
+	}
+	for _, d := range tmpdirs {
+		if err = os.RemoveAll(d); err != nil {
+			log.Warnf("Failed to remove temporary takeout directory %s: %s", d, err.Error())
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	takeouts = make(map[string]*Takeout)
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		t := &Takeout{}
+		if err = json.Unmarshal(b, t); err != nil || t.ID+".json" != filepath.Base(f) {
+			log.Warnf("Skipping invalid takeout file %s", f)
+			continue
+		}
+		if t.Status == StatusPending {
+			os.Remove(t.filename(".zip"))
+			t.Status = StatusFailed
+			t.Error = "ConnectorDB was stopped while the archive was being written"
+			t.save()
+		}
+		takeouts[t.ID] = t
+	}
+	return nil
+}
+
+// expire removes the takeouts which have expired. Failed takeouts are kept for as long as archives would have been,
+// so that the user can see what happened. The takeoutLock must be held.
+func expire(now int64) {
+	for id, t := range takeouts {
+		if t.Expires > 0 && now > t.Expires || t.Status == StatusFailed && now > t.Requested+config.Get().TakeoutExpire {
+			t.remove()
+			delete(takeouts, id)
+		}
+	}
+}
+
+//RunExpiry periodically removes the expired takeouts, so that their archives are deleted even if the takeouts
+//are not looked at again. It also cleans up after archives which were being written when ConnectorDB stopped.
+func RunExpiry() {
+	for {
+		takeoutLock.Lock()
+		err := load()
+		if err == nil {
+			expire(time.Now().Unix())
+		}
+		takeoutLock.Unlock()
+		if err != nil {
+			log.Warn("Failed to expire takeouts: ", err)
+		}
+
+		// Check often enough that archives are removed at most a quarter of the expiry time late
+		interval := time.Duration(config.Get().TakeoutExpire) * time.Second / 4
+		if interval < time.Minute {
+			interval = time.Minute
+		}
+		if interval > time.Hour {
+			interval = time.Hour
+		}
+		time.Sleep(interval)
+	}
+}
+
+// canRead returns true if the operator's device can read everything that the takeout's device can read, so that
+// the takeout's archive doesn't show it anything that it could not read already. All of the devices belong to the
+// takeout's user, whose own access is the same for each, so only the devices' access levels are compared.
+func canRead(o *authoperator.AuthOperator, t *Takeout) bool {
+	if o.Name() == t.Device {
+		return true
+	}
+	u, d, err := o.UserAndDevice()
+	if err != nil {
+		return false
+	}
+	td, err := o.AdminOperator().ReadDevice(t.Device)
+	if err != nil || td.UserID != u.UserID {
+		return false
+	}
+	perm := pconfig.Get()
+	_, da := permissions.GetAccessLevels(perm, u, d, u.UserID, u.Public, false)
+	_, tda := permissions.GetAccessLevels(perm, u, td, u.UserID, u.Public, false)
+	return permissions.GetReadAccess(perm, da).Covers(permissions.GetReadAccess(perm, tda))
+}
+
+type requestSorter []Takeout
+
+func (s requestSorter) Len() int           { return len(s) }
+func (s requestSorter) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s requestSorter) Less(i, j int) bool { return s[i].Requested < s[j].Requested }
+
+//List returns the takeouts of the given user which the operator's device can read, in the order in which they were requested
+func List(o *authoperator.AuthOperator, username string) ([]Takeout, error) {
+	takeoutLock.Lock()
+	defer takeoutLock.Unlock()
+	if err := load(); err != nil {
+		return nil, err
+	}
+	expire(time.Now().Unix())
+
+	result := []Takeout{}
+	for _, t := range takeouts {
+		if t.User == username && canRead(o, t) {
+			result = append(result, *t)
+		}
+	}
+	sort.Sort(requestSorter(result))
+	return result, nil
+}
+
+//Get returns the takeout with the given ID belonging to the user, if the operator's device can read it
+func Get(o *authoperator.AuthOperator, username, id string) (*Takeout, error) {
+	takeoutLock.Lock()
+	defer takeoutLock.Unlock()
+	if err := load(); err != nil {
+		return nil, err
+	}
+	expire(time.Now().Unix())
+
+	t, ok := takeouts[id]
+	if !ok || t.User != username || !canRead(o, t) {
+		return nil, ErrNotFound
+	}
+	tcopy := *t
+	return &tcopy, nil
+}
+
+//Start starts writing an archive of the user's data in the background, using the given operator to read it. If an archive
+//of the user which the operator's device can read is already being written, that takeout is returned instead of starting a new one.
+func Start(o *authoperator.AuthOperator, username, format string) (*Takeout, error) {
+	if _, ok := connectordb.ExportFormats[format]; !ok {
+		return nil, errors.New("The takeout format must be json, parquet or arrow")
+	}
+
+	takeoutLock.Lock()
+	defer takeoutLock.Unlock()
+	if err := load(); err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	expire(now)
+
+	for _, t := range takeouts {
+		if t.User == username && t.Status == StatusPending && canRead(o, t) {
+			tcopy := *t
+			return &tcopy, nil
+		}
+	}
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+	t := &Takeout{
+		ID:        id.String(),
+		User:      username,
+		Device:    o.Name(),
+		Format:    format,
+		Status:    StatusPending,
+		Requested: now,
+	}
+	if err = t.save(); err != nil {
+		return nil, err
+	}
+	takeouts[t.ID] = t
+
+	go run(o, *t)
+
+	tcopy := *t
+	return &tcopy, nil
+}
+
+// run writes the takeout's archive, and updates its status when done
+func run(o *authoperator.AuthOperator, t Takeout) {
+	logger := log.WithFields(log.Fields{"user": t.User, "takeout": t.ID})
+	logger.Info("Writing takeout archive")
+
+	size, err := writeArchive(o, &t)
+
+	takeoutLock.Lock()
+	defer takeoutLock.Unlock()
+	if _, ok := takeouts[t.ID]; !ok {
+		// The takeout expired while being written
+		return
+	}
+	if err != nil {
+		logger.Errorf("Takeout failed: %s", err.Error())
+		os.Remove(t.filename(".zip"))
+		t.Status = StatusFailed
+		t.Error = err.Error()
+	} else {
+		cfg := config.Get()
+		t.Status = StatusReady
+		t.Size = size
+		t.Expires = time.Now().Unix() + cfg.TakeoutExpire
+		t.URL = cfg.GetSiteURL() + "/api/v1/takeout/" + t.User + "/" + t.ID
+		logger.Infof("Takeout archive ready (%d bytes)", size)
+	}
+	if err = t.save(); err != nil {
+		logger.Errorf("Failed to save takeout: %s", err.Error())
+	}
+	takeouts[t.ID] = &t
+}
+
+// writeArchive exports the user into a temporary directory, and zips it into the takeout's archive
+func writeArchive(o *authoperator.AuthOperator, t *Takeout) (int64, error) {
+	tmpdir, err := ioutil.TempDir(config.Get().TakeoutDirectory, "."+t.ID)
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(tmpdir)
+
+	if err = connectordb.ExportUser(o, tmpdir, t.User, t.Format); err != nil {
+		return 0, err
+	}
+	if err = connectordb.WriteExportInfo(tmpdir, t.Format); err != nil {
+		return 0, err
+	}
+
+	f, err := os.Create(t.filename(".zip"))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if err = zipDirectory(f, tmpdir); err != nil {
+		return 0, err
+	}
+	return f.Seek(0, io.SeekCurrent)
+}
+
+// zipDirectory writes all of the files in the directory to the zip file, with paths relative to the directory
+func zipDirectory(w io.Writer, dir string) error {
+	z := zip.NewWriter(w)
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = strings.Replace(rel, string(filepath.Separator), "/", -1)
+		header.Method = zip.Deflate
+		zf, err := z.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(zf, f)
+		return err
+	})
+	if err != nil {
+		z.Close()
+		return err
+	}
+	return z.Close()
+}
@@ -0,0 +1,46 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package takeout
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestZipDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "takeout")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "myuser", "mydevice", "mystream"), 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "connectordb.json"), []byte(`{"Version":2}`), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "myuser", "mydevice", "mystream", "data.json"), []byte("[]"), 0600))
+
+	var buf bytes.Buffer
+	require.NoError(t, zipDirectory(&buf, dir))
+
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	files := make(map[string]string)
+	for _, f := range z.File {
+		r, err := f.Open()
+		require.NoError(t, err)
+		b, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		r.Close()
+		files[f.Name] = string(b)
+	}
+	require.Equal(t, map[string]string{
+		"connectordb.json":                   `{"Version":2}`,
+		"myuser/mydevice/mystream/data.json": "[]",
+	}, files)
+}
@@ -0,0 +1,102 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package webhook
+
+import (
+	"connectordb"
+	"connectordb/authoperator"
+	"connectordb/datastream"
+	"connectordb/users"
+	"connectordb/webhook"
+	"errors"
+	"fmt"
+	"net/http"
+	"server/restapi/restcore"
+	"server/webcore"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/gorilla/mux"
+)
+
+//ErrInvalidToken is returned when there is no device with the webhook's token
+var ErrInvalidToken = errors.New("The webhook does not exist")
+
+//Result is the response to a webhook, giving the number of datapoints inserted, and the errors encountered
+type Result struct {
+	Inserted int      `json:"inserted"`
+	Errors   []string `json:"errors"`
+}
+
+//tokenLogin logs in as the device which owns the webhook token given in the URL. Webhooks are called by external
+//services which do not have the device's credentials - the unguessable token in the URL is the credential.
+func tokenLogin(db *connectordb.Database) restcore.Login {
+	return func(request *http.Request) (*authoperator.AuthOperator, int, error) {
+		o, err := db.WebhookLogin(mux.Vars(request)["token"])
+		if err == users.ErrDeviceNotFound {
+			err = ErrInvalidToken
+		}
+		return o, http.StatusNotFound, err
+	}
+}
+
+//Receive handles a JSON payload sent to a device's webhook. The device's webhook_rules map the payload
+//to datapoints, which are inserted into the device's streams.
+func Receive(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
+	dev, err := o.Device()
+	if err != nil {
+		return restcore.WriteError(writer, logger, http.StatusForbidden, err, false)
+	}
+	rules, err := webhook.ParseRules(dev.WebhookRules)
+	if err != nil {
+		// A device without rules does not accept webhooks
+		return restcore.WriteError(writer, logger, http.StatusNotFound, err, false)
+	}
+
+	var payload interface{}
+	if err = restcore.UnmarshalRequest(request, &payload); err != nil {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
+	}
+
+	data, errs := rules.Apply(payload, time.Now())
+
+	// The rules give stream names, which are inserted into the webhook's device
+	devpath := o.Name()
+	streams := make(map[string]datastream.DatapointArray, len(data))
+	for stream, dpa := range data {
+		streams[devpath+"/"+stream] = dpa
+	}
+
+	failed := o.InsertStreams("", streams, false)
+
+	result := Result{Errors: make([]string, 0, len(errs)+len(failed))}
+	for i := range errs {
+		result.Errors = append(result.Errors, errs[i].Error())
+	}
+	for streampath, dpa := range streams {
+		if err, ok := failed[streampath]; ok {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %s", streampath, err.Error()))
+		} else {
+			result.Inserted += len(dpa)
+		}
+	}
+	atomic.AddUint32(&webcore.StatsInserts, uint32(result.Inserted))
+
+	restcore.JSONWriter(writer, result, logger, nil)
+	return webcore.DEBUG, fmt.Sprintf("Webhook inserted %d into %d streams (%d errors)", result.Inserted, len(streams)-len(failed), len(result.Errors))
+}
+
+//Router returns a fully formed Gorilla router given an optional prefix
+func Router(db *connectordb.Database, prefix *mux.Router) *mux.Router {
+	if prefix == nil {
+		prefix = mux.NewRouter()
+	}
+
+	prefix.HandleFunc("/{token}", restcore.LoginAuthenticator(Receive, tokenLogin(db))).Methods("POST")
+
+	return prefix
+}
@@ -10,11 +10,15 @@ import (
 	"connectordb/datastream"
 	"connectordb/messenger"
 	"connectordb/query"
+	"connectordb/users"
 	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
+	"server/restapi/restcore"
 	"server/webcore"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -36,6 +40,40 @@ const (
 	webSocketClosedNonClean = "@EXIT"
 )
 
+// The websocket protocol versions. Clients select the protocol with the "protocol" query parameter when connecting.
+// Protocol 1 (the default) only sends subscription data. Protocol 2 responds to every command with an "ack" or "error"
+// message carrying the command's id, wraps subscription data in "data" messages, and sends a "notice" when the server drops a subscription,
+// or moves it to the new path of a renamed device or moved stream.
+const (
+	WebsocketProtocol1 = 1
+	WebsocketProtocol2 = 2
+)
+
+var (
+	// ErrUnknownCommand is returned when the websocket command is not recognized
+	ErrUnknownCommand = errors.New("Command not recognized")
+
+	// ErrSubscriptionDNE is returned when unsubscribing from a subscription that does not exist
+	ErrSubscriptionDNE = errors.New("Subscription does not exist")
+
+	// ErrProtocolVersion is returned when the client asks for an unsupported protocol version
+	ErrProtocolVersion = errors.New("Unsupported websocket protocol version")
+
+	// ErrPatternReplay is returned when subscribing to a wildcard pattern from an index or time
+	ErrPatternReplay = errors.New("Subscriptions to wildcard patterns can't replay history")
+
+	// ErrPatternUnit is returned when subscribing to a wildcard pattern with a unit, since its streams have different datatypes
+	ErrPatternUnit = errors.New("Subscriptions to wildcard patterns can't convert units")
+)
+
+// bulkInsertError is returned from a bulk insert where some of the streams failed. It maps the failed
+// streams to their errors, which are sent in the results of the error response.
+type bulkInsertError map[string]string
+
+func (b bulkInsertError) Error() string {
+	return strconv.Itoa(len(b)) + " of the bulk inserts failed"
+}
+
 //The websocket upgrader
 var (
 	// upgrader is initialized in the router
@@ -45,18 +83,48 @@ var (
 	websocketWaitGroup = sync.WaitGroup{}
 )
 
+// replayBatchSize is the maximum number of datapoints sent in each message when replaying a stream's history
+const replayBatchSize = 1000
+
 type Subscription struct {
 	sync.Mutex //The transform mutex
 
 	nats *nats.Subscription //The nats subscription
 
 	transform map[string]*pipescript.Script //the transforms associated with the subscription - this allows us to run transforms on the data!
+
+	replay map[string]*replayState // The state of transform subscriptions which started by replaying the stream's history
+}
+
+// replayState tracks a subscription which sends the stream's history before switching to live data
+type replayState struct {
+	replaying bool                // Whether the history is still being sent
+	pending   []messenger.Message // Live messages received while the history is being sent
+	next      int64               // The index of the next datapoint to send
+}
+
+// filter removes the datapoints of the message which were already sent, and returns false if there are none left
+func (r *replayState) filter(m messenger.Message) (messenger.Message, bool) {
+	if m.Index < 0 {
+		// Ephemeral streams have no history
+		return m, true
+	}
+	if skip := r.next - m.Index; skip > 0 {
+		if skip >= int64(len(m.Data)) {
+			return m, false
+		}
+		m.Data = m.Data[skip:]
+		m.Index += skip
+	}
+	r.next = m.Index + int64(len(m.Data))
+	return m, true
 }
 
 func NewSubscription(subs *nats.Subscription) *Subscription {
 	return &Subscription{
 		nats:      subs,
 		transform: make(map[string]*pipescript.Script),
+		replay:    make(map[string]*replayState),
 	}
 }
 
@@ -75,7 +143,16 @@ func (s *Subscription) Size() int {
 }
 
 //Add a transform subscription to the string
-func (s *Subscription) AddTransform(transform string) (err error) {
+func (s *Subscription) AddTransform(transform string) error {
+	return s.addTransform(transform, nil)
+}
+
+//AddReplayTransform adds a transform subscription which replays the stream's history before sending live data
+func (s *Subscription) AddReplayTransform(transform string, r *replayState) error {
+	return s.addTransform(transform, r)
+}
+
+func (s *Subscription) addTransform(transform string, r *replayState) (err error) {
 	s.Lock()
 	defer s.Unlock()
 	if _, ok := s.transform[transform]; ok {
@@ -92,6 +169,9 @@ func (s *Subscription) AddTransform(transform string) (err error) {
 	}
 
 	s.transform[transform] = t
+	if r != nil {
+		s.replay[transform] = r
+	}
 
 	return nil
 }
@@ -100,6 +180,7 @@ func (s *Subscription) AddTransform(transform string) (err error) {
 func (s *Subscription) RemTransform(transform string) (err error) {
 	s.Lock()
 	delete(s.transform, transform)
+	delete(s.replay, transform)
 	s.Unlock()
 	return nil
 }
@@ -118,10 +199,69 @@ type WebsocketConnection struct {
 
 	logger *log.Entry //logrus uses a mutex internally
 	o      *authoperator.AuthOperator
+
+	protocol   int        // The protocol version used by the client
+	writeMutex sync.Mutex // Both the reader and the writer write responses to the websocket
+
+	meta *nats.Subscription       // The subscription to metadata change events, if any
+	mc   chan messenger.MetaEvent // The metadata change events
+
+	moves *nats.Subscription       // The subscription to moves of devices and streams, which are followed by the subscriptions
+	mv    chan messenger.MetaEvent // The move events
+
+	commands     map[int64]*commandSubscription // The subscriptions to downlink commands, by stream ID
+	cc           chan users.Command             // The downlink commands
+	sentCommands map[int64]bool                 // The IDs of the commands already sent, since a command can arrive both live and from the queue
+}
+
+// commandSubscription is a subscription to the commands sent to a downlink stream
+type commandSubscription struct {
+	nats  *nats.Subscription
+	owner bool   // Whether the connected device owns the stream - commands are only marked as delivered when sent to the owner
+	path  string // The path of the stream
+}
+
+// websocketResponse is sent in response to commands in protocol 2, as well as for notices from the server
+type websocketResponse struct {
+	Type  string `json:"type"` // "ack", "error" or "notice"
+	ID    string `json:"id,omitempty"`
+	Cmd   string `json:"cmd"`
+	Arg   string `json:"arg,omitempty"`
+	To    string `json:"to,omitempty"` // The new path of a subscription moved along with its device or stream
+	Error string `json:"error,omitempty"`
+
+	Results map[string]string `json:"results,omitempty"` // The errors of the failed streams of an insert_bulk
+}
+
+// websocketMeta is a metadata change event in protocol 2
+type websocketMeta struct {
+	Type string              `json:"type"` // "meta"
+	Meta messenger.MetaEvent `json:"meta"`
+}
+
+// websocketCommandMessage is a downlink command in protocol 2
+type websocketCommandMessage struct {
+	Type    string        `json:"type"` // "command"
+	Command users.Command `json:"command"`
+}
+
+// websocketData is a message from a subscription in protocol 2
+type websocketData struct {
+	Type string `json:"type"` // "data"
+	messenger.Message
+	Next int64 `json:"next,omitempty"` // The index from which to resume the subscription after this message
 }
 
 //NewWebsocketConnection creates a new websocket connection based on the operators and stuff
 func NewWebsocketConnection(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (*WebsocketConnection, error) {
+	protocol := WebsocketProtocol1
+	if p := request.URL.Query().Get("protocol"); p != "" {
+		v, err := strconv.Atoi(p)
+		if err != nil || v < WebsocketProtocol1 || v > WebsocketProtocol2 {
+			return nil, ErrProtocolVersion
+		}
+		protocol = v
+	}
 
 	ws, err := upgrader.Upgrade(writer, request, nil)
 	if err != nil {
@@ -131,7 +271,19 @@ func NewWebsocketConnection(o *authoperator.AuthOperator, writer http.ResponseWr
 
 	ws.SetReadLimit(config.Get().Websocket.MessageLimitBytes)
 
-	return &WebsocketConnection{sync.RWMutex{}, ws, make(map[string]*Subscription), make(chan messenger.Message, config.Get().Websocket.MessageBuffer), logger, o}, nil
+	return &WebsocketConnection{
+		ws:            ws,
+		subscriptions: make(map[string]*Subscription),
+		c:             make(chan messenger.Message, config.Get().Websocket.MessageBuffer),
+		mc:            make(chan messenger.MetaEvent, config.Get().Websocket.MessageBuffer),
+		mv:            make(chan messenger.MetaEvent, config.Get().Websocket.MessageBuffer),
+		commands:      make(map[int64]*commandSubscription),
+		cc:            make(chan users.Command, config.Get().Websocket.MessageBuffer),
+		sentCommands:  make(map[int64]bool),
+		logger:        logger,
+		o:             o,
+		protocol:      protocol,
+	}, nil
 }
 
 func (c *WebsocketConnection) write(obj interface{}) error {
@@ -144,10 +296,93 @@ func (c *WebsocketConnection) write(obj interface{}) error {
 		}
 	}
 
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
 	c.ws.SetWriteDeadline(time.Now().Add(config.Get().Websocket.WriteWait * time.Second))
 	return c.ws.WriteJSON(obj)
 }
 
+// respond sends the ack or error of the command to clients using protocol 2
+func (c *WebsocketConnection) respond(cmd *websocketCommand, err error) error {
+	if c.protocol < WebsocketProtocol2 {
+		return nil
+	}
+	r := &websocketResponse{Type: "ack", ID: cmd.ID, Cmd: cmd.Cmd, Arg: cmd.Arg}
+	if err != nil {
+		r.Type = "error"
+		r.Error = err.Error()
+		if b, ok := err.(bulkInsertError); ok {
+			r.Results = b
+		}
+	}
+	return c.write(r)
+}
+
+// writeMessage writes a message from a subscription
+func (c *WebsocketConnection) writeMessage(m messenger.Message, next int64) error {
+	if c.protocol < WebsocketProtocol2 {
+		return c.write(m)
+	}
+	return c.write(&websocketData{"data", m, next})
+}
+
+// writeMeta writes a metadata change event
+func (c *WebsocketConnection) writeMeta(e messenger.MetaEvent) error {
+	if c.protocol < WebsocketProtocol2 {
+		return c.write(e)
+	}
+	return c.write(&websocketMeta{"meta", e})
+}
+
+// writeCommand writes a downlink command, and marks it as delivered if the device owns the stream.
+// Commands which were already sent are skipped.
+func (c *WebsocketConnection) writeCommand(cmd users.Command) error {
+	c.Lock()
+	sub, ok := c.commands[cmd.StreamID]
+	if !ok || c.sentCommands[cmd.CommandID] {
+		c.Unlock()
+		return nil
+	}
+	c.sentCommands[cmd.CommandID] = true
+	c.Unlock()
+
+	if sub.owner && cmd.Status == users.CommandPending {
+		if err := c.o.UpdateCommandByID(cmd.CommandID, users.CommandDelivered, ""); err != nil {
+			c.logger.WithField("cmd", "command").Warningln(err)
+		} else {
+			cmd.Status = users.CommandDelivered
+		}
+	}
+
+	if c.protocol < WebsocketProtocol2 {
+		return c.write(cmd)
+	}
+	return c.write(&websocketCommandMessage{"command", cmd})
+}
+
+// send runs the transform on the message from a subscription, and writes the result
+func (c *WebsocketConnection) send(m messenger.Message, transform string, tf *pipescript.Script) error {
+	next := int64(0)
+	if m.Index >= 0 {
+		next = m.Index + int64(len(m.Data))
+	}
+	if tf == nil {
+		return c.writeMessage(m, next)
+	}
+
+	datapointArray, err := query.TransformArray(tf, &m.Data)
+	if err != nil {
+		return err
+	}
+	if datapointArray.Length() <= 0 {
+		return nil
+	}
+
+	m.Transform = transform
+	m.Data = *datapointArray
+	return c.writeMessage(m, next)
+}
+
 //Close the websocket connection
 func (c *WebsocketConnection) Close() {
 	c.UnsubscribeAll()
@@ -159,33 +394,226 @@ func (c *WebsocketConnection) Close() {
 //CheckSubscriptions checks the current subscriptions, making sure that there are no issues (such as lost permissions or deleted streams)
 //which trigger unsubscribes
 func (c *WebsocketConnection) CheckSubscriptions() error {
+	var notices []*websocketResponse
 	c.Lock()
 	for stream, val := range c.subscriptions {
+		if messenger.IsPattern(stream) {
+			// Permissions of wildcard subscriptions are checked for each message
+			continue
+		}
 		if _, err := c.o.ReadStream(stream); err != nil {
 			c.logger.Warnf("Invalidated: %s", stream)
 			val.Close()
 			delete(c.subscriptions, stream)
+			notices = append(notices, &websocketResponse{Type: "notice", Cmd: "unsubscribe", Arg: stream, Error: err.Error()})
 		}
 	}
 	c.Unlock()
+
+	if c.protocol >= WebsocketProtocol2 {
+		for _, n := range notices {
+			if err := c.write(n); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// movedPath returns the new path of the given path when from is moved to to, and false if the path is not affected
+func movedPath(path, from, to string) (string, bool) {
+	if path == from || strings.HasPrefix(path, from+"/") {
+		return to + path[len(from):], true
+	}
+	return path, false
+}
+
+// Move follows the rename of a device or move of a stream: the subscriptions to the old path and its children
+// are moved to the new path, so that the client keeps receiving their data.
+func (c *WebsocketConnection) Move(from, to string) error {
+	var notices []*websocketResponse
+	c.Lock()
+	moved := make(map[string]string)
+	for s := range c.subscriptions {
+		if news, ok := movedPath(s, from, to); ok && !messenger.IsPattern(s) {
+			moved[s] = news
+		}
+	}
+	for s, news := range moved {
+		val := c.subscriptions[s]
+		delete(c.subscriptions, s)
+		if _, ok := c.subscriptions[news]; ok {
+			// The client is already subscribed to the new path
+			val.Close()
+		} else {
+			subs, err := c.o.Subscribe(news, c.c)
+			if err != nil {
+				c.logger.Warnf("Failed to move subscription %s to %s: %s", s, news, err.Error())
+				val.Close()
+				notices = append(notices, &websocketResponse{Type: "notice", Cmd: "unsubscribe", Arg: s, Error: err.Error()})
+				continue
+			}
+			val.Lock()
+			val.nats.Unsubscribe()
+			val.nats = subs
+			val.Unlock()
+			c.subscriptions[news] = val
+		}
+		c.logger.Debugf("Moved subscription %s to %s", s, news)
+		notices = append(notices, &websocketResponse{Type: "notice", Cmd: "move", Arg: s, To: news})
+	}
+	for _, sub := range c.commands {
+		if news, ok := movedPath(sub.path, from, to); ok {
+			subs, err := c.o.SubscribeCommands(news, c.cc)
+			if err != nil {
+				c.logger.Warnf("Failed to move command subscription %s to %s: %s", sub.path, news, err.Error())
+				continue
+			}
+			sub.nats.Unsubscribe()
+			sub.nats = subs
+			sub.path = news
+		}
+	}
+	c.Unlock()
+
+	if c.protocol >= WebsocketProtocol2 {
+		for _, n := range notices {
+			if err := c.write(n); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
 //Insert a datapoint using the websocket
-func (c *WebsocketConnection) Insert(ws *websocketCommand) {
+func (c *WebsocketConnection) Insert(ws *websocketCommand) error {
 	logger := c.logger.WithFields(log.Fields{"cmd": "insert", "arg": ws.Arg})
 	logger.Debugln("-> insert ", len(ws.D), "dp")
-	err := c.o.InsertStream(ws.Arg, ws.D, true)
+	err := c.o.InsertStreamOnce(ws.Arg, ws.Key, ws.D, true)
 	if err != nil {
-		//TODO: Notify user of insert failure
 		logger.Warn(err.Error())
-	} else {
-		atomic.AddUint32(&webcore.StatsInserts, uint32(len(ws.D)))
+		return err
+	}
+	atomic.AddUint32(&webcore.StatsInserts, uint32(len(ws.D)))
+	return nil
+}
+
+//InsertBulk inserts into multiple streams using the websocket
+func (c *WebsocketConnection) InsertBulk(ws *websocketCommand) error {
+	logger := c.logger.WithFields(log.Fields{"cmd": "insert_bulk"})
+	logger.Debugln("-> insert_bulk ", len(ws.Data), "streams")
+	failed := c.o.InsertStreams(ws.Key, ws.Data, true)
+
+	inserted := 0
+	for streampath, dpa := range ws.Data {
+		if _, ok := failed[streampath]; !ok {
+			inserted += len(dpa)
+		}
+	}
+	atomic.AddUint32(&webcore.StatsInserts, uint32(inserted))
+
+	if len(failed) == 0 {
+		return nil
 	}
+	b := make(bulkInsertError, len(failed))
+	for streampath, err := range failed {
+		b[streampath] = err.Error()
+	}
+	logger.Warn(b.Error())
+	return b
 }
 
 //Subscribe to the given data stream
-func (c *WebsocketConnection) Subscribe(s, transform string) {
+func (c *WebsocketConnection) Subscribe(s, transform string) error {
+	return c.subscribe(s, transform, nil)
+}
+
+//SubscribeFrom subscribes to the given data stream, first sending the stream's data starting at index i1.
+//Live data is held back until the history is sent, and datapoints which were already sent are dropped,
+//so that the client gets each datapoint exactly once.
+func (c *WebsocketConnection) SubscribeFrom(s, transform string, i1 int64) error {
+	if messenger.IsPattern(s) {
+		return ErrPatternReplay
+	}
+	// Negative indices are from the end of the stream
+	if i1 < 0 {
+		l, err := c.o.LengthStream(s)
+		if err != nil {
+			return err
+		}
+		i1 += l
+		if i1 < 0 {
+			i1 = 0
+		}
+	}
+
+	r := &replayState{replaying: true, next: i1}
+	if err := c.subscribe(s, transform, r); err != nil {
+		return err
+	}
+	err := c.replay(s, transform, r)
+	if err != nil {
+		c.logger.WithFields(log.Fields{"cmd": "subscribe", "arg": s}).Warningln(err)
+		c.Unsubscribe(s, transform)
+	}
+	return err
+}
+
+// replay sends the stream's history, and then the live data which arrived in the meantime
+func (c *WebsocketConnection) replay(s, transform string, r *replayState) error {
+	c.RLock()
+	subs := c.subscriptions[s]
+	c.RUnlock()
+	subs.Lock()
+	tf := subs.transform[transform]
+	subs.Unlock()
+
+	dr, err := c.o.GetStreamIndexRange(s, r.next, 0, "")
+	if err != nil {
+		return err
+	}
+	defer dr.Close()
+
+	for done := false; !done; {
+		dpa := make(datastream.DatapointArray, 0, replayBatchSize)
+		for len(dpa) < replayBatchSize {
+			dp, err := dr.Next()
+			if err != nil {
+				return err
+			}
+			if dp == nil {
+				done = true
+				break
+			}
+			dpa = append(dpa, *dp)
+		}
+		if len(dpa) == 0 {
+			break
+		}
+		m := messenger.Message{Stream: s, Data: dpa, Index: r.next}
+		r.next += int64(len(dpa))
+		if err = c.send(m, transform, tf); err != nil {
+			return err
+		}
+	}
+
+	// The history was sent - switch to live data
+	subs.Lock()
+	defer subs.Unlock()
+	for _, m := range r.pending {
+		if m, ok := r.filter(m); ok {
+			if err = c.send(m, transform, tf); err != nil {
+				return err
+			}
+		}
+	}
+	r.pending = nil
+	r.replaying = false
+	return nil
+}
+
+func (c *WebsocketConnection) subscribe(s, transform string, r *replayState) error {
 	logger := c.logger.WithFields(log.Fields{"cmd": "subscribe", "arg": s})
 
 	//Next check if nats is subscribed
@@ -196,44 +624,164 @@ func (c *WebsocketConnection) Subscribe(s, transform string) {
 		subs, err := c.o.Subscribe(s, c.c)
 		if err != nil {
 			logger.Warningln(err)
-		} else {
-			logger.Debugln("Initializing subscription")
-			c.Lock()
-			c.subscriptions[s] = NewSubscription(subs)
-			c.Unlock()
+			return err
 		}
+		logger.Debugln("Initializing subscription")
+		c.Lock()
+		c.subscriptions[s] = NewSubscription(subs)
+		c.Unlock()
+		c.followMoves()
 	}
 	c.Lock()
-	err := c.subscriptions[s].AddTransform(transform)
-	c.Unlock()
+	defer c.Unlock()
+	val := c.subscriptions[s]
+	var err error
+	if r != nil {
+		err = val.AddReplayTransform(transform, r)
+	} else {
+		err = val.AddTransform(transform)
+	}
 	if err != nil {
 		logger.Warningln(err)
+		if val.Size() == 0 {
+			val.Close()
+			delete(c.subscriptions, s)
+		}
 	}
+	return err
 }
 
 //Unsubscribe from the given data stream
-func (c *WebsocketConnection) Unsubscribe(s, transform string) {
+func (c *WebsocketConnection) Unsubscribe(s, transform string) error {
 	logger := c.logger.WithFields(log.Fields{"cmd": "unsubscribe", "arg": s})
 	c.RLock()
 	val, ok := c.subscriptions[s]
 	c.RUnlock()
+	if !ok {
+		logger.Warningln("subscription DNE")
+		return ErrSubscriptionDNE
+	}
+	c.Lock()
+	val.RemTransform(transform)
+	if val.Size() == 0 {
+		logger.Debugln("stop subscription")
+		val.Close()
+		delete(c.subscriptions, s)
+	} else {
+		logger.Debugln()
+	}
+	c.Unlock()
+	return nil
+}
+
+//SubscribeMeta subscribes to the metadata change events of the given user, device or stream and its children.
+//An empty path subscribes to the changes of everything that the device can read.
+func (c *WebsocketConnection) SubscribeMeta(path string) error {
+	logger := c.logger.WithFields(log.Fields{"cmd": "subscribe_meta", "arg": path})
+	under := func(p string) bool {
+		return p == path || strings.HasPrefix(p, path+"/")
+	}
+	subs, err := c.o.SubscribeMeta(func(e *messenger.MetaEvent) bool {
+		// Moving something away from the path is also a change of the path
+		return path == "" || under(e.Path) || e.From != "" && under(e.From)
+	}, c.mc)
+	if err != nil {
+		logger.Warningln(err)
+		return err
+	}
+	logger.Debugln()
+
+	c.Lock()
+	if c.meta != nil {
+		c.meta.Unsubscribe()
+	}
+	c.meta = subs
+	c.Unlock()
+	c.followMoves()
+	return nil
+}
+
+//UnsubscribeMeta stops the subscription to metadata change events
+func (c *WebsocketConnection) UnsubscribeMeta() error {
+	c.Lock()
+	defer c.Unlock()
+	if c.meta == nil {
+		return ErrSubscriptionDNE
+	}
+	c.logger.WithField("cmd", "unsubscribe_meta").Debugln()
+	c.meta.Unsubscribe()
+	c.meta = nil
+	return nil
+}
+
+//SubscribeCommands subscribes to the commands sent to the given downlink stream. Commands which are not yet finished
+//are sent first, so a device receives the commands which were sent while it was offline when it reconnects.
+func (c *WebsocketConnection) SubscribeCommands(s string) error {
+	logger := c.logger.WithFields(log.Fields{"cmd": "subscribe_commands", "arg": s})
+	strm, err := c.o.ReadStream(s)
+	if err != nil {
+		logger.Warningln(err)
+		return err
+	}
+	dev, err := c.o.Device()
+	if err != nil {
+		return err
+	}
+
+	c.RLock()
+	_, ok := c.commands[strm.StreamID]
+	c.RUnlock()
 	if ok {
-		c.Lock()
-		val.RemTransform(transform)
-		if val.Size() == 0 {
-			logger.Debugln("stop subscription")
-			val.Close()
-			delete(c.subscriptions, s)
-		} else {
-			logger.Debugln()
+		return errors.New("Subscription to the stream's commands already exists")
+	}
+
+	// The live subscription is made before reading the queue, so that no commands are lost
+	subs, err := c.o.SubscribeCommands(s, c.cc)
+	if err != nil {
+		logger.Warningln(err)
+		return err
+	}
+	c.Lock()
+	c.commands[strm.StreamID] = &commandSubscription{subs, dev.DeviceID == strm.DeviceID, s}
+	c.Unlock()
+	c.followMoves()
+
+	cmds, err := c.o.ReadCommands(s, "")
+	if err != nil {
+		logger.Warningln(err)
+		c.UnsubscribeCommands(s)
+		return err
+	}
+	logger.Debugln()
+	for _, cmd := range cmds {
+		if !cmd.IsFinished() {
+			if err = c.writeCommand(*cmd); err != nil {
+				return err
+			}
 		}
-		c.Unlock()
-	} else {
-		logger.Warningln("subscription DNE")
 	}
+	return nil
+}
+
+//UnsubscribeCommands stops the subscription to the commands of the given stream
+func (c *WebsocketConnection) UnsubscribeCommands(s string) error {
+	strm, err := c.o.ReadStream(s)
+	if err != nil {
+		return err
+	}
+	c.Lock()
+	defer c.Unlock()
+	sub, ok := c.commands[strm.StreamID]
+	if !ok {
+		return ErrSubscriptionDNE
+	}
+	c.logger.WithFields(log.Fields{"cmd": "unsubscribe_commands", "arg": s}).Debugln()
+	sub.nats.Unsubscribe()
+	delete(c.commands, strm.StreamID)
+	return nil
 }
 
-//UnsubscribeAll from all streams of data
+//UnsubscribeAll from all streams of data and metadata events
 func (c *WebsocketConnection) UnsubscribeAll() {
 	c.Lock()
 	for key, val := range c.subscriptions {
@@ -241,16 +789,65 @@ func (c *WebsocketConnection) UnsubscribeAll() {
 		val.Close()
 	}
 	c.subscriptions = make(map[string]*Subscription)
+	if c.meta != nil {
+		c.meta.Unsubscribe()
+		c.meta = nil
+	}
+	for key, val := range c.commands {
+		val.nats.Unsubscribe()
+		delete(c.commands, key)
+	}
+	if c.moves != nil {
+		c.moves.Unsubscribe()
+		c.moves = nil
+	}
 	c.Unlock()
 }
 
+//followMoves subscribes to the moves of devices and streams, so that the subscriptions follow them when these are moved.
+//It is called when a subscription is made, so that connections without subscriptions don't receive every move.
+func (c *WebsocketConnection) followMoves() {
+	c.Lock()
+	defer c.Unlock()
+	if c.moves != nil {
+		return
+	}
+	moves, err := c.o.SubscribeMeta(func(e *messenger.MetaEvent) bool {
+		return e.Action == "move"
+	}, c.mv)
+	if err != nil {
+		c.logger.Warn("Failed to subscribe to moves: ", err)
+		return
+	}
+	c.moves = moves
+}
+
 //A command is a cmd and the arg operation
 type websocketCommand struct {
+	ID        string `json:"id"` //In protocol 2, the id is returned in the command's ack or error
 	Cmd       string `json:"cmd"`
 	Arg       string `json:"arg"`
 	Transform string `json:"transform"` //Allows subscribing with a transform
+	Unit      string `json:"unit"`      //Allows subscribing with the data converted to a unit of the stream's datatype
+
+	// Allows subscribing from the given index or time: the stream's data starting from the index/time is sent
+	// before the live data. Negative indices are from the end of the stream.
+	I1 *int64   `json:"i1,omitempty"`
+	T1 *float64 `json:"t1,omitempty"`
+
+	D   []datastream.Datapoint `json:"d"`             //If the command is "insert", it needs an additional datapoint
+	Key string                 `json:"key,omitempty"` //The optional idempotency key of an insert, which makes retries safe
 
-	D []datastream.Datapoint `json:"d"` //If the command is "insert", it needs an additional datapoint
+	Data map[string]datastream.DatapointArray `json:"data,omitempty"` //If the command is "insert_bulk", the datapoints of each stream
+}
+
+//unitTransform returns the transform of a subscription command, which converts the data to the command's unit
+//before running its transform. Subscriptions are identified by this transform, which is also sent with their data.
+func (c *WebsocketConnection) unitTransform(cmd *websocketCommand) (string, error) {
+	if cmd.Unit != "" && messenger.IsPattern(cmd.Arg) {
+		return "", ErrPatternUnit
+	}
+	return query.UnitTransform(c.o, cmd.Arg, cmd.Unit, cmd.Transform)
 }
 
 //RunReader runs the reading routine. It also maps the commands to actual subscriptions
@@ -266,8 +863,8 @@ func (c *WebsocketConnection) RunReader(readmessenger chan string) {
 		return nil
 	})
 
-	var cmd websocketCommand
 	for {
+		var cmd websocketCommand
 		err := c.ws.ReadJSON(&cmd)
 		if err != nil {
 			if err == io.EOF {
@@ -288,15 +885,47 @@ func (c *WebsocketConnection) RunReader(readmessenger chan string) {
 		switch cmd.Cmd {
 		default:
 			c.logger.Warningln("Command not recognized:", cmd.Cmd)
-			//Do nothing - the command is not recognized
+			err = ErrUnknownCommand
 		case "insert":
-			c.Insert(&cmd)
+			err = c.Insert(&cmd)
+		case "insert_bulk":
+			err = c.InsertBulk(&cmd)
 		case "subscribe":
-			c.Subscribe(cmd.Arg, cmd.Transform)
+			var transform string
+			if transform, err = c.unitTransform(&cmd); err != nil {
+				break
+			}
+			if cmd.T1 != nil && messenger.IsPattern(cmd.Arg) {
+				err = ErrPatternReplay
+			} else if cmd.T1 != nil {
+				var i1 int64
+				if i1, err = c.o.TimeToIndexStream(cmd.Arg, *cmd.T1); err == nil {
+					err = c.SubscribeFrom(cmd.Arg, transform, i1)
+				}
+			} else if cmd.I1 != nil {
+				err = c.SubscribeFrom(cmd.Arg, transform, *cmd.I1)
+			} else {
+				err = c.Subscribe(cmd.Arg, transform)
+			}
 		case "unsubscribe":
-			c.Unsubscribe(cmd.Arg, cmd.Transform)
+			var transform string
+			if transform, err = c.unitTransform(&cmd); err == nil {
+				err = c.Unsubscribe(cmd.Arg, transform)
+			}
 		case "unsubscribe_all":
 			c.UnsubscribeAll()
+		case "subscribe_meta":
+			err = c.SubscribeMeta(cmd.Arg)
+		case "unsubscribe_meta":
+			err = c.UnsubscribeMeta()
+		case "subscribe_commands":
+			err = c.SubscribeCommands(cmd.Arg)
+		case "unsubscribe_commands":
+			err = c.UnsubscribeCommands(cmd.Arg)
+		}
+		if err = c.respond(&cmd, err); err != nil {
+			c.logger.Warningln(err)
+			break
 		}
 	}
 	//Since the reader is exiting, notify the writer to send close message
@@ -304,6 +933,8 @@ func (c *WebsocketConnection) RunReader(readmessenger chan string) {
 }
 
 func (c *WebsocketConnection) updateDeadline(messageCode int, message string) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
 	c.ws.SetWriteDeadline(time.Now().Add(config.Get().Websocket.WriteWait * time.Second))
 	return c.ws.WriteMessage(messageCode, []byte(message))
 }
@@ -313,42 +944,37 @@ func (c *WebsocketConnection) processDatapoint(datapoint messenger.Message) erro
 
 	logger := c.logger.WithFields(log.Fields{"stream": datapoint.Stream})
 
+	// Messages from wildcard subscriptions belong to the pattern's subscription
+	key := datapoint.Stream
+	if datapoint.Pattern != "" {
+		key = datapoint.Pattern
+	}
+
 	//Now loop through all transforms for the datapoint array
 	c.RLock()
-	subs, ok := c.subscriptions[datapoint.Stream]
+	subs, ok := c.subscriptions[key]
 	c.RUnlock()
 	if !ok {
-		return c.write(datapoint)
+		return c.send(datapoint, "", nil)
 	}
 
 	subs.Lock()
 	defer subs.Unlock()
 
 	for transform, tf := range subs.transform {
-		if transform == "" {
-			logger.Debugln("<- send")
-			if err := c.write(datapoint); err != nil {
-				return err
+		m := datapoint
+		if r, ok := subs.replay[transform]; ok {
+			if r.replaying {
+				r.pending = append(r.pending, datapoint)
+				continue
+			}
+			if m, ok = r.filter(m); !ok {
+				continue
 			}
-			continue
 		}
 
-		datapointArray, err := query.TransformArray(tf, &datapoint.Data)
 		logger.Debugf("<- send %s", transform)
-		if err != nil {
-			return err
-		}
-		if datapointArray.Length() <= 0 {
-			continue
-		}
-
-		message := messenger.Message{
-			datapoint.Stream,
-			transform,
-			*datapointArray,
-		}
-
-		if err := c.write(message); err != nil {
+		if err := c.send(m, transform, tf); err != nil {
 			return err
 		}
 	}
@@ -376,6 +1002,21 @@ func (c *WebsocketConnection) RunWriter(readmessenger chan string, exitchan chan
 				c.logger.Errorf("Writing failed: %s. Killing connection.", err.Error())
 			}
 
+		case e := <-c.mc:
+			if err := c.writeMeta(e); err != nil {
+				c.logger.Errorf("Writing failed: %s. Killing connection.", err.Error())
+			}
+
+		case e := <-c.mv:
+			if err := c.Move(e.From, e.Path); err != nil {
+				c.logger.Errorf("Writing failed: %s. Killing connection.", err.Error())
+			}
+
+		case cmd := <-c.cc:
+			if err := c.writeCommand(cmd); err != nil {
+				c.logger.Errorf("Writing failed: %s. Killing connection.", err.Error())
+			}
+
 		case <-ticker.C:
 			if VerboseWebsocket {
 				c.logger.Debug("PING")
@@ -386,6 +1027,11 @@ func (c *WebsocketConnection) RunWriter(readmessenger chan string, exitchan chan
 				return
 			}
 
+			// An open connection keeps the device online
+			if err := c.o.Seen(); err != nil {
+				c.logger.Warn("Failed to update presence: ", err)
+			}
+
 			//Now, let's make sure that the active subscriptions are still valid
 			c.CheckSubscriptions()
 
@@ -434,6 +1080,9 @@ func (c *WebsocketConnection) Run() error {
 //RunWebsocket runs the websocket handler
 func RunWebsocket(o *authoperator.AuthOperator, writer http.ResponseWriter, request *http.Request, logger *log.Entry) (int, string) {
 	conn, err := NewWebsocketConnection(o, writer, request, logger)
+	if err == ErrProtocolVersion {
+		return restcore.WriteError(writer, logger, http.StatusBadRequest, err, false)
+	}
 	if err != nil {
 		writer.WriteHeader(http.StatusInternalServerError)
 		return 3, err.Error()
@@ -0,0 +1,148 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package restapi
+
+import (
+	"config"
+	"connectordb"
+	"connectordb/datastream"
+	"connectordb/users"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+var Tdb *connectordb.Database
+
+func init() {
+	db, err := connectordb.Open(config.TestConfiguration.Options())
+	if err != nil {
+		log.Fatal(err)
+	}
+	Tdb = db
+	go db.RunWriter()
+}
+
+// dialWebsocket connects to a websocket run as the given user, returning the client and server sides of the connection
+func dialWebsocket(t *testing.T, username string, protocol string) (*websocket.Conn, *WebsocketConnection, func()) {
+	o, err := Tdb.AsUser(username)
+	require.NoError(t, err)
+	logger := log.NewEntry(log.StandardLogger())
+
+	conns := make(chan *WebsocketConnection, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := NewWebsocketConnection(o, w, r, logger)
+		if err != nil {
+			close(conns)
+			return
+		}
+		defer c.Close()
+		conns <- c
+		c.Run()
+	}))
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/?protocol="+protocol, nil)
+	require.NoError(t, err)
+	c := <-conns
+	require.NotNil(t, c)
+	return ws, c, func() {
+		ws.Close()
+		srv.Close()
+	}
+}
+
+// readResponse reads the next response from the websocket
+func readResponse(t *testing.T, ws *websocket.Conn) websocketResponse {
+	var r websocketResponse
+	require.NoError(t, ws.ReadJSON(&r))
+	return r
+}
+
+func setupWebsocketTest(t *testing.T) {
+	Tdb.Clear()
+	require.NoError(t, Tdb.CreateUser(&users.UserMaker{User: users.User{Name: "myuser", Email: "my@email", Password: "test", Role: "user", Public: false}}))
+	require.NoError(t, Tdb.CreateDevice("myuser/mydevice", &users.DeviceMaker{}))
+	require.NoError(t, Tdb.CreateStream("myuser/mydevice/mystream", &users.StreamMaker{Stream: users.Stream{Schema: `{"type":"number"}`}}))
+}
+
+func TestWebsocketProtocolVersion(t *testing.T) {
+	setupWebsocketTest(t)
+	o, err := Tdb.AsUser("myuser")
+	require.NoError(t, err)
+
+	// Unsupported protocols are rejected before the websocket is upgraded
+	w := httptest.NewRecorder()
+	RunWebsocket(o, w, httptest.NewRequest("GET", "/?protocol=3", nil), log.NewEntry(log.StandardLogger()))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), ErrProtocolVersion.Error())
+
+	w = httptest.NewRecorder()
+	RunWebsocket(o, w, httptest.NewRequest("GET", "/?protocol=two", nil), log.NewEntry(log.StandardLogger()))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestWebsocketResponses(t *testing.T) {
+	setupWebsocketTest(t)
+	ws, _, done := dialWebsocket(t, "myuser", "2")
+	defer done()
+
+	// Each command is answered with an ack or an error, which carry its id
+	require.NoError(t, ws.WriteJSON(&websocketCommand{ID: "1", Cmd: "insert", Arg: "myuser/mydevice/mystream",
+		D: []datastream.Datapoint{{Timestamp: 1, Data: 1.0}}}))
+	require.Equal(t, websocketResponse{Type: "ack", ID: "1", Cmd: "insert", Arg: "myuser/mydevice/mystream"}, readResponse(t, ws))
+
+	require.NoError(t, ws.WriteJSON(&websocketCommand{ID: "2", Cmd: "insert", Arg: "myuser/mydevice/mystream",
+		D: []datastream.Datapoint{{Timestamp: 2, Data: "hi"}}}))
+	r := readResponse(t, ws)
+	require.Equal(t, "error", r.Type)
+	require.Equal(t, "2", r.ID)
+	require.Equal(t, "insert", r.Cmd)
+	require.NotEmpty(t, r.Error)
+
+	require.NoError(t, ws.WriteJSON(&websocketCommand{ID: "3", Cmd: "subscribe", Arg: "myuser/mydevice/mystream"}))
+	require.Equal(t, websocketResponse{Type: "ack", ID: "3", Cmd: "subscribe", Arg: "myuser/mydevice/mystream"}, readResponse(t, ws))
+
+	require.NoError(t, ws.WriteJSON(&websocketCommand{ID: "4", Cmd: "subscribe", Arg: "myuser/mydevice/nostream"}))
+	r = readResponse(t, ws)
+	require.Equal(t, "error", r.Type)
+	require.Equal(t, "4", r.ID)
+
+	require.NoError(t, ws.WriteJSON(&websocketCommand{ID: "5", Cmd: "unsubscribe", Arg: "myuser/mydevice/mystream"}))
+	require.Equal(t, websocketResponse{Type: "ack", ID: "5", Cmd: "unsubscribe", Arg: "myuser/mydevice/mystream"}, readResponse(t, ws))
+
+	require.NoError(t, ws.WriteJSON(&websocketCommand{ID: "6", Cmd: "unsubscribe", Arg: "myuser/mydevice/mystream"}))
+	require.Equal(t, websocketResponse{Type: "error", ID: "6", Cmd: "unsubscribe", Arg: "myuser/mydevice/mystream",
+		Error: ErrSubscriptionDNE.Error()}, readResponse(t, ws))
+
+	require.NoError(t, ws.WriteJSON(&websocketCommand{ID: "7", Cmd: "nocommand"}))
+	require.Equal(t, websocketResponse{Type: "error", ID: "7", Cmd: "nocommand", Error: ErrUnknownCommand.Error()}, readResponse(t, ws))
+}
+
+func TestWebsocketSubscriptionNotice(t *testing.T) {
+	setupWebsocketTest(t)
+	ws, c, done := dialWebsocket(t, "myuser", "2")
+	defer done()
+
+	require.NoError(t, ws.WriteJSON(&websocketCommand{ID: "1", Cmd: "subscribe", Arg: "myuser/mydevice/mystream"}))
+	require.Equal(t, "ack", readResponse(t, ws).Type)
+
+	// A subscription dropped because the stream can no longer be read is announced to the client
+	require.NoError(t, Tdb.DeleteStream("myuser/mydevice/mystream"))
+	require.NoError(t, c.CheckSubscriptions())
+	r := readResponse(t, ws)
+	require.Equal(t, "notice", r.Type)
+	require.Equal(t, "unsubscribe", r.Cmd)
+	require.Equal(t, "myuser/mydevice/mystream", r.Arg)
+	require.NotEmpty(t, r.Error)
+
+	c.RLock()
+	require.Len(t, c.subscriptions, 0)
+	c.RUnlock()
+}
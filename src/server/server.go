@@ -14,13 +14,16 @@ import (
 	"net/http/httptest"
 	"net/http/httputil"
 	"net/http/pprof"
+	"server/metrics"
 	"server/restapi"
 	"server/restapi/restcore"
+	"server/restapi/takeout"
 	"server/webcore"
 	"server/website"
 	"strings"
 	"sync/atomic"
 	"time"
+	"util"
 
 	"github.com/dkumor/acmewrapper"
 	"github.com/gorilla/mux"
@@ -219,9 +222,28 @@ func RunServer(verbose, profiling bool) error {
 	go webcore.RunStats()
 	go webcore.RunQueryTimers()
 
+	//Remove expired takeout archives
+	go takeout.RunExpiry()
+
 	//Run the dbwriter
 	go db.RunWriter()
 
+	//Run the metrics listeners. They write the metrics of their last flush interval on exit.
+	if c.StatsD.Enabled {
+		l := metrics.NewListener(db, c.StatsD, "statsd", metrics.ParseStatsD)
+		if err = l.Start(); err != nil {
+			return err
+		}
+		util.CloseOnExit(l)
+	}
+	if c.Graphite.Enabled {
+		l := metrics.NewListener(db, c.Graphite, "graphite", metrics.ParseGraphite)
+		if err = l.Start(); err != nil {
+			return err
+		}
+		util.CloseOnExit(l)
+	}
+
 	if c.Redirect80 {
 		go Redirect80(c.GetSiteURL())
 	}
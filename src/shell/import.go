@@ -0,0 +1,77 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package shell
+
+/* Provides the ability to import activity files and health exports
+
+Copyright 2016 - The ConnectorDB Contributors; see AUTHORS for a list of authors.
+All Rights Reserved
+*/
+
+import (
+	"connectordb/importer"
+	"fmt"
+	"strings"
+)
+
+func init() {
+	help := "Imports an activity file (GPX, TCX, FIT) or export (Google Takeout, Apple Health) into a device's streams"
+	usage := `Usage: import user/device file [--backfill] [kind=stream...]
+
+	Each kind of data (location, heartrate, steps...) is imported into the
+	device's stream named after it, unless another stream is given with
+	kind=stream. A kind given an empty stream (kind=) is not imported.
+
+	Datapoints older than the data of a stream are skipped, unless the stream
+	backfills. With --backfill, created streams backfill, which shifts the
+	indices of their later datapoints.
+	`
+	name := "import"
+
+	main := func(shell *Shell, args []string) uint8 {
+		if len(args) < 3 {
+			fmt.Printf(Red + "Error: Wrong number of args\n" + Reset)
+			return 1
+		}
+
+		devicepath := shell.ResolvePath(args[1])
+		streams := importer.DefaultStreams()
+		backfill := false
+		for _, arg := range args[3:] {
+			if arg == "--backfill" {
+				backfill = true
+				continue
+			}
+			kv := strings.SplitN(arg, "=", 2)
+			if _, ok := streams[kv[0]]; !ok || len(kv) != 2 {
+				shell.PrintErrorText("Unknown stream option '%s'", arg)
+				return 1
+			}
+			streams[kv[0]] = kv[1]
+		}
+
+		progress := false
+		results, err := importer.ImportFile(shell.operator, devicepath, args[2], streams, backfill, func(records, inserted int64) {
+			fmt.Printf("\r%d records read, %d datapoints inserted", records, inserted)
+			progress = true
+		})
+		if progress {
+			fmt.Println()
+		}
+		for _, r := range results {
+			if r.Error != "" {
+				shell.PrintErrorText("%s: %s", r.Stream, r.Error)
+				continue
+			}
+			fmt.Printf("%s: %d inserted, %d duplicates skipped, %d older datapoints skipped\n", r.Stream, r.Inserted, r.Duplicates, r.Late)
+		}
+		if shell.PrintError(err) {
+			return 2
+		}
+		return 0
+	}
+
+	registerShellCommand(help, usage, name, main)
+}
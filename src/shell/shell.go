@@ -119,7 +119,7 @@ func CreateShell(sdb *connectordb.Database) *Shell {
 	s.host, _ = os.Hostname()
 	s.reader = bufio.NewReader(os.Stdin)
 	s.sdb = sdb
-	s.operator = sdb
+	s.operator = sdb.AdminOperator()
 	s.operatorName = "ConnectorDB"
 	s.pwd = ""
 	return &s
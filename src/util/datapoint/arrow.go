@@ -0,0 +1,96 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package datapoint
+
+import (
+	"io"
+
+	"connectordb/datastream"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/ipc"
+	"github.com/apache/arrow/go/arrow/memory"
+)
+
+// arrowJSONMetadata marks the fields of JSON columns, which are strings with the arrow.json extension type
+var arrowJSONMetadata = arrow.NewMetadata([]string{"ARROW:extension:name"}, []string{"arrow.json"})
+
+// arrowSchema returns the schema of the Arrow output with the given columns
+func arrowSchema(columns []Column) *arrow.Schema {
+	fields := make([]arrow.Field, len(columns))
+	for i := range columns {
+		fields[i] = arrow.Field{Name: columns[i].Name, Nullable: !columns[i].isTime}
+		switch columns[i].Type {
+		case BoolColumn:
+			fields[i].Type = arrow.FixedWidthTypes.Boolean
+		case IntColumn:
+			fields[i].Type = arrow.PrimitiveTypes.Int64
+		case FloatColumn:
+			fields[i].Type = arrow.PrimitiveTypes.Float64
+		case StringColumn:
+			fields[i].Type = arrow.BinaryTypes.String
+		default:
+			fields[i].Type = arrow.BinaryTypes.String
+			fields[i].Metadata = arrowJSONMetadata
+		}
+	}
+	return arrow.NewSchema(fields, nil)
+}
+
+// WriteArrow writes the datapoints of the DataRange to w in the Apache Arrow IPC streaming format. The columns
+// are given by the JSON schema that the datapoints follow (see Columns), and each BatchSize datapoints are a record batch.
+// JSON columns are strings with the arrow.json extension type. The DataRange is not closed.
+func WriteArrow(w io.Writer, dr datastream.DataRange, schema string) error {
+	batch, err := readBatch(dr, BatchSize)
+	if err != nil {
+		return err
+	}
+	columns := Columns(schema, batch)
+	s := arrowSchema(columns)
+
+	aw := ipc.NewWriter(w, ipc.WithSchema(s))
+	b := array.NewRecordBuilder(memory.NewGoAllocator(), s)
+	defer b.Release()
+
+	for len(batch) > 0 {
+		for i := range columns {
+			appendArrow(b.Field(i), &columns[i], batch)
+		}
+		rec := b.NewRecord()
+		err = aw.Write(rec)
+		rec.Release()
+		if err != nil {
+			return err
+		}
+		if batch, err = readBatch(dr, BatchSize); err != nil {
+			return err
+		}
+	}
+
+	// Closing the writer ends the stream, writing the schema if there were no batches
+	return aw.Close()
+}
+
+// appendArrow appends the column's values in the batch to the column's builder
+func appendArrow(fb array.Builder, c *Column, batch datastream.DatapointArray) {
+	for i := range batch {
+		v := c.Value(&batch[i])
+		if v == nil {
+			fb.AppendNull()
+			continue
+		}
+		switch b := fb.(type) {
+		case *array.BooleanBuilder:
+			b.Append(v.(bool))
+		case *array.Int64Builder:
+			b.Append(v.(int64))
+		case *array.Float64Builder:
+			b.Append(v.(float64))
+		case *array.StringBuilder:
+			b.Append(v.(string))
+		}
+	}
+}
@@ -0,0 +1,203 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package datapoint
+
+import (
+	"bytes"
+	"connectordb/datastream"
+	"testing"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/ipc"
+	"github.com/stretchr/testify/require"
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+// The tests read back the written files with the Go implementations of Arrow and Parquet
+
+type table struct {
+	names   []string
+	columns [][]interface{}
+}
+
+// readArrow reads back Arrow output, returning the table along with the type of each column
+func readArrow(t *testing.T, b []byte) (tbl table, types []arrow.Type) {
+	r, err := ipc.NewReader(bytes.NewReader(b))
+	require.NoError(t, err)
+	defer r.Release()
+
+	for _, f := range r.Schema().Fields() {
+		tbl.names = append(tbl.names, f.Name)
+		types = append(types, f.Type.ID())
+	}
+	tbl.columns = make([][]interface{}, len(tbl.names))
+	for r.Next() {
+		rec := r.Record()
+		for i := range tbl.columns {
+			col := rec.Column(i)
+			for j := 0; j < col.Len(); j++ {
+				var v interface{}
+				if col.IsValid(j) {
+					switch a := col.(type) {
+					case *array.Float64:
+						v = a.Value(j)
+					case *array.Int64:
+						v = a.Value(j)
+					case *array.String:
+						v = a.Value(j)
+					case *array.Boolean:
+						v = a.Value(j)
+					default:
+						t.Fatalf("Unexpected arrow type %s of column %s", col.DataType().Name(), tbl.names[i])
+					}
+				}
+				tbl.columns[i] = append(tbl.columns[i], v)
+			}
+		}
+	}
+	require.NoError(t, r.Err())
+	return tbl, types
+}
+
+// readParquet reads back Parquet output, returning the table along with the physical type of each column
+func readParquet(t *testing.T, b []byte) (tbl table, types []parquet.Type) {
+	pr, err := reader.NewParquetColumnReader(buffer.NewBufferFileFromBytes(b), 1)
+	require.NoError(t, err)
+	defer pr.ReadStop()
+
+	rows := pr.GetNumRows()
+	for i := range pr.SchemaHandler.ValueColumns {
+		// The first schema element is the root of the flat schema
+		tbl.names = append(tbl.names, pr.SchemaHandler.Infos[i+1].ExName)
+		types = append(types, pr.SchemaHandler.SchemaElements[i+1].GetType())
+		values := []interface{}{}
+		if rows > 0 {
+			values, _, _, err = pr.ReadColumnByIndex(int64(i), rows)
+			require.NoError(t, err)
+		}
+		tbl.columns = append(tbl.columns, values)
+	}
+	return tbl, types
+}
+
+var columnarData = []datastream.Datapoint{
+	{Timestamp: 1, Data: map[string]interface{}{"a": int64(1), "b": "hi", "c": true, "t": 2.5, "x": []interface{}{1.0}}},
+	{Timestamp: 2, Data: map[string]interface{}{"a": 2.0, "c": false, "x": "y"}},
+	{Timestamp: 3, Data: map[string]interface{}{"a": 3.5, "b": 4.0, "c": true}},
+}
+
+const columnarSchema = `{"type": "object", "properties": {"a": {"type": "integer"}, "b": {"type": ["string", "null"]}, "c": {"type": "boolean"}, "t": {"type": "number"}, "x": {}}}`
+
+var columnarTable = table{
+	names: []string{"t", "a", "b", "c", "d.t", "x"},
+	columns: [][]interface{}{
+		{1.0, 2.0, 3.0},
+		{int64(1), int64(2), nil},
+		{"hi", nil, nil},
+		{true, false, true},
+		{2.5, nil, nil},
+		{`[1]`, `"y"`, nil},
+	},
+}
+
+func TestColumns(t *testing.T) {
+	c := Columns(columnarSchema, nil)
+	require.Len(t, c, 6)
+	require.Equal(t, []ColumnType{FloatColumn, IntColumn, StringColumn, BoolColumn, FloatColumn, JSONColumn},
+		[]ColumnType{c[0].Type, c[1].Type, c[2].Type, c[3].Type, c[4].Type, c[5].Type})
+
+	// Without a schema, the columns come from the data
+	c = Columns("", columnarData)
+	require.Len(t, c, 6)
+	require.Equal(t, []ColumnType{FloatColumn, FloatColumn, JSONColumn, BoolColumn, FloatColumn, JSONColumn},
+		[]ColumnType{c[0].Type, c[1].Type, c[2].Type, c[3].Type, c[4].Type, c[5].Type})
+
+	c = Columns(`{"type": "string"}`, columnarData)
+	require.Len(t, c, 2)
+	require.Equal(t, "d", c[1].Name)
+	require.Equal(t, StringColumn, c[1].Type)
+
+	c = Columns("{}", []datastream.Datapoint{{Data: 1.0}, {Data: nil}, {Data: int64(2)}})
+	require.Len(t, c, 2)
+	require.Equal(t, FloatColumn, c[1].Type)
+
+	c = Columns("{}", []datastream.Datapoint{{Data: 1.0}, {Data: "hi"}})
+	require.Equal(t, JSONColumn, c[1].Type)
+	require.Equal(t, `"hi"`, c[1].Value(&datastream.Datapoint{Data: "hi"}))
+}
+
+func TestParquet(t *testing.T) {
+	var b bytes.Buffer
+	require.NoError(t, WriteParquet(&b, datastream.NewDatapointArrayRange(columnarData, 0), columnarSchema))
+	tbl, types := readParquet(t, b.Bytes())
+	require.Equal(t, columnarTable, tbl)
+	require.Equal(t, []parquet.Type{parquet.Type_DOUBLE, parquet.Type_INT64, parquet.Type_BYTE_ARRAY, parquet.Type_BOOLEAN,
+		parquet.Type_DOUBLE, parquet.Type_BYTE_ARRAY}, types)
+
+	// Multiple row groups
+	defer func(n int) { BatchSize = n }(BatchSize)
+	BatchSize = 2
+	b.Reset()
+	require.NoError(t, WriteParquet(&b, datastream.NewDatapointArrayRange(columnarData, 0), columnarSchema))
+	tbl, _ = readParquet(t, b.Bytes())
+	require.Equal(t, columnarTable, tbl)
+
+	data := make([]datastream.Datapoint, 21)
+	for i := range data {
+		data[i] = datastream.Datapoint{Timestamp: float64(i), Data: float64(i * i)}
+	}
+	data[20].Data = nil
+	b.Reset()
+	require.NoError(t, WriteParquet(&b, datastream.NewDatapointArrayRange(data, 0), ""))
+	tbl, _ = readParquet(t, b.Bytes())
+	require.Equal(t, []string{"t", "d"}, tbl.names)
+	require.Len(t, tbl.columns[1], 21)
+	require.Equal(t, 361.0, tbl.columns[1][19])
+	require.Nil(t, tbl.columns[1][20])
+
+	// An empty range still gives a valid file
+	b.Reset()
+	require.NoError(t, WriteParquet(&b, datastream.NewDatapointArrayRange(nil, 0), `{"type": "number"}`))
+	tbl, _ = readParquet(t, b.Bytes())
+	require.Equal(t, []string{"t", "d"}, tbl.names)
+	require.Len(t, tbl.columns[0], 0)
+}
+
+func TestArrow(t *testing.T) {
+	var b bytes.Buffer
+	require.NoError(t, WriteArrow(&b, datastream.NewDatapointArrayRange(columnarData, 0), columnarSchema))
+	tbl, types := readArrow(t, b.Bytes())
+	require.Equal(t, columnarTable, tbl)
+	require.Equal(t, []arrow.Type{arrow.FLOAT64, arrow.INT64, arrow.STRING, arrow.BOOL, arrow.FLOAT64, arrow.STRING}, types)
+
+	// Multiple record batches
+	defer func(n int) { BatchSize = n }(BatchSize)
+	BatchSize = 2
+	b.Reset()
+	require.NoError(t, WriteArrow(&b, datastream.NewDatapointArrayRange(columnarData, 0), columnarSchema))
+	tbl, _ = readArrow(t, b.Bytes())
+	require.Equal(t, columnarTable, tbl)
+
+	data := make([]datastream.Datapoint, 21)
+	for i := range data {
+		data[i] = datastream.Datapoint{Timestamp: float64(i), Data: "s"}
+	}
+	data[20].Data = true
+	b.Reset()
+	require.NoError(t, WriteArrow(&b, datastream.NewDatapointArrayRange(data, 0), `{"type": "string"}`))
+	tbl, _ = readArrow(t, b.Bytes())
+	require.Len(t, tbl.columns[1], 21)
+	require.Equal(t, "s", tbl.columns[1][19])
+	require.Nil(t, tbl.columns[1][20])
+
+	b.Reset()
+	require.NoError(t, WriteArrow(&b, datastream.NewDatapointArrayRange(nil, 0), ""))
+	tbl, _ = readArrow(t, b.Bytes())
+	require.Equal(t, []string{"t", "d"}, tbl.names)
+	require.Len(t, tbl.columns[0], 0)
+}
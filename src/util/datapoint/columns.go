@@ -0,0 +1,257 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package datapoint
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"sort"
+
+	"connectordb/datastream"
+)
+
+// BatchSize is the number of datapoints in each row group of Parquet output and each record batch of Arrow output.
+// The columns are inferred from the first batch when the schema does not give their types.
+var BatchSize = 10000
+
+// ColumnType is the type of the values of a column in columnar (Parquet and Arrow) output
+type ColumnType int
+
+const (
+	// JSONColumn holds values of any type, written as JSON strings
+	JSONColumn ColumnType = iota
+	// BoolColumn holds booleans
+	BoolColumn
+	// IntColumn holds 64 bit integers
+	IntColumn
+	// FloatColumn holds 64 bit floats
+	FloatColumn
+	// StringColumn holds strings
+	StringColumn
+	// unknownColumn is a column whose type was not given by the schema, and is inferred from the data
+	unknownColumn
+)
+
+// Column is a single column of a table of datapoints. The first column is always the timestamp, followed
+// either by a single column of the datapoints' data, or by one column per property when the data are objects.
+type Column struct {
+	Name     string
+	Type     ColumnType
+	Property string // The property of the data object in this column - empty if the column holds the entire data
+	isTime   bool
+}
+
+// Value returns the value of the column in the datapoint, converted to the column's type
+// (bool, int64, float64 or string), or nil if it has no value of that type
+func (c *Column) Value(dp *datastream.Datapoint) interface{} {
+	if c.isTime {
+		return dp.Timestamp
+	}
+	v := dp.Data
+	if c.Property != "" {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[c.Property]
+	}
+	if v == nil {
+		return nil
+	}
+	switch c.Type {
+	case BoolColumn:
+		if b, ok := v.(bool); ok {
+			return b
+		}
+	case IntColumn:
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return rv.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return int64(rv.Uint())
+		case reflect.Float32, reflect.Float64:
+			if f := rv.Float(); f == math.Trunc(f) {
+				return int64(f)
+			}
+		}
+	case FloatColumn:
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return float64(rv.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return float64(rv.Uint())
+		case reflect.Float32, reflect.Float64:
+			return rv.Float()
+		}
+	case StringColumn:
+		if s, ok := v.(string); ok {
+			return s
+		}
+	default:
+		b, err := json.Marshal(v)
+		if err == nil {
+			return string(b)
+		}
+	}
+	return nil
+}
+
+// Columns returns the columns of a table holding datapoints which follow the given JSON schema. The types of columns
+// which are not given by the schema are inferred from the given datapoints.
+func Columns(schema string, dpa datastream.DatapointArray) []Column {
+	var s map[string]interface{}
+	if schema != "" {
+		if err := json.Unmarshal([]byte(schema), &s); err != nil {
+			s = nil
+		}
+	}
+
+	columns := []Column{{Name: "t", Type: FloatColumn, isTime: true}}
+
+	// Objects get a column for each property. If the schema does not give the properties,
+	// they are taken from the data, as long as all of the data are objects.
+	var properties map[string]interface{}
+	if s["type"] == "object" {
+		properties, _ = s["properties"].(map[string]interface{})
+		if len(properties) == 0 {
+			properties = inferProperties(dpa)
+		}
+	} else if schemaType(s) == unknownColumn {
+		properties = inferProperties(dpa)
+	}
+	if len(properties) == 0 {
+		c := Column{Name: "d", Type: schemaType(s)}
+		if c.Type == unknownColumn {
+			c.Type = inferType(dpa, "")
+		}
+		return append(columns, c)
+	}
+
+	names := make([]string, 0, len(properties))
+	for p := range properties {
+		names = append(names, p)
+	}
+	sort.Strings(names)
+	for _, p := range names {
+		ps, _ := properties[p].(map[string]interface{})
+		c := Column{Name: p, Type: schemaType(ps), Property: p}
+		if c.Type == unknownColumn {
+			c.Type = inferType(dpa, p)
+		}
+		if c.Name == "t" {
+			c.Name = "d.t"
+		}
+		columns = append(columns, c)
+	}
+	return columns
+}
+
+// schemaType returns the column type of values following the JSON schema
+func schemaType(s map[string]interface{}) ColumnType {
+	var t string
+	switch st := s["type"].(type) {
+	case string:
+		t = st
+	case []interface{}:
+		// Nullable types are given as ["type","null"]
+		for _, v := range st {
+			if vs, ok := v.(string); ok && vs != "null" {
+				if t != "" {
+					return JSONColumn
+				}
+				t = vs
+			}
+		}
+	}
+	switch t {
+	case "boolean":
+		return BoolColumn
+	case "integer":
+		return IntColumn
+	case "number":
+		return FloatColumn
+	case "string":
+		return StringColumn
+	case "", "null":
+		return unknownColumn
+	}
+	return JSONColumn
+}
+
+// valueType returns the column type of a single value, or unknownColumn for nil
+func valueType(v interface{}) ColumnType {
+	if v == nil {
+		return unknownColumn
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Bool:
+		return BoolColumn
+	case reflect.String:
+		return StringColumn
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return FloatColumn
+	}
+	return JSONColumn
+}
+
+// inferType returns the type of the given property of the datapoints' data (or of the data itself if property is empty)
+func inferType(dpa datastream.DatapointArray, property string) ColumnType {
+	t := unknownColumn
+	for i := range dpa {
+		v := dpa[i].Data
+		if property != "" {
+			m, _ := v.(map[string]interface{})
+			v = m[property]
+		}
+		vt := valueType(v)
+		if vt == unknownColumn || vt == t {
+			continue
+		}
+		if t != unknownColumn {
+			return JSONColumn
+		}
+		t = vt
+	}
+	if t == unknownColumn {
+		return JSONColumn
+	}
+	return t
+}
+
+// inferProperties returns the union of the properties of the datapoints' data if all of them are objects
+func inferProperties(dpa datastream.DatapointArray) map[string]interface{} {
+	properties := make(map[string]interface{})
+	for i := range dpa {
+		m, ok := dpa[i].Data.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		for k := range m {
+			properties[k] = nil
+		}
+	}
+	return properties
+}
+
+// readBatch reads up to n datapoints from the DataRange
+func readBatch(dr datastream.DataRange, n int) (datastream.DatapointArray, error) {
+	dpa := make(datastream.DatapointArray, 0, n)
+	for len(dpa) < n {
+		dp, err := dr.Next()
+		if err != nil {
+			return nil, err
+		}
+		if dp == nil {
+			break
+		}
+		dpa = append(dpa, *dp)
+	}
+	return dpa, nil
+}
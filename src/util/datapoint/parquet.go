@@ -0,0 +1,84 @@
+/**
+Copyright (c) 2016 The ConnectorDB Contributors
+Licensed under the MIT license.
+**/
+package datapoint
+
+import (
+	"io"
+
+	"connectordb/datastream"
+
+	"github.com/xitongsys/parquet-go-source/writerfile"
+	"github.com/xitongsys/parquet-go/marshal"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// parquetSchema returns the flat schema of the Parquet output with the given columns. The timestamp is required,
+// and all other columns are optional, since datapoints don't necessarily have a value of the column's type.
+func parquetSchema(columns []Column) []*parquet.SchemaElement {
+	n := int32(len(columns))
+	required := parquet.FieldRepetitionType_REQUIRED
+	elements := []*parquet.SchemaElement{{Name: "schema", NumChildren: &n, RepetitionType: &required}}
+	for i := range columns {
+		e := &parquet.SchemaElement{Name: columns[i].Name, RepetitionType: parquet.FieldRepetitionTypePtr(parquet.FieldRepetitionType_OPTIONAL)}
+		if columns[i].isTime {
+			e.RepetitionType = &required
+		}
+		switch columns[i].Type {
+		case BoolColumn:
+			e.Type = parquet.TypePtr(parquet.Type_BOOLEAN)
+		case IntColumn:
+			e.Type = parquet.TypePtr(parquet.Type_INT64)
+		case FloatColumn:
+			e.Type = parquet.TypePtr(parquet.Type_DOUBLE)
+		case StringColumn:
+			e.Type = parquet.TypePtr(parquet.Type_BYTE_ARRAY)
+			e.ConvertedType = parquet.ConvertedTypePtr(parquet.ConvertedType_UTF8)
+		default:
+			e.Type = parquet.TypePtr(parquet.Type_BYTE_ARRAY)
+			e.ConvertedType = parquet.ConvertedTypePtr(parquet.ConvertedType_JSON)
+		}
+		elements = append(elements, e)
+	}
+	return elements
+}
+
+// WriteParquet writes the datapoints of the DataRange to w as a Snappy compressed Apache Parquet file. The columns
+// are given by the JSON schema that the datapoints follow (see Columns), and each BatchSize datapoints are a row group.
+// The DataRange is not closed.
+func WriteParquet(w io.Writer, dr datastream.DataRange, schema string) error {
+	batch, err := readBatch(dr, BatchSize)
+	if err != nil {
+		return err
+	}
+	columns := Columns(schema, batch)
+
+	pw, err := writer.NewParquetWriter(writerfile.NewWriterFile(w), parquetSchema(columns), 1)
+	if err != nil {
+		return err
+	}
+	// Rows are written as lists of the columns' values, which is how the library marshals CSV rows
+	pw.MarshalFunc = marshal.MarshalCSV
+
+	for len(batch) > 0 {
+		for i := range batch {
+			row := make([]interface{}, len(columns))
+			for j := range columns {
+				row[j] = columns[j].Value(&batch[i])
+			}
+			if err = pw.Write(row); err != nil {
+				return err
+			}
+		}
+		// Each batch is flushed as its own row group
+		if err = pw.Flush(true); err != nil {
+			return err
+		}
+		if batch, err = readBatch(dr, BatchSize); err != nil {
+			return err
+		}
+	}
+	return pw.WriteStop()
+}